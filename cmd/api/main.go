@@ -4,19 +4,35 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/health"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/metrics"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/app/repositories/postgres"
 	"github.com/grid-stream-org/api/internal/app/server"
+	"github.com/grid-stream-org/api/internal/app/tracing"
 	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/migrate"
 	"github.com/grid-stream-org/api/pkg/firebase"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
 	"github.com/grid-stream-org/go-commons/pkg/logger"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/option"
 )
 
+// how long buffered spans get to reach the exporter on shutdown
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
 	ctx := context.Background()
 	if err := run(ctx, os.Stdout, os.Args); err != nil {
@@ -37,27 +53,122 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 	if err != nil {
 		return errors.Wrap(err, "init logger")
 	}
+	// log lines written with a request's context carry its trace id, and anything that looks like a credential is redacted
+	log = slog.New(logging.NewRedactingHandler(tracing.NewLogHandler(log.Handler())))
+
+	// nil when disabled, everything that records metrics then does nothing
+	m := metrics.New(cfg.Metrics, prometheus.NewRegistry())
+
+	// clients are closed in reverse order of opening once the server has drained,
+	// the store's database before firebase
+	var closers []closer
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].close(); err != nil {
+				log.Error("failed to close client", "client", closers[i].name, "error", err)
+			}
+		}
+	}()
 
-	bqClient, err := bqclient.New(ctx, cfg.Database)
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
 	if err != nil {
-		return errors.Wrap(err, "failed to init big query client")
+		return errors.Wrap(err, "failed to init tracing")
 	}
-	defer bqClient.Close()
+	// closed last so spans from the shutdown itself are flushed
+	closers = append(closers, closer{"tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		return shutdownTracing(ctx)
+	}})
 
-	// Initialize Firebase Auth client
-	firebaseClient, err := firebase.NewFirebaseClient(ctx, cfg.Firebase, log)
-	if err != nil {
-		return errors.Wrap(err, "failed to initialize Firebase Auth client")
+	// Initialize Firebase Auth client, the in-memory backend can run without it
+	var firebaseClient firebase.FirebaseClient
+	if cfg.StorageBackend != config.StorageMemory || cfg.Firebase.GoogleCredential != "" {
+		firebaseClient, err = firebase.NewFirebaseClient(ctx, cfg.Firebase, log)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize Firebase Auth client")
+		}
+		closers = append(closers, closer{"firebase", firebaseClient.Close})
+	} else if cfg.AuthProvider == config.AuthFirebase {
+		log.Warn("firebase not configured, authenticated routes will reject every ID token")
+	}
+
+	// dependencies probed by /readyz, memory has none of its own
+	var checks []health.Check
+	if firebaseClient != nil {
+		checks = append(checks,
+			// roles fall back to the users collection when a token has no claims
+			health.Check{Name: "firestore", Critical: true, Probe: health.FirestoreCollection(firebaseClient.Firestore(), "users")},
+			health.Check{Name: "notifications", Probe: health.FirestoreCollection(firebaseClient.Firestore(), "notifications")},
+		)
+		if cfg.AuthProvider == config.AuthFirebase {
+			checks = append(checks, health.Check{Name: "firebase_auth_keys", Critical: true, Probe: health.KeySet(http.DefaultClient, health.FirebaseKeySetURL)})
+		}
+	}
+
+	var store *repositories.Store
+	switch cfg.StorageBackend {
+	case config.StorageMemory:
+		log.Info("using in-memory storage backend")
+		store = memory.NewStore(log)
+	case config.StoragePostgres:
+		pool, err := postgres.Open(ctx, cfg.Postgres, log)
+		if err != nil {
+			return errors.Wrap(err, "failed to init postgres")
+		}
+		closers = append(closers, closer{"postgres", func() error { pool.Close(); return nil }})
+		checks = append(checks, health.Check{Name: "postgres", Critical: true, Probe: pool.Ping})
+		store = postgres.NewStore(pool, firebaseClient, log)
+	default:
+		bqClient, err := bqclient.New(ctx, cfg.Database)
+		if err != nil {
+			return errors.Wrap(err, "failed to init big query client")
+		}
+		closers = append(closers, closer{"bigquery", bqClient.Close})
+		bqClient = tracing.InstrumentBigQuery(m.InstrumentBigQuery(bqClient))
+
+		tables, err := repositories.NewTableResolver(cfg.Database.DatasetID, cfg.TableOverrides)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve table names")
+		}
+
+		// fail fast with the schema diff rather than 500s from every query that touches a missing column
+		if cfg.SchemaCheck {
+			if err := migrate.New(bqClient, tables, log).Verify(ctx); err != nil {
+				return errors.Wrap(err, "schema check failed")
+			}
+		}
+		store = repositories.NewBigQueryStore(bqClient, tables, firebaseClient, log)
+
+		// bqclient has no dry runs, the probe gets a client of its own
+		probeClient, err := bigquery.NewClient(ctx, cfg.Database.ProjectID, option.WithCredentialsFile(cfg.Database.CredsPath))
+		if err != nil {
+			return errors.Wrap(err, "failed to init big query health client")
+		}
+		closers = append(closers, closer{"bigquery health", probeClient.Close})
+		probe, err := tables.Render(`SELECT id FROM {{table "projects"}} LIMIT 0`)
+		if err != nil {
+			return errors.Wrap(err, "failed to render big query health query")
+		}
+		checks = append(checks, health.Check{Name: "bigquery", Critical: true, Probe: health.BigQueryDryRun(probeClient, probe)})
 	}
-	defer firebaseClient.Close()
 
 	// setup server handler
-	handler := server.NewServer(cfg, bqClient, firebaseClient, log)
+	handler, err := server.NewServer(cfg, store, firebaseClient, health.NewChecker(cfg.Health, checks...), m, log)
+	if err != nil {
+		return errors.Wrap(err, "failed to init server")
+	}
 
 	// Create the HTTP server
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: handler,
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(log.Handler(), slog.LevelError),
 	}
 
 	// Start the server in a goroutine
@@ -71,10 +182,44 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 	}()
 
 	log.Info("Application is running...")
-	<-ctx.Done()
+	select {
+	case err := <-serverErrChan:
+		// the listener failed before any signal, e.g. the port is taken
+		return err
+	case <-ctx.Done():
+	}
+	// a second signal kills the process instead of waiting on the drain
+	cancel()
 
-	log.Info("Shutting down...")
-	// call shutdowns
+	log.Info("Shutting down...", "drain_delay", cfg.Server.DrainDelay, "timeout", cfg.Server.ShutdownTimeout)
+	// fail the health check first so load balancers stop routing here before the listener closes
+	handler.Drain()
+	select {
+	case <-time.After(cfg.Server.DrainDelay):
+	case err := <-serverErrChan:
+		return err
+	}
 
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// deadline passed with requests still running, cut them off
+		_ = srv.Close()
+		return errors.Wrap(err, "failed to drain in-flight requests")
+	}
+	if err := <-serverErrChan; err != nil {
+		return err
+	}
+	// callbacks to OpenADR subscribers outlive the requests that started them, they get what's left of the timeout
+	if err := handler.Wait(shutdownCtx); err != nil {
+		return errors.Wrap(err, "failed to finish openadr callbacks")
+	}
+
+	log.Info("server stopped")
 	return nil
 }
+
+type closer struct {
+	name  string
+	close func() error
+}
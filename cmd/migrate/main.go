@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/migrate"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/grid-stream-org/go-commons/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up          apply every pending migration
+  down [n]    revert the last n applied migrations (default 1)
+  status      list migrations and whether they are applied
+  verify      compare the live dataset with the schema the models expect`
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx, os.Stdout, os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, w io.Writer, args []string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return errors.Wrap(err, "loading conf")
+	}
+	log, err := logger.New(cfg.Logger, w)
+	if err != nil {
+		return errors.Wrap(err, "init logger")
+	}
+
+	bqClient, err := bqclient.New(ctx, cfg.Database)
+	if err != nil {
+		return errors.Wrap(err, "failed to init big query client")
+	}
+	defer bqClient.Close()
+
+	tables, err := repositories.NewTableResolver(cfg.Database.DatasetID, cfg.TableOverrides)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve table names")
+	}
+	m := migrate.New(bqClient, tables, log)
+
+	switch args[1] {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			if steps, err = strconv.Atoi(args[2]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[2])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "reverted %d migration(s)\n", n)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+	case "verify":
+		if err := m.Verify(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "schema matches models")
+	default:
+		return errors.New(usage)
+	}
+	return nil
+}
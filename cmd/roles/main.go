@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/pkg/firebase"
+	"github.com/grid-stream-org/go-commons/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+const usage = `usage: roles <command> <uid>
+
+commands:
+  show <uid>  print the role in the user's Firestore document and their current custom claims
+  sync <uid>  copy the user's Firestore role into their custom claims`
+
+func main() {
+	ctx := context.Background()
+	if err := run(ctx, os.Stdout, os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, w io.Writer, args []string) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if len(args) < 3 {
+		return errors.New(usage)
+	}
+	uid := args[2]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return errors.Wrap(err, "loading conf")
+	}
+	log, err := logger.New(cfg.Logger, w)
+	if err != nil {
+		return errors.Wrap(err, "init logger")
+	}
+
+	firebaseClient, err := firebase.NewFirebaseClient(ctx, cfg.Firebase, log)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize Firebase Auth client")
+	}
+	defer firebaseClient.Close()
+	source := roles.NewFirestoreStore(firebaseClient.Firestore())
+
+	switch args[1] {
+	case "show":
+		membership, err := source.Lookup(ctx, uid)
+		if err != nil {
+			return err
+		}
+		user, err := firebaseClient.Auth().GetUser(ctx, uid)
+		if err != nil {
+			return errors.Wrap(err, "fetching user")
+		}
+		return printJSON(w, map[string]any{"firestore": membership, "claims": user.CustomClaims})
+	case "sync":
+		// the API caches roles in its own process, tokens pick up the new claims when they are refreshed
+		membership, err := roles.NewSyncer(source, firebaseClient.Auth(), nil).Sync(ctx, uid)
+		if err != nil {
+			return err
+		}
+		return printJSON(w, membership)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,295 @@
+// Package apikeys issues and checks the API keys backend services use instead of Firebase ID tokens.
+// Keys are shown once when created or rotated, only their SHA-256 is stored.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/pkg/errors"
+)
+
+// Scopes name the routes a key may call
+const (
+	ScopeNotificationsWrite   = "notifications:write"
+	ScopeProjectAveragesRead  = "project-averages:read"
+	ScopeProjectAveragesWrite = "project-averages:write"
+	ScopeOpenADRVEN           = "openadr:ven" // OpenADR gateways acting for the projects of the key's utilities
+)
+
+var scopes = map[string]bool{
+	ScopeNotificationsWrite:   true,
+	ScopeProjectAveragesRead:  true,
+	ScopeProjectAveragesWrite: true,
+	ScopeOpenADRVEN:           true,
+}
+
+// AllUtilities in a key's utility ids lets it act for every utility
+const AllUtilities = "*"
+
+const (
+	secretPrefix = "gsk_"
+	// starts the user id of the principal a key authenticates as, followed by the key's id
+	servicePrefix = "service:"
+	// how much of the secret is kept in the clear to tell keys apart
+	prefixLength = len(secretPrefix) + 8
+	// last used is written at most this often per key, it doesn't need to be exact
+	touchInterval = time.Minute
+)
+
+// ErrInvalidKey is returned for keys that don't exist, have expired or were revoked
+var ErrInvalidKey = errors.New("invalid api key")
+
+// CreateRequest is the body of the create endpoint, ExpiresAt defaults to the configured expiry
+type CreateRequest struct {
+	Name       string    `json:"name"`
+	Scopes     []string  `json:"scopes"`
+	UtilityIDs []string  `json:"utility_ids"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type cached struct {
+	key     *models.APIKey
+	expires time.Time
+}
+
+type Service struct {
+	repo repositories.APIKeyRepository
+	cfg  *config.APIKeysConfig
+	log  *slog.Logger
+	now  func() time.Time
+
+	mu      sync.Mutex
+	cache   map[string]cached // by key hash
+	touched map[string]time.Time
+}
+
+func NewService(repo repositories.APIKeyRepository, cfg *config.APIKeysConfig, log *slog.Logger) *Service {
+	return &Service{
+		repo:    repo,
+		cfg:     cfg,
+		log:     log,
+		now:     time.Now,
+		cache:   map[string]cached{},
+		touched: map[string]time.Time{},
+	}
+}
+
+// IsSecret reports whether s looks like an API key secret rather than some other credential, such as an ID token
+func IsSecret(s string) bool {
+	return strings.HasPrefix(s, secretPrefix)
+}
+
+// HasScope reports whether key may call routes with scope
+func HasScope(key *models.APIKey, scope string) bool {
+	for _, s := range key.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is the caller a key authenticates as
+func Principal(key *models.APIKey) authz.Principal {
+	return authz.Principal{
+		UserID:     servicePrefix + key.ID,
+		Role:       authz.RoleService,
+		KeyID:      key.ID,
+		UtilityIDs: key.UtilityIDs,
+	}
+}
+
+// KeyID returns the id of the key a principal's user id names, false for users
+func KeyID(userID string) (string, bool) {
+	return strings.CutPrefix(userID, servicePrefix)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Authenticate returns the key for secret, ErrInvalidKey if it can't be used
+func (s *Service) Authenticate(ctx context.Context, secret string) (*models.APIKey, error) {
+	h := hash(secret)
+	now := s.now()
+
+	s.mu.Lock()
+	entry, ok := s.cache[h]
+	s.mu.Unlock()
+
+	key := entry.key
+	if !ok || now.After(entry.expires) {
+		var err error
+		key, err = s.repo.GetAPIKeyByHash(ctx, h)
+		if err != nil {
+			var customErr *custom_error.CustomError
+			if errors.As(err, &customErr) && customErr.Code == http.StatusNotFound {
+				return nil, ErrInvalidKey
+			}
+			return nil, err
+		}
+		s.mu.Lock()
+		s.cache[h] = cached{key: key, expires: now.Add(s.cfg.CacheTTL)}
+		s.mu.Unlock()
+	}
+
+	if key.RevokedAt.Valid || !now.Before(key.ExpiresAt) {
+		return nil, ErrInvalidKey
+	}
+	s.touch(ctx, key.ID, now)
+	return key, nil
+}
+
+// Get returns the key with the id, ErrInvalidKey if it can't be used
+func (s *Service) Get(ctx context.Context, id string) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		var customErr *custom_error.CustomError
+		if errors.As(err, &customErr) && customErr.Code == http.StatusNotFound {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	if key.RevokedAt.Valid || !s.now().Before(key.ExpiresAt) {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// touch records the key was used, in the background so requests don't wait on it
+func (s *Service) touch(ctx context.Context, id string, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.touched[id]) < touchInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.touched[id] = now
+	s.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := s.repo.TouchAPIKey(ctx, id, now); err != nil {
+			s.log.Error("failed to record api key use", "key_id", id, "err", err)
+		}
+	}()
+}
+
+// Create issues a new key, the secret is only ever returned here
+func (s *Service) Create(ctx context.Context, req *CreateRequest, createdBy string) (*models.APIKey, string, error) {
+	if req.Name == "" {
+		return nil, "", custom_error.New(http.StatusBadRequest, "Name is required", nil)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, "", custom_error.New(http.StatusBadRequest, "At least one scope is required", nil)
+	}
+	for _, scope := range req.Scopes {
+		if !scopes[scope] {
+			return nil, "", custom_error.New(http.StatusBadRequest, "Unknown scope: "+scope, nil)
+		}
+	}
+	if len(req.UtilityIDs) == 0 {
+		return nil, "", custom_error.New(http.StatusBadRequest, "At least one utility id is required, use * for every utility", nil)
+	}
+
+	now := s.now()
+	expiresAt := req.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(s.cfg.Expiry)
+	}
+	if !expiresAt.After(now) {
+		return nil, "", custom_error.New(http.StatusBadRequest, "Expiry must be in the future", nil)
+	}
+
+	secret, err := generate()
+	if err != nil {
+		return nil, "", custom_error.New(http.StatusInternalServerError, "Failed to generate api key", err)
+	}
+	key := &models.APIKey{
+		ID:         uuid.New().String(),
+		Name:       req.Name,
+		Prefix:     secret[:prefixLength],
+		KeyHash:    hash(secret),
+		Scopes:     req.Scopes,
+		UtilityIDs: req.UtilityIDs,
+		CreatedBy:  createdBy,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Rotate replaces the secret of a key and restarts its expiry, the old secret stops working immediately
+func (s *Service) Rotate(ctx context.Context, id string) (*models.APIKey, string, error) {
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if key.RevokedAt.Valid {
+		return nil, "", custom_error.New(http.StatusConflict, "Revoked api keys can't be rotated", nil)
+	}
+
+	secret, err := generate()
+	if err != nil {
+		return nil, "", custom_error.New(http.StatusInternalServerError, "Failed to generate api key", err)
+	}
+	oldHash := key.KeyHash
+	key.Prefix = secret[:prefixLength]
+	key.KeyHash = hash(secret)
+	key.ExpiresAt = s.now().Add(s.cfg.Expiry)
+	if err := s.repo.RotateAPIKey(ctx, id, key.Prefix, key.KeyHash, key.ExpiresAt); err != nil {
+		return nil, "", err
+	}
+	s.forget(oldHash)
+	return key, secret, nil
+}
+
+// Revoke stops a key from working, it is kept so its use stays auditable
+func (s *Service) Revoke(ctx context.Context, id string) error {
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.RevokeAPIKey(ctx, id, s.now()); err != nil {
+		return err
+	}
+	s.forget(key.KeyHash)
+	return nil
+}
+
+func (s *Service) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *Service) forget(keyHash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, keyHash)
+}
@@ -0,0 +1,130 @@
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newService() *Service {
+	return NewService(memory.NewStore(nil).APIKeys, &config.APIKeysConfig{Expiry: time.Hour, CacheTTL: time.Minute}, nil)
+}
+
+func validRequest() *CreateRequest {
+	return &CreateRequest{Name: "validator", Scopes: []string{ScopeNotificationsWrite}, UtilityIDs: []string{"util-1"}}
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	s := newService()
+	ctx := context.Background()
+
+	key, secret, err := s.Create(ctx, validRequest(), "tech-1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	assert.NotContains(t, key.KeyHash, secret, "Only the hash of the secret should be stored")
+	assert.Equal(t, "tech-1", key.CreatedBy)
+
+	got, err := s.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.True(t, HasScope(got, ScopeNotificationsWrite))
+	assert.False(t, HasScope(got, ScopeProjectAveragesWrite))
+
+	// last used is recorded in the background
+	assert.Eventually(t, func() bool {
+		stored, err := s.repo.GetAPIKey(ctx, key.ID)
+		return err == nil && stored.LastUsedAt.Valid
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = s.Authenticate(ctx, secret+"x")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestCreateValidation(t *testing.T) {
+	s := newService()
+	tests := []struct {
+		name   string
+		modify func(req *CreateRequest)
+	}{
+		{"missing name", func(req *CreateRequest) { req.Name = "" }},
+		{"missing scopes", func(req *CreateRequest) { req.Scopes = nil }},
+		{"unknown scope", func(req *CreateRequest) { req.Scopes = []string{"contracts:write"} }},
+		{"missing utilities", func(req *CreateRequest) { req.UtilityIDs = nil }},
+		{"expired", func(req *CreateRequest) { req.ExpiresAt = time.Now().Add(-time.Minute) }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := validRequest()
+			tc.modify(req)
+			_, _, err := s.Create(context.Background(), req, "tech-1")
+			customErr, ok := err.(*custom_error.CustomError)
+			require.True(t, ok, "expected custom error, got: %v", err)
+			assert.Equal(t, http.StatusBadRequest, customErr.Code)
+		})
+	}
+}
+
+func TestRotateRevokeExpire(t *testing.T) {
+	s := newService()
+	ctx := context.Background()
+
+	key, oldSecret, err := s.Create(ctx, validRequest(), "tech-1")
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, oldSecret)
+	require.NoError(t, err)
+
+	// rotating must lock out the old secret straight away even though it was cached
+	_, newSecret, err := s.Rotate(ctx, key.ID)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, oldSecret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = s.Authenticate(ctx, newSecret)
+	require.NoError(t, err)
+
+	// keys stop working once they expire
+	now := time.Now()
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, err = s.Authenticate(ctx, newSecret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	s.now = time.Now
+
+	require.NoError(t, s.Revoke(ctx, key.ID))
+	_, err = s.Authenticate(ctx, newSecret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, _, err = s.Rotate(ctx, key.ID)
+	assert.Error(t, err, "Revoked keys should not be rotated back to life")
+}
+
+func TestRevokeOnAnotherInstance(t *testing.T) {
+	repo := memory.NewStore(nil).APIKeys
+	cfg := &config.APIKeysConfig{Expiry: time.Hour, CacheTTL: 10 * time.Second}
+	a := NewService(repo, cfg, nil)
+	b := NewService(repo, cfg, nil)
+	ctx := context.Background()
+
+	key, secret, err := a.Create(ctx, validRequest(), "tech-1")
+	require.NoError(t, err)
+	_, err = b.Authenticate(ctx, secret)
+	require.NoError(t, err)
+
+	// b only hears of the revocation once its cached copy of the key expires
+	require.NoError(t, a.Revoke(ctx, key.ID))
+	_, err = a.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = b.Authenticate(ctx, secret)
+	require.NoError(t, err, "the key is still cached")
+
+	now := time.Now()
+	b.now = func() time.Time { return now.Add(cfg.CacheTTL + time.Second) }
+	_, err = b.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,260 @@
+// Package authz decides which projects, utilities and the resources hanging off them a caller may see.
+// Anything a caller may not see is reported exactly like a resource that doesn't exist.
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+const (
+	RoleUtility     = "Utility"
+	RoleResidential = "Residential"
+	RoleTechnician  = "Technician"
+	// RoleService is a backend service calling with an API key rather than a user
+	RoleService = "Service"
+)
+
+type Action string
+
+const (
+	Read  Action = "read"
+	Write Action = "write"
+	// Claim is a write that sets the project's user to the caller, allowed on projects nobody owns yet
+	Claim Action = "claim"
+)
+
+// Principal is the authenticated caller, built from their token's custom claims or Firestore user document
+type Principal struct {
+	UserID     string
+	Role       string
+	UtilityID  string   // the utility a Utility user works for
+	ProjectIDs []string // projects a Residential user belongs to without being the project's user
+	KeyID      string   // the API key a Service called with
+	UtilityIDs []string // utilities a Service may act for, * for all
+}
+
+// IsService reports whether the caller is a backend service rather than a user
+func (p Principal) IsService() bool {
+	return p.Role == RoleService
+}
+
+func (p Principal) actsFor(utilityID string) bool {
+	for _, id := range p.UtilityIDs {
+		if id == "*" || (id != "" && id == utilityID) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+type projectRule func(p Principal, project *models.Project, a Action) bool
+type utilityRule func(p Principal, utilityID string, a Action) bool
+
+// Technicians install and service DERs for every utility, so they are not scoped
+var projectRules = map[string]projectRule{
+	RoleTechnician: func(Principal, *models.Project, Action) bool { return true },
+	RoleResidential: func(p Principal, project *models.Project, a Action) bool {
+		if a == Claim && project.UserID == "" {
+			return true
+		}
+		if p.UserID != "" && project.UserID == p.UserID {
+			return true
+		}
+		for _, id := range p.ProjectIDs {
+			if id == project.ID {
+				return true
+			}
+		}
+		return false
+	},
+	RoleUtility: func(p Principal, project *models.Project, a Action) bool {
+		return a != Claim && p.UtilityID != "" && project.UtilityID == p.UtilityID
+	},
+	RoleService: func(p Principal, project *models.Project, a Action) bool {
+		return a != Claim && p.actsFor(project.UtilityID)
+	},
+}
+
+var utilityRules = map[string]utilityRule{
+	RoleTechnician: func(Principal, string, Action) bool { return true },
+	// homeowners only ever see a utility's display name
+	RoleResidential: func(_ Principal, _ string, a Action) bool { return a == Read },
+	RoleUtility: func(p Principal, utilityID string, _ Action) bool {
+		return p.UtilityID != "" && utilityID == p.UtilityID
+	},
+	RoleService: func(p Principal, utilityID string, _ Action) bool {
+		return p.actsFor(utilityID)
+	},
+}
+
+// CanProject reports whether p may perform a on project
+func CanProject(p Principal, project *models.Project, a Action) bool {
+	rule, ok := projectRules[p.Role]
+	return ok && rule(p, project, a)
+}
+
+// CanUtility reports whether p may perform a on the utility, or anything scoped to it such as its DR events
+func CanUtility(p Principal, utilityID string, a Action) bool {
+	rule, ok := utilityRules[p.Role]
+	return ok && rule(p, utilityID, a)
+}
+
+// ProjectGetter is the part of the project repository the policy needs
+type ProjectGetter interface {
+	GetProject(ctx context.Context, id string) (*models.Project, error)
+}
+
+// Policy applies the rules to the principal on the request context
+type Policy struct {
+	projects ProjectGetter
+}
+
+func NewPolicy(projects ProjectGetter) *Policy {
+	return &Policy{projects: projects}
+}
+
+// Project checks the caller may perform a on the project, notFound is the message of the 404
+// returned when it may not, so callers can match the resource they are actually looking up
+func (p *Policy) Project(ctx context.Context, projectID string, a Action, notFound string) error {
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return custom_error.New(http.StatusUnauthorized, "Unauthorized", nil)
+	}
+	project, err := p.projects.GetProject(ctx, projectID)
+	if err != nil {
+		var customErr *custom_error.CustomError
+		if errors.As(err, &customErr) && customErr.Code == http.StatusNotFound {
+			return custom_error.New(http.StatusNotFound, notFound, nil)
+		}
+		return err
+	}
+	if !CanProject(principal, project, a) {
+		return custom_error.New(http.StatusNotFound, notFound, nil)
+	}
+	return nil
+}
+
+// Utility checks the caller may perform a on the utility or something scoped to it
+func (p *Policy) Utility(ctx context.Context, utilityID string, a Action, notFound string) error {
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return custom_error.New(http.StatusUnauthorized, "Unauthorized", nil)
+	}
+	if !CanUtility(principal, utilityID, a) {
+		return custom_error.New(http.StatusNotFound, notFound, nil)
+	}
+	return nil
+}
+
+// Scope is what a caller may read expressed as filters, so list queries apply the rules in the database instead of
+// checking every row. A row is visible when All is set or it matches any of the other fields.
+type Scope struct {
+	All        bool
+	UtilityIDs []string // rows of these utilities
+	UserID     string   // projects owned by the user
+	ProjectIDs []string // and these projects
+}
+
+// ProjectScope is the projects p may read, the list form of the project rules
+func ProjectScope(p Principal) Scope {
+	switch p.Role {
+	case RoleTechnician:
+		return Scope{All: true}
+	case RoleResidential:
+		return Scope{UserID: p.UserID, ProjectIDs: p.ProjectIDs}
+	case RoleUtility:
+		if p.UtilityID != "" {
+			return Scope{UtilityIDs: []string{p.UtilityID}}
+		}
+	case RoleService:
+		return serviceScope(p)
+	}
+	return Scope{}
+}
+
+// UtilityScope is the utilities p may read, the list form of the utility rules
+func UtilityScope(p Principal) Scope {
+	switch p.Role {
+	case RoleTechnician, RoleResidential:
+		return Scope{All: true}
+	case RoleUtility:
+		if p.UtilityID != "" {
+			return Scope{UtilityIDs: []string{p.UtilityID}}
+		}
+	case RoleService:
+		return serviceScope(p)
+	}
+	return Scope{}
+}
+
+func serviceScope(p Principal) Scope {
+	for _, id := range p.UtilityIDs {
+		if id == "*" {
+			return Scope{All: true}
+		}
+	}
+	return Scope{UtilityIDs: p.UtilityIDs}
+}
+
+// Project reports whether the scope includes project, what the list queries check in SQL
+func (s Scope) Project(project *models.Project) bool {
+	if s.All || s.Utility(project.UtilityID) {
+		return true
+	}
+	if s.UserID != "" && project.UserID == s.UserID {
+		return true
+	}
+	for _, id := range s.ProjectIDs {
+		if id == project.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Utility reports whether the scope includes the utility
+func (s Scope) Utility(utilityID string) bool {
+	if s.All {
+		return true
+	}
+	for _, id := range s.UtilityIDs {
+		if id != "" && id == utilityID {
+			return true
+		}
+	}
+	return false
+}
+
+// Projects is the scope of the projects the caller may list
+func (p *Policy) Projects(ctx context.Context) (Scope, error) {
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return Scope{}, custom_error.New(http.StatusUnauthorized, "Unauthorized", nil)
+	}
+	return ProjectScope(principal), nil
+}
+
+// Utilities is the scope of the utilities the caller may list
+func (p *Policy) Utilities(ctx context.Context) (Scope, error) {
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return Scope{}, custom_error.New(http.StatusUnauthorized, "Unauthorized", nil)
+	}
+	return UtilityScope(principal), nil
+}
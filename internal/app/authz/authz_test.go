@@ -0,0 +1,147 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	homeowner  = Principal{UserID: "user-1", Role: RoleResidential}
+	neighbour  = Principal{UserID: "user-2", Role: RoleResidential}
+	utility    = Principal{UserID: "util-user-1", Role: RoleUtility, UtilityID: "util-1"}
+	competitor = Principal{UserID: "util-user-2", Role: RoleUtility, UtilityID: "util-2"}
+	technician = Principal{UserID: "tech-1", Role: RoleTechnician}
+	unassigned = Principal{UserID: "util-user-3", Role: RoleUtility}
+	unknown    = Principal{UserID: "user-3", Role: "Admin"}
+	service    = Principal{UserID: "service:key-1", Role: RoleService, KeyID: "key-1", UtilityIDs: []string{"util-1"}}
+	everywhere = Principal{UserID: "service:key-2", Role: RoleService, KeyID: "key-2", UtilityIDs: []string{"*"}}
+	member     = Principal{UserID: "user-4", Role: RoleResidential, ProjectIDs: []string{"proj-1"}}
+
+	owned   = &models.Project{ID: "proj-1", UserID: "user-1", UtilityID: "util-1"}
+	unowned = &models.Project{ID: "proj-2", UtilityID: "util-1"}
+)
+
+func TestCanProject(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		project   *models.Project
+		action    Action
+		allowed   bool
+	}{
+		{"owner reads", homeowner, owned, Read, true},
+		{"owner writes", homeowner, owned, Write, true},
+		{"other homeowner reads", neighbour, owned, Read, false},
+		{"other homeowner writes", neighbour, owned, Write, false},
+		{"member writes", member, owned, Write, true},
+		{"member reads other project", member, unowned, Read, false},
+		{"homeowner claims unowned", neighbour, unowned, Claim, true},
+		{"homeowner writes unowned", neighbour, unowned, Write, false},
+		{"homeowner claims owned", neighbour, owned, Claim, false},
+		{"utility reads its project", utility, owned, Read, true},
+		{"utility writes its project", utility, owned, Write, true},
+		{"utility claims its project", utility, unowned, Claim, false},
+		{"other utility reads", competitor, owned, Read, false},
+		{"utility without utility id", unassigned, &models.Project{ID: "proj-3"}, Read, false},
+		{"technician writes", technician, owned, Write, true},
+		{"service writes its utility's project", service, owned, Write, true},
+		{"service reads another utility's project", service, &models.Project{ID: "proj-3", UtilityID: "util-2"}, Read, false},
+		{"service claims", service, unowned, Claim, false},
+		{"service for every utility", everywhere, &models.Project{ID: "proj-3", UtilityID: "util-2"}, Write, true},
+		{"unknown role", unknown, owned, Read, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, CanProject(tc.principal, tc.project, tc.action))
+		})
+	}
+}
+
+func TestCanUtility(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		utilityID string
+		action    Action
+		allowed   bool
+	}{
+		{"utility reads itself", utility, "util-1", Read, true},
+		{"utility writes itself", utility, "util-1", Write, true},
+		{"utility reads another", utility, "util-2", Read, false},
+		{"utility without utility id", unassigned, "", Read, false},
+		{"homeowner reads", homeowner, "util-1", Read, true},
+		{"homeowner writes", homeowner, "util-1", Write, false},
+		{"technician writes", technician, "util-2", Write, true},
+		{"service reads its utility", service, "util-1", Read, true},
+		{"service reads another utility", service, "util-2", Read, false},
+		{"unknown role", unknown, "util-1", Read, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, CanUtility(tc.principal, tc.utilityID, tc.action))
+		})
+	}
+}
+
+type stubProjects map[string]*models.Project
+
+func (p stubProjects) GetProject(ctx context.Context, id string) (*models.Project, error) {
+	project, ok := p[id]
+	if !ok {
+		return nil, custom_error.New(http.StatusNotFound, "Project id not found", nil)
+	}
+	return project, nil
+}
+
+func status(t *testing.T, err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	customErr, ok := err.(*custom_error.CustomError)
+	require.True(t, ok, "expected custom error, got: %v", err)
+	return customErr.Code
+}
+
+// denied and missing projects must be indistinguishable
+func TestPolicyProject(t *testing.T) {
+	policy := NewPolicy(stubProjects{owned.ID: owned})
+
+	ctx := WithPrincipal(context.Background(), homeowner)
+	assert.Equal(t, http.StatusOK, status(t, policy.Project(ctx, owned.ID, Write, "Contract not found")))
+
+	ctx = WithPrincipal(context.Background(), neighbour)
+	denied := policy.Project(ctx, owned.ID, Read, "Contract not found")
+	missing := policy.Project(ctx, "proj-404", Read, "Contract not found")
+	assert.Equal(t, http.StatusNotFound, status(t, denied))
+	assert.Equal(t, denied.Error(), missing.Error())
+
+	assert.Equal(t, http.StatusUnauthorized, status(t, policy.Project(context.Background(), owned.ID, Read, "Contract not found")))
+}
+
+func TestPolicyUtility(t *testing.T) {
+	policy := NewPolicy(stubProjects{})
+
+	ctx := WithPrincipal(context.Background(), utility)
+	assert.Equal(t, http.StatusOK, status(t, policy.Utility(ctx, "util-1", Read, "Utility id not found")))
+	assert.Equal(t, http.StatusNotFound, status(t, policy.Utility(ctx, "util-2", Read, "Utility id not found")))
+}
+
+// The scopes must agree with the read rules they are the list form of
+func TestScopesMatchRules(t *testing.T) {
+	principals := []Principal{homeowner, neighbour, utility, competitor, technician, unassigned, unknown, service, everywhere, member}
+	projects := []*models.Project{owned, unowned, {ID: "proj-3", UtilityID: "util-2", UserID: "user-2"}}
+	for _, p := range principals {
+		for _, project := range projects {
+			assert.Equal(t, CanProject(p, project, Read), ProjectScope(p).Project(project), "%s %s on %s", p.Role, p.UserID, project.ID)
+		}
+		for _, utilityID := range []string{"util-1", "util-2"} {
+			assert.Equal(t, CanUtility(p, utilityID, Read), UtilityScope(p).Utility(utilityID), "%s %s on %s", p.Role, p.UserID, utilityID)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	feeds := memory.NewStore(slog.New(slog.NewTextHandler(io.Discard, nil))).CalendarFeeds
+	tokens := NewTokens(&config.CalendarConfig{Secret: strings.Repeat("s", 32), TokenTTL: time.Hour}, feeds)
+	tokens.now = func() time.Time { return now }
+
+	token, expires, err := tokens.Issue(ctx, "home-1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, tokenPrefix))
+	assert.Equal(t, now.Add(time.Hour), expires)
+
+	uid, err := tokens.Verify(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "home-1", uid)
+
+	other := NewTokens(&config.CalendarConfig{Secret: strings.Repeat("o", 32), TokenTTL: time.Hour}, feeds)
+	other.now = tokens.now
+	for name, bad := range map[string]string{
+		"tampered":     token[:len(token)-2] + "xx",
+		"no signature": strings.Split(token, ".")[0],
+		"empty":        "",
+	} {
+		_, err := tokens.Verify(ctx, bad)
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+	_, err = other.Verify(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "signed with another secret")
+
+	// revoking stops the user's tokens, not anyone else's, and the next token works
+	home2, _, err := tokens.Issue(ctx, "home-2")
+	require.NoError(t, err)
+	require.NoError(t, tokens.Revoke(ctx, "home-1"))
+	_, err = tokens.Verify(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "revoked")
+	_, err = tokens.Verify(ctx, home2)
+	assert.NoError(t, err)
+	reissued, _, err := tokens.Issue(ctx, "home-1")
+	require.NoError(t, err)
+	_, err = tokens.Verify(ctx, reissued)
+	assert.NoError(t, err)
+
+	tokens.now = func() time.Time { return now.Add(time.Hour) }
+	_, err = tokens.Verify(ctx, reissued)
+	assert.ErrorIs(t, err, ErrInvalidToken, "expired")
+
+	_, _, err = NewTokens(&config.CalendarConfig{}, feeds).Issue(ctx, "home-1")
+	assert.Error(t, err, "feeds are off without a secret")
+}
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	scheduled := bigquery.NullTimestamp{Timestamp: now.Add(-time.Hour), Valid: true}
+	events := []models.DREvents{
+		{ID: "e-1", Status: models.DREventScheduled, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour),
+			UtilityName: "Power, Light; and a name long enough to fold the summary line", ScheduledAt: scheduled, Version: 2,
+			Participation: models.ParticipantEnrolled},
+		{ID: "e-2", Status: models.DREventScheduled, StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour),
+			ScheduledAt: scheduled, Version: 2, Participation: models.ParticipantOptedOut},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "Feed", events, now))
+	out := buf.String()
+
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	assert.Equal(t, "BEGIN:VCALENDAR", lines[0])
+	assert.Equal(t, "END:VCALENDAR", lines[len(lines)-1])
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), maxLineLength, line)
+	}
+	assert.Contains(t, out, "DTSTART:20250601T130000Z\r\n")
+	assert.Contains(t, out, "LAST-MODIFIED:20250601T110000Z\r\n")
+
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	assert.Contains(t, unfolded, `SUMMARY:Demand response event - Power\, Light\; and a name long enough to fold the summary line`)
+	// the opted out project's copy is cancelled, a version later than the event
+	e2 := unfolded[strings.Index(unfolded, "UID:e-2@"):]
+	assert.Contains(t, e2, "SEQUENCE:2\r\nSTATUS:CANCELLED\r\n")
+	assert.Contains(t, unfolded[:strings.Index(unfolded, "UID:e-2@")], "SEQUENCE:1\r\nSTATUS:CONFIRMED\r\n")
+}
@@ -0,0 +1,149 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+const (
+	// ContentType is the media type of a feed
+	ContentType = "text/calendar; charset=utf-8"
+
+	prodID    = "-//Grid Stream//DR Events//EN"
+	uidDomain = "grid-stream-org"
+	// calendar apps poll a subscribed feed at their own pace, this asks them to come back hourly
+	refreshInterval = "PT1H"
+	// lines are folded at 75 octets, not counting the line break
+	maxLineLength = 75
+)
+
+// Published reports whether an event belongs in a feed, drafts haven't been announced yet
+func Published(e *models.DREvents) bool {
+	return e.Status != models.DREventDraft
+}
+
+// Write renders events as a calendar named name. Every event keeps its id as the UID, SEQUENCE follows its version
+// so calendar apps take updates over the copy they hold, and cancelled events stay in with STATUS:CANCELLED. On a
+// project's feed an event the project opted out of is cancelled too.
+func Write(w io.Writer, name string, events []models.DREvents, now time.Time) error {
+	b := bufio.NewWriter(w)
+	line := func(property, value string) {
+		writeLine(b, property+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escape(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION", refreshInterval)
+	line("X-PUBLISHED-TTL", refreshInterval)
+	for i := range events {
+		e := &events[i]
+		// the version starts at 1, every update and transition bumps it. Opting out doesn't touch the event, so
+		// it bumps the project's copy on its own.
+		sequence := e.Version - 1
+		cancelled := e.Status == models.DREventCancelled
+		if e.Participation == models.ParticipantOptedOut {
+			sequence++
+			cancelled = true
+		}
+		status := "CONFIRMED"
+		if cancelled {
+			status = "CANCELLED"
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", e.ID+"@"+uidDomain)
+		line("DTSTAMP", stamp(now)) // with a METHOD, when the feed was rendered
+		line("LAST-MODIFIED", stamp(modified(e, now)))
+		line("DTSTART", stamp(e.StartTime))
+		line("DTEND", stamp(e.EndTime))
+		line("SEQUENCE", fmt.Sprint(sequence))
+		line("STATUS", status)
+		line("SUMMARY", escape(summary(e, cancelled)))
+		line("DESCRIPTION", escape(description(e)))
+		line("TRANSP", "OPAQUE")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.Flush()
+}
+
+func summary(e *models.DREvents, cancelled bool) string {
+	s := "Demand response event"
+	if e.UtilityName != "" {
+		s += " - " + e.UtilityName
+	}
+	if cancelled {
+		s = "Cancelled: " + s
+	}
+	return s
+}
+
+func description(e *models.DREvents) string {
+	d := "Status: " + string(e.Status)
+	switch e.Participation {
+	case models.ParticipantOptedOut:
+		d += "\nYour project opted out of this event."
+	case models.ParticipantEnrolled:
+		d += "\nYour project is enrolled, its DERs will reduce their demand during the event."
+	}
+	return d
+}
+
+// modified is when the event last changed, the latest of its transitions
+func modified(e *models.DREvents, fallback time.Time) time.Time {
+	var last time.Time
+	for _, t := range []struct {
+		valid bool
+		at    time.Time
+	}{
+		{e.ScheduledAt.Valid, e.ScheduledAt.Timestamp},
+		{e.ActivatedAt.Valid, e.ActivatedAt.Timestamp},
+		{e.CompletedAt.Valid, e.CompletedAt.Timestamp},
+		{e.CancelledAt.Valid, e.CancelledAt.Timestamp},
+	} {
+		if t.valid && t.at.After(last) {
+			last = t.at
+		}
+	}
+	if last.IsZero() {
+		return fallback
+	}
+	return last
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape escapes a TEXT value
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeLine writes a content line folded at 75 octets, without splitting a UTF-8 sequence
+func writeLine(b *bufio.Writer, s string) {
+	limit := maxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = maxLineLength - 1 // the continuation's leading space counts
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
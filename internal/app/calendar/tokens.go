@@ -0,0 +1,120 @@
+// Package calendar serves the DR events of a project or a utility as an RFC 5545 iCalendar feed. Calendar apps
+// can't send an ID token, so feeds are fetched with a long-lived token signed for the user, carried in the url.
+package calendar
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/pkg/errors"
+)
+
+// tokenPrefix tells feed tokens apart from ID tokens and API keys, they aren't JWTs so no verifier of ID tokens
+// accepts one even when it shares the secret
+const tokenPrefix = "gsf_"
+
+// ErrInvalidToken is returned for tokens that weren't signed with the secret, are malformed, have expired or were
+// revoked
+var ErrInvalidToken = errors.New("invalid calendar feed token")
+
+// Tokens signs and checks feed tokens. Each token carries the user's generation when it was issued, revoking bumps
+// the generation so every token issued before stops working.
+type Tokens struct {
+	cfg   *config.CalendarConfig
+	feeds repositories.CalendarFeedRepository
+	now   func() time.Time
+}
+
+func NewTokens(cfg *config.CalendarConfig, feeds repositories.CalendarFeedRepository) *Tokens {
+	return &Tokens{cfg: cfg, feeds: feeds, now: time.Now}
+}
+
+// Enabled reports whether a secret is configured
+func (t *Tokens) Enabled() bool {
+	return t.cfg.Secret != ""
+}
+
+// claims are the signed part of a token
+type claims struct {
+	Subject    string `json:"sub"`
+	Generation int64  `json:"gen"`
+	IssuedAt   int64  `json:"iat"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+// Issue signs a token for the user, valid for the configured lifetime
+func (t *Tokens) Issue(ctx context.Context, uid string) (string, time.Time, error) {
+	if !t.Enabled() {
+		return "", time.Time{}, custom_error.New(http.StatusServiceUnavailable, "Calendar feeds aren't configured", nil)
+	}
+	generation, err := t.feeds.GetFeedGeneration(ctx, uid)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := t.now()
+	expires := now.Add(t.cfg.TokenTTL).Truncate(time.Second)
+	payload, err := json.Marshal(claims{Subject: uid, Generation: generation, IssuedAt: now.Unix(), ExpiresAt: expires.Unix()})
+	if err != nil {
+		return "", time.Time{}, custom_error.New(http.StatusInternalServerError, "Failed to issue feed token", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return tokenPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded)), expires, nil
+}
+
+// Revoke stops every token issued to the user so far from working, tokens issued after work again
+func (t *Tokens) Revoke(ctx context.Context, uid string) error {
+	if !t.Enabled() {
+		return custom_error.New(http.StatusServiceUnavailable, "Calendar feeds aren't configured", nil)
+	}
+	_, err := t.feeds.RevokeFeedTokens(ctx, uid, t.now())
+	return err
+}
+
+// Verify returns the user a token was issued to, ErrInvalidToken if it can't be used
+func (t *Tokens) Verify(ctx context.Context, token string) (string, error) {
+	if !t.Enabled() {
+		return "", ErrInvalidToken
+	}
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, tokenPrefix), ".")
+	if !ok || !strings.HasPrefix(token, tokenPrefix) {
+		return "", ErrInvalidToken
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sum, t.sign(encoded)) {
+		return "", ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Subject == "" {
+		return "", ErrInvalidToken
+	}
+	if !t.now().Before(time.Unix(c.ExpiresAt, 0)) {
+		return "", ErrInvalidToken
+	}
+	generation, err := t.feeds.GetFeedGeneration(ctx, c.Subject)
+	if err != nil {
+		return "", err
+	}
+	if c.Generation != generation {
+		return "", ErrInvalidToken
+	}
+	return c.Subject, nil
+}
+
+func (t *Tokens) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, []byte(t.cfg.Secret))
+	mac.Write([]byte("calendar-feed:" + encoded))
+	return mac.Sum(nil)
+}
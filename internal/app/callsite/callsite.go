@@ -0,0 +1,56 @@
+// Package callsite finds the repository method a database call was made from, so metrics and
+// spans can be labelled per repository method without every repository labelling its own queries.
+package callsite
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Other labels calls made outside a repository, like migrations and health probes
+const Other = "other"
+
+type site struct {
+	repository string
+	method     string
+}
+
+// repository frames parsed so far by program counter, there are only as many as there are repository methods
+var sites sync.Map
+
+// Repository walks the stack for the nearest repository method, e.g. (*projectRepository).GetProject
+// gives projectRepository and GetProject
+func Repository() (string, string) {
+	var pcs [24]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if s, ok := sites.Load(frame.PC); ok {
+			s := s.(site)
+			return s.repository, s.method
+		}
+		if repository, method, ok := repositoryMethod(frame.Function); ok {
+			sites.Store(frame.PC, site{repository, method})
+			return repository, method
+		}
+		if !more {
+			return Other, Other
+		}
+	}
+}
+
+// repositoryMethod splits pkg/path.(*projectRepository).GetProject into projectRepository and GetProject
+func repositoryMethod(function string) (string, string, bool) {
+	function = function[strings.LastIndex(function, "/")+1:]
+	parts := strings.Split(function, ".")
+	if len(parts) < 3 {
+		return "", "", false
+	}
+	receiver := strings.Trim(parts[1], "(*)")
+	if !strings.HasSuffix(receiver, "Repository") {
+		return "", "", false
+	}
+	return receiver, parts[2], true
+}
@@ -0,0 +1,49 @@
+package callsite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type widgetRepository struct{}
+
+func (r *widgetRepository) GetWidget() (string, string) {
+	return lookup()
+}
+
+// the repository method doesn't have to be the direct caller
+func lookup() (string, string) {
+	return Repository()
+}
+
+func TestRepository(t *testing.T) {
+	repository, method := (&widgetRepository{}).GetWidget()
+	assert.Equal(t, "widgetRepository", repository)
+	assert.Equal(t, "GetWidget", method)
+
+	repository, method = lookup()
+	assert.Equal(t, Other, repository)
+	assert.Equal(t, Other, method)
+}
+
+func TestRepositoryMethod(t *testing.T) {
+	tests := []struct {
+		function   string
+		repository string
+		method     string
+		ok         bool
+	}{
+		{"github.com/grid-stream-org/api/internal/app/repositories.(*projectRepository).GetProject", "projectRepository", "GetProject", true},
+		{"github.com/grid-stream-org/api/internal/app/repositories/postgres.(*contractRepository).CreateContract.func1", "contractRepository", "CreateContract", true},
+		{"github.com/grid-stream-org/api/internal/app/repositories.(*tableClient).Get", "", "", false},
+		{"github.com/grid-stream-org/api/internal/migrate.(*Migrator).Up", "", "", false},
+		{"main.run", "", "", false},
+	}
+	for _, tc := range tests {
+		repository, method, ok := repositoryMethod(tc.function)
+		assert.Equal(t, tc.ok, ok, tc.function)
+		assert.Equal(t, tc.repository, repository)
+		assert.Equal(t, tc.method, method)
+	}
+}
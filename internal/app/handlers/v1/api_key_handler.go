@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/apikeys"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type APIKeyHandlers interface {
+	CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) error
+	ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) error
+	RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) error
+	RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiKeyHandlers struct {
+	keys *apikeys.Service
+	log  *slog.Logger
+}
+
+func NewAPIKeyHandlers(keys *apikeys.Service, log *slog.Logger) APIKeyHandlers {
+	return &apiKeyHandlers{keys: keys, log: log}
+}
+
+// issuedKey is the response of create and rotate, the only time the secret is shown
+type issuedKey struct {
+	Key    *models.APIKey `json:"key"`
+	Secret string         `json:"secret"`
+}
+
+func (h *apiKeyHandlers) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) error {
+	var req apikeys.CreateRequest
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	principal, _ := authz.PrincipalFrom(r.Context())
+	key, secret, err := h.keys.Create(r.Context(), &req, principal.UserID)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(r.Context(), h.log).InfoContext(r.Context(), "created api key", "key_id", key.ID, "prefix", key.Prefix, "created_by", key.CreatedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(issuedKey{Key: key, Secret: secret})
+}
+
+func (h *apiKeyHandlers) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) error {
+	keys, err := h.keys.List(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(keys)
+}
+
+func (h *apiKeyHandlers) RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		return custom_error.New(http.StatusBadRequest, "api key ID required", nil)
+	}
+
+	key, secret, err := h.keys.Rotate(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(r.Context(), h.log).InfoContext(r.Context(), "rotated api key", "key_id", key.ID, "prefix", key.Prefix)
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(issuedKey{Key: key, Secret: secret})
+}
+
+func (h *apiKeyHandlers) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		return custom_error.New(http.StatusBadRequest, "api key ID required", nil)
+	}
+	if err := h.keys.Revoke(r.Context(), id); err != nil {
+		return err
+	}
+	logging.FromContext(r.Context(), h.log).InfoContext(r.Context(), "revoked api key", "key_id", id)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
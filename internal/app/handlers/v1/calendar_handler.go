@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/calendar"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type CalendarHandlers interface {
+	CreateFeedTokenHandler(w http.ResponseWriter, r *http.Request) error
+	RevokeFeedTokensHandler(w http.ResponseWriter, r *http.Request) error
+	ProjectFeedHandler(w http.ResponseWriter, r *http.Request) error
+	UtilityFeedHandler(w http.ResponseWriter, r *http.Request) error
+}
+
+type calendarHandlers struct {
+	Repo   repositories.DREventRepository
+	Tokens *calendar.Tokens
+	Cfg    *config.CalendarConfig
+	Policy *authz.Policy
+	Log    *slog.Logger
+	now    func() time.Time
+}
+
+func NewCalendarHandlers(repo repositories.DREventRepository, tokens *calendar.Tokens, cfg *config.CalendarConfig, policy *authz.Policy, log *slog.Logger) CalendarHandlers {
+	return &calendarHandlers{Repo: repo, Tokens: tokens, Cfg: cfg, Policy: policy, Log: log, now: time.Now}
+}
+
+// feedToken is the response of the token endpoint, the token goes in the feed urls as ?token=
+type feedToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateFeedTokenHandler issues the caller a token for the feeds of the projects and utilities they can read. The
+// token isn't stored, so each call issues a new one and the older ones keep working until they expire or are revoked.
+func (h *calendarHandlers) CreateFeedTokenHandler(w http.ResponseWriter, r *http.Request) error {
+	principal, err := h.user(r)
+	if err != nil {
+		return err
+	}
+	token, expires, err := h.Tokens.Issue(r.Context(), principal.UserID)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(r.Context(), h.Log).InfoContext(r.Context(), "issued calendar feed token", "expires_at", expires)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(feedToken{Token: token, ExpiresAt: expires})
+}
+
+// RevokeFeedTokensHandler revokes every feed token issued to the caller, for when a feed url was shared or leaked
+func (h *calendarHandlers) RevokeFeedTokensHandler(w http.ResponseWriter, r *http.Request) error {
+	principal, err := h.user(r)
+	if err != nil {
+		return err
+	}
+	if err := h.Tokens.Revoke(r.Context(), principal.UserID); err != nil {
+		return err
+	}
+	logging.FromContext(r.Context(), h.Log).InfoContext(r.Context(), "revoked calendar feed tokens")
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// user is the calling user, services have no feeds
+func (h *calendarHandlers) user(r *http.Request) (authz.Principal, error) {
+	principal, ok := authz.PrincipalFrom(r.Context())
+	if !ok || principal.Role == authz.RoleService {
+		return authz.Principal{}, custom_error.New(http.StatusForbidden, "Calendar feeds are for users", nil)
+	}
+	return principal, nil
+}
+
+func (h *calendarHandlers) ProjectFeedHandler(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "projectID")
+	if err := h.Policy.Project(r.Context(), id, authz.Read, "Project id not found"); err != nil {
+		return err
+	}
+	events, err := h.events(func(filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+		return h.Repo.GetDREventsByProjectID(r.Context(), id, filter, page)
+	})
+	if err != nil {
+		return err
+	}
+	return h.write(w, "Demand response events - project "+id, events)
+}
+
+func (h *calendarHandlers) UtilityFeedHandler(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "utilityID")
+	if err := h.Policy.Utility(r.Context(), id, authz.Read, "Utility id not found"); err != nil {
+		return err
+	}
+	events, err := h.events(func(filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+		return h.Repo.GetDREventsByUtilityID(r.Context(), id, filter, page)
+	})
+	if err != nil {
+		return err
+	}
+	name := "Demand response events"
+	if len(events) > 0 && events[0].UtilityName != "" {
+		name += " - " + events[0].UtilityName
+	}
+	return h.write(w, name, events)
+}
+
+// events reads every published event of a list that ends after the feed's window opens, earliest first
+func (h *calendarHandlers) events(list func(repositories.DREventFilter, pagination.Params) (pagination.Page[models.DREvents], error)) ([]models.DREvents, error) {
+	filter := repositories.DREventFilter{From: h.now().Add(-h.Cfg.Past)}
+	page := pagination.Params{Limit: pagination.MaxLimit, Sort: "start_time"}
+	events := []models.DREvents{}
+	for {
+		p, err := list(filter, page)
+		if err != nil {
+			return nil, err
+		}
+		for i := range p.Data {
+			if calendar.Published(&p.Data[i]) {
+				events = append(events, p.Data[i])
+			}
+		}
+		if p.NextCursor == "" {
+			return events, nil
+		}
+		page.Cursor = p.NextCursor
+	}
+}
+
+func (h *calendarHandlers) write(w http.ResponseWriter, name string, events []models.DREvents) error {
+	w.Header().Set("Content-Type", calendar.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="events.ics"`)
+	// the url holds the token, keep shared caches from keeping a copy
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	return calendar.Write(w, name, events, h.now())
+}
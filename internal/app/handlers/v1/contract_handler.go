@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/app/repositories"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
@@ -16,42 +18,56 @@ type ContractHandler interface {
 	CreateContractHandler(w http.ResponseWriter, r *http.Request) error
 	GetContractHandler(w http.ResponseWriter, r *http.Request) error
 	UpdateContractHandler(w http.ResponseWriter, r *http.Request) error
+	PatchContractHandler(w http.ResponseWriter, r *http.Request) error
 	DeleteContractHandler(w http.ResponseWriter, r *http.Request) error
     GetContractsByProjectIDHandler(w http.ResponseWriter, r *http.Request) error
 }
 
 type contractHandler struct {
-	repo repositories.ContractRepository
-	log  *slog.Logger
+	repo   repositories.ContractRepository
+	policy *authz.Policy
+	log    *slog.Logger
 }
 
-func NewContractHandlers(repo repositories.ContractRepository, log *slog.Logger) ContractHandler {
-	return &contractHandler{repo: repo, log: log}
+func NewContractHandlers(repo repositories.ContractRepository, policy *authz.Policy, log *slog.Logger) ContractHandler {
+	return &contractHandler{repo: repo, policy: policy, log: log}
+}
+
+// contract loads the contract and checks the caller may perform a on its project
+func (h *contractHandler) contract(r *http.Request, id string, a authz.Action) (*models.Contract, error) {
+	contract, err := h.repo.GetContract(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.policy.Project(r.Context(), contract.ProjectID, a, "Contract not found"); err != nil {
+		return nil, err
+	}
+	return contract, nil
 }
 
 func (h *contractHandler) CreateContractHandler(w http.ResponseWriter, r *http.Request) error {
 	var req models.Contract
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
-	// TODO: add better start date and end date validation
-	if req.ContractThreshold <= 0 || req.ProjectID == "" || !req.EndDate.Valid || !req.StartDate.Valid || req.Status == "" {
-		return custom_error.New(http.StatusBadRequest, "All fields (contract threshold, start date, end date, projectID, status) are required", nil)
+	if err := req.Validate(); err != nil {
+		return err
 	}
-	if !req.Status.IsValid() {
-		return custom_error.New(http.StatusBadRequest, "Invalid status must be active, inactive or pending", nil)
+	if err := h.policy.Project(r.Context(), req.ProjectID, authz.Write, "Project id not found"); err != nil {
+		return err
 	}
-	err := h.repo.CreateContract(r.Context(), &models.Contract{
+	contract := &models.Contract{
 		ID:                uuid.New().String(),
 		ContractThreshold: req.ContractThreshold,
 		StartDate:         req.StartDate,
 		EndDate:           req.EndDate,
 		ProjectID:         req.ProjectID,
 		Status:            req.Status,
-	})
-	if err != nil {
+	}
+	if err := h.repo.CreateContract(r.Context(), contract); err != nil {
 		return err
 	}
+	w.Header().Set("Location", "/v1/contracts/"+contract.ID)
 	w.WriteHeader(http.StatusCreated)
 	return nil
 }
@@ -62,50 +78,48 @@ func (h *contractHandler) GetContractHandler(w http.ResponseWriter, r *http.Requ
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Contract ID not given", nil)
 	}
-	contract, err := h.repo.GetContract(r.Context(), id)
+	contract, err := h.contract(r, id, authz.Read)
 	if err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(contract)
-	if err != nil {
-		return err
-	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+	return logic.WriteVersioned(w, r, contract, contract.Version)
 }
 
+// UpdateContractHandler replaces the contract's mutable fields with the body
 func (h *contractHandler) UpdateContractHandler(w http.ResponseWriter, r *http.Request) error {
-	var req models.Contract
+	return h.update(w, r, logic.Replace)
+}
+
+// PatchContractHandler applies the body to the contract as a JSON merge patch
+func (h *contractHandler) PatchContractHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.update(w, r, logic.MergePatch)
+}
+
+// update applies a PUT or PATCH body to the contract and writes only the fields it set
+func (h *contractHandler) update(w http.ResponseWriter, r *http.Request, apply logic.UpdateFunc) error {
 	id := chi.URLParam(r, "id")
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
-	}
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Contract ID is required", nil)
 	}
-	if req.ProjectID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating project id is not allowed", nil)
-	}
-	if req.ID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating contract id not allowed", nil)
+	contract, err := h.contract(r, id, authz.Write)
+	if err != nil {
+		return err
 	}
-	if req.Status != "" && !req.Status.IsValid() {
-		return custom_error.New(http.StatusBadRequest, "Invalid status must be active, inactive or pending", nil)
+	if err := logic.CheckIfMatch(r, contract.Version); err != nil {
+		return err
 	}
-	err := h.repo.UpdateContract(r.Context(), id, &models.Contract{
-		ContractThreshold: req.ContractThreshold,
-		StartDate:         req.StartDate,
-		EndDate:           req.EndDate,
-		Status:            req.Status,
-	})
-
+	fields, err := apply(r, contract, contract.MutableFields())
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+	if err := contract.ValidateUpdate(); err != nil {
+		return err
+	}
+	if err := h.repo.UpdateContract(r.Context(), id, contract, fields); err != nil {
+		return err
+	}
+	return logic.WriteVersioned(w, r, contract, contract.Version)
 }
 
 func (h *contractHandler) DeleteContractHandler(w http.ResponseWriter, r *http.Request) error {
@@ -114,7 +128,14 @@ func (h *contractHandler) DeleteContractHandler(w http.ResponseWriter, r *http.R
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Contract ID required", nil)
 	}
-	if err := h.repo.DeleteContract(r.Context(), id); err != nil {
+	contract, err := h.contract(r, id, authz.Write)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, contract.Version); err != nil {
+		return err
+	}
+	if err := h.repo.DeleteContract(r.Context(), id, contract.Version); err != nil {
 		return err
 	}
 	w.WriteHeader(http.StatusOK)
@@ -126,20 +147,22 @@ func (h *contractHandler) GetContractsByProjectIDHandler(w http.ResponseWriter,
     if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Project ID required", nil)
 	}
+	if err := h.policy.Project(r.Context(), id, authz.Read, "Project id not found"); err != nil {
+		return err
+	}
 
-    contracts, err := h.repo.GetContractsByProjectID(r.Context(), id)
+	page, err := pagination.ParseParams(r)
 	if err != nil {
 		return err
 	}
-
-	if contracts == nil {
-		contracts = []models.Contract{}
+	filter := repositories.ContractFilter{Status: models.ContractStatus(r.URL.Query().Get("status"))}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return custom_error.Validation(custom_error.FieldError{Field: "status", Code: models.RuleOneOf, Message: "must be one of active, inactive, pending"})
 	}
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(contracts); err != nil {
-        return err
-    }
-    w.WriteHeader(http.StatusOK)
 
-    return nil
+	contracts, err := h.repo.GetContractsByProjectID(r.Context(), id, filter, page)
+	if err != nil {
+		return err
+	}
+	return pagination.Write(w, r, contracts)
 }
\ No newline at end of file
@@ -11,7 +11,10 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"cloud.google.com/go/civil"
+	"github.com/grid-stream-org/api/internal/app/authz"
 	"github.com/grid-stream-org/api/internal/app/handlers/v1"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/stretchr/testify/assert"
@@ -33,22 +36,33 @@ func (m *MockContractRepository) GetContract(ctx context.Context, id string) (*m
 	return args.Get(0).(*models.Contract), args.Error(1)
 }
 
-func (m *MockContractRepository) UpdateContract(ctx context.Context, id string, contract *models.Contract) error {
-	args := m.Called(ctx, id, contract)
+func (m *MockContractRepository) UpdateContract(ctx context.Context, id string, contract *models.Contract, fields []string) error {
+	args := m.Called(ctx, id, contract, fields)
 	return args.Error(0)
 }
 
-func (m *MockContractRepository) DeleteContract(ctx context.Context, id string) error {
-	args := m.Called(ctx, id)
+func (m *MockContractRepository) DeleteContract(ctx context.Context, id string, version int64) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
-func (m *MockContractRepository) GetContractsByProjectID(ctx context.Context, id string) ([]models.Contract, error) {
-	args := m.Called(ctx, id)
-	var c []models.Contract
+func (m *MockContractRepository) GetContractsByProjectID(ctx context.Context, id string, filter repositories.ContractFilter, page pagination.Params) (pagination.Page[models.Contract], error) {
+	args := m.Called(ctx, id, filter, page)
+	var c pagination.Page[models.Contract]
 	return c, args.Error(1)
 }
 
+// projects the policy checks ownership against
+type stubProjects map[string]*models.Project
+
+func (p stubProjects) GetProject(ctx context.Context, id string) (*models.Project, error) {
+	project, ok := p[id]
+	if !ok {
+		return nil, custom_error.New(http.StatusNotFound, "Project id not found", nil)
+	}
+	return project, nil
+}
+
 // convert time.Time to bigquery.NullDate
 func toNullDate(t time.Time) bigquery.NullDate {
 	return bigquery.NullDate{
@@ -59,7 +73,11 @@ func toNullDate(t time.Time) bigquery.NullDate {
 
 func TestCreateContractHandler(t *testing.T) {
 	mockRepo := new(MockContractRepository)
-	handler := handlers.NewContractHandlers(mockRepo, nil)
+	policy := authz.NewPolicy(stubProjects{
+		"proj-123": {ID: "proj-123", UserID: "user-1"},
+		"proj-456": {ID: "proj-456", UserID: "user-2"},
+	})
+	handler := handlers.NewContractHandlers(mockRepo, policy, nil)
 
 	startDate := toNullDate(time.Now())
 	endDate := toNullDate(time.Now().AddDate(1, 0, 0)) // 1 year later
@@ -98,6 +116,19 @@ func TestCreateContractHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectRepoCall: false,
 		},
+		{
+			name: "Fail - Project Owned By Another User",
+			requestBody: models.Contract{
+				ContractThreshold: 100,
+				ProjectID:         "proj-456",
+				StartDate:         startDate,
+				EndDate:           endDate,
+				Status:            "active",
+			},
+			mockReturnErr:  nil,
+			expectedStatus: http.StatusNotFound,
+			expectRepoCall: false,
+		},
 		{
 			name: "Fail - Missing Required Fields",
 			requestBody: models.Contract{
@@ -114,6 +145,7 @@ func TestCreateContractHandler(t *testing.T) {
 			body, _ := json.Marshal(tc.requestBody)
 			req, err := http.NewRequest("POST", "/contracts", bytes.NewBuffer(body))
 			assert.NoError(t, err)
+			req = req.WithContext(authz.WithPrincipal(req.Context(), authz.Principal{UserID: "user-1", Role: authz.RoleResidential}))
 			rec := httptest.NewRecorder()
 
 			// Mock only if repo should be called
@@ -1,11 +1,15 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/app/repositories"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
@@ -17,29 +21,42 @@ type DERMetadataHandlers interface {
 	GetDERMetadataHandler(w http.ResponseWriter, r *http.Request) error
 	ListDERMetadataByProjectHandler(w http.ResponseWriter, r *http.Request) error
 	UpdateDERMetadataHandler(w http.ResponseWriter, r *http.Request) error
+	PatchDERMetadataHandler(w http.ResponseWriter, r *http.Request) error
 	DeleteDERMetadataHandler(w http.ResponseWriter, r *http.Request) error
 }
 type derMetadataHandlers struct {
-	Repo repositories.DERMetadataRepository
-	Log  *slog.Logger
+	Repo   repositories.DERMetadataRepository
+	Policy *authz.Policy
+	Log    *slog.Logger
 }
 
-func NewDERMetadataHandlers(repo repositories.DERMetadataRepository, log *slog.Logger) DERMetadataHandlers {
-	return &derMetadataHandlers{Repo: repo, Log: log}
+func NewDERMetadataHandlers(repo repositories.DERMetadataRepository, policy *authz.Policy, log *slog.Logger) DERMetadataHandlers {
+	return &derMetadataHandlers{Repo: repo, Policy: policy, Log: log}
+}
+
+// der loads the DER and checks the caller may perform a on its project
+func (h *derMetadataHandlers) der(r *http.Request, id string, a authz.Action) (*models.DERMetadata, error) {
+	der, err := h.Repo.GetDERMetadata(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Policy.Project(r.Context(), der.ProjectID, a, "der not found"); err != nil {
+		return nil, err
+	}
+	return der, nil
 }
 
 func (h *derMetadataHandlers) CreateDERMetadataHandler(w http.ResponseWriter, r *http.Request) error {
 	var req models.DERMetadata
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
 
-	// Power capacity can be entered by the user at a later date as it changes
-	if req.ID == "" || req.Type == "" || req.ProjectID == "" || req.NameplateCapacity <= 0 {
-		return custom_error.New(http.StatusBadRequest, "All fields (ID, Type, ProjectID, Type, NameplateCapacity) are required", nil)
+	if err := req.Validate(); err != nil {
+		return err
 	}
-	if !req.Type.IsValid() {
-		return custom_error.New(http.StatusBadRequest, "Invalid DERType. Allowed values: solar, battery, ev", nil)
+	if err := h.Policy.Project(r.Context(), req.ProjectID, authz.Write, "Project id not found"); err != nil {
+		return err
 	}
 
 	err := h.Repo.CreateDERMetadata(r.Context(), &req)
@@ -47,23 +64,36 @@ func (h *derMetadataHandlers) CreateDERMetadataHandler(w http.ResponseWriter, r
 	if err != nil {
 		return err
 	}
+	w.Header().Set("Location", "/v1/der-metadata/"+req.ID)
 	w.WriteHeader(http.StatusCreated)
 	return nil
 }
 
 func (h *derMetadataHandlers) BatchCreateDERMetadataHandler(w http.ResponseWriter, r *http.Request) error {
 	var req []models.DERMetadata
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
 
-	for _, der := range req {
-		// Power capacity can be entered by the user at a later date as it changes
-		if der.Type == "" || der.ProjectID == "" || der.NameplateCapacity <= 0 {
-			return custom_error.New(http.StatusBadRequest, "All fields (Type, ProjectID, Type, NameplateCapacity) are required", nil)
+	// every violation of every DER at once, fields prefixed with the DER's index
+	projects := map[string]bool{}
+	var fields []custom_error.FieldError
+	for i, der := range req {
+		projects[der.ProjectID] = true
+		var invalid *custom_error.CustomError
+		if errors.As(der.Validate(), &invalid) {
+			for _, f := range invalid.Fields {
+				f.Field = fmt.Sprintf("[%d].%s", i, f.Field)
+				fields = append(fields, f)
+			}
 		}
-		if !der.Type.IsValid() {
-			return custom_error.New(http.StatusBadRequest, "Invalid DERType. Allowed values: solar, battery, ev", nil)
+	}
+	if len(fields) > 0 {
+		return custom_error.Validation(fields...)
+	}
+	for projectID := range projects {
+		if err := h.Policy.Project(r.Context(), projectID, authz.Write, "Project id not found"); err != nil {
+			return err
 		}
 	}
 
@@ -82,18 +112,12 @@ func (h *derMetadataHandlers) GetDERMetadataHandler(w http.ResponseWriter, r *ht
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "der ID not given", nil)
 	}
-	contract, err := h.Repo.GetDERMetadata(r.Context(), id)
+	der, err := h.der(r, id, authz.Read)
 	if err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(contract)
-	if err != nil {
-		return err
-	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+	return logic.WriteVersioned(w, r, der, der.Version)
 }
 
 func (h *derMetadataHandlers) ListDERMetadataByProjectHandler(w http.ResponseWriter, r *http.Request) error {
@@ -101,49 +125,60 @@ func (h *derMetadataHandlers) ListDERMetadataByProjectHandler(w http.ResponseWri
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "project ID request", nil)
 	}
+	if err := h.Policy.Project(r.Context(), id, authz.Read, "Project id not found"); err != nil {
+		return err
+	}
 
-	ders, err := h.Repo.ListDERMetadataByProject(r.Context(), id)
+	page, err := pagination.ParseParams(r)
 	if err != nil {
 		return err
 	}
-
-	if ders == nil {
-		ders = []models.DERMetadata{}
+	filter := repositories.DERMetadataFilter{Type: models.DERType(r.URL.Query().Get("type"))}
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return custom_error.Validation(custom_error.FieldError{Field: "type", Code: models.RuleOneOf, Message: "must be one of solar, battery, ev"})
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(ders)
+	ders, err := h.Repo.ListDERMetadataByProject(r.Context(), id, filter, page)
+	if err != nil {
+		return err
+	}
+	return pagination.Write(w, r, ders)
 }
 
+// UpdateDERMetadataHandler replaces the DER's mutable fields with the body
 func (h *derMetadataHandlers) UpdateDERMetadataHandler(w http.ResponseWriter, r *http.Request) error {
-	var req models.DERMetadata
+	return h.update(w, r, logic.Replace)
+}
+
+// PatchDERMetadataHandler applies the body to the DER as a JSON merge patch
+func (h *derMetadataHandlers) PatchDERMetadataHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.update(w, r, logic.MergePatch)
+}
+
+// update applies a PUT or PATCH body to the DER and writes only the fields it set
+func (h *derMetadataHandlers) update(w http.ResponseWriter, r *http.Request, apply logic.UpdateFunc) error {
 	id := chi.URLParam(r, "id")
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
-	}
 	if id == "" {
-		return custom_error.New(http.StatusBadRequest, "Contract ID is required", nil)
+		return custom_error.New(http.StatusBadRequest, "der ID is required", nil)
 	}
-	if req.ProjectID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating project id is not allowed", nil)
-	}
-	if req.ID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating der id not allowed", nil)
+	der, err := h.der(r, id, authz.Write)
+	if err != nil {
+		return err
 	}
-	if req.Type != "" && !req.Type.IsValid() {
-		return custom_error.New(http.StatusBadRequest, "Invalid DERType. Allowed values: solar, battery, ev", nil)
+	if err := logic.CheckIfMatch(r, der.Version); err != nil {
+		return err
 	}
-	err := h.Repo.UpdateDERMetadata(r.Context(), id, &models.DERMetadata{
-		Type:              req.Type,
-		NameplateCapacity: req.NameplateCapacity,
-		PowerCapacity:     req.PowerCapacity,
-	})
-
+	fields, err := apply(r, der, der.MutableFields())
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+	if err := der.ValidateUpdate(); err != nil {
+		return err
+	}
+	if err := h.Repo.UpdateDERMetadata(r.Context(), id, der, fields); err != nil {
+		return err
+	}
+	return logic.WriteVersioned(w, r, der, der.Version)
 }
 
 func (handler *derMetadataHandlers) DeleteDERMetadataHandler(w http.ResponseWriter, r *http.Request) error {
@@ -151,7 +186,14 @@ func (handler *derMetadataHandlers) DeleteDERMetadataHandler(w http.ResponseWrit
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Contract ID required", nil)
 	}
-	if err := handler.Repo.DeleteDERMetadata(r.Context(), id); err != nil {
+	der, err := handler.der(r, id, authz.Write)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, der.Version); err != nil {
+		return err
+	}
+	if err := handler.Repo.DeleteDERMetadata(r.Context(), id, der.Version); err != nil {
 		return err
 	}
 	w.WriteHeader(http.StatusOK)
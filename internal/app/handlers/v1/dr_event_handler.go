@@ -1,14 +1,20 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 )
@@ -17,83 +23,242 @@ type DREventHandlers interface {
 	CreateDREventHandler(w http.ResponseWriter, r *http.Request) error
 	GetDREventHandler(w http.ResponseWriter, r *http.Request) error
 	UpdateDREventHandler(w http.ResponseWriter, r *http.Request) error
+	PatchDREventHandler(w http.ResponseWriter, r *http.Request) error
 	DeleteDREventHandler(w http.ResponseWriter, r *http.Request) error
+	DispatchDREventHandler(w http.ResponseWriter, r *http.Request) error
+	ActivateDREventHandler(w http.ResponseWriter, r *http.Request) error
+	CompleteDREventHandler(w http.ResponseWriter, r *http.Request) error
+	CancelDREventHandler(w http.ResponseWriter, r *http.Request) error
+	EnrollDREventParticipantsHandler(w http.ResponseWriter, r *http.Request) error
+	GetDREventParticipantsHandler(w http.ResponseWriter, r *http.Request) error
+	OptOutDREventHandler(w http.ResponseWriter, r *http.Request) error
 	GetDREventsByProjectIDHandler(w http.ResponseWriter, r *http.Request) error
     GetDREventsByUtilityIDHandler(w http.ResponseWriter, r *http.Request) error
 }
 
+// DREventChange is what happened to the event a DREventListener is told about
+type DREventChange string
+
+const (
+	DREventCreated      DREventChange = "created"
+	DREventUpdated      DREventChange = "updated" // its times or participants changed
+	DREventTransitioned DREventChange = "transitioned"
+	DREventDeleted      DREventChange = "deleted"
+)
+
+// DREventListener hears about every change made to an event through the API once it is stored. It is called on the
+// request's goroutine, anything slow has to be handed off. A deleted event comes with the participants it had.
+type DREventListener interface {
+	DREventChanged(ctx context.Context, event *models.DREvents, change DREventChange)
+}
+
 type drEventHandlers struct {
-	Repo repositories.DREventRepository
-	Log  *slog.Logger
+	Repo     repositories.DREventRepository
+	Cfg      *config.DREventsConfig
+	Policy   *authz.Policy
+	Listener DREventListener
+	Log      *slog.Logger
 }
 
-func NewDREventHandlers(repo repositories.DREventRepository, log *slog.Logger) DREventHandlers {
-	return &drEventHandlers{Repo: repo, Log: log}
+// NewDREventHandlers creates the event handlers, listener may be nil
+func NewDREventHandlers(repo repositories.DREventRepository, cfg *config.DREventsConfig, policy *authz.Policy, listener DREventListener, log *slog.Logger) DREventHandlers {
+	return &drEventHandlers{Repo: repo, Cfg: cfg, Policy: policy, Listener: listener, Log: log}
 }
 
-func (h *drEventHandlers) GetDREventHandler(w http.ResponseWriter, r *http.Request) error {
-	id := chi.URLParam(r, "id")
+func (h *drEventHandlers) changed(ctx context.Context, event *models.DREvents, change DREventChange) {
+	if h.Listener != nil {
+		h.Listener.DREventChanged(ctx, event, change)
+	}
+}
 
-	drEvent, err := h.Repo.GetDREvent(r.Context(), id)
+// event loads the event and checks the caller may perform a on its utility
+func (h *drEventHandlers) event(r *http.Request, id string, a authz.Action) (*models.DREvents, error) {
+	event, err := h.Repo.GetDREvent(r.Context(), id)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if err := h.Policy.Utility(r.Context(), event.UtilityID, a, "demand response event not found"); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(drEvent)
+func (h *drEventHandlers) GetDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "id")
+
+	drEvent, err := h.event(r, id, authz.Read)
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+
+	return logic.WriteVersioned(w, r, drEvent, drEvent.Version)
 }
 
 func (h *drEventHandlers) CreateDREventHandler(w http.ResponseWriter, r *http.Request) error {
 	var req models.DREvents
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
-	if req.UtilityID == "" || req.StartTime.String() == "" || req.EndTime.String() == "" {
-		return custom_error.New(http.StatusBadRequest, "All fields (utilityId, userId, location) are required", nil)
+	if err := req.Validate(); err != nil {
+		return err
 	}
-	req.ID = uuid.New().String()
-
-	if err := h.Repo.CreateDREvent(r.Context(), &req); err != nil {
+	if err := h.Policy.Utility(r.Context(), req.UtilityID, authz.Write, "Utility id not found"); err != nil {
 		return err
 	}
+	// every event starts as a draft, the status only moves through the action endpoints
+	event := models.DREvents{
+		ID:        uuid.New().String(),
+		UtilityID: req.UtilityID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Status:    models.DREventDraft,
+	}
 
-	if err := json.NewEncoder(w).Encode(req); err != nil {
+	if err := h.Repo.CreateDREvent(r.Context(), &event); err != nil {
 		return err
 	}
+	h.changed(r.Context(), &event, DREventCreated)
 
+	w.Header().Set("Location", "/v1/dr-events/"+event.ID)
+	w.Header().Set("ETag", logic.ETag(event.Version))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	return nil
+	return json.NewEncoder(w).Encode(event)
 }
 
+// UpdateDREventHandler replaces the event's mutable fields with the body
 func (h *drEventHandlers) UpdateDREventHandler(w http.ResponseWriter, r *http.Request) error {
-	var req models.DREvents
+	return h.update(w, r, logic.Replace)
+}
+
+// PatchDREventHandler applies the body to the event as a JSON merge patch
+func (h *drEventHandlers) PatchDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.update(w, r, logic.MergePatch)
+}
+
+// update applies a PUT or PATCH body to the event and writes only the fields it set
+func (h *drEventHandlers) update(w http.ResponseWriter, r *http.Request, apply logic.UpdateFunc) error {
 	id := chi.URLParam(r, "id")
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
-	}
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Demand Response Event ID is required", nil)
 	}
+	event, err := h.event(r, id, authz.Write)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, event.Version); err != nil {
+		return err
+	}
+	fields, err := apply(r, event, event.MutableFields())
+	if err != nil {
+		return err
+	}
+	if err := event.ValidateUpdate(); err != nil {
+		return err
+	}
+	if err := h.Repo.UpdateDREvent(r.Context(), id, event, fields); err != nil {
+		return err
+	}
+	h.changed(r.Context(), event, DREventUpdated)
+	return logic.WriteVersioned(w, r, event, event.Version)
+}
+
+// DispatchDREventHandler schedules a draft event, telling its participants
+func (h *drEventHandlers) DispatchDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.transition(w, r, models.DREventDispatch)
+}
+
+// ActivateDREventHandler starts a scheduled event
+func (h *drEventHandlers) ActivateDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.transition(w, r, models.DREventActivate)
+}
+
+// CompleteDREventHandler ends an active event
+func (h *drEventHandlers) CompleteDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.transition(w, r, models.DREventComplete)
+}
+
+// CancelDREventHandler cancels an event that hasn't ended
+func (h *drEventHandlers) CancelDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.transition(w, r, models.DREventCancel)
+}
+
+// transition applies action to the event and writes the event in its new status
+func (h *drEventHandlers) transition(w http.ResponseWriter, r *http.Request, action models.DREventAction) error {
+	id := chi.URLParam(r, "id")
+	event, err := h.event(r, id, authz.Write)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, event.Version); err != nil {
+		return err
+	}
+	if err := h.Repo.TransitionDREvent(r.Context(), id, event, action, time.Now()); err != nil {
+		return err
+	}
+	h.changed(r.Context(), event, DREventTransitioned)
+	return logic.WriteVersioned(w, r, event, event.Version)
+}
 
-	if req.ID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating Demand Response Event id is not allowed", nil)
+// EnrollDREventParticipantsHandler enrolls the projects the body selects and returns every participant of the event
+func (h *drEventHandlers) EnrollDREventParticipantsHandler(w http.ResponseWriter, r *http.Request) error {
+	var target models.ParticipantTarget
+	if err := logic.DecodeJSON(r, &target); err != nil {
+		return err
+	}
+	if err := target.Validate(); err != nil {
+		return err
 	}
+	event, err := h.event(r, chi.URLParam(r, "id"), authz.Write)
+	if err != nil {
+		return err
+	}
+	participants, err := h.Repo.EnrollProjects(r.Context(), event, target, time.Now())
+	if err != nil {
+		return err
+	}
+	h.changed(r.Context(), event, DREventUpdated)
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(participants)
+}
 
-	if req.ID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating utility ID is not allowed", nil)
+func (h *drEventHandlers) GetDREventParticipantsHandler(w http.ResponseWriter, r *http.Request) error {
+	event, err := h.event(r, chi.URLParam(r, "id"), authz.Read)
+	if err != nil {
+		return err
 	}
-	err := h.Repo.UpdateDREvent(r.Context(), id, &req)
+	participants, err := h.Repo.GetParticipants(r.Context(), event.ID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(participants)
+}
 
+// OptOutDREventHandler withdraws one of the caller's projects from an event, until the cutoff before its start
+func (h *drEventHandlers) OptOutDREventHandler(w http.ResponseWriter, r *http.Request) error {
+	projectID := chi.URLParam(r, "projectID")
+	// homeowners may read every utility's events, the project decides whether they may opt out
+	event, err := h.event(r, chi.URLParam(r, "id"), authz.Read)
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+	if err := h.Policy.Project(r.Context(), projectID, authz.Write, "Project id not found"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := event.CheckOptOut(now, h.Cfg.OptOutCutoff); err != nil {
+		return err
+	}
+
+	participant, err := h.Repo.OptOut(r.Context(), event.ID, projectID, now)
+	if err != nil {
+		return err
+	}
+	h.changed(r.Context(), event, DREventUpdated)
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(participant)
 }
 
 func (h *drEventHandlers) DeleteDREventHandler(w http.ResponseWriter, r *http.Request) error {
@@ -101,11 +266,25 @@ func (h *drEventHandlers) DeleteDREventHandler(w http.ResponseWriter, r *http.Re
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "demand response ID is required", nil)
 	}
-	err := h.Repo.DeleteDREvent(r.Context(), id)
+	event, err := h.event(r, id, authz.Write)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, event.Version); err != nil {
+		return err
+	}
+	// the participants go with the event, the listener still needs to know who was in it
+	if h.Listener != nil {
+		if event.Participants, err = h.Repo.GetParticipants(r.Context(), id); err != nil {
+			return err
+		}
+	}
+	err = h.Repo.DeleteDREvent(r.Context(), id, event.Version)
 
 	if err != nil {
 		return err
 	}
+	h.changed(r.Context(), event, DREventDeleted)
 	w.WriteHeader(http.StatusOK)
 	return nil
 }
@@ -115,16 +294,18 @@ func (h *drEventHandlers) GetDREventsByProjectIDHandler(w http.ResponseWriter, r
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "project ID is required", nil)
 	}
-	events, err := h.Repo.GetDREventsByProjectID(r.Context(), id)
+	if err := h.Policy.Project(r.Context(), id, authz.Read, "Project id not found"); err != nil {
+		return err
+	}
+	filter, page, err := listParams(r)
 	if err != nil {
 		return err
 	}
-
-	if events == nil {
-		events = []models.DREvents{}
+	events, err := h.Repo.GetDREventsByProjectID(r.Context(), id, filter, page)
+	if err != nil {
+		return err
 	}
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(events)
+	return pagination.Write(w, r, events)
 }
 
 func (h *drEventHandlers) GetDREventsByUtilityIDHandler(w http.ResponseWriter, r *http.Request) error {
@@ -132,14 +313,36 @@ func (h *drEventHandlers) GetDREventsByUtilityIDHandler(w http.ResponseWriter, r
     if id == "" {
 		return custom_error.New(http.StatusBadRequest, "project ID is required", nil)
 	}
-    events, err := h.Repo.GetDREventsByUtilityID(r.Context(), id)
+	if err := h.Policy.Utility(r.Context(), id, authz.Read, "Utility id not found"); err != nil {
+		return err
+	}
+	filter, page, err := listParams(r)
+	if err != nil {
+		return err
+	}
+    events, err := h.Repo.GetDREventsByUtilityID(r.Context(), id, filter, page)
 	if err != nil {
 		return err
 	}
+	return pagination.Write(w, r, events)
+}
 
-	if events == nil {
-		events = []models.DREvents{}
+// listParams reads the from, to, status and type filter and the pagination of an event list
+func listParams(r *http.Request) (repositories.DREventFilter, pagination.Params, error) {
+	filter := repositories.DREventFilter{
+		Status:  models.DREventStatus(r.URL.Query().Get("status")),
+		DERType: models.DERType(r.URL.Query().Get("type")),
 	}
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(events)
+	var err error
+	if filter.From, filter.To, err = logic.QueryTimeRange(r, "from", "to"); err != nil {
+		return filter, pagination.Params{}, err
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, pagination.Params{}, custom_error.Validation(custom_error.FieldError{Field: "status", Code: models.RuleOneOf, Message: "must be one of draft, scheduled, active, completed, cancelled"})
+	}
+	if filter.DERType != "" && !filter.DERType.IsValid() {
+		return filter, pagination.Params{}, custom_error.Validation(custom_error.FieldError{Field: "type", Code: models.RuleOneOf, Message: "must be one of solar, battery, ev"})
+	}
+	page, err := pagination.ParseParams(r)
+	return filter, page, err
 }
@@ -1,26 +1,44 @@
 package handlers
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
 
+	"github.com/grid-stream-org/api/internal/app/health"
+	"github.com/grid-stream-org/api/internal/app/logging"
 	"github.com/grid-stream-org/api/internal/custom_error"
 )
 
 // HealthHandler contains the logger for health check handling.
 type HealthHandler struct {
-	Log *slog.Logger
+	Log      *slog.Logger
+	checker  *health.Checker
+	draining atomic.Bool
 }
 
 // NewHealthHandler creates a new instance of HealthHandler.
-func NewHealthHandler(log *slog.Logger) *HealthHandler {
-	return &HealthHandler{Log: log}
+func NewHealthHandler(checker *health.Checker, log *slog.Logger) *HealthHandler {
+	return &HealthHandler{Log: log, checker: checker}
+}
+
+// SetDraining makes the health check fail while the server shuts down,
+// so load balancers stop routing to it before in-flight requests are drained
+func (h *HealthHandler) SetDraining(draining bool) {
+	h.draining.Store(draining)
 }
 
 // HealthCheckHandler handles the health check endpoint.
 // Does not call a repository because it's simple enough
 func (h *HealthHandler) HealthCheckHandler(w http.ResponseWriter, r *http.Request) error {
-	h.Log.Info("Health check endpoint hit")
+	// every request is logged once it completes, this is only worth seeing when debugging
+	logging.FromContext(r.Context(), h.Log).DebugContext(r.Context(), "Health check endpoint hit")
+	if h.draining.Load() {
+		// not an error, just not taking new traffic
+		custom_error.Write(w, r, http.StatusServiceUnavailable, custom_error.CodeUnavailable, "Shutting down")
+		return nil
+	}
 
 	w.WriteHeader(http.StatusOK)
 	_, err := w.Write([]byte("OK"))
@@ -29,3 +47,31 @@ func (h *HealthHandler) HealthCheckHandler(w http.ResponseWriter, r *http.Reques
 	}
     return nil
 }
+
+// LivezHandler reports the process is up, it never touches a dependency and keeps passing
+// while draining so the orchestrator doesn't restart a server that is shutting down cleanly
+func (h *HealthHandler) LivezHandler(w http.ResponseWriter, r *http.Request) error {
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte("OK"))
+	return err
+}
+
+// ReadyzHandler reports whether the API should get traffic, with the status and latency of every
+// dependency. Degraded is still ready, only a critical dependency being down or draining fails it.
+func (h *HealthHandler) ReadyzHandler(w http.ResponseWriter, r *http.Request) error {
+	report := &health.Report{Status: health.StatusDraining, Checks: []health.Result{}}
+	if !h.draining.Load() {
+		report = h.checker.Check(r.Context())
+	}
+
+	code := http.StatusOK
+	if report.Status == health.StatusUnavailable || report.Status == health.StatusDraining {
+		code = http.StatusServiceUnavailable
+		logging.FromContext(r.Context(), h.Log).WarnContext(r.Context(), "not ready", "status", report.Status, "checks", report.Checks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(report)
+}
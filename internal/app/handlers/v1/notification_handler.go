@@ -1,12 +1,12 @@
 package handlers
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
 
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
 	"github.com/grid-stream-org/api/internal/app/repositories"
-	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 )
 
@@ -15,27 +15,31 @@ type NotificationHandler interface {
 }
 
 type notificationtHandler struct {
-	repo repositories.NotificationRepository
-	log  *slog.Logger
+	repo   repositories.NotificationRepository
+	policy *authz.Policy
+	log    *slog.Logger
 }
 
-func NewNotificationHandler(r repositories.NotificationRepository, log *slog.Logger) NotificationHandler {
+func NewNotificationHandler(r repositories.NotificationRepository, policy *authz.Policy, log *slog.Logger) NotificationHandler {
 	return &notificationtHandler{
-		repo: r,
-		log:  log,
+		repo:   r,
+		policy: policy,
+		log:    log,
 	}
 }
 
 func (h *notificationtHandler) NotifyUserHandler(w http.ResponseWriter, r *http.Request) error {
 	var req models.FaultNotification
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
-	if req.ProjectID == "" {
-		return custom_error.New(http.StatusBadRequest, "Project ID must not be empty", nil)
+	if err := req.Validate(); err != nil {
+		return err
 	}
-    
-	// TODO: validate dates and maybe messages
+	if err := h.policy.Project(r.Context(), req.ProjectID, authz.Write, "Project id not found"); err != nil {
+		return err
+	}
+
 	if err := h.repo.NotifyUser(r.Context(), &req); err != nil {
 		return err
 	}
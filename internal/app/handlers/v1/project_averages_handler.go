@@ -1,11 +1,12 @@
 package handlers
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
-	"time"
 
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/app/repositories"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
@@ -17,28 +18,26 @@ type ProjectAverageHandler interface {
 }
 
 type projectAverageHandler struct {
-	repo repositories.ProjectAverageRepository
-	log  *slog.Logger
+	repo   repositories.ProjectAverageRepository
+	policy *authz.Policy
+	log    *slog.Logger
 }
 
-func NewProjectAverageHandlers(repo repositories.ProjectAverageRepository, log *slog.Logger) ProjectAverageHandler {
-	return &projectAverageHandler{repo: repo, log: log}
+func NewProjectAverageHandlers(repo repositories.ProjectAverageRepository, policy *authz.Policy, log *slog.Logger) ProjectAverageHandler {
+	return &projectAverageHandler{repo: repo, policy: policy, log: log}
 }
 
 func (h *projectAverageHandler) CreateProjectAverageHandler(w http.ResponseWriter, r *http.Request) error {
 	var req models.ProjectAverage
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
 
-	// Validate required fields
-	if req.ProjectID == "" || req.StartTime.IsZero() || req.EndTime.IsZero() || req.Baseline <= 0 || req.ContractThreshold <= 0 {
-		return custom_error.New(http.StatusBadRequest, "All fields (project_id, start_time, end_time, baseline, contract_threshold, average_output) are required", nil)
+	if err := req.Validate(); err != nil {
+		return err
 	}
-
-	// Validate time range
-	if req.EndTime.Before(req.StartTime) {
-		return custom_error.New(http.StatusBadRequest, "End time must be after start time", nil)
+	if err := h.policy.Project(r.Context(), req.ProjectID, authz.Write, "Project id not found"); err != nil {
+		return err
 	}
 
 	err := h.repo.CreateProjectAverage(r.Context(), &req)
@@ -51,61 +50,38 @@ func (h *projectAverageHandler) CreateProjectAverageHandler(w http.ResponseWrite
 }
 
 func (h *projectAverageHandler) GetProjectAveragesHandler(w http.ResponseWriter, r *http.Request) error {
-	// Get query parameters
 	projectID := r.URL.Query().Get("project_id")
-	startTimeStr := r.URL.Query().Get("start_time")
-	endTimeStr := r.URL.Query().Get("end_time")
-	
+
 	// Project ID is required
 	if projectID == "" {
 		return custom_error.New(http.StatusBadRequest, "project_id query parameter is required", nil)
 	}
-
-	// If both time parameters are provided, filter by date range
-	if startTimeStr != "" && endTimeStr != "" {
-		startTime, err := time.Parse(time.RFC3339, startTimeStr)
-		if err != nil {
-			return custom_error.New(http.StatusBadRequest, "Invalid start_time format. Use RFC3339 format (e.g., 2006-01-02T15:04:05Z)", err)
-		}
-
-		endTime, err := time.Parse(time.RFC3339, endTimeStr)
-		if err != nil {
-			return custom_error.New(http.StatusBadRequest, "Invalid end_time format. Use RFC3339 format (e.g., 2006-01-02T15:04:05Z)", err)
-		}
-
-		if endTime.Before(startTime) {
-			return custom_error.New(http.StatusBadRequest, "End time must be after start time", nil)
-		}
-
-		averages, err := h.repo.GetProjectAveragesByDateRange(r.Context(), projectID, startTime, endTime)
-		if err != nil {
-			return err
-		}
-
-		if averages == nil {
-			averages = []models.ProjectAverage{}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(averages); err != nil {
-			return err
-		}
-		return nil
+	if err := h.policy.Project(r.Context(), projectID, authz.Read, "Project id not found"); err != nil {
+		return err
 	}
 
-	// If time parameters are not provided, get all averages for the project
-	averages, err := h.repo.GetProjectAveragesByProjectID(r.Context(), projectID)
+	// start_time and end_time are the older range, which keeps the averages inside it oldest first
+	var filter repositories.ProjectAverageFilter
+	fromParam, toParam := "from", "to"
+	if r.URL.Query().Has("start_time") || r.URL.Query().Has("end_time") {
+		fromParam, toParam = "start_time", "end_time"
+		filter.Within = true
+	}
+	var err error
+	if filter.From, filter.To, err = logic.QueryTimeRange(r, fromParam, toParam); err != nil {
+		return err
+	}
+	page, err := pagination.ParseParams(r)
 	if err != nil {
 		return err
 	}
-
-	if averages == nil {
-		averages = []models.ProjectAverage{}
+	if filter.Within && page.Sort == "" {
+		page.Sort = "start_time"
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(averages); err != nil {
+	averages, err := h.repo.GetProjectAveragesByProjectID(r.Context(), projectID, filter, page)
+	if err != nil {
 		return err
 	}
-	return nil
-}
\ No newline at end of file
+	return pagination.Write(w, r, averages)
+}
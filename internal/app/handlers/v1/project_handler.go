@@ -3,11 +3,15 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/app/repositories"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
@@ -17,90 +21,161 @@ type ProjectHandlers interface {
 	CreateProjectHandler(w http.ResponseWriter, r *http.Request) error
 	GetProjectHandler(w http.ResponseWriter, r *http.Request) error
 	UpdateProjectHandler(w http.ResponseWriter, r *http.Request) error
+	PatchProjectHandler(w http.ResponseWriter, r *http.Request) error
 	DeleteProjectHandler(w http.ResponseWriter, r *http.Request) error
+	ListProjectsHandler(w http.ResponseWriter, r *http.Request) error
 }
 
 // ProjectHandlers contains the repository and logger
 type projectHandlers struct {
-	Repo repositories.ProjectRepository
-	Log  *slog.Logger
+	Repo   repositories.ProjectRepository
+	Policy *authz.Policy
+	Log    *slog.Logger
 }
 
 // NewProjectHandlers creates a new instance of ProjectHandlers
-func NewProjectHandlers(repo repositories.ProjectRepository, log *slog.Logger) ProjectHandlers {
-	return &projectHandlers{Repo: repo, Log: log}
+func NewProjectHandlers(repo repositories.ProjectRepository, policy *authz.Policy, log *slog.Logger) ProjectHandlers {
+	return &projectHandlers{Repo: repo, Policy: policy, Log: log}
 }
 
 // GetProjectHandler handles retrieving a project by ID
 func (h *projectHandlers) GetProjectHandler(w http.ResponseWriter, r *http.Request) error {
 	id := chi.URLParam(r, "id") // Get the project ID from the URL
+	if err := h.Policy.Project(r.Context(), id, authz.Read, "Project id not found"); err != nil {
+		return err
+	}
 
 	project, err := h.Repo.GetProject(r.Context(), id)
 	if err != nil {
 		return err
 	}
 
-	// Return the project as JSON
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(project)
+	// Return the project as JSON, with its version as the ETag
+	return logic.WriteVersioned(w, r, project, project.Version)
+}
+
+// ListProjectsHandler lists the projects the caller may see, searched by location and filtered by the query
+func (h *projectHandlers) ListProjectsHandler(w http.ResponseWriter, r *http.Request) error {
+	scope, err := h.Policy.Projects(r.Context())
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+
+	q := r.URL.Query()
+	filter := repositories.ProjectFilter{
+		Scope:     scope,
+		Search:    q.Get("q"),
+		UtilityID: q.Get("utility_id"),
+		UserID:    q.Get("user_id"),
+		DERType:   models.DERType(q.Get("der_type")),
+	}
+	var fields []custom_error.FieldError
+	if s := q.Get("has_active_contract"); s != "" {
+		active, err := strconv.ParseBool(s)
+		if err != nil {
+			fields = append(fields, custom_error.FieldError{Field: "has_active_contract", Code: logic.RuleType, Message: "must be true or false"})
+		}
+		filter.HasActiveContract = &active
+	}
+	if filter.DERType != "" && !filter.DERType.IsValid() {
+		fields = append(fields, custom_error.FieldError{Field: "der_type", Code: models.RuleOneOf, Message: "must be one of solar, battery, ev"})
+	}
+	if len(fields) > 0 {
+		return custom_error.Validation(fields...)
+	}
+	page, err := pagination.ParseParams(r)
+	if err != nil {
+		return err
+	}
+
+	projects, err := h.Repo.ListProjects(r.Context(), filter, page)
+	if err != nil {
+		return err
+	}
+	return pagination.Write(w, r, projects)
 }
 
 func (h *projectHandlers) CreateProjectHandler(w http.ResponseWriter, r *http.Request) error {
 
 	var req models.Project
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
-	if req.UtilityID == "" || req.Location == "" {
-		return custom_error.New(http.StatusBadRequest, "All fields (utilityId, userId, location) are required", nil)
+	if err := req.Validate(); err != nil {
+		return err
 	}
-    proj := &models.Project{
+	proj := &models.Project{
 		ID:        uuid.New().String(),
 		UtilityID: req.UtilityID,
 		UserID:    "",
 		Location:  req.Location,
 	}
 	if err := h.Repo.CreateProject(r.Context(), proj); err != nil {
-        return err
-    }
+		return err
+	}
 
-    if err := json.NewEncoder(w).Encode(*proj); err != nil {
-        return err
-    }
-	
+	w.Header().Set("Location", "/v1/projects/"+proj.ID)
+	w.Header().Set("ETag", logic.ETag(proj.Version))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	return nil
+	return json.NewEncoder(w).Encode(*proj)
 }
 
+// UpdateProjectHandler replaces the project's mutable fields with the body
 func (h *projectHandlers) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) error {
-	var req models.Project
+	return h.update(w, r, logic.Replace)
+}
+
+// PatchProjectHandler applies the body to the project as a JSON merge patch
+func (h *projectHandlers) PatchProjectHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.update(w, r, logic.MergePatch)
+}
+
+// update applies a PUT or PATCH body to the project and writes only the fields it set
+func (h *projectHandlers) update(w http.ResponseWriter, r *http.Request, apply logic.UpdateFunc) error {
 	id := chi.URLParam(r, "id")
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
-	}
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Project ID is required", nil)
 	}
-
-	if req.ID != "" {
-		return custom_error.New(http.StatusBadRequest, "Updating project id is not allowed", nil)
+	// callers who can neither read nor claim the project learn nothing from the body, it isn't looked at
+	if err := h.Policy.Project(r.Context(), id, authz.Read, "Project id not found"); err != nil {
+		if h.Policy.Project(r.Context(), id, authz.Claim, "Project id not found") != nil {
+			return err
+		}
 	}
-	err := h.Repo.UpdateProject(r.Context(), id, &models.Project{
-		UtilityID: req.UtilityID,
-		UserID:    req.UserID,
-		Location:  req.Location,
-	})
-
+	project, err := h.Repo.GetProject(r.Context(), id)
 	if err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusOK)
-	return nil
+	owner := project.UserID
+	fields, err := apply(r, project, project.MutableFields())
+	if err != nil {
+		return err
+	}
+
+	// the write check depends on the user the body sets. Changing it claims the project, which homeowners can only
+	// do for themselves and technicians do when they hand a project over. A denied claim looks like any project the
+	// caller can't see
+	action := authz.Write
+	if project.UserID != owner {
+		if principal, _ := authz.PrincipalFrom(r.Context()); principal.Role != authz.RoleTechnician && project.UserID != principal.UserID {
+			return custom_error.New(http.StatusNotFound, "Project id not found", nil)
+		}
+		action = authz.Claim
+	}
+	if err := h.Policy.Project(r.Context(), id, action, "Project id not found"); err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, project.Version); err != nil {
+		return err
+	}
+	if err := project.ValidateUpdate(); err != nil {
+		return err
+	}
+	if err := h.Repo.UpdateProject(r.Context(), id, project, fields); err != nil {
+		return err
+	}
+	return logic.WriteVersioned(w, r, project, project.Version)
 }
 
 func (h *projectHandlers) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) error {
@@ -108,7 +183,17 @@ func (h *projectHandlers) DeleteProjectHandler(w http.ResponseWriter, r *http.Re
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Project ID is required", nil)
 	}
-	err := h.Repo.DeleteProject(r.Context(), id)
+	if err := h.Policy.Project(r.Context(), id, authz.Write, "Project id not found"); err != nil {
+		return err
+	}
+	project, err := h.Repo.GetProject(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, project.Version); err != nil {
+		return err
+	}
+	err = h.Repo.DeleteProject(r.Context(), id, project.Version)
 
 	if err != nil {
 		return err
@@ -0,0 +1,70 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/handlers/v1"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateProjectHandlerClaims(t *testing.T) {
+	homeowner := authz.Principal{UserID: "home-2", Role: authz.RoleResidential}
+	technician := authz.Principal{UserID: "tech-1", Role: authz.RoleTechnician}
+
+	tests := []struct {
+		name      string
+		principal authz.Principal
+		projectID string
+		body      string
+		status    int
+		owner     string
+	}{
+		{"homeowner claims an unowned project", homeowner, "proj-free", `{"user_id":"home-2"}`, http.StatusOK, "home-2"},
+		{"homeowner claims another's project", homeowner, "proj-owned", `{"user_id":"home-2"}`, http.StatusNotFound, "home-1"},
+		{"homeowner claims for someone else", homeowner, "proj-free", `{"user_id":"home-3"}`, http.StatusNotFound, ""},
+		{"owner hands the project over", authz.Principal{UserID: "home-1", Role: authz.RoleResidential}, "proj-owned", `{"user_id":"home-3"}`, http.StatusNotFound, "home-1"},
+		{"missing project", homeowner, "proj-missing", `{"user_id":"home-3"}`, http.StatusNotFound, ""},
+		{"technician reassigns", technician, "proj-owned", `{"user_id":"home-3"}`, http.StatusOK, "home-3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := memory.NewStore(nil)
+			ctx := context.Background()
+			require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "proj-owned", UtilityID: "util-1", UserID: "home-1"}))
+			require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "proj-free", UtilityID: "util-1"}))
+			handler := handlers.NewProjectHandlers(store.Projects, authz.NewPolicy(store.Projects), nil)
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/projects/"+tc.projectID, bytes.NewBufferString(tc.body))
+			routeCtx := chi.NewRouteContext()
+			routeCtx.URLParams.Add("id", tc.projectID)
+			req = req.WithContext(authz.WithPrincipal(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx), tc.principal))
+			rec := httptest.NewRecorder()
+
+			err := handler.UpdateProjectHandler(rec, req)
+			if tc.status == http.StatusOK {
+				require.NoError(t, err)
+			} else {
+				// a denied claim can't be told apart from a project that doesn't exist
+				customErr, ok := err.(*custom_error.CustomError)
+				require.True(t, ok, "expected custom error, got: %v", err)
+				assert.Equal(t, tc.status, customErr.Code)
+				assert.Equal(t, "Project id not found", customErr.Message)
+			}
+
+			if project, err := store.Projects.GetProject(ctx, tc.projectID); err == nil {
+				assert.Equal(t, tc.owner, project.UserID)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+type RoleHandlers interface {
+	SyncClaimsHandler(w http.ResponseWriter, r *http.Request) error
+	InvalidateRoleHandler(w http.ResponseWriter, r *http.Request) error
+}
+
+type roleHandlers struct {
+	syncer *roles.Syncer
+	cache  *roles.Cache
+	log    *slog.Logger
+}
+
+// NewRoleHandlers creates the admin handlers for user roles, syncer is nil unless auth is backed by firebase
+// and cache is nil when there is no auth provider at all
+func NewRoleHandlers(syncer *roles.Syncer, cache *roles.Cache, log *slog.Logger) RoleHandlers {
+	return &roleHandlers{syncer: syncer, cache: cache, log: log}
+}
+
+// SyncClaimsHandler copies the user's Firestore role into their custom claims and responds with it
+func (h *roleHandlers) SyncClaimsHandler(w http.ResponseWriter, r *http.Request) error {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		return custom_error.New(http.StatusBadRequest, "User ID required", nil)
+	}
+	if h.syncer == nil {
+		return custom_error.New(http.StatusServiceUnavailable, "Custom claims require firebase auth", nil)
+	}
+
+	membership, err := h.syncer.Sync(r.Context(), uid)
+	if errors.Is(err, roles.ErrNoMembership) {
+		return custom_error.New(http.StatusNotFound, "User has no role", err)
+	}
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to sync custom claims", err)
+	}
+	logging.FromContext(r.Context(), h.log).InfoContext(r.Context(), "synced custom claims", "uid", uid, "role", membership.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(membership)
+}
+
+// InvalidateRoleHandler drops the cached role of the user, for when the role store was edited directly
+func (h *roleHandlers) InvalidateRoleHandler(w http.ResponseWriter, r *http.Request) error {
+	uid := chi.URLParam(r, "uid")
+	if uid == "" {
+		return custom_error.New(http.StatusBadRequest, "User ID required", nil)
+	}
+	if h.cache != nil {
+		h.cache.Invalidate(uid)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -7,6 +7,9 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/app/repositories"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
@@ -16,18 +19,22 @@ type UtilityHandler interface {
 	CreateUtilityHandler(w http.ResponseWriter, r *http.Request) error
 	GetUtilityHandler(w http.ResponseWriter, r *http.Request) error
 	UpdateUtilityHandler(w http.ResponseWriter, r *http.Request) error
+	PatchUtilityHandler(w http.ResponseWriter, r *http.Request) error
 	DeleteUtilityHandler(w http.ResponseWriter, r *http.Request) error
 	GetProjectSummaryHandler(w http.ResponseWriter, r *http.Request) error
+	ListUtilitiesHandler(w http.ResponseWriter, r *http.Request) error
 }
 
 type utilityHandler struct {
 	Repo   repositories.UtilityRepository
+	Policy *authz.Policy
 	logger *slog.Logger
 }
 
-func NewUtilityRepository(repo repositories.UtilityRepository, logger *slog.Logger) UtilityHandler {
+func NewUtilityRepository(repo repositories.UtilityRepository, policy *authz.Policy, logger *slog.Logger) UtilityHandler {
 	return &utilityHandler{
 		Repo:   repo,
+		Policy: policy,
 		logger: logger,
 	}
 }
@@ -38,6 +45,10 @@ func (handler *utilityHandler) GetUtilityHandler(w http.ResponseWriter, r *http.
 		return custom_error.New(http.StatusBadRequest, "ID is required", errors.New("ID is required"))
 	}
 
+	if err := handler.Policy.Utility(r.Context(), id, authz.Read, "Utility id not found"); err != nil {
+		return err
+	}
+
 	var util *models.Utility
 
 	util, err := handler.Repo.GetUtility(r.Context(), id)
@@ -45,55 +56,86 @@ func (handler *utilityHandler) GetUtilityHandler(w http.ResponseWriter, r *http.
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(util)
-	if err != nil {
+	if err := logic.WriteVersioned(w, r, util, util.Version); err != nil {
 		return custom_error.New(http.StatusInternalServerError, "Failed to encode utility into json", err)
 	}
-	w.WriteHeader(http.StatusOK)
-
 	return nil
 }
 
+// ListUtilitiesHandler lists the utilities the caller may see, searched by display name
+func (handler *utilityHandler) ListUtilitiesHandler(w http.ResponseWriter, r *http.Request) error {
+	scope, err := handler.Policy.Utilities(r.Context())
+	if err != nil {
+		return err
+	}
+	page, err := pagination.ParseParams(r)
+	if err != nil {
+		return err
+	}
+
+	utilities, err := handler.Repo.ListUtilities(r.Context(), repositories.UtilityFilter{Scope: scope, Search: r.URL.Query().Get("q")}, page)
+	if err != nil {
+		return err
+	}
+	return pagination.Write(w, r, utilities)
+}
+
 func (handler *utilityHandler) CreateUtilityHandler(w http.ResponseWriter, r *http.Request) error {
 	var req models.Utility
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
 	}
-	if req.DisplayName == "" {
-		return custom_error.New(http.StatusBadRequest, "Display name required", errors.New("Display name not provided"))
+	if err := req.Validate(); err != nil {
+		return err
 	}
 
-	if err := handler.Repo.CreateUtility(r.Context(), &models.Utility{DisplayName: req.DisplayName}); err != nil {
+	util := &models.Utility{DisplayName: req.DisplayName}
+	if err := handler.Repo.CreateUtility(r.Context(), util); err != nil {
 		return err
 	}
 
+	w.Header().Set("Location", "/v1/utilities/"+util.ID)
 	w.WriteHeader(http.StatusCreated)
 	return nil
 }
 
+// UpdateUtilityHandler replaces the utility's mutable fields with the body
 func (handler *utilityHandler) UpdateUtilityHandler(w http.ResponseWriter, r *http.Request) error {
-	var req models.Utility
+	return handler.update(w, r, logic.Replace)
+}
+
+// PatchUtilityHandler applies the body to the utility as a JSON merge patch
+func (handler *utilityHandler) PatchUtilityHandler(w http.ResponseWriter, r *http.Request) error {
+	return handler.update(w, r, logic.MergePatch)
+}
+
+// update applies a PUT or PATCH body to the utility and writes only the fields it set
+func (handler *utilityHandler) update(w http.ResponseWriter, r *http.Request, apply logic.UpdateFunc) error {
 	id := chi.URLParam(r, "id")
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return custom_error.New(http.StatusBadRequest, "Invalid request payload", err)
+	if id == "" {
+		return custom_error.New(http.StatusBadRequest, "Utlity ID required", nil)
 	}
-	if req.DisplayName == "" {
-		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
+	if err := handler.Policy.Utility(r.Context(), id, authz.Write, "Utility id not found"); err != nil {
+		return err
 	}
-	if req.ID != "" {
-		return custom_error.New(http.StatusBadRequest, "Not permitted to update Utility ID", nil)
+	util, err := handler.Repo.GetUtility(r.Context(), id)
+	if err != nil {
+		return err
 	}
-	if id == "" {
-		return custom_error.New(http.StatusBadRequest, "Utlity ID required", nil)
+	if err := logic.CheckIfMatch(r, util.Version); err != nil {
+		return err
 	}
-	if err := handler.Repo.UpdateUtility(r.Context(), id, &models.Utility{DisplayName: req.DisplayName}); err != nil {
+	fields, err := apply(r, util, util.MutableFields())
+	if err != nil {
 		return err
 	}
-
-	w.WriteHeader(http.StatusOK)
-
-	return nil
+	if err := util.Validate(); err != nil {
+		return err
+	}
+	if err := handler.Repo.UpdateUtility(r.Context(), id, util, fields); err != nil {
+		return err
+	}
+	return logic.WriteVersioned(w, r, util, util.Version)
 }
 
 func (handler *utilityHandler) DeleteUtilityHandler(w http.ResponseWriter, r *http.Request) error {
@@ -102,7 +144,17 @@ func (handler *utilityHandler) DeleteUtilityHandler(w http.ResponseWriter, r *ht
 	if id == "" {
 		return custom_error.New(http.StatusBadRequest, "Utlity ID required", errors.New("Utlity ID required"))
 	}
-	if err := handler.Repo.DeleteUtility(r.Context(), id); err != nil {
+	if err := handler.Policy.Utility(r.Context(), id, authz.Write, "Utility id not found"); err != nil {
+		return err
+	}
+	util, err := handler.Repo.GetUtility(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if err := logic.CheckIfMatch(r, util.Version); err != nil {
+		return err
+	}
+	if err := handler.Repo.DeleteUtility(r.Context(), id, util.Version); err != nil {
 		return err
 	}
 	w.WriteHeader(http.StatusOK)
@@ -114,6 +166,9 @@ func (handler *utilityHandler) GetProjectSummaryHandler(w http.ResponseWriter, r
 	if utilityID == "" {
 		return custom_error.New(http.StatusBadRequest, "utility_id query parameter is required", nil)
 	}
+	if err := handler.Policy.Utility(r.Context(), utilityID, authz.Read, "Utility id not found"); err != nil {
+		return err
+	}
 
 	summaries, err := handler.Repo.GetProjectSummary(r.Context(), utilityID)
 	if err != nil {
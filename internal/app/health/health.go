@@ -0,0 +1,124 @@
+// Package health probes the API's dependencies for the readiness endpoint.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/config"
+)
+
+// Status of a single dependency or of the API as a whole
+type Status string
+
+const (
+	StatusOK          Status = "ok"
+	StatusDown        Status = "down"
+	StatusDegraded    Status = "degraded"    // only non-critical dependencies are down
+	StatusUnavailable Status = "unavailable" // a critical dependency is down
+	StatusDraining    Status = "draining"    // shutting down, not taking new traffic
+)
+
+// Probe returns nil when the dependency is usable
+type Probe func(ctx context.Context) error
+
+// Check is a dependency the API needs, the API can still serve most requests while
+// a non-critical one is down
+type Check struct {
+	Name     string
+	Critical bool
+	Probe    Probe
+}
+
+type Result struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status    Status    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Checker runs every check concurrently and caches the report, so load balancers polling
+// readiness don't turn into a query per poll against BigQuery and Firestore
+type Checker struct {
+	checks []Check
+	cfg    *config.HealthConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	report  *Report
+	expires time.Time
+}
+
+func NewChecker(cfg *config.HealthConfig, checks ...Check) *Checker {
+	return &Checker{checks: checks, cfg: cfg, now: time.Now}
+}
+
+// Check returns the cached report, probing the dependencies again once it has expired
+func (c *Checker) Check(ctx context.Context) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.report != nil && now.Before(c.expires) {
+		return c.report
+	}
+
+	// a caller hanging up mustn't cache every dependency as down
+	ctx = context.WithoutCancel(ctx)
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	c.report = &Report{Status: summarize(results), CheckedAt: now, Checks: results}
+	c.expires = now.Add(c.cfg.CacheTTL)
+	return c.report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusOK,
+		Critical:  check.Critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func summarize(results []Result) Status {
+	status := StatusOK
+	for _, r := range results {
+		if r.Status == StatusOK {
+			continue
+		}
+		if r.Critical {
+			return StatusUnavailable
+		}
+		status = StatusDegraded
+	}
+	return status
+}
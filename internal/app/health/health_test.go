@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(err error) Probe {
+	return func(ctx context.Context) error { return err }
+}
+
+func TestCheckerStatus(t *testing.T) {
+	down := errors.New("connection refused")
+	cfg := &config.HealthConfig{Timeout: time.Second}
+
+	tests := []struct {
+		name   string
+		checks []Check
+		status Status
+	}{
+		{"no dependencies", nil, StatusOK},
+		{"all up", []Check{{Name: "bigquery", Critical: true, Probe: probe(nil)}, {Name: "notifications", Probe: probe(nil)}}, StatusOK},
+		{"non-critical down", []Check{{Name: "bigquery", Critical: true, Probe: probe(nil)}, {Name: "notifications", Probe: probe(down)}}, StatusDegraded},
+		{"critical down", []Check{{Name: "bigquery", Critical: true, Probe: probe(down)}, {Name: "notifications", Probe: probe(down)}}, StatusUnavailable},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			report := NewChecker(cfg, tc.checks...).Check(context.Background())
+			assert.Equal(t, tc.status, report.Status)
+			assert.Len(t, report.Checks, len(tc.checks))
+		})
+	}
+}
+
+func TestCheckerTimeout(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	checker := NewChecker(&config.HealthConfig{Timeout: 20 * time.Millisecond}, Check{Name: "firestore", Critical: true, Probe: hang})
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusUnavailable, report.Status)
+	assert.Equal(t, StatusDown, report.Checks[0].Status)
+	assert.Contains(t, report.Checks[0].Error, "deadline exceeded")
+}
+
+func TestCheckerCache(t *testing.T) {
+	var calls atomic.Int32
+	counted := func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}
+	checker := NewChecker(&config.HealthConfig{Timeout: time.Second, CacheTTL: time.Minute}, Check{Name: "bigquery", Probe: counted})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return now }
+
+	checker.Check(context.Background())
+	checker.Check(context.Background())
+	assert.Equal(t, int32(1), calls.Load(), "Report should be cached")
+
+	now = now.Add(2 * time.Minute)
+	checker.Check(context.Background())
+	assert.Equal(t, int32(2), calls.Load(), "Expired report should be probed again")
+
+	// a cancelled request still gets a real answer
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, StatusOK, checker.Check(ctx).Status)
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/firestore"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// FirebaseKeySetURL is where Firebase publishes the certificates ID tokens are signed with
+const FirebaseKeySetURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+
+// BigQueryDryRun validates query against the dataset without running it, dry runs are free
+// but still need the credentials, the dataset and the table to be there
+func BigQueryDryRun(client *bigquery.Client, query string) Probe {
+	return func(ctx context.Context) error {
+		q := client.Query(query)
+		q.DryRun = true
+		job, err := q.Run(ctx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if status := job.LastStatus(); status != nil && status.Err() != nil {
+			return errors.WithStack(status.Err())
+		}
+		return nil
+	}
+}
+
+// FirestoreCollection reads at most one document of the collection
+func FirestoreCollection(client *firestore.Client, collection string) Probe {
+	return func(ctx context.Context) error {
+		iter := client.Collection(collection).Limit(1).Documents(ctx)
+		defer iter.Stop()
+		if _, err := iter.Next(); err != nil && err != iterator.Done {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+}
+
+// KeySet fetches the public keys tokens are verified against
+func KeySet(client *http.Client, url string) Probe {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("key set returned %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,212 @@
+// Package idempotency makes POST routes safe to retry. The first response to a request sent with an
+// Idempotency-Key header is stored and replayed to retries with the same key and payload, so a client on a
+// flaky connection doesn't create the same contract or DR event twice.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Header carries the client's key, any string up to maxKeyLength unique to the operation, a UUID works well
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from the store
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// responses larger than this aren't stored, the key is released instead
+	maxStoredBody = 1 << 20
+)
+
+type Middleware struct {
+	repo repositories.IdempotencyRepository
+	cfg  *config.IdempotencyConfig
+	log  *slog.Logger
+	now  func() time.Time
+}
+
+func New(repo repositories.IdempotencyRepository, cfg *config.IdempotencyConfig, log *slog.Logger) *Middleware {
+	return &Middleware{repo: repo, cfg: cfg, log: log, now: time.Now}
+}
+
+// Handler honors the Idempotency-Key header, requests without it pass straight through.
+// Keys are scoped to the caller, so it has to run after authentication.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		principal, ok := authz.PrincipalFrom(r.Context())
+		if key == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			custom_error.WriteProblem(w, r, custom_error.New(http.StatusBadRequest, "Idempotency-Key must be at most 255 characters", nil).
+				WithCode(custom_error.CodeInvalidIdempotency))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			custom_error.WriteProblem(w, r, custom_error.InvalidPayload(err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := m.now()
+		rec := &models.IdempotencyRecord{
+			Scope:       scope(principal),
+			Key:         key,
+			RequestHash: requestHash(r, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(m.cfg.TTL),
+		}
+		existing, err := m.repo.ReserveIdempotencyKey(r.Context(), rec)
+		if err != nil {
+			// a reservation that raced another one is answered like the key being in progress
+			var customErr *custom_error.CustomError
+			if errors.As(err, &customErr) && customErr.Code == http.StatusConflict {
+				w.Header().Set("Retry-After", "1")
+			}
+			custom_error.WriteProblem(w, r, err)
+			return
+		}
+		if existing != nil {
+			m.replay(w, r, rec, existing)
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			// failed and panicking requests give the key back, the client may retry them with it
+			if !completed {
+				m.release(r.Context(), rec)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+
+		status := rw.statusCode()
+		if status < 200 || status >= 300 || rw.overflow {
+			return
+		}
+		rec.Status = status
+		rec.ContentType = rw.Header().Get("Content-Type")
+		rec.Location = rw.Header().Get("Location")
+		if rec.Location != "" {
+			rec.ResourceID = path.Base(rec.Location)
+		}
+		rec.Body = rw.body.String()
+		// the response is already sent, store it even if the client went away
+		if err := m.repo.CompleteIdempotencyKey(context.WithoutCancel(r.Context()), rec); err != nil {
+			logging.FromContext(r.Context(), m.log).Error("failed to store idempotent response", "err", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// replay answers a retry from the record of the first request
+func (m *Middleware) replay(w http.ResponseWriter, r *http.Request, rec *models.IdempotencyRecord, existing *models.IdempotencyRecord) {
+	switch {
+	case existing.RequestHash != rec.RequestHash:
+		custom_error.WriteProblem(w, r, custom_error.New(http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request", nil).
+			WithCode(custom_error.CodeIdempotencyReused))
+	case !existing.Completed():
+		w.Header().Set("Retry-After", "1")
+		custom_error.WriteProblem(w, r, custom_error.New(http.StatusConflict, "A request with this Idempotency-Key is still being processed", nil).
+			WithCode(custom_error.CodeIdempotencyBusy))
+	default:
+		if existing.ContentType != "" {
+			w.Header().Set("Content-Type", existing.ContentType)
+		}
+		if existing.Location != "" {
+			w.Header().Set("Location", existing.Location)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(existing.Status)
+		_, _ = io.WriteString(w, existing.Body)
+	}
+}
+
+func (m *Middleware) release(ctx context.Context, rec *models.IdempotencyRecord) {
+	if err := m.repo.ReleaseIdempotencyKey(context.WithoutCancel(ctx), rec.Scope, rec.Key); err != nil {
+		logging.FromContext(ctx, m.log).Error("failed to release idempotency key", "err", err)
+	}
+}
+
+// scope keeps the keys of different callers apart, services by their API key and users by their id
+func scope(p authz.Principal) string {
+	if p.IsService() {
+		return "key:" + p.KeyID
+	}
+	return "user:" + p.UserID
+}
+
+// requestHash fingerprints the request a key was first used for. JSON bodies are compacted so a retry
+// that only differs in whitespace is still the same request.
+func requestHash(r *http.Request, body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder passes the response through while keeping a copy of its status and body
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if rw.body.Len()+len(b) > maxStoredBody {
+		rw.overflow = true
+	} else if !rw.overflow {
+		rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recorder) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *recorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
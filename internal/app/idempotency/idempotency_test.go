@@ -0,0 +1,116 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creator is a create handler that counts its calls, failing while fail is set
+type creator struct {
+	calls int
+	fail  bool
+}
+
+func (c *creator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	if c.fail {
+		custom_error.WriteProblem(w, r, custom_error.New(http.StatusInternalServerError, "Failed to create", nil))
+		return
+	}
+	w.Header().Set("Location", "/v1/contracts/c-1")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"id":"c-1"}`))
+}
+
+func post(handler http.Handler, user string, key string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/contracts/", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	r = r.WithContext(authz.WithPrincipal(r.Context(), authz.Principal{UserID: user, Role: authz.RoleResidential}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func newHandler(t *testing.T, next http.Handler) (http.Handler, *Middleware) {
+	t.Helper()
+	m := New(memory.NewStore(nil).Idempotency, &config.IdempotencyConfig{TTL: time.Hour}, nil)
+	return m.Handler(next), m
+}
+
+func TestReplay(t *testing.T) {
+	c := &creator{}
+	handler, _ := newHandler(t, c)
+
+	first := post(handler, "home-1", "k-1", `{"project_id": "p-1"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	// whitespace doesn't make it another request
+	retry := post(handler, "home-1", "k-1", `{"project_id":"p-1"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"id":"c-1"}`, retry.Body.String())
+	assert.Equal(t, "/v1/contracts/c-1", retry.Header().Get("Location"))
+	assert.Equal(t, "true", retry.Header().Get(ReplayedHeader))
+	assert.Equal(t, 1, c.calls, "the retry should not reach the handler")
+
+	// the same key with another payload
+	rec := post(handler, "home-1", "k-1", `{"project_id": "p-2"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), custom_error.CodeIdempotencyReused)
+
+	// keys are per caller, and requests without one are never replayed
+	assert.Equal(t, http.StatusCreated, post(handler, "home-2", "k-1", `{"project_id": "p-1"}`).Code)
+	assert.Equal(t, http.StatusCreated, post(handler, "home-1", "", `{"project_id": "p-1"}`).Code)
+	assert.Equal(t, 3, c.calls)
+}
+
+func TestFailedRequestsReleaseTheKey(t *testing.T) {
+	c := &creator{fail: true}
+	handler, _ := newHandler(t, c)
+
+	assert.Equal(t, http.StatusInternalServerError, post(handler, "home-1", "k-1", `{}`).Code)
+	c.fail = false
+	assert.Equal(t, http.StatusCreated, post(handler, "home-1", "k-1", `{}`).Code)
+	assert.Equal(t, 2, c.calls)
+}
+
+func TestExpiredKeysAreReused(t *testing.T) {
+	c := &creator{}
+	handler, m := newHandler(t, c)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	post(handler, "home-1", "k-1", `{}`)
+	now = now.Add(2 * time.Hour)
+	rec := post(handler, "home-1", "k-1", `{"other": true}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get(ReplayedHeader))
+	assert.Equal(t, 2, c.calls)
+}
+
+func TestInProgress(t *testing.T) {
+	var handler http.Handler
+	var nested *httptest.ResponseRecorder
+	// the retry arrives while the first request is still in the handler
+	handler, _ = newHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nested = post(handler, "home-1", "k-1", `{}`)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	assert.Equal(t, http.StatusCreated, post(handler, "home-1", "k-1", `{}`).Code)
+	require.NotNil(t, nested)
+	assert.Equal(t, http.StatusConflict, nested.Code)
+	assert.Equal(t, "1", nested.Header().Get("Retry-After"))
+}
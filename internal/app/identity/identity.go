@@ -0,0 +1,39 @@
+// Package identity verifies the bearer tokens users authenticate with. Firebase ID tokens are the
+// default, locally signed JWTs let the whole stack, auth included, run offline.
+package identity
+
+import (
+	"context"
+
+	"firebase.google.com/go/auth"
+	"github.com/grid-stream-org/api/internal/app/tracing"
+)
+
+// Token is a verified bearer token
+type Token struct {
+	UID    string
+	Claims map[string]interface{}
+}
+
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*Token, error)
+}
+
+type firebaseVerifier struct {
+	client *auth.Client
+}
+
+// NewFirebaseVerifier verifies Firebase ID tokens, claims are the token's custom claims
+func NewFirebaseVerifier(client *auth.Client) TokenVerifier {
+	return &firebaseVerifier{client: client}
+}
+
+func (v *firebaseVerifier) VerifyIDToken(ctx context.Context, idToken string) (*Token, error) {
+	ctx, span := tracing.Start(ctx, "firebase.auth.VerifyIDToken")
+	token, err := v.client.VerifyIDToken(ctx, idToken)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{UID: token.UID, Claims: token.Claims}, nil
+}
@@ -0,0 +1,152 @@
+package identity
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type localVerifier struct {
+	secret  []byte
+	keys    map[string]*rsa.PublicKey // by kid
+	methods []string
+	options []jwt.ParserOption
+}
+
+// NewLocalVerifier verifies JWTs signed with the configured keys, the uid is the sub claim
+func NewLocalVerifier(cfg *config.LocalAuthConfig) (TokenVerifier, error) {
+	v := &localVerifier{}
+	if cfg.HS256Secret != "" {
+		v.secret = []byte(cfg.HS256Secret)
+		v.methods = append(v.methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		v.methods = append(v.methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	v.options = []jwt.ParserOption{jwt.WithValidMethods(v.methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		v.options = append(v.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		v.options = append(v.options, jwt.WithAudience(cfg.Audience))
+	}
+	return v, nil
+}
+
+func (v *localVerifier) VerifyIDToken(ctx context.Context, idToken string) (*Token, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, claims, v.key, v.options...); err != nil {
+		return nil, errors.Wrap(err, "verifying token")
+	}
+	uid, err := claims.GetSubject()
+	if err != nil || uid == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Token{UID: uid, Claims: claims}, nil
+}
+
+func (v *localVerifier) key(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		return v.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	// a JWKS with a single key doesn't need tokens to name it
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key: %q", kid)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJWKS reads the RSA public keys of a JWKS file
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading jwks file")
+	}
+	var set jwks
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, errors.Wrap(err, "parsing jwks file")
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding modulus of key %q", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding exponent of key %q", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks file has no RSA keys")
+	}
+	return keys, nil
+}
+
+type yamlStore struct {
+	users map[string]*roles.Membership
+}
+
+// NewYAMLRoleStore loads memberships from a file of the form
+//
+//	users:
+//	  <uid>:
+//	    role: Utility
+//	    utility_id: <utility id>
+func NewYAMLRoleStore(path string) (roles.Store, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading roles file")
+	}
+	var file struct {
+		Users map[string]*roles.Membership `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, errors.Wrap(err, "parsing roles file")
+	}
+	return &yamlStore{users: file.Users}, nil
+}
+
+func (s *yamlStore) Lookup(ctx context.Context, uid string) (*roles.Membership, error) {
+	m, ok := s.users[uid]
+	if !ok || m == nil || m.Role == "" {
+		return nil, roles.ErrNoMembership
+	}
+	return m, nil
+}
@@ -0,0 +1,131 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestLocalVerifierHS256(t *testing.T) {
+	verifier, err := NewLocalVerifier(&config.LocalAuthConfig{HS256Secret: "secret", Issuer: "gridstream-local"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour).Unix()
+
+	token, err := verifier.VerifyIDToken(ctx, sign(t, jwt.SigningMethodHS256, []byte("secret"), "",
+		jwt.MapClaims{"sub": "user-1", "iss": "gridstream-local", "exp": exp, "role": "Utility"}))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", token.UID)
+	assert.Equal(t, "Utility", token.Claims["role"])
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, []byte("other"), "", jwt.MapClaims{"sub": "user-1", "iss": "gridstream-local", "exp": exp})},
+		{"expired", sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": "user-1", "iss": "gridstream-local", "exp": time.Now().Add(-time.Minute).Unix()})},
+		{"no expiry", sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": "user-1", "iss": "gridstream-local"})},
+		{"wrong issuer", sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": "user-1", "iss": "elsewhere", "exp": exp})},
+		{"no subject", sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"iss": "gridstream-local", "exp": exp})},
+		{"garbage", "not-a-jwt"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifier.VerifyIDToken(ctx, tc.token)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLocalVerifierRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	raw, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kid": "key-1",
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(jwksFile, raw, 0o600))
+
+	verifier, err := NewLocalVerifier(&config.LocalAuthConfig{JWKSFile: jwksFile})
+	require.NoError(t, err)
+	ctx := context.Background()
+	claims := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	token, err := verifier.VerifyIDToken(ctx, sign(t, jwt.SigningMethodRS256, key, "key-1", claims))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", token.UID)
+
+	_, err = verifier.VerifyIDToken(ctx, sign(t, jwt.SigningMethodRS256, key, "", claims))
+	assert.NoError(t, err, "A single key should be used when the token names none")
+
+	_, err = verifier.VerifyIDToken(ctx, sign(t, jwt.SigningMethodRS256, other, "key-1", claims))
+	assert.Error(t, err)
+	_, err = verifier.VerifyIDToken(ctx, sign(t, jwt.SigningMethodRS256, key, "key-2", claims))
+	assert.Error(t, err)
+
+	// without an HS256 secret configured HS256 tokens must not be accepted
+	_, err = verifier.VerifyIDToken(ctx, sign(t, jwt.SigningMethodHS256, []byte(""), "", claims))
+	assert.Error(t, err)
+}
+
+func TestYAMLRoleStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+users:
+  util-user-1:
+    role: Utility
+    utility_id: util-1
+  home-1:
+    role: Residential
+    project_ids: [proj-1]
+  nobody:
+`), 0o600))
+
+	store, err := NewYAMLRoleStore(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	m, err := store.Lookup(ctx, "util-user-1")
+	require.NoError(t, err)
+	assert.Equal(t, &roles.Membership{Role: "Utility", UtilityID: "util-1"}, m)
+
+	m, err = store.Lookup(ctx, "home-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"proj-1"}, m.ProjectIDs)
+
+	_, err = store.Lookup(ctx, "nobody")
+	assert.ErrorIs(t, err, roles.ErrNoMembership)
+	_, err = store.Lookup(ctx, "user-404")
+	assert.ErrorIs(t, err, roles.ErrNoMembership)
+}
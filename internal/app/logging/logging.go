@@ -0,0 +1,145 @@
+// Package logging scopes the app's slog logger to a request. The request middleware puts a logger
+// carrying the request id in the context, auth adds the caller, and handlers and repositories pull it
+// back out with FromContext so every line they log can be tied to the request that caused it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+type loggerKey struct{}
+
+type entryKey struct{}
+
+// entry collects attributes added further down the chain, like the caller once auth has run,
+// for the line logged when the request completes
+type entry struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// FromContext returns the request's logger, or fallback outside of a request
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// NewContext returns ctx carrying log
+func NewContext(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// With adds attributes to the request's logger and to its completed request line
+func With(ctx context.Context, fallback *slog.Logger, args ...any) context.Context {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		record := slog.Record{}
+		record.Add(args...)
+		e.mu.Lock()
+		record.Attrs(func(a slog.Attr) bool {
+			e.attrs = append(e.attrs, a)
+			return true
+		})
+		e.mu.Unlock()
+	}
+	return NewContext(ctx, FromContext(ctx, fallback).With(args...))
+}
+
+// Middleware logs one line per request once it completes, with the request id, route pattern,
+// caller, status, latency and bytes written. It needs middleware.RequestID to run first.
+func Middleware(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLog := log.With("request_id", middleware.GetReqID(r.Context()))
+			e := &entry{}
+			ctx := context.WithValue(NewContext(r.Context(), reqLog), entryKey{}, e)
+
+			if reqLog.Enabled(ctx, slog.LevelDebug) {
+				reqLog.DebugContext(ctx, "request started",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"headers", RedactHeaders(r.Header),
+				)
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := ""
+			if rctx := chi.RouteContext(ctx); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.String("remote_ip", remoteIP(r)),
+				slog.String("user_agent", r.UserAgent()),
+			}
+			e.mu.Lock()
+			attrs = append(attrs, e.attrs...)
+			e.mu.Unlock()
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			reqLog.LogAttrs(ctx, level, "request completed", attrs...)
+		})
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// Recoverer turns a panic into a 500 and logs it, with its stack, through the request's logger
+func Recoverer(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// the server aborts the response on this one, like net/http does
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				FromContext(r.Context(), log).ErrorContext(r.Context(), "panic serving request",
+					"panic", fmt.Sprint(rec),
+					"stack", string(debug.Stack()),
+				)
+				custom_error.Write(w, r, http.StatusInternalServerError, custom_error.CodeInternal, http.StatusText(http.StatusInternalServerError))
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(Middleware(log))
+	r.Get("/v1/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// what auth does once it knows the caller
+		ctx := With(r.Context(), log, "user_id", "home-1", "role", "Residential")
+		FromContext(ctx, log).InfoContext(ctx, "loaded project")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("Project not found"))
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/projects/proj-1", nil))
+
+	logged := lines(t, &buf)
+	require.Len(t, logged, 2)
+	handler, done := logged[0], logged[1]
+	assert.Equal(t, "loaded project", handler["msg"])
+	assert.NotEmpty(t, handler["request_id"])
+	assert.Equal(t, "home-1", handler["user_id"])
+
+	assert.Equal(t, "request completed", done["msg"])
+	assert.Equal(t, "WARN", done["level"])
+	assert.Equal(t, handler["request_id"], done["request_id"])
+	assert.Equal(t, "/v1/projects/{id}", done["route"])
+	assert.Equal(t, float64(http.StatusNotFound), done["status"])
+	assert.Equal(t, float64(len("Project not found")), done["bytes"])
+	assert.Equal(t, "home-1", done["user_id"])
+	assert.Equal(t, "Residential", done["role"])
+	assert.Contains(t, done, "latency_ms")
+}
+
+func TestRedaction(t *testing.T) {
+	headers := RedactHeaders(http.Header{
+		"Authorization": {"Bearer eyJhbGciOi"},
+		"X-Api-Key":     {"gsk_abc"},
+		"Accept":        {"application/json"},
+	})
+	assert.Equal(t, map[string]string{"Authorization": redacted, "X-Api-Key": redacted, "Accept": "application/json"}, headers)
+
+	var buf bytes.Buffer
+	log := slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil)))
+	log.With("api_key", "gsk_abc").Info("calling", "id_token", "eyJhbGciOi", "user_id", "home-1",
+		slog.Group("request", "authorization", "Bearer eyJhbGciOi", "path", "/v1/projects"))
+
+	assert.NotContains(t, buf.String(), "gsk_abc")
+	assert.NotContains(t, buf.String(), "eyJhbGciOi")
+	logged := lines(t, &buf)[0]
+	assert.Equal(t, redacted, logged["api_key"])
+	assert.Equal(t, redacted, logged["id_token"])
+	assert.Equal(t, "home-1", logged["user_id"])
+	assert.Equal(t, "/v1/projects", logged["request"].(map[string]any)["path"])
+}
+
+func TestRecoverer(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := Recoverer(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	logged := lines(t, &buf)[0]
+	assert.Equal(t, "nil map", logged["panic"])
+	assert.Contains(t, logged["stack"], "TestRecoverer")
+}
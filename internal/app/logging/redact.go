@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// headers that carry credentials, never logged as is
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// attribute keys that look like they hold a credential, matched anywhere in the key
+var sensitiveKeys = []string{"authorization", "password", "secret", "token", "api_key", "apikey", "cookie"}
+
+// RedactHeaders flattens h for logging with every credential replaced
+func RedactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHandler replaces the value of any attribute whose key looks like a credential, a backstop
+// for a token or key ending up in a log call by accident
+type redactHandler struct {
+	slog.Handler
+}
+
+// NewRedactingHandler wraps h so attributes like "token" or "api_key" are logged as [REDACTED]
+func NewRedactingHandler(h slog.Handler) slog.Handler {
+	return redactHandler{Handler: h}
+}
+
+func (h redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	clean := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, clean)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = redact(a)
+	}
+	return redactHandler{Handler: h.Handler.WithAttrs(clean)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redact(a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		clean := make([]any, len(group))
+		for i, g := range group {
+			clean[i] = redact(g)
+		}
+		return slog.Group(a.Key, clean...)
+	}
+	return a
+}
@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+// RuleUnknown and RuleType are the field error codes of a body that doesn't fit the payload struct
+const (
+	RuleUnknown = "unknown"
+	RuleType    = "type"
+)
+
+// DecodeJSON decodes the request body into dst, rejecting fields dst doesn't have and anything after the JSON value.
+// A field with the wrong type or an unknown field is reported as a validation error on that field.
+func DecodeJSON(r *http.Request, dst any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return custom_error.InvalidPayload(errors.New("body must contain a single JSON value"))
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return custom_error.Validation(custom_error.FieldError{
+			Field:   typeErr.Field,
+			Code:    RuleType,
+			Message: "must be " + jsonType(typeErr.Type.Kind().String()),
+		})
+	}
+	// the json package has no error type for unknown fields, only its message
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return custom_error.Validation(custom_error.FieldError{
+			Field:   strings.Trim(field, `"`),
+			Code:    RuleUnknown,
+			Message: "is not a known field",
+		})
+	}
+	return custom_error.InvalidPayload(err)
+}
+
+// jsonType names a Go kind the way a client writing JSON knows it
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
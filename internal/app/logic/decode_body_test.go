@@ -0,0 +1,48 @@
+package logic
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		code  string
+		field string
+	}{
+		{"valid", `{"id":"d-1","type":"solar","nameplate_capacity":5}`, "", ""},
+		{"unknown field", `{"id":"d-1","capacity":5}`, custom_error.CodeValidationFailed, "capacity"},
+		{"wrong type", `{"id":"d-1","nameplate_capacity":"5"}`, custom_error.CodeValidationFailed, "nameplate_capacity"},
+		{"malformed", `{"id":`, custom_error.CodeInvalidPayload, ""},
+		{"trailing data", `{"id":"d-1"} {"id":"d-2"}`, custom_error.CodeInvalidPayload, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var der models.DERMetadata
+			err := DecodeJSON(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)), &der)
+			if tc.code == "" {
+				require.NoError(t, err)
+				assert.Equal(t, models.Solar, der.Type)
+				return
+			}
+			var invalid *custom_error.CustomError
+			require.True(t, errors.As(err, &invalid))
+			assert.Equal(t, http.StatusBadRequest, invalid.Code)
+			assert.Equal(t, tc.code, invalid.ErrorCode)
+			if tc.field != "" {
+				require.Len(t, invalid.Fields, 1)
+				assert.Equal(t, tc.field, invalid.Fields[0].Field)
+			}
+		})
+	}
+}
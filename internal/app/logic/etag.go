@@ -0,0 +1,66 @@
+package logic
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+// ETag is the strong entity tag of a resource at version, the version column every update bumps
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// CheckIfMatch fails with 412 when the request has an If-Match header that doesn't list the ETag of the resource
+// at version. Without the header the write is unconditional, it is still checked against the version it was read at.
+func CheckIfMatch(r *http.Request, version int64) error {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchETag(header, version, false) {
+		return nil
+	}
+	return custom_error.New(http.StatusPreconditionFailed, "Resource has changed, its ETag no longer matches If-Match", nil).
+		WithCode(custom_error.CodePreconditionFailed)
+}
+
+// NotModified reports whether the If-None-Match header lists the ETag of the resource at version,
+// in which case a GET answers 304 instead of sending the resource again
+func NotModified(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	return header != "" && matchETag(header, version, true)
+}
+
+// WriteVersioned writes v as the JSON body of a single resource with its ETag, or a 304 to a GET when the client's
+// copy is current
+func WriteVersioned(w http.ResponseWriter, r *http.Request, v any, version int64) error {
+	w.Header().Set("ETag", ETag(version))
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && NotModified(r, version) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(v)
+}
+
+// matchETag compares the tag list of a conditional header to the resource's ETag. If-Match uses the strong
+// comparison (RFC 9110 13.1.1), If-None-Match the weak one that ignores the W/ prefix.
+func matchETag(header string, version int64, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	etag := ETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		} else if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
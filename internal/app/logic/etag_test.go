@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		match  bool
+	}{
+		{"no header", "", true},
+		{"current etag", `"3"`, true},
+		{"any", "*", true},
+		{"listed", `"1", "3"`, true},
+		{"stale etag", `"2"`, false},
+		{"weak etags never match", `W/"3"`, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("If-Match", tc.header)
+			}
+			err := CheckIfMatch(r, 3)
+			if tc.match {
+				assert.NoError(t, err)
+				return
+			}
+			var customErr *custom_error.CustomError
+			require.ErrorAs(t, err, &customErr)
+			assert.Equal(t, http.StatusPreconditionFailed, customErr.Code)
+			assert.Equal(t, custom_error.CodePreconditionFailed, customErr.ErrorCode)
+		})
+	}
+}
+
+func TestWriteVersioned(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", `W/"2"`)
+	rec := httptest.NewRecorder()
+	require.NoError(t, WriteVersioned(rec, r, map[string]int{"a": 1}, 2))
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Body.String())
+
+	// the client's copy is out of date, and a write always sends the updated resource
+	tests := map[string]string{http.MethodGet: `"1"`, http.MethodPatch: `"2"`}
+	for method, header := range tests {
+		r := httptest.NewRequest(method, "/", nil)
+		r.Header.Set("If-None-Match", header)
+		rec := httptest.NewRecorder()
+		require.NoError(t, WriteVersioned(rec, r, map[string]int{"a": 1}, 2))
+		assert.Equal(t, http.StatusOK, rec.Code, method)
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+		assert.JSONEq(t, `{"a": 1}`, rec.Body.String())
+	}
+}
@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+// MergePatchContentType is the media type of an RFC 7396 merge patch, plain application/json is accepted too
+const MergePatchContentType = "application/merge-patch+json"
+
+// RuleImmutable is the field error code of a field an update may not change
+const RuleImmutable = "immutable"
+
+// UpdateFunc applies the request body to dst, the current resource, and returns the fields it set
+type UpdateFunc func(r *http.Request, dst any, mutable []string) ([]string, error)
+
+// MergePatch applies the request body to dst as an RFC 7396 JSON merge patch: members set their field,
+// null clears it and absent fields are left alone. The models are flat, so there are no nested objects to merge.
+// Only fields in mutable may change, an immutable field is accepted only with its current value.
+func MergePatch(r *http.Request, dst any, mutable []string) ([]string, error) {
+	return applyUpdate(r, dst, mutable, false)
+}
+
+// Replace applies the request body to dst as a full replacement for PUT: every mutable field is set to its value
+// in the body, and absent fields are cleared
+func Replace(r *http.Request, dst any, mutable []string) ([]string, error) {
+	return applyUpdate(r, dst, mutable, true)
+}
+
+// ExtractFields returns the named fields of input by json name, zero values included, for the repositories
+// to only touch the fields an update set
+func ExtractFields(input any, fields []string) map[string]any {
+	v := reflect.Indirect(reflect.ValueOf(input))
+	byName := jsonFields(v.Type())
+	updates := make(map[string]any, len(fields))
+	for _, name := range fields {
+		if i, ok := byName[name]; ok {
+			updates[name] = v.Field(i).Interface()
+		}
+	}
+	return updates
+}
+
+func applyUpdate(r *http.Request, dst any, mutable []string, replace bool) ([]string, error) {
+	if err := checkPatchContentType(r); err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&members); err != nil {
+		return nil, custom_error.InvalidPayload(err)
+	}
+	if members == nil {
+		return nil, custom_error.InvalidPayload(errors.New("body must be a JSON object"))
+	}
+
+	v := reflect.ValueOf(dst).Elem()
+	byName := jsonFields(v.Type())
+	allowed := make(map[string]bool, len(mutable))
+	for _, name := range mutable {
+		allowed[name] = true
+	}
+
+	// every member is checked before anything is set, so all violations are reported at once
+	var fields []custom_error.FieldError
+	for name, raw := range members {
+		i, ok := byName[name]
+		switch {
+		case !ok:
+			fields = append(fields, custom_error.FieldError{Field: name, Code: RuleUnknown, Message: "is not a known field"})
+		case !allowed[name] && !sameValue(v.Field(i), raw):
+			fields = append(fields, custom_error.FieldError{Field: name, Code: RuleImmutable, Message: "cannot be changed"})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, custom_error.Validation(sortFields(fields)...)
+	}
+
+	var set []string
+	for _, name := range mutable {
+		i, ok := byName[name]
+		if !ok {
+			continue
+		}
+		raw, present := members[name]
+		if !present && !replace {
+			continue
+		}
+		field := v.Field(i)
+		if !present || isNull(raw) {
+			field.Set(reflect.Zero(field.Type()))
+		} else if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+			fields = append(fields, custom_error.FieldError{Field: name, Code: RuleType, Message: "must be " + jsonType(field.Kind().String())})
+			continue
+		}
+		set = append(set, name)
+	}
+	if len(fields) > 0 {
+		return nil, custom_error.Validation(fields...)
+	}
+	return set, nil
+}
+
+func checkPatchContentType(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == MergePatchContentType || mediaType == "application/json") {
+		return nil
+	}
+	return custom_error.New(http.StatusUnsupportedMediaType, "Content-Type must be "+MergePatchContentType+" or application/json", nil)
+}
+
+// jsonFields maps the json names of t's fields to their index
+func jsonFields(t reflect.Type) map[string]int {
+	byName := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		byName[name] = i
+	}
+	return byName
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// sameValue reports whether raw decodes to the field's current value, clients may send back what they read
+func sameValue(field reflect.Value, raw json.RawMessage) bool {
+	value := reflect.New(field.Type())
+	if err := json.Unmarshal(raw, value.Interface()); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(value.Elem().Interface(), field.Interface())
+}
+
+func sortFields(fields []custom_error.FieldError) []custom_error.FieldError {
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
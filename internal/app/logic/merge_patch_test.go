@@ -0,0 +1,94 @@
+package logic
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patchRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", MergePatchContentType)
+	return r
+}
+
+func current() *models.DERMetadata {
+	return &models.DERMetadata{ID: "d-1", ProjectID: "p-1", Type: models.Battery, NameplateCapacity: 10, PowerCapacity: 4}
+}
+
+func TestMergePatch(t *testing.T) {
+	der := current()
+	fields, err := MergePatch(patchRequest(`{"power_capacity": 0, "type": "solar"}`), der, der.MutableFields())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"power_capacity", "type"}, fields)
+	assert.Equal(t, &models.DERMetadata{ID: "d-1", ProjectID: "p-1", Type: models.Solar, NameplateCapacity: 10}, der)
+
+	// null clears, absent fields are left alone
+	project := &models.Project{ID: "p-1", UtilityID: "u-1", UserID: "home-1", Location: "Ottawa"}
+	fields, err = MergePatch(patchRequest(`{"location": null}`), project, project.MutableFields())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"location"}, fields)
+	assert.Equal(t, &models.Project{ID: "p-1", UtilityID: "u-1", UserID: "home-1"}, project)
+
+	// an immutable field may be sent back unchanged
+	der = current()
+	fields, err = MergePatch(patchRequest(`{"id": "d-1", "project_id": "p-1", "nameplate_capacity": 12}`), der, der.MutableFields())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nameplate_capacity"}, fields)
+}
+
+func TestMergePatchRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields []custom_error.FieldError
+	}{
+		{"immutable and unknown", `{"project_id": "p-2", "owner": "x", "type": "ev"}`, http.StatusBadRequest, []custom_error.FieldError{
+			{Field: "owner", Code: RuleUnknown, Message: "is not a known field"},
+			{Field: "project_id", Code: RuleImmutable, Message: "cannot be changed"},
+		}},
+		{"wrong type", `{"power_capacity": "lots"}`, http.StatusBadRequest, []custom_error.FieldError{
+			{Field: "power_capacity", Code: RuleType, Message: "must be a number"},
+		}},
+		{"not an object", `[1]`, http.StatusBadRequest, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			der := current()
+			_, err := MergePatch(patchRequest(tc.body), der, der.MutableFields())
+			var invalid *custom_error.CustomError
+			require.True(t, errors.As(err, &invalid))
+			assert.Equal(t, tc.status, invalid.Code)
+			assert.Equal(t, tc.fields, invalid.Fields)
+			assert.Equal(t, current(), der, "nothing is applied when the patch is rejected")
+		})
+	}
+
+	r := patchRequest(`{}`)
+	r.Header.Set("Content-Type", "text/plain")
+	_, err := MergePatch(r, current(), current().MutableFields())
+	var invalid *custom_error.CustomError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, http.StatusUnsupportedMediaType, invalid.Code)
+}
+
+func TestReplace(t *testing.T) {
+	der := current()
+	fields, err := Replace(patchRequest(`{"type": "ev", "nameplate_capacity": 7}`), der, der.MutableFields())
+	require.NoError(t, err)
+	assert.Equal(t, der.MutableFields(), fields)
+	assert.Equal(t, &models.DERMetadata{ID: "d-1", ProjectID: "p-1", Type: models.EV, NameplateCapacity: 7}, der)
+}
+
+func TestExtractFields(t *testing.T) {
+	der := &models.DERMetadata{ID: "d-1", Type: models.Solar}
+	assert.Equal(t, map[string]any{"type": models.Solar, "power_capacity": float64(0)}, ExtractFields(der, []string{"type", "power_capacity"}))
+}
@@ -0,0 +1,37 @@
+package logic
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// QueryTimeRange reads the optional RFC 3339 bounds of a list's time range filter from the fromParam and toParam
+// query parameters, a missing bound is the zero time
+func QueryTimeRange(r *http.Request, fromParam, toParam string) (time.Time, time.Time, error) {
+	var fields []custom_error.FieldError
+	parse := func(param string) time.Time {
+		s := r.URL.Query().Get(param)
+		if s == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			fields = append(fields, custom_error.FieldError{
+				Field: param, Code: RuleType, Message: "must be an RFC 3339 timestamp, e.g. 2006-01-02T15:04:05Z",
+			})
+		}
+		return t
+	}
+
+	from, to := parse(fromParam), parse(toParam)
+	if len(fields) == 0 && !from.IsZero() && !to.IsZero() && !to.After(from) {
+		fields = append(fields, custom_error.FieldError{Field: toParam, Code: models.RuleAfter, Message: "must be after " + fromParam})
+	}
+	if len(fields) > 0 {
+		return time.Time{}, time.Time{}, custom_error.Validation(fields...)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/callsite"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+)
+
+// how long the background lookup of a job's bytes processed may take
+const jobStatusTimeout = 5 * time.Second
+
+// InstrumentBigQuery wraps client so every call is counted and timed against the repository method
+// that made it, and the bytes processed by every query job are added up
+func (m *Metrics) InstrumentBigQuery(client bqclient.BQClient) bqclient.BQClient {
+	if m == nil {
+		return client
+	}
+	return &bigQueryClient{BQClient: client, metrics: m}
+}
+
+type bigQueryClient struct {
+	bqclient.BQClient
+	metrics *Metrics
+}
+
+func (c *bigQueryClient) observe(start time.Time, err error) (string, string) {
+	repository, method := callsite.Repository()
+	outcome := OutcomeOK
+	switch {
+	case err == bqclient.ErrNotFound:
+		outcome = OutcomeNotFound
+	case err != nil:
+		outcome = OutcomeError
+	}
+	c.metrics.ObserveQuery(repository, method, time.Since(start), outcome)
+	return repository, method
+}
+
+func (c *bigQueryClient) Query(ctx context.Context, query string, params []bigquery.QueryParameter) (*bigquery.RowIterator, error) {
+	start := time.Now()
+	it, err := c.BQClient.Query(ctx, query, params)
+	repository, method := c.observe(start, err)
+	if err == nil && c.metrics.bigQueryBytes {
+		c.bytesProcessed(ctx, it, repository, method)
+	}
+	return it, err
+}
+
+// bytesProcessed looks the job up in the background, the request doesn't wait on it
+func (c *bigQueryClient) bytesProcessed(ctx context.Context, it *bigquery.RowIterator, repository, method string) {
+	job := it.SourceJob()
+	if job == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, jobStatusTimeout)
+		defer cancel()
+		status, err := job.Status(ctx)
+		if err != nil || status.Statistics == nil {
+			return
+		}
+		c.metrics.addBytesProcessed(repository, method, status.Statistics.TotalBytesProcessed)
+	}()
+}
+
+func (c *bigQueryClient) QueryRow(ctx context.Context, query string, params []bigquery.QueryParameter, dst any) error {
+	start := time.Now()
+	err := c.BQClient.QueryRow(ctx, query, params, dst)
+	c.observe(start, err)
+	return err
+}
+
+func (c *bigQueryClient) Put(ctx context.Context, table string, data any) error {
+	start := time.Now()
+	err := c.BQClient.Put(ctx, table, data)
+	c.observe(start, err)
+	return err
+}
+
+func (c *bigQueryClient) StreamPut(ctx context.Context, table string, data any) error {
+	start := time.Now()
+	err := c.BQClient.StreamPut(ctx, table, data)
+	c.observe(start, err)
+	return err
+}
+
+func (c *bigQueryClient) StreamPutAll(ctx context.Context, inputs map[string][]any) error {
+	start := time.Now()
+	err := c.BQClient.StreamPutAll(ctx, inputs)
+	c.observe(start, err)
+	return err
+}
+
+func (c *bigQueryClient) Update(ctx context.Context, table string, id string, updates map[string]any) error {
+	start := time.Now()
+	err := c.BQClient.Update(ctx, table, id, updates)
+	c.observe(start, err)
+	return err
+}
+
+func (c *bigQueryClient) Delete(ctx context.Context, table string, id string) error {
+	start := time.Now()
+	err := c.BQClient.Delete(ctx, table, id)
+	c.observe(start, err)
+	return err
+}
+
+func (c *bigQueryClient) Get(ctx context.Context, table string, id string, dst any) error {
+	start := time.Now()
+	err := c.BQClient.Get(ctx, table, id, dst)
+	c.observe(start, err)
+	return err
+}
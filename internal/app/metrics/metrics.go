@@ -0,0 +1,178 @@
+// Package metrics holds the API's Prometheus collectors. A nil *Metrics records nothing,
+// so everything that takes one keeps working with metrics turned off.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gridstream"
+
+// Auth failure reasons
+const (
+	AuthMissingToken  = "missing_token"
+	AuthInvalidToken  = "invalid_token"
+	AuthNoRole        = "no_role"
+	AuthRoleLookup    = "role_lookup_error"
+	AuthWrongRole     = "wrong_role"
+	AuthInvalidAPIKey = "invalid_api_key"
+	AuthAPIKeyLookup  = "api_key_lookup_error"
+	AuthMissingScope  = "missing_scope"
+	AuthNoVerifier    = "no_verifier"
+	AuthFeedLookup    = "feed_lookup_error"
+)
+
+// Query outcomes
+const (
+	OutcomeOK       = "ok"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+// requests chi couldn't route, rejected before routing or for an unknown path
+const unmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry      *prometheus.Registry
+	bigQueryBytes bool
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	rateLimited     prometheus.Counter
+	blocked         prometheus.Counter
+	authFailures    *prometheus.CounterVec
+	queries         *prometheus.CounterVec
+	queryDuration   *prometheus.HistogramVec
+	bytesProcessed  *prometheus.CounterVec
+}
+
+// New registers every collector with reg, tests pass their own registry to read values back.
+// Returns nil when metrics are disabled.
+func New(cfg *config.MetricsConfig, reg *prometheus.Registry) *Metrics {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	m := &Metrics{
+		registry:      reg,
+		bigQueryBytes: cfg.BigQueryBytes,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by chi route pattern, method and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by chi route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		rateLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_rate_limited_total",
+			Help:      "Requests rejected by the per client rate limiter.",
+		}),
+		blocked: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_blocked_requests_total",
+			Help:      "Requests rejected for probing suspicious paths.",
+		}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_failures_total",
+			Help:      "Requests rejected by the auth middleware by reason.",
+		}, []string{"reason"}),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_queries_total",
+			Help:      "Database calls by repository, method and outcome.",
+		}, []string{"repository", "method", "outcome"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_query_duration_seconds",
+			Help:      "Database call latency by repository and method.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2, 4, 8, 16},
+		}, []string{"repository", "method"}),
+		bytesProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bigquery_bytes_processed_total",
+			Help:      "Bytes processed by BigQuery jobs by repository and method, what on-demand queries are billed on.",
+		}, []string{"repository", "method"}),
+	}
+	reg.MustRegister(
+		m.requests, m.requestDuration, m.rateLimited, m.blocked, m.authFailures,
+		m.queries, m.queryDuration, m.bytesProcessed,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware records every request once chi has routed it, labelled by the route pattern
+// rather than the raw path so ids don't explode the label set
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		m.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *Metrics) RateLimited() {
+	if m != nil {
+		m.rateLimited.Inc()
+	}
+}
+
+func (m *Metrics) Blocked() {
+	if m != nil {
+		m.blocked.Inc()
+	}
+}
+
+func (m *Metrics) AuthFailure(reason string) {
+	if m != nil {
+		m.authFailures.WithLabelValues(reason).Inc()
+	}
+}
+
+// ObserveQuery records one database call made by the repository method
+func (m *Metrics) ObserveQuery(repository, method string, took time.Duration, outcome string) {
+	if m == nil {
+		return
+	}
+	m.queries.WithLabelValues(repository, method, outcome).Inc()
+	m.queryDuration.WithLabelValues(repository, method).Observe(took.Seconds())
+}
+
+func (m *Metrics) addBytesProcessed(repository, method string, bytes int64) {
+	m.bytesProcessed.WithLabelValues(repository, method).Add(float64(bytes))
+}
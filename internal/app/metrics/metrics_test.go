@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestMetrics() *Metrics {
+	return New(&config.MetricsConfig{Enabled: true}, prometheus.NewRegistry())
+}
+
+func TestMiddlewareLabelsRoutePattern(t *testing.T) {
+	m := newTestMetrics()
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Route("/v1/projects", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	})
+
+	for _, path := range []string{"/v1/projects/a", "/v1/projects/b", "/nope"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/v1/projects/a", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/v1/projects/{id}", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("DELETE", "/v1/projects/{id}", "204")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", unmatchedRoute, "404")))
+}
+
+func TestDisabledMetricsRecordNothing(t *testing.T) {
+	m := New(&config.MetricsConfig{Enabled: false}, prometheus.NewRegistry())
+	assert.Nil(t, m)
+
+	// none of these may panic on a nil *Metrics
+	m.RateLimited()
+	m.Blocked()
+	m.AuthFailure(AuthInvalidToken)
+	m.ObserveQuery("projectRepository", "GetProject", 0, OutcomeOK)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	assert.NotNil(t, m.Middleware(next))
+	client := &fakeBigQuery{}
+	assert.Same(t, bqclient.BQClient(client), m.InstrumentBigQuery(client))
+}
+
+type fakeBigQuery struct {
+	bqclient.BQClient
+	err error
+}
+
+func (f *fakeBigQuery) Get(ctx context.Context, table string, id string, dst any) error {
+	return f.err
+}
+
+func (f *fakeBigQuery) Query(ctx context.Context, query string, params []bigquery.QueryParameter) (*bigquery.RowIterator, error) {
+	return nil, f.err
+}
+
+// widgetRepository stands in for a repository, labels come from the method on the stack
+type widgetRepository struct {
+	client bqclient.BQClient
+}
+
+func (r *widgetRepository) GetWidget(ctx context.Context) error {
+	return r.client.Get(ctx, "widgets", "w-1", nil)
+}
+
+func TestInstrumentBigQueryLabelsRepositoryMethod(t *testing.T) {
+	m := newTestMetrics()
+	fake := &fakeBigQuery{}
+	repo := &widgetRepository{client: m.InstrumentBigQuery(fake)}
+
+	assert.NoError(t, repo.GetWidget(context.Background()))
+	fake.err = bqclient.ErrNotFound
+	assert.Error(t, repo.GetWidget(context.Background()))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.queries.WithLabelValues("widgetRepository", "GetWidget", OutcomeOK)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.queries.WithLabelValues("widgetRepository", "GetWidget", OutcomeNotFound)))
+
+	// calls from outside a repository still get counted
+	_, _ = m.InstrumentBigQuery(fake).Query(context.Background(), "SELECT 1", nil)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.queries.WithLabelValues("other", "other", OutcomeNotFound)))
+}
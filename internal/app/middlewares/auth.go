@@ -1,29 +1,86 @@
 package middlewares
 
 import (
-	"context"
-	"fmt"
+	"errors"
 	"log/slog"
 	"net/http"
 
-	"cloud.google.com/go/firestore"
-	"firebase.google.com/go/auth"
-	"github.com/grid-stream-org/api/pkg/firebase"
+	"github.com/grid-stream-org/api/internal/app/apikeys"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/calendar"
+	"github.com/grid-stream-org/api/internal/app/identity"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/metrics"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/custom_error"
 )
 
-type contextKey string
-
-const userKey contextKey = "users"
+// apiKeyHeader carries the API key of backend services, users send a Firebase ID token as a bearer token
+const apiKeyHeader = "X-API-Key"
 
 type AuthMiddleware struct {
-	FirebaseAuth *auth.Client
-	Firestore    *firestore.Client
+	Tokens  identity.TokenVerifier
+	Roles   *roles.Resolver
+	APIKeys *apikeys.Service
+	Metrics *metrics.Metrics
+	log     *slog.Logger
 }
 
-func NewAuthMiddleware(firebaseClient firebase.FirebaseClient, log *slog.Logger) *AuthMiddleware {
+// NewAuthMiddleware creates the auth middleware, without a token verifier every request with an ID token is rejected
+func NewAuthMiddleware(verifier identity.TokenVerifier, resolver *roles.Resolver, keys *apikeys.Service, m *metrics.Metrics, log *slog.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
-		FirebaseAuth: firebaseClient.Auth(),
-		Firestore:    firebaseClient.Firestore(),
+		Tokens:  verifier,
+		Roles:   resolver,
+		APIKeys: keys,
+		Metrics: m,
+		log:     log,
+	}
+}
+
+// RequireRoleOrService accepts a user with one of the roles, or a service whose API key has the scope. Clients that
+// only speak bearer tokens, such as OpenADR 3.0 VENs, may send the key as one.
+func (am *AuthMiddleware) RequireRoleOrService(scope string, requiredRoles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		requireRole := am.RequireRole(requiredRoles...)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret := r.Header.Get(apiKeyHeader)
+			if bearer := getTokenFromHeader(r); secret == "" && apikeys.IsSecret(bearer) {
+				secret = bearer
+			}
+			if secret == "" || r.Method == "OPTIONS" {
+				requireRole.ServeHTTP(w, r)
+				return
+			}
+			if am.APIKeys == nil {
+				am.Metrics.AuthFailure(metrics.AuthInvalidAPIKey)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeInvalidAPIKey, "Invalid API key")
+				return
+			}
+
+			key, err := am.APIKeys.Authenticate(r.Context(), secret)
+			if errors.Is(err, apikeys.ErrInvalidKey) {
+				am.Metrics.AuthFailure(metrics.AuthInvalidAPIKey)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeInvalidAPIKey, "Invalid API key")
+				return
+			}
+			if err != nil {
+				am.Metrics.AuthFailure(metrics.AuthAPIKeyLookup)
+				logging.FromContext(r.Context(), am.log).ErrorContext(r.Context(), "error checking api key", "error", err)
+				custom_error.Write(w, r, http.StatusInternalServerError, custom_error.CodeInternal, "Failed to check api key")
+				return
+			}
+			if !apikeys.HasScope(key, scope) {
+				am.Metrics.AuthFailure(metrics.AuthMissingScope)
+				logging.FromContext(r.Context(), am.log).WarnContext(r.Context(), "api key lacks scope", "key_id", key.ID, "prefix", key.Prefix, "scope", scope)
+				custom_error.Write(w, r, http.StatusForbidden, custom_error.CodeInsufficientScope, "API key lacks the required scope")
+				return
+			}
+
+			principal := apikeys.Principal(key)
+			ctx := logging.With(r.Context(), am.log, "user_id", principal.UserID, "role", principal.Role)
+			ctx = authz.WithPrincipal(ctx, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
 	}
 }
 
@@ -32,19 +89,6 @@ func (am *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return am.RequireRole()(next)
 }
 
-func getRoleFromInt(value int) string {
-	switch value {
-	case 0:
-		return "Utility"
-	case 1:
-		return "Residential"
-	case 2:
-		return "Technician"
-	default:
-		return ""
-	}
-}
-
 // RequireRole checks authentication and role(s)
 func (am *AuthMiddleware) RequireRole(requiredRoles ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -53,66 +97,105 @@ func (am *AuthMiddleware) RequireRole(requiredRoles ...string) func(http.Handler
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
-			// Get and verify token
-			token, err := am.FirebaseAuth.VerifyIDToken(r.Context(), getTokenFromHeader(r))
-			if err != nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			if am.Tokens == nil {
+				am.Metrics.AuthFailure(metrics.AuthNoVerifier)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeUnauthorized, "Unauthorized")
 				return
 			}
 
-			// If no roles required, proceed after auth check
-			if len(requiredRoles) == 0 {
-				next.ServeHTTP(w, r)
+			// Get and verify token
+			idToken := getTokenFromHeader(r)
+			if idToken == "" {
+				am.Metrics.AuthFailure(metrics.AuthMissingToken)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeMissingCredentials, "Missing bearer token")
 				return
 			}
-
-			// Get user from Firestore
-			userDoc, err := am.Firestore.Collection(string(userKey)).Doc(token.UID).Get(r.Context())
+			token, err := am.Tokens.VerifyIDToken(r.Context(), idToken)
 			if err != nil {
-				fmt.Printf("error fetching user doc: %v\n", err)
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				am.Metrics.AuthFailure(metrics.AuthInvalidToken)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeInvalidToken, "Invalid or expired token")
 				return
 			}
 
-			// Get user's role
-			userRole, ok := userDoc.Data()["role"]
-			if !ok {
-				fmt.Printf("User role %d\n", userDoc.Data()["role"])
-				fmt.Printf("user has no role field: %v\n", userDoc.Data())
-				http.Error(w, "Forbidden", http.StatusForbidden)
+			am.serveUser(w, r, next, token.UID, token.Claims, requiredRoles)
+		})
+	}
+}
+
+// RequireFeedToken authenticates calendar feeds by the token in the url, calendar apps can't send a bearer token.
+// The user's role is read from the role store since the token carries no claims.
+func (am *AuthMiddleware) RequireFeedToken(tokens *calendar.Tokens) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// without Firebase there's no role store to read the user's role from
+			if am.Roles == nil || !tokens.Enabled() {
+				am.Metrics.AuthFailure(metrics.AuthNoVerifier)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeUnauthorized, "Unauthorized")
 				return
 			}
-
-			val, ok := userRole.(int64)
-			if !ok {
-				http.Error(w, "Failed to resolve user role", http.StatusInternalServerError)
+			feedToken := r.URL.Query().Get("token")
+			if feedToken == "" {
+				am.Metrics.AuthFailure(metrics.AuthMissingToken)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeMissingCredentials, "Missing feed token")
 				return
 			}
-
-			ro := getRoleFromInt(int(val))
-
-			// Check if user's role matches any required role
-			hasRequiredRole := false
-			for _, requiredRole := range requiredRoles {
-				if ro == requiredRole {
-					hasRequiredRole = true
-					break
-				}
+			uid, err := tokens.Verify(r.Context(), feedToken)
+			if errors.Is(err, calendar.ErrInvalidToken) {
+				am.Metrics.AuthFailure(metrics.AuthInvalidToken)
+				custom_error.Write(w, r, http.StatusUnauthorized, custom_error.CodeInvalidToken, "Invalid or expired feed token")
+				return
 			}
-
-			if !hasRequiredRole {
-				fmt.Printf("user lacks required role. Has: %v, Needs one of: %v\n", ro, requiredRoles)
-				http.Error(w, "Forbidden", http.StatusForbidden)
+			if err != nil {
+				am.Metrics.AuthFailure(metrics.AuthFeedLookup)
+				logging.FromContext(r.Context(), am.log).ErrorContext(r.Context(), "error checking feed token", "error", err)
+				custom_error.Write(w, r, http.StatusInternalServerError, custom_error.CodeInternal, "Failed to check feed token")
 				return
 			}
-
-			// Add user data to context
-			ctx := context.WithValue(r.Context(), userKey, userDoc.Data())
-			next.ServeHTTP(w, r.WithContext(ctx))
+			am.serveUser(w, r, next, uid, nil, nil)
 		})
 	}
 }
 
+// serveUser resolves the user's role, checks it against the required roles and serves the request as the user
+func (am *AuthMiddleware) serveUser(w http.ResponseWriter, r *http.Request, next http.Handler, uid string, claims map[string]interface{}, requiredRoles []string) {
+	// Get the user's role from the token's custom claims, or Firestore when the claims haven't been synced,
+	// needed even without a role check to scope what the user can see
+	membership, err := am.Roles.Resolve(r.Context(), uid, claims)
+	if errors.Is(err, roles.ErrNoMembership) {
+		am.Metrics.AuthFailure(metrics.AuthNoRole)
+		logging.FromContext(r.Context(), am.log).WarnContext(r.Context(), "user has no role", "user_id", uid)
+		custom_error.Write(w, r, http.StatusForbidden, custom_error.CodeNoRole, "User has no role")
+		return
+	}
+	if err != nil {
+		am.Metrics.AuthFailure(metrics.AuthRoleLookup)
+		logging.FromContext(r.Context(), am.log).ErrorContext(r.Context(), "error resolving user role", "user_id", uid, "error", err)
+		custom_error.Write(w, r, http.StatusInternalServerError, custom_error.CodeInternal, "Failed to resolve user role")
+		return
+	}
+
+	// Check if user's role matches any required role, no roles required means any role will do
+	hasRequiredRole := len(requiredRoles) == 0
+	for _, requiredRole := range requiredRoles {
+		if membership.Role == requiredRole {
+			hasRequiredRole = true
+			break
+		}
+	}
+
+	if !hasRequiredRole {
+		am.Metrics.AuthFailure(metrics.AuthWrongRole)
+		logging.FromContext(r.Context(), am.log).WarnContext(r.Context(), "user lacks required role", "user_id", uid, "role", membership.Role, "required_roles", requiredRoles)
+		custom_error.Write(w, r, http.StatusForbidden, custom_error.CodeInsufficientRole, "User lacks the required role")
+		return
+	}
+
+	// Add the caller to the context for the ownership checks in the handlers, and to the request's log lines
+	ctx := logging.With(r.Context(), am.log, "user_id", uid, "role", membership.Role)
+	ctx = authz.WithPrincipal(ctx, membership.Principal(uid))
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func getTokenFromHeader(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/calendar"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireFeedTokenWithoutResolver(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tokens := calendar.NewTokens(&config.CalendarConfig{Secret: strings.Repeat("s", 32), TokenTTL: time.Hour}, memory.NewStore(log).CalendarFeeds)
+	token, _, err := tokens.Issue(context.Background(), "home-1")
+	require.NoError(t, err)
+
+	am := NewAuthMiddleware(nil, nil, nil, nil, log)
+	handler := am.RequireFeedToken(tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("served without a role store")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calendar/projects/p-1/events.ics?token="+token, nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, rec.Body.String())
+}
@@ -5,9 +5,19 @@ package middlewares
 import (
 	"net/http"
 	"strings"
+
+	"github.com/grid-stream-org/api/internal/app/metrics"
+	"github.com/grid-stream-org/api/internal/custom_error"
 )
 
-func BlockSuspiciousRequests(next http.Handler) http.Handler {
+// BlockSuspiciousRequests rejects bots probing for well known files, counting every hit in m
+func BlockSuspiciousRequests(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return blockSuspiciousRequests(next, m)
+	}
+}
+
+func blockSuspiciousRequests(next http.Handler, m *metrics.Metrics) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// list of suspicious paths from chatgpt, seems robust enough for now
 		suspiciousPaths := []string{
@@ -34,7 +44,8 @@ func BlockSuspiciousRequests(next http.Handler) http.Handler {
 
 		for _, path := range suspiciousPaths {
 			if strings.Contains(r.URL.Path, path) {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				m.Blocked()
+				custom_error.Write(w, r, http.StatusForbidden, custom_error.CodeBlocked, "Forbidden")
 				return
 			}
 		}
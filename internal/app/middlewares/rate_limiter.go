@@ -3,13 +3,12 @@
 package middlewares
 
 import (
-	"encoding/json"
-	"errors"
 	"net"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/grid-stream-org/api/internal/app/metrics"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"golang.org/x/time/rate"
 )
@@ -40,13 +39,19 @@ func init() {
 	}()
 }
 
-// Per-client rate limiter middleware
-func PerClientRateLimiter(next http.Handler) http.Handler {
+// Per-client rate limiter middleware, rejections are counted in m
+func PerClientRateLimiter(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return perClientRateLimiter(next, m)
+	}
+}
+
+func perClientRateLimiter(next http.Handler, m *metrics.Metrics) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract client IP
 		ip, _, err := net.SplitHostPort(r.RemoteAddr)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			custom_error.WriteProblem(w, r, custom_error.New(http.StatusInternalServerError, "Internal Server Error", err))
 			return
 		}
 
@@ -64,12 +69,9 @@ func PerClientRateLimiter(next http.Handler) http.Handler {
 
 		// Check if request is allowed
 		if !cli.limiter.Allow() {
-			message := custom_error.New(http.StatusTooManyRequests, "Too many requests", errors.New("429 Too many requests"))
-			w.WriteHeader(http.StatusTooManyRequests)
-			err = json.NewEncoder(w).Encode(&message)
-			if err != nil {
-				http.Error(w, "Internal Server error", http.StatusInternalServerError)
-			}
+			m.RateLimited()
+			w.Header().Set("Retry-After", "1")
+			custom_error.Write(w, r, http.StatusTooManyRequests, custom_error.CodeRateLimited, "Too many requests")
 			return
 		}
 
@@ -4,7 +4,9 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/grid-stream-org/api/internal/app/logging"
 	"github.com/grid-stream-org/api/internal/custom_error"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type HandlerFunc = func(w http.ResponseWriter, r *http.Request) error
@@ -15,17 +17,13 @@ func WrapHandler(handler HandlerFunc, log *slog.Logger) http.HandlerFunc {
 		// Call the handler
 		err := handler(w, r)
 		if err != nil {
-			// Log error
-			log.Error("Handler error", "error", err)
+			// Log error, with the request's trace id, and attach it to the request's span
+			logging.FromContext(r.Context(), log).ErrorContext(r.Context(), "Handler error", "error", err)
+			span := trace.SpanFromContext(r.Context())
+			span.RecordError(err)
 
-			// throw our error from custom_error.go
-			if customErr, ok := err.(*custom_error.CustomError); ok {
-				http.Error(w, customErr.Message, customErr.Code)
-				return
-			}
-
-			// Default to internal server error
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			// render it as problem+json, anything but our error from custom_error.go is an internal server error
+			custom_error.WriteProblem(w, r, err)
 		}
 	}
 }
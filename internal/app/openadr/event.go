@@ -0,0 +1,155 @@
+package openadr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+const (
+	// every event is a SIMPLE signal at level 1 (moderate) for its whole duration
+	signalName  = "SIMPLE"
+	signalType  = "level"
+	signalLevel = 1
+)
+
+// distribute builds the oadrDistributeEvent of the VEN's events and remembers what it was sent. Without a
+// response, as for a poll, it returns nil when the VEN already has these events.
+func (h *VTN) distribute(ctx context.Context, ven *models.VEN, res *eiResponse, limit int) (*distributeEvent, error) {
+	now := h.now()
+	events, err := h.events(ctx, ven.ProjectID, now)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	oadrEvents := make([]oadrEvent, 0, len(events))
+	for i := range events {
+		oadrEvents = append(oadrEvents, h.event(&events[i], ven.ID, now))
+	}
+	digest := digest(oadrEvents)
+	if res == nil && digest == ven.EventsDigest {
+		return nil, nil
+	}
+	if err := h.store.VENs.SetEventsDigest(ctx, ven.ID, digest); err != nil {
+		return nil, err
+	}
+	return &distributeEvent{
+		SchemaVersion: schemaVersion,
+		Response:      res,
+		RequestID:     h.newID(),
+		VtnID:         h.cfg.VTNID,
+		Events:        oadrEvents,
+	}, nil
+}
+
+// events are the project's events that haven't ended, in start order. Drafts haven't been dispatched and an
+// event the project opted out of is left out, which a VEN takes as the event being cancelled for it.
+func (h *VTN) events(ctx context.Context, projectID string, now time.Time) ([]models.DREvents, error) {
+	page, err := h.store.DREvents.GetDREventsByProjectID(ctx, projectID,
+		repositories.DREventFilter{From: now}, pagination.Params{Limit: pagination.MaxLimit, Sort: "start_time"})
+	if err != nil {
+		return nil, err
+	}
+	events := []models.DREvents{}
+	for _, e := range page.Data {
+		if e.Status != models.DREventDraft && e.Participation == models.ParticipantEnrolled {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// event maps a DR event to the oadrEvent targeting the VEN, every edit bumps the version so it is
+// the modification number
+func (h *VTN) event(e *models.DREvents, venID string, now time.Time) oadrEvent {
+	length := duration(e.EndTime.Sub(e.StartTime))
+	current := 0.0
+	if e.Status == models.DREventActive {
+		current = signalLevel
+	}
+	created := e.StartTime
+	if e.ScheduledAt.Valid {
+		created = e.ScheduledAt.Timestamp
+	}
+	return oadrEvent{
+		Event: eiEvent{
+			Descriptor: eventDescriptor{
+				EventID:            e.ID,
+				ModificationNumber: e.Version - 1,
+				MarketContext:      h.cfg.MarketContext + e.UtilityID,
+				CreatedDateTime:    dateTime(created),
+				EventStatus:        h.status(e, now),
+			},
+			ActivePeriod: activePeriod{Start: dateTime(e.StartTime), Duration: length, Components: components{Nil: true}},
+			Signals: []eventSignal{{
+				Intervals:    []interval{{Duration: length, UID: "0", Value: signalLevel}},
+				SignalName:   signalName,
+				SignalType:   signalType,
+				SignalID:     e.ID,
+				CurrentValue: current,
+			}},
+			Target: target{VenIDs: []string{venID}},
+		},
+		ResponseRequired: "always",
+	}
+}
+
+// status maps the lifecycle to the eventStatus of the specification
+func (h *VTN) status(e *models.DREvents, now time.Time) string {
+	switch e.Status {
+	case models.DREventActive:
+		return "active"
+	case models.DREventCompleted:
+		return "completed"
+	case models.DREventCancelled:
+		return "cancelled"
+	}
+	if e.StartTime.Sub(now) <= h.cfg.NearWindow {
+		return "near"
+	}
+	return "far"
+}
+
+// digest identifies what the VEN acts on in a set of events, their ids, modifications and status
+func digest(events []oadrEvent) string {
+	sum := sha256.New()
+	for _, e := range events {
+		d := e.Event.Descriptor
+		fmt.Fprintf(sum, "%s:%d:%s\n", d.EventID, d.ModificationNumber, d.EventStatus)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func dateTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// duration formats d as an xcal duration, such as PT1H30M
+func duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
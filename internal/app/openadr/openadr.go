@@ -0,0 +1,194 @@
+// Package openadr is an OpenADR 2.0b Virtual Top Node, it publishes DR events to the gateways of the projects
+// enrolled in them. Gateways are VENs (Virtual End Nodes) that register for one project, naming the project id
+// as their venName, and pull their events over the simple HTTP profile. Opt responses to events are recorded on
+// the project's participation, an optOut before the opt out cutoff also opts the project out.
+package openadr
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// Response codes of the OpenADR 2.0b specification, errors are reported in the payload with HTTP 200
+const (
+	codeOK            = 200
+	codeInvalidID     = 452
+	codeInvalidData   = 454
+	codeNotRegistered = 463
+)
+
+// requests larger than this are rejected, event and report payloads of a single VEN are a few kilobytes
+const maxBody = 1 << 20
+
+// VTN serves the EiRegisterParty, EiEvent, EiReport and OadrPoll services
+type VTN struct {
+	store        *repositories.Store
+	policy       *authz.Policy
+	cfg          *config.OpenADRConfig
+	optOutCutoff time.Duration
+	log          *slog.Logger
+	now          func() time.Time
+	newID        func() string
+}
+
+// New creates a VTN, optOutCutoff is how long before an event's start a VEN's optOut still opts the project out
+func New(store *repositories.Store, policy *authz.Policy, cfg *config.OpenADRConfig, optOutCutoff time.Duration, log *slog.Logger) *VTN {
+	return &VTN{
+		store:        store,
+		policy:       policy,
+		cfg:          cfg,
+		optOutCutoff: optOutCutoff,
+		log:          log,
+		now:          time.Now,
+		newID:        func() string { return uuid.New().String() },
+	}
+}
+
+// oadrError is answered as an oadrResponse carrying the code, rather than as an HTTP error
+type oadrError struct {
+	code        int
+	description string
+}
+
+func (e *oadrError) Error() string {
+	return fmt.Sprintf("openadr %d: %s", e.code, e.description)
+}
+
+func invalidID(description string) error {
+	return &oadrError{code: codeInvalidID, description: description}
+}
+
+func invalidData(description string) error {
+	return &oadrError{code: codeInvalidData, description: description}
+}
+
+func notRegistered(description string) error {
+	return &oadrError{code: codeNotRegistered, description: description}
+}
+
+// service answers the message of a request, it is only called with messages the service accepts
+type service func(ctx context.Context, req *signedRequest) (*signedResponse, error)
+
+// serve decodes the request, lets the service answer it and writes the response payload. OpenADR errors are
+// written as an oadrResponse, anything else is returned for middlewares.WrapHandler to render.
+func (h *VTN) serve(w http.ResponseWriter, r *http.Request, name string, accepts func(req *signedRequest) bool, answer service) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		return custom_error.InvalidPayload(err)
+	}
+
+	var req requestPayload
+	var res *signedResponse
+	switch {
+	case xml.Unmarshal(body, &req) != nil:
+		err = invalidData("Payload is not well formed XML")
+	case req.XMLName.Space != nsOADR || req.XMLName.Local != "oadrPayload":
+		err = invalidData("Payload is not an OpenADR 2.0b oadrPayload")
+	case !accepts(&req.Signed):
+		err = invalidData(fmt.Sprintf("Payload is not a message of the %s service", name))
+	default:
+		res, err = answer(r.Context(), &req.Signed)
+	}
+
+	var oerr *oadrError
+	if errors.As(err, &oerr) {
+		logging.FromContext(r.Context(), h.log).WarnContext(r.Context(), "openadr request rejected", "service", name, "code", oerr.code, "description", oerr.description)
+		requestID, venID := req.Signed.ids()
+		res = &signedResponse{Response: &response{
+			SchemaVersion: schemaVersion,
+			Response:      eiResponse{ResponseCode: oerr.code, ResponseDescription: oerr.description, RequestID: requestID},
+			VenID:         venID,
+		}}
+	} else if err != nil {
+		return err
+	}
+	return write(w, res)
+}
+
+func write(w http.ResponseWriter, res *signedResponse) error {
+	out, err := xml.MarshalIndent(newPayload(*res), "", "  ")
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to encode OpenADR payload", err)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(append([]byte(xml.Header), append(out, '\n')...))
+	return err
+}
+
+// ids are the requestID and venID of the request's message, echoed in error responses
+func (s *signedRequest) ids() (string, string) {
+	switch {
+	case s.QueryRegistration != nil:
+		return s.QueryRegistration.RequestID, ""
+	case s.CreatePartyRegistration != nil:
+		return s.CreatePartyRegistration.RequestID, s.CreatePartyRegistration.VenID
+	case s.CancelPartyRegistration != nil:
+		return s.CancelPartyRegistration.RequestID, s.CancelPartyRegistration.VenID
+	case s.RequestEvent != nil:
+		return s.RequestEvent.RequestID, s.RequestEvent.VenID
+	case s.CreatedEvent != nil:
+		return s.CreatedEvent.RequestID, s.CreatedEvent.VenID
+	case s.RegisterReport != nil:
+		return s.RegisterReport.RequestID, s.RegisterReport.VenID
+	case s.UpdateReport != nil:
+		return s.UpdateReport.RequestID, s.UpdateReport.VenID
+	case s.CreatedReport != nil:
+		return s.CreatedReport.RequestID, s.CreatedReport.VenID
+	case s.Poll != nil:
+		return "", s.Poll.VenID
+	}
+	return "", ""
+}
+
+// ven loads a registered VEN, the caller must be allowed to act for its project
+func (h *VTN) ven(ctx context.Context, venID string) (*models.VEN, error) {
+	if venID == "" {
+		return nil, invalidID("venID is required")
+	}
+	ven, err := h.store.VENs.GetVEN(ctx, venID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, notRegistered("VEN is not registered")
+		}
+		return nil, err
+	}
+	if err := h.authorize(ctx, ven.ProjectID); err != nil {
+		return nil, err
+	}
+	return ven, nil
+}
+
+// authorize checks the caller may act for the project, a VEN controls the project's DERs so it needs write access
+func (h *VTN) authorize(ctx context.Context, projectID string) error {
+	if err := h.policy.Project(ctx, projectID, authz.Write, "Project id not found"); err != nil {
+		if isNotFound(err) {
+			return notRegistered("Not authorized for this VEN's project")
+		}
+		return err
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var customErr *custom_error.CustomError
+	return errors.As(err, &customErr) && customErr.Code == http.StatusNotFound
+}
+
+func ok(requestID string) eiResponse {
+	return eiResponse{ResponseCode: codeOK, ResponseDescription: "OK", RequestID: requestID}
+}
@@ -0,0 +1,162 @@
+package openadr
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// TestVTNGolden plays a VEN's conversation with the VTN, every response is compared to testdata/<step>.golden.xml.
+// Run with -update to rewrite them after checking the change against the OpenADR 2.0b schema.
+func TestVTNGolden(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+
+	// a far event, an active one, a draft the VEN mustn't see and one the homeowner already opted out of
+	event := func(id string, start time.Duration, actions ...models.DREventAction) {
+		e := &models.DREvents{ID: id, UtilityID: util.ID, Status: models.DREventDraft, StartTime: now.Add(start), EndTime: now.Add(start + 2*time.Hour)}
+		require.NoError(t, store.DREvents.CreateDREvent(ctx, e))
+		_, err := store.DREvents.EnrollProjects(ctx, e, models.ParticipantTarget{ProjectIDs: []string{"p-1"}}, now.Add(-time.Hour))
+		require.NoError(t, err)
+		for _, action := range actions {
+			require.NoError(t, store.DREvents.TransitionDREvent(ctx, id, e, action, now.Add(-time.Hour)))
+		}
+	}
+	event("e-far", 3*time.Hour, models.DREventDispatch)
+	event("e-active", -30*time.Minute, models.DREventDispatch, models.DREventActivate)
+	event("e-draft", 4*time.Hour)
+	event("e-opted-out", 5*time.Hour, models.DREventDispatch)
+	_, err := store.DREvents.OptOut(ctx, "e-opted-out", "p-1", now)
+	require.NoError(t, err)
+
+	cfg := &config.OpenADRConfig{VTNID: "vtn-test", PollFrequency: 10 * time.Second, NearWindow: time.Hour, MarketContext: "urn:grid-stream:utility:"}
+	vtn := New(store, authz.NewPolicy(store.Projects), cfg, time.Hour, log)
+	vtn.now = func() time.Time { return now }
+	ids := 0
+	vtn.newID = func() string { ids++; return fmt.Sprintf("id-%d", ids) }
+
+	gateway := authz.Principal{Role: authz.RoleService, KeyID: "key-1", UtilityIDs: []string{util.ID}}
+	steps := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request) error
+	}{
+		{"query_registration", vtn.RegisterPartyHandler},
+		{"create_party_registration_push", vtn.RegisterPartyHandler},
+		{"create_party_registration", vtn.RegisterPartyHandler},
+		{"request_event_unregistered", vtn.EventHandler},
+		{"request_event", vtn.EventHandler},
+		{"poll", vtn.PollHandler}, // unchanged since the request, nothing to distribute
+		{"created_event", vtn.EventHandler},
+		{"poll_after_opt_out", vtn.PollHandler},
+		{"register_report", vtn.ReportHandler},
+		{"update_report", vtn.ReportHandler},
+		{"wrong_namespace", vtn.PollHandler},
+		{"cancel_party_registration", vtn.RegisterPartyHandler},
+	}
+	for _, step := range steps {
+		request := strings.TrimSuffix(step.name, "_after_opt_out")
+		body, err := os.ReadFile(filepath.Join("testdata", request+".request.xml"))
+		require.NoError(t, err, step.name)
+
+		req := httptest.NewRequest(http.MethodPost, "/OpenADR2/Simple/2.0b/", bytes.NewReader(body))
+		req = req.WithContext(authz.WithPrincipal(req.Context(), gateway))
+		rec := httptest.NewRecorder()
+		require.NoError(t, step.handler(rec, req), step.name)
+		require.Equal(t, http.StatusOK, rec.Code, step.name)
+		assert.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+
+		// the utility's id is a random uuid, it only shows up in the market context
+		got := strings.ReplaceAll(rec.Body.String(), util.ID, "u-1")
+		golden := filepath.Join("testdata", step.name+".golden.xml")
+		if *update {
+			require.NoError(t, os.WriteFile(golden, []byte(got), 0o644))
+		}
+		want, err := os.ReadFile(golden)
+		require.NoError(t, err, step.name)
+		assert.Equal(t, string(want), got, step.name)
+
+		// every prefix has to resolve to its namespace for a VEN to read the response
+		var payload requestPayload
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &payload), step.name)
+		assert.Equal(t, xml.Name{Space: nsOADR, Local: "oadrPayload"}, payload.XMLName, step.name)
+	}
+
+	// the optOut came before the cutoff so the project left the event, the optIn is only recorded
+	participants, err := store.DREvents.GetParticipants(ctx, "e-far")
+	require.NoError(t, err)
+	require.Len(t, participants, 1)
+	assert.Equal(t, models.ParticipantOptedOut, participants[0].Status)
+	assert.Equal(t, models.VENOptOut, participants[0].VENOpt)
+	participants, err = store.DREvents.GetParticipants(ctx, "e-active")
+	require.NoError(t, err)
+	assert.Equal(t, models.ParticipantEnrolled, participants[0].Status)
+	assert.Equal(t, models.VENOptIn, participants[0].VENOpt)
+
+	_, err = store.VENs.GetVEN(ctx, "ven-1")
+	assert.Error(t, err, "the cancelled registration should be gone")
+}
+
+// TestOptOutPastCutoff keeps the project in an event starting within the cutoff, the VEN's answer is still recorded
+func TestOptOutPastCutoff(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	now := time.Now()
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+	e := &models.DREvents{ID: "e-soon", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(30 * time.Minute), EndTime: now.Add(time.Hour)}
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, e))
+	_, err := store.DREvents.EnrollProjects(ctx, e, models.ParticipantTarget{ProjectIDs: []string{"p-1"}}, now)
+	require.NoError(t, err)
+	require.NoError(t, store.VENs.CreateVEN(ctx, &models.VEN{ID: "ven-1", RegistrationID: "reg-1", ProjectID: "p-1", RegisteredAt: now}))
+
+	vtn := New(store, authz.NewPolicy(store.Projects), &config.OpenADRConfig{}, time.Hour, log)
+	ven, err := store.VENs.GetVEN(ctx, "ven-1")
+	require.NoError(t, err)
+	require.NoError(t, vtn.recordResponse(ctx, ven, eventResponse{EventID: "e-soon", OptType: "optOut"}, now))
+
+	participants, err := store.DREvents.GetParticipants(ctx, "e-soon")
+	require.NoError(t, err)
+	assert.Equal(t, models.ParticipantEnrolled, participants[0].Status)
+	assert.Equal(t, models.VENOptOut, participants[0].VENOpt)
+
+	var oerr *oadrError
+	require.ErrorAs(t, vtn.recordResponse(ctx, ven, eventResponse{EventID: "e-missing", OptType: "optIn"}, now), &oerr)
+	assert.Equal(t, codeInvalidID, oerr.code)
+}
+
+func TestDuration(t *testing.T) {
+	assert.Equal(t, "PT0S", duration(0))
+	assert.Equal(t, "PT10S", duration(10*time.Second))
+	assert.Equal(t, "PT2H", duration(2*time.Hour))
+	assert.Equal(t, "PT1H30M", duration(90*time.Minute))
+	assert.Equal(t, "PT1H5S", duration(time.Hour+5*time.Second))
+}
@@ -0,0 +1,246 @@
+package openadr
+
+import "encoding/xml"
+
+// Namespaces of the OpenADR 2.0b schema, responses bind each to the prefix the specification's examples use
+const (
+	nsOADR = "http://openadr.org/oadr-2.0b/2012/07"
+	nsEI   = "http://docs.oasis-open.org/ns/energyinterop/201110"
+	nsPyld = "http://docs.oasis-open.org/ns/energyinterop/201110/payloads"
+	nsEMIX = "http://docs.oasis-open.org/ns/emix/2011/06"
+	nsXCal = "urn:ietf:params:xml:ns:icalendar-2.0"
+	nsStrm = "urn:ietf:params:xml:ns:icalendar-2.0:stream"
+	nsXSI  = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+const (
+	schemaVersion = "2.0b"
+	profileName   = "2.0b"
+	transportName = "simpleHttp"
+)
+
+// Requests are decoded by local name, the decoder already resolved the prefixes a VEN chose, and only the
+// root's namespace is checked so a VEN speaking another OpenADR version is told so rather than half understood.
+
+type requestPayload struct {
+	XMLName xml.Name
+	Signed  signedRequest `xml:"oadrSignedObject"`
+}
+
+// signedRequest holds the one message of a request, the others stay nil
+type signedRequest struct {
+	QueryRegistration       *queryRegistration       `xml:"oadrQueryRegistration"`
+	CreatePartyRegistration *createPartyRegistration `xml:"oadrCreatePartyRegistration"`
+	CancelPartyRegistration *cancelPartyRegistration `xml:"oadrCancelPartyRegistration"`
+	RequestEvent            *requestEvent            `xml:"oadrRequestEvent"`
+	CreatedEvent            *createdEvent            `xml:"oadrCreatedEvent"`
+	RegisterReport          *registerReport          `xml:"oadrRegisterReport"`
+	UpdateReport            *updateReport            `xml:"oadrUpdateReport"`
+	CreatedReport           *createdReport           `xml:"oadrCreatedReport"`
+	Poll                    *poll                    `xml:"oadrPoll"`
+}
+
+type queryRegistration struct {
+	RequestID string `xml:"requestID"`
+}
+
+type createPartyRegistration struct {
+	RequestID      string `xml:"requestID"`
+	RegistrationID string `xml:"registrationID"`
+	VenID          string `xml:"venID"`
+	ProfileName    string `xml:"oadrProfileName"`
+	TransportName  string `xml:"oadrTransportName"`
+	ReportOnly     bool   `xml:"oadrReportOnly"`
+	HTTPPullModel  *bool  `xml:"oadrHttpPullModel"`
+	VenName        string `xml:"oadrVenName"`
+}
+
+type cancelPartyRegistration struct {
+	RequestID      string `xml:"requestID"`
+	RegistrationID string `xml:"registrationID"`
+	VenID          string `xml:"venID"`
+}
+
+type requestEvent struct {
+	RequestID  string `xml:"eiRequestEvent>requestID"`
+	VenID      string `xml:"eiRequestEvent>venID"`
+	ReplyLimit int    `xml:"eiRequestEvent>replyLimit"`
+}
+
+type createdEvent struct {
+	RequestID string          `xml:"eiCreatedEvent>eiResponse>requestID"`
+	Responses []eventResponse `xml:"eiCreatedEvent>eventResponses>eventResponse"`
+	VenID     string          `xml:"eiCreatedEvent>venID"`
+}
+
+type eventResponse struct {
+	ResponseCode       int    `xml:"responseCode"`
+	EventID            string `xml:"qualifiedEventID>eventID"`
+	ModificationNumber int64  `xml:"qualifiedEventID>modificationNumber"`
+	OptType            string `xml:"optType"`
+}
+
+type registerReport struct {
+	RequestID string         `xml:"requestID"`
+	Reports   []reportHeader `xml:"oadrReport"`
+	VenID     string         `xml:"venID"`
+}
+
+type updateReport struct {
+	RequestID string         `xml:"requestID"`
+	Reports   []reportHeader `xml:"oadrReport"`
+	VenID     string         `xml:"venID"`
+}
+
+type reportHeader struct {
+	ReportSpecifierID string `xml:"reportSpecifierID"`
+	ReportName        string `xml:"reportName"`
+}
+
+type createdReport struct {
+	RequestID string `xml:"eiResponse>requestID"`
+	VenID     string `xml:"venID"`
+}
+
+type poll struct {
+	VenID string `xml:"venID"`
+}
+
+// Responses spell out their prefixes, encoding/xml would otherwise declare a namespace on every element
+
+type responsePayload struct {
+	XMLName xml.Name       `xml:"oadr:oadrPayload"`
+	OADR    string         `xml:"xmlns:oadr,attr"`
+	EI      string         `xml:"xmlns:ei,attr"`
+	Pyld    string         `xml:"xmlns:pyld,attr"`
+	EMIX    string         `xml:"xmlns:emix,attr"`
+	XCal    string         `xml:"xmlns:xcal,attr"`
+	Strm    string         `xml:"xmlns:strm,attr"`
+	XSI     string         `xml:"xmlns:xsi,attr"`
+	Signed  signedResponse `xml:"oadr:oadrSignedObject"`
+}
+
+func newPayload(signed signedResponse) *responsePayload {
+	return &responsePayload{
+		OADR: nsOADR, EI: nsEI, Pyld: nsPyld, EMIX: nsEMIX, XCal: nsXCal, Strm: nsStrm, XSI: nsXSI,
+		Signed: signed,
+	}
+}
+
+type signedResponse struct {
+	CreatedPartyRegistration  *createdPartyRegistration  `xml:"oadr:oadrCreatedPartyRegistration,omitempty"`
+	CanceledPartyRegistration *canceledPartyRegistration `xml:"oadr:oadrCanceledPartyRegistration,omitempty"`
+	DistributeEvent           *distributeEvent           `xml:"oadr:oadrDistributeEvent,omitempty"`
+	RegisteredReport          *registeredReport          `xml:"oadr:oadrRegisteredReport,omitempty"`
+	UpdatedReport             *updatedReport             `xml:"oadr:oadrUpdatedReport,omitempty"`
+	Response                  *response                  `xml:"oadr:oadrResponse,omitempty"`
+}
+
+type eiResponse struct {
+	ResponseCode        int    `xml:"ei:responseCode"`
+	ResponseDescription string `xml:"ei:responseDescription,omitempty"`
+	RequestID           string `xml:"pyld:requestID"`
+}
+
+type createdPartyRegistration struct {
+	SchemaVersion  string     `xml:"ei:schemaVersion,attr"`
+	Response       eiResponse `xml:"ei:eiResponse"`
+	RegistrationID string     `xml:"ei:registrationID,omitempty"`
+	VenID          string     `xml:"ei:venID,omitempty"`
+	VtnID          string     `xml:"ei:vtnID"`
+	Profiles       []profile  `xml:"oadr:oadrProfiles>oadr:oadrProfile"`
+	PollFreq       string     `xml:"oadr:oadrRequestedOadrPollFreq>xcal:duration"`
+}
+
+type profile struct {
+	Name       string      `xml:"oadr:oadrProfileName"`
+	Transports []transport `xml:"oadr:oadrTransports>oadr:oadrTransport"`
+}
+
+type transport struct {
+	Name string `xml:"oadr:oadrTransportName"`
+}
+
+type canceledPartyRegistration struct {
+	SchemaVersion  string     `xml:"ei:schemaVersion,attr"`
+	Response       eiResponse `xml:"ei:eiResponse"`
+	RegistrationID string     `xml:"ei:registrationID"`
+	VenID          string     `xml:"ei:venID"`
+}
+
+type distributeEvent struct {
+	SchemaVersion string      `xml:"ei:schemaVersion,attr"`
+	Response      *eiResponse `xml:"ei:eiResponse,omitempty"`
+	RequestID     string      `xml:"pyld:requestID"`
+	VtnID         string      `xml:"ei:vtnID"`
+	Events        []oadrEvent `xml:"oadr:oadrEvent"`
+}
+
+type registeredReport struct {
+	SchemaVersion string     `xml:"ei:schemaVersion,attr"`
+	Response      eiResponse `xml:"ei:eiResponse"`
+	VenID         string     `xml:"ei:venID"`
+}
+
+type updatedReport struct {
+	SchemaVersion string     `xml:"ei:schemaVersion,attr"`
+	Response      eiResponse `xml:"ei:eiResponse"`
+	VenID         string     `xml:"ei:venID"`
+}
+
+type response struct {
+	SchemaVersion string     `xml:"ei:schemaVersion,attr"`
+	Response      eiResponse `xml:"ei:eiResponse"`
+	VenID         string     `xml:"ei:venID,omitempty"`
+}
+
+type oadrEvent struct {
+	Event            eiEvent `xml:"ei:eiEvent"`
+	ResponseRequired string  `xml:"oadr:oadrResponseRequired"`
+}
+
+type eiEvent struct {
+	Descriptor   eventDescriptor `xml:"ei:eventDescriptor"`
+	ActivePeriod activePeriod    `xml:"ei:eiActivePeriod"`
+	Signals      []eventSignal   `xml:"ei:eiEventSignals>ei:eiEventSignal"`
+	Target       target          `xml:"ei:eiTarget"`
+}
+
+type eventDescriptor struct {
+	EventID            string `xml:"ei:eventID"`
+	ModificationNumber int64  `xml:"ei:modificationNumber"`
+	Priority           int    `xml:"ei:priority"`
+	MarketContext      string `xml:"ei:eiMarketContext>emix:marketContext"`
+	CreatedDateTime    string `xml:"ei:createdDateTime"`
+	EventStatus        string `xml:"ei:eventStatus"`
+	TestEvent          bool   `xml:"ei:testEvent"`
+}
+
+type activePeriod struct {
+	Start      string     `xml:"xcal:properties>xcal:dtstart>xcal:date-time"`
+	Duration   string     `xml:"xcal:properties>xcal:duration>xcal:duration"`
+	Components components `xml:"xcal:components"`
+}
+
+// components is the empty xcal:components the schema requires, marked nil like the specification's examples
+type components struct {
+	Nil bool `xml:"xsi:nil,attr"`
+}
+
+type eventSignal struct {
+	Intervals    []interval `xml:"strm:intervals>ei:interval"`
+	SignalName   string     `xml:"ei:signalName"`
+	SignalType   string     `xml:"ei:signalType"`
+	SignalID     string     `xml:"ei:signalID"`
+	CurrentValue float64    `xml:"ei:currentValue>ei:payloadFloat>ei:value"`
+}
+
+type interval struct {
+	Duration string  `xml:"xcal:duration>xcal:duration"`
+	UID      string  `xml:"xcal:uid>xcal:text"`
+	Value    float64 `xml:"ei:signalPayload>ei:payloadFloat>ei:value"`
+}
+
+type target struct {
+	VenIDs []string `xml:"ei:venID"`
+}
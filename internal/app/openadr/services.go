@@ -0,0 +1,242 @@
+package openadr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// RegisterPartyHandler serves EiRegisterParty, where VENs query, create and cancel their registration
+func (h *VTN) RegisterPartyHandler(w http.ResponseWriter, r *http.Request) error {
+	accepts := func(req *signedRequest) bool {
+		return req.QueryRegistration != nil || req.CreatePartyRegistration != nil || req.CancelPartyRegistration != nil
+	}
+	return h.serve(w, r, "EiRegisterParty", accepts, func(ctx context.Context, req *signedRequest) (*signedResponse, error) {
+		switch {
+		case req.QueryRegistration != nil:
+			return &signedResponse{CreatedPartyRegistration: h.created(req.QueryRegistration.RequestID, nil)}, nil
+		case req.CreatePartyRegistration != nil:
+			return h.register(ctx, req.CreatePartyRegistration)
+		default:
+			return h.cancelRegistration(ctx, req.CancelPartyRegistration)
+		}
+	})
+}
+
+// EventHandler serves EiEvent, where VENs request their events and answer them with oadrCreatedEvent
+func (h *VTN) EventHandler(w http.ResponseWriter, r *http.Request) error {
+	accepts := func(req *signedRequest) bool { return req.RequestEvent != nil || req.CreatedEvent != nil }
+	return h.serve(w, r, "EiEvent", accepts, func(ctx context.Context, req *signedRequest) (*signedResponse, error) {
+		if req.RequestEvent != nil {
+			return h.requestEvent(ctx, req.RequestEvent)
+		}
+		return h.createdEvent(ctx, req.CreatedEvent)
+	})
+}
+
+// ReportHandler serves EiReport. Telemetry reaches the API through project averages, so reports are
+// acknowledged without requesting any.
+func (h *VTN) ReportHandler(w http.ResponseWriter, r *http.Request) error {
+	accepts := func(req *signedRequest) bool {
+		return req.RegisterReport != nil || req.UpdateReport != nil || req.CreatedReport != nil
+	}
+	return h.serve(w, r, "EiReport", accepts, func(ctx context.Context, req *signedRequest) (*signedResponse, error) {
+		requestID, venID := req.ids()
+		ven, err := h.ven(ctx, venID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case req.RegisterReport != nil:
+			logging.FromContext(ctx, h.log).DebugContext(ctx, "ven registered reports", "ven_id", ven.ID, "reports", len(req.RegisterReport.Reports))
+			return &signedResponse{RegisteredReport: &registeredReport{SchemaVersion: schemaVersion, Response: ok(requestID), VenID: ven.ID}}, nil
+		case req.UpdateReport != nil:
+			return &signedResponse{UpdatedReport: &updatedReport{SchemaVersion: schemaVersion, Response: ok(requestID), VenID: ven.ID}}, nil
+		default:
+			return &signedResponse{Response: &response{SchemaVersion: schemaVersion, Response: ok(requestID), VenID: ven.ID}}, nil
+		}
+	})
+}
+
+// PollHandler serves OadrPoll, events are only distributed again once they changed since the VEN last got them
+func (h *VTN) PollHandler(w http.ResponseWriter, r *http.Request) error {
+	accepts := func(req *signedRequest) bool { return req.Poll != nil }
+	return h.serve(w, r, "OadrPoll", accepts, func(ctx context.Context, req *signedRequest) (*signedResponse, error) {
+		ven, err := h.ven(ctx, req.Poll.VenID)
+		if err != nil {
+			return nil, err
+		}
+		distribute, err := h.distribute(ctx, ven, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if distribute == nil {
+			return &signedResponse{Response: &response{SchemaVersion: schemaVersion, Response: ok(""), VenID: ven.ID}}, nil
+		}
+		return &signedResponse{DistributeEvent: distribute}, nil
+	})
+}
+
+// created is the registration a VEN is told about, ven is nil when it isn't registered
+func (h *VTN) created(requestID string, ven *models.VEN) *createdPartyRegistration {
+	res := &createdPartyRegistration{
+		SchemaVersion: schemaVersion,
+		Response:      ok(requestID),
+		VtnID:         h.cfg.VTNID,
+		Profiles:      []profile{{Name: profileName, Transports: []transport{{Name: transportName}}}},
+		PollFreq:      duration(h.cfg.PollFrequency),
+	}
+	if ven != nil {
+		res.RegistrationID = ven.RegistrationID
+		res.VenID = ven.ID
+	}
+	return res
+}
+
+// register creates a registration for the project named by venName, a VEN re-registering with its
+// registrationID is told the registration it already has
+func (h *VTN) register(ctx context.Context, req *createPartyRegistration) (*signedResponse, error) {
+	switch {
+	case req.ProfileName != profileName:
+		return nil, invalidData("Only the 2.0b profile is supported")
+	case req.TransportName != transportName:
+		return nil, invalidData("Only the simpleHttp transport is supported")
+	case req.HTTPPullModel != nil && !*req.HTTPPullModel:
+		return nil, invalidData("Only the pull model is supported")
+	}
+
+	if req.RegistrationID != "" {
+		ven, err := h.ven(ctx, req.VenID)
+		if err != nil {
+			return nil, err
+		}
+		if ven.RegistrationID != req.RegistrationID {
+			return nil, invalidID("registrationID does not match the VEN's registration")
+		}
+		return &signedResponse{CreatedPartyRegistration: h.created(req.RequestID, ven)}, nil
+	}
+
+	if req.VenName == "" {
+		return nil, invalidData("oadrVenName must be the id of the VEN's project")
+	}
+	if err := h.authorize(ctx, req.VenName); err != nil {
+		return nil, err
+	}
+	// a VEN may bring its own venID, usually its certificate's fingerprint
+	venID := req.VenID
+	if venID == "" {
+		venID = h.newID()
+	} else if _, err := h.store.VENs.GetVEN(ctx, venID); err == nil {
+		return nil, invalidID("venID is already registered")
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	ven := &models.VEN{
+		ID:             venID,
+		RegistrationID: h.newID(),
+		ProjectID:      req.VenName,
+		Name:           req.VenName,
+		RegisteredAt:   h.now(),
+	}
+	if err := h.store.VENs.CreateVEN(ctx, ven); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx, h.log).InfoContext(ctx, "ven registered", "ven_id", ven.ID, "project_id", ven.ProjectID)
+	return &signedResponse{CreatedPartyRegistration: h.created(req.RequestID, ven)}, nil
+}
+
+func (h *VTN) cancelRegistration(ctx context.Context, req *cancelPartyRegistration) (*signedResponse, error) {
+	ven, err := h.ven(ctx, req.VenID)
+	if err != nil {
+		return nil, err
+	}
+	if ven.RegistrationID != req.RegistrationID {
+		return nil, invalidID("registrationID does not match the VEN's registration")
+	}
+	if err := h.store.VENs.DeleteVEN(ctx, ven.ID); err != nil {
+		return nil, err
+	}
+	return &signedResponse{CanceledPartyRegistration: &canceledPartyRegistration{
+		SchemaVersion:  schemaVersion,
+		Response:       ok(req.RequestID),
+		RegistrationID: ven.RegistrationID,
+		VenID:          ven.ID,
+	}}, nil
+}
+
+func (h *VTN) requestEvent(ctx context.Context, req *requestEvent) (*signedResponse, error) {
+	ven, err := h.ven(ctx, req.VenID)
+	if err != nil {
+		return nil, err
+	}
+	res := ok(req.RequestID)
+	distribute, err := h.distribute(ctx, ven, &res, req.ReplyLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &signedResponse{DistributeEvent: distribute}, nil
+}
+
+// createdEvent records the VEN's opt responses, an optOut the project can still make also opts it out
+func (h *VTN) createdEvent(ctx context.Context, req *createdEvent) (*signedResponse, error) {
+	ven, err := h.ven(ctx, req.VenID)
+	if err != nil {
+		return nil, err
+	}
+	now := h.now()
+	var failed error
+	for _, res := range req.Responses {
+		if err := h.recordResponse(ctx, ven, res, now); err != nil {
+			var oerr *oadrError
+			if !errors.As(err, &oerr) {
+				return nil, err
+			}
+			// the other responses are still recorded, the first failure is reported
+			if failed == nil {
+				failed = err
+			}
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	return &signedResponse{Response: &response{SchemaVersion: schemaVersion, Response: ok(req.RequestID), VenID: ven.ID}}, nil
+}
+
+func (h *VTN) recordResponse(ctx context.Context, ven *models.VEN, res eventResponse, now time.Time) error {
+	opt := models.VENOpt(res.OptType)
+	if !opt.IsValid() {
+		return invalidData("optType must be optIn or optOut")
+	}
+	event, err := h.store.DREvents.GetDREvent(ctx, res.EventID)
+	if err != nil {
+		if isNotFound(err) {
+			return invalidID("Unknown eventID " + res.EventID)
+		}
+		return err
+	}
+	if err := h.store.DREvents.RecordVENResponse(ctx, event.ID, ven.ProjectID, opt, now); err != nil {
+		if isNotFound(err) {
+			return invalidID("VEN is not a participant of event " + event.ID)
+		}
+		return err
+	}
+	// past the cutoff the optOut stays on record for the utility, but the project is still expected to curtail
+	if opt != models.VENOptOut || event.CheckOptOut(now, h.optOutCutoff) != nil {
+		return nil
+	}
+	if _, err := h.store.DREvents.OptOut(ctx, event.ID, ven.ProjectID, now); err != nil {
+		var customErr *custom_error.CustomError
+		if errors.As(err, &customErr) && customErr.Code == http.StatusConflict {
+			return nil // already opted out
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package openadr3
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/grid-stream-org/api/internal/config"
+)
+
+// errPrivateAddress refuses a callback that resolved to an address inside the network
+var errPrivateAddress = errors.New("callback address isn't public")
+
+// newCallbackClient makes the client subscribers are called back with. Callback urls come from callers, so it
+// doesn't follow redirects, ignores proxy settings and only connects to public addresses. The address is checked
+// when dialing, after DNS resolution, so a name pointing inside the network is refused as well.
+func newCallbackClient(cfg *config.OpenADR3Config) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: cfg.CallbackTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if cfg.AllowPrivateCallbacks {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   cfg.CallbackTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// nonPublic are the ranges the net.IP predicates miss: this network, carrier-grade NAT and NAT64, which
+// translates to IPv4 addresses that may be private
+var nonPublic = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("64:ff9b::/96"),
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// isPublic reports whether ip is routable on the internet, not loopback, private, link-local, unspecified,
+// carrier-grade NAT or NAT64
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range nonPublic {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,384 @@
+package openadr3
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// every program's events ask for a relative setpoint in kW, and for reports of the demand against the baseline
+var (
+	eventPayloadDescriptors = []payloadDescriptor{
+		{ObjectType: descriptorEvent, PayloadType: payloadSetpointRelative, Units: unitsKW},
+	}
+	eventReportDescriptors = []reportDescriptor{
+		{PayloadType: payloadDemand, ReadingType: readingDirect, Units: unitsKW, Historical: true},
+		{PayloadType: payloadBaseline, Units: unitsKW, Historical: true},
+	}
+)
+
+// viewer is what one caller may read, built once per request or per subscriber of a notification
+type viewer struct {
+	principal authz.Principal
+	scope     authz.Scope
+	// projects are the caller's own projects and their utilities, when the scope doesn't cover whole utilities
+	projects   map[string]string
+	thresholds map[string]float64
+}
+
+func (h *VTN) viewer(ctx context.Context, p authz.Principal) (*viewer, error) {
+	v := &viewer{principal: p, scope: authz.ProjectScope(p), projects: map[string]string{}, thresholds: map[string]float64{}}
+	if v.scope.All || (v.scope.UserID == "" && len(v.scope.ProjectIDs) == 0) {
+		return v, nil
+	}
+	own := authz.Scope{UserID: v.scope.UserID, ProjectIDs: v.scope.ProjectIDs}
+	projects, err := collect(0, func(page pagination.Params) (pagination.Page[models.Project], error) {
+		return h.store.Projects.ListProjects(ctx, repositories.ProjectFilter{Scope: own}, page)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		v.projects[project.ID] = project.UtilityID
+	}
+	return v, nil
+}
+
+func (h *VTN) requestViewer(r *http.Request) (*viewer, error) {
+	p, err := principal(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	return h.viewer(r.Context(), p)
+}
+
+// program reports whether the utility's program is visible, either whole or through one of the caller's projects
+func (v *viewer) program(utilityID string) bool {
+	if v.scope.Utility(utilityID) {
+		return true
+	}
+	for _, u := range v.projects {
+		if u == utilityID {
+			return true
+		}
+	}
+	return false
+}
+
+// resources are the enrolled projects of the event the caller may see, visible is whether they may see the event
+func (v *viewer) resources(e *models.DREvents) (resources []string, visible bool) {
+	whole := v.scope.Utility(e.UtilityID)
+	visible = whole
+	for _, p := range e.Participants {
+		if _, own := v.projects[p.ProjectID]; !whole && !own {
+			continue
+		}
+		visible = true
+		if p.Status == models.ParticipantEnrolled {
+			resources = append(resources, p.ProjectID)
+		}
+	}
+	sort.Strings(resources)
+	return resources, visible
+}
+
+// threshold is the contract threshold of the project's active contract, 0 without one
+func (h *VTN) threshold(ctx context.Context, v *viewer, projectID string) (float64, error) {
+	if t, ok := v.thresholds[projectID]; ok {
+		return t, nil
+	}
+	page, err := h.store.Contracts.GetContractsByProjectID(ctx, projectID,
+		repositories.ContractFilter{Status: models.Active}, pagination.Params{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	t := 0.0
+	if len(page.Data) > 0 {
+		t = page.Data[0].ContractThreshold
+	}
+	v.thresholds[projectID] = t
+	return t, nil
+}
+
+// programs are the utilities whose programs the caller may see, in id order
+func (h *VTN) programs(ctx context.Context, v *viewer) ([]models.Utility, error) {
+	utilities := []models.Utility{}
+	if v.scope.All || len(v.scope.UtilityIDs) > 0 {
+		var err error
+		scope := authz.Scope{All: v.scope.All, UtilityIDs: v.scope.UtilityIDs}
+		utilities, err = collect(0, func(page pagination.Params) (pagination.Page[models.Utility], error) {
+			return h.store.Utilities.ListUtilities(ctx, repositories.UtilityFilter{Scope: scope}, page)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, utilityID := range v.projects {
+		if slices.ContainsFunc(utilities, func(u models.Utility) bool { return u.ID == utilityID }) {
+			continue
+		}
+		u, err := h.store.Utilities.GetUtility(ctx, utilityID)
+		if isNotFound(err) {
+			continue // a project left behind by a deleted utility has no program
+		}
+		if err != nil {
+			return nil, err
+		}
+		utilities = append(utilities, *u)
+	}
+	sort.Slice(utilities, func(i, j int) bool { return utilities[i].ID < utilities[j].ID })
+	return utilities, nil
+}
+
+// program maps a utility to its program, the targets are the caller's projects under an active contract
+func (h *VTN) program(ctx context.Context, v *viewer, u *models.Utility) (*program, error) {
+	active := true
+	projects, err := collect(0, func(page pagination.Params) (pagination.Page[models.Project], error) {
+		return h.store.Projects.ListProjects(ctx, repositories.ProjectFilter{Scope: v.scope, UtilityID: u.ID, HasActiveContract: &active}, page)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resources := []any{}
+	for _, p := range projects {
+		resources = append(resources, p.ID)
+	}
+	return &program{
+		ID:                 u.ID,
+		ObjectType:         objectProgram,
+		ProgramName:        u.DisplayName,
+		RetailerName:       u.DisplayName,
+		PayloadDescriptors: eventPayloadDescriptors,
+		Targets:            []valuesMap{{Type: targetResourceName, Values: resources}},
+	}, nil
+}
+
+// ListProgramsHandler serves GET /programs
+func (h *VTN) ListProgramsHandler(w http.ResponseWriter, r *http.Request) error {
+	win, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	utilities, err := h.programs(r.Context(), v)
+	if err != nil {
+		return err
+	}
+	programs := []*program{}
+	page := apply(win, utilities)
+	for i := range page {
+		p, err := h.program(r.Context(), v, &page[i])
+		if err != nil {
+			return err
+		}
+		programs = append(programs, p)
+	}
+	return writeJSON(w, http.StatusOK, programs)
+}
+
+// GetProgramHandler serves GET /programs/{programID}
+func (h *VTN) GetProgramHandler(w http.ResponseWriter, r *http.Request) error {
+	id := chi.URLParam(r, "programID")
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	if !v.program(id) {
+		return custom_error.New(http.StatusNotFound, "Program not found", nil)
+	}
+	u, err := h.store.Utilities.GetUtility(r.Context(), id)
+	if err != nil {
+		if isNotFound(err) {
+			return custom_error.New(http.StatusNotFound, "Program not found", nil)
+		}
+		return err
+	}
+	p, err := h.program(r.Context(), v, u)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, p)
+}
+
+// event maps a DR event with its participants to the event the caller sees, nil when they may not see it. The
+// interval asks the targeted projects for the reduction their active contracts promised.
+func (h *VTN) event(ctx context.Context, v *viewer, e *models.DREvents) (*event, error) {
+	resources, visible := v.resources(e)
+	if !visible {
+		return nil, nil
+	}
+	reduction := 0.0
+	targets := []any{}
+	for _, id := range resources {
+		t, err := h.threshold(ctx, v, id)
+		if err != nil {
+			return nil, err
+		}
+		reduction += t
+		targets = append(targets, id)
+	}
+
+	out := &event{
+		ID:                 e.ID,
+		ObjectType:         objectEvent,
+		ProgramID:          e.UtilityID,
+		Targets:            []valuesMap{{Type: targetResourceName, Values: targets}},
+		PayloadDescriptors: eventPayloadDescriptors,
+		ReportDescriptors:  eventReportDescriptors,
+		IntervalPeriod:     &intervalPeriod{Start: dateTime(e.StartTime), Duration: duration(e.EndTime.Sub(e.StartTime))},
+		Intervals: []interval{{
+			ID:       0,
+			Payloads: []valuesMap{{Type: payloadSetpointRelative, Values: []any{-reduction}}},
+		}},
+	}
+	// an event is published when it is dispatched, every later transition modifies it
+	var modified time.Time
+	for _, stamp := range []struct {
+		valid bool
+		at    time.Time
+	}{
+		{e.ScheduledAt.Valid, e.ScheduledAt.Timestamp},
+		{e.ActivatedAt.Valid, e.ActivatedAt.Timestamp},
+		{e.CompletedAt.Valid, e.CompletedAt.Timestamp},
+		{e.CancelledAt.Valid, e.CancelledAt.Timestamp},
+	} {
+		if stamp.valid && stamp.at.After(modified) {
+			modified = stamp.at
+		}
+	}
+	if e.ScheduledAt.Valid {
+		out.CreatedDateTime = dateTime(e.ScheduledAt.Timestamp)
+	}
+	if !modified.IsZero() {
+		out.ModificationDateTime = dateTime(modified)
+	}
+	return out, nil
+}
+
+// published reports whether clients see the event at all, drafts aren't dispatched yet and cancelled events
+// are deleted as far as the specification goes
+func published(e *models.DREvents) bool {
+	return e.Status != models.DREventDraft && e.Status != models.DREventCancelled
+}
+
+// ListEventsHandler serves GET /events, filtered by programID and by a targetType and its targetValues
+func (h *VTN) ListEventsHandler(w http.ResponseWriter, r *http.Request) error {
+	win, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	utilities, err := h.programs(r.Context(), v)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	programID := q.Get("programID")
+	target := valuesMap{Type: q.Get("targetType")}
+	for _, value := range q["targetValues"] {
+		target.Values = append(target.Values, value)
+	}
+
+	type dated struct {
+		start time.Time
+		event *event
+	}
+	var all []dated
+	for _, u := range utilities {
+		if programID != "" && u.ID != programID {
+			continue
+		}
+		events, err := collect(0, func(page pagination.Params) (pagination.Page[models.DREvents], error) {
+			page.Sort = "start_time"
+			return h.store.DREvents.GetDREventsByUtilityID(r.Context(), u.ID, repositories.DREventFilter{}, page)
+		})
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			if !published(&events[i]) {
+				continue
+			}
+			e, err := h.event(r.Context(), v, &events[i])
+			if err != nil {
+				return err
+			}
+			if e != nil && (target.Type == "" || targeted(e.Targets, target)) {
+				all = append(all, dated{start: events[i].StartTime, event: e})
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+
+	events := []*event{}
+	for _, d := range apply(win, all) {
+		events = append(events, d.event)
+	}
+	return writeJSON(w, http.StatusOK, events)
+}
+
+// GetEventHandler serves GET /events/{eventID}
+func (h *VTN) GetEventHandler(w http.ResponseWriter, r *http.Request) error {
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	e, err := h.loadEvent(r.Context(), chi.URLParam(r, "eventID"))
+	if err != nil {
+		return err
+	}
+	out, err := h.event(r.Context(), v, e)
+	if err != nil {
+		return err
+	}
+	if out == nil || !published(e) {
+		return custom_error.New(http.StatusNotFound, "Event not found", nil)
+	}
+	return writeJSON(w, http.StatusOK, out)
+}
+
+// loadEvent reads a DR event with its participants
+func (h *VTN) loadEvent(ctx context.Context, id string) (*models.DREvents, error) {
+	e, err := h.store.DREvents.GetDREvent(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, custom_error.New(http.StatusNotFound, "Event not found", nil)
+		}
+		return nil, err
+	}
+	if e.Participants, err = h.store.DREvents.GetParticipants(ctx, id); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// targeted reports whether targets has any of the values of want's type
+func targeted(targets []valuesMap, want valuesMap) bool {
+	for _, t := range targets {
+		if t.Type != want.Type {
+			continue
+		}
+		for _, value := range t.Values {
+			if slices.Contains(want.Values, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package openadr3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/grid-stream-org/api/internal/app/apikeys"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/handlers/v1"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// eventOperation is how a change of a DR event looks to subscribers, empty when they don't hear of it. Drafts
+// aren't published, dispatching publishes an event and cancelling it takes it back.
+func eventOperation(e *models.DREvents, change handlers.DREventChange) string {
+	switch {
+	case !e.ScheduledAt.Valid:
+		return ""
+	case change == handlers.DREventDeleted:
+		if e.Status == models.DREventCancelled {
+			return "" // subscribers already heard of the cancellation
+		}
+		return operationDelete
+	case e.Status == models.DREventCancelled:
+		if change == handlers.DREventTransitioned {
+			return operationDelete
+		}
+		return ""
+	case change == handlers.DREventTransitioned && e.Status == models.DREventScheduled:
+		return operationPost
+	default:
+		return operationPut
+	}
+}
+
+// DREventChanged calls back the subscribers of the event's program who may see it. It returns right away, the
+// callbacks are made in the background.
+func (h *VTN) DREventChanged(ctx context.Context, e *models.DREvents, change handlers.DREventChange) {
+	operation := eventOperation(e, change)
+	if operation == "" {
+		return
+	}
+	changed := *e
+	h.background(ctx, func(ctx context.Context) {
+		if change != handlers.DREventDeleted {
+			participants, err := h.store.DREvents.GetParticipants(ctx, changed.ID)
+			if err != nil {
+				h.log.ErrorContext(ctx, "openadr event notification failed", "event_id", changed.ID, "error", err)
+				return
+			}
+			changed.Participants = participants
+		}
+		h.notify(ctx, changed.UtilityID, objectEvent, operation, func(v *viewer) (any, []valuesMap, error) {
+			out, err := h.event(ctx, v, &changed)
+			if out == nil || err != nil {
+				return nil, nil, err
+			}
+			return out, out.Targets, nil
+		})
+	})
+}
+
+// reportChanged calls back the subscribers of the report's program who may read it
+func (h *VTN) reportChanged(ctx context.Context, row *models.OpenADRReport, operation string) {
+	changed := *row
+	h.background(ctx, func(ctx context.Context) {
+		h.notify(ctx, changed.ProgramID, objectReport, operation, func(v *viewer) (any, []valuesMap, error) {
+			if !v.report(&changed) {
+				return nil, nil, nil
+			}
+			rep, err := toReport(&changed)
+			if err != nil {
+				return nil, nil, err
+			}
+			resources := []any{}
+			for _, res := range rep.Resources {
+				resources = append(resources, res.ResourceName)
+			}
+			return rep, []valuesMap{{Type: targetResourceName, Values: resources}}, nil
+		})
+	})
+}
+
+// background runs fn past the end of the request, Wait waits for it
+func (h *VTN) background(ctx context.Context, fn func(ctx context.Context)) {
+	h.deliveries.Add(1)
+	go func() {
+		defer h.deliveries.Done()
+		fn(context.WithoutCancel(ctx))
+	}()
+}
+
+// notify sends the object, as every subscriber of the program sees it, to the callbacks they asked to be called on
+// for the operation. build returns nil for a subscriber who may not see the object, the targets it returns are
+// matched against the subscription's.
+func (h *VTN) notify(ctx context.Context, programID, objectType, operation string, build func(v *viewer) (any, []valuesMap, error)) {
+	subs, err := collect(0, func(page pagination.Params) (pagination.Page[models.OpenADRSubscription], error) {
+		return h.store.OpenADR.ListSubscriptions(ctx, repositories.OpenADRSubscriptionFilter{ProgramID: programID}, page)
+	})
+	if err != nil {
+		h.log.ErrorContext(ctx, "openadr notification failed", "program_id", programID, "error", err)
+		return
+	}
+	for _, sub := range subs {
+		var body subscriptionBody
+		if err := json.Unmarshal([]byte(sub.Body), &body); err != nil {
+			h.log.ErrorContext(ctx, "openadr subscription unreadable", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		var callbacks []objectOperation
+		for _, op := range body.ObjectOperations {
+			if slices.Contains(op.Objects, objectType) && slices.Contains(op.Operations, operation) {
+				callbacks = append(callbacks, op)
+			}
+		}
+		if len(callbacks) == 0 {
+			continue
+		}
+
+		p, err := h.subscriber(ctx, sub.CreatedBy)
+		if err != nil {
+			h.log.WarnContext(ctx, "openadr subscriber not called back", "subscription_id", sub.ID, "created_by", sub.CreatedBy, "error", err)
+			continue
+		}
+		v, err := h.viewer(ctx, p)
+		if err != nil {
+			h.log.ErrorContext(ctx, "openadr notification failed", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		object, targets, err := build(v)
+		if err != nil {
+			h.log.ErrorContext(ctx, "openadr notification failed", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		if object == nil || !matches(body.Targets, targets) {
+			continue
+		}
+		// a slow subscriber doesn't hold up the others, the slots bound how many calls are in flight
+		for _, op := range callbacks {
+			h.background(ctx, func(ctx context.Context) {
+				h.deliver(ctx, sub.ID, op, notification{ObjectType: objectType, Operation: operation, Object: object})
+			})
+		}
+	}
+}
+
+// subscriber resolves who created a subscription as they are now, so a user who lost their role or a key that was
+// revoked, expired or lost the VEN scope stops hearing of changes
+func (h *VTN) subscriber(ctx context.Context, createdBy string) (authz.Principal, error) {
+	if id, ok := apikeys.KeyID(createdBy); ok {
+		if h.keys == nil {
+			return authz.Principal{}, apikeys.ErrInvalidKey
+		}
+		key, err := h.keys.Get(ctx, id)
+		if err != nil {
+			return authz.Principal{}, err
+		}
+		if !apikeys.HasScope(key, apikeys.ScopeOpenADRVEN) {
+			return authz.Principal{}, errors.New("api key lacks the openadr scope")
+		}
+		return apikeys.Principal(key), nil
+	}
+	if h.roles == nil {
+		return authz.Principal{}, roles.ErrNoMembership
+	}
+	membership, err := h.roles.Resolve(ctx, createdBy, nil)
+	if err != nil {
+		return authz.Principal{}, err
+	}
+	return membership.Principal(createdBy), nil
+}
+
+// matches reports whether the object's targets meet any of the subscription's, a subscription without targets
+// hears of everything
+func matches(subscribed, targets []valuesMap) bool {
+	if len(subscribed) == 0 {
+		return true
+	}
+	for _, want := range subscribed {
+		if targeted(targets, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver posts one notification. Failures are logged and not retried, subscribers catch up by reading the object.
+func (h *VTN) deliver(ctx context.Context, subscriptionID string, op objectOperation, n notification) {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	log := h.log.With("subscription_id", subscriptionID, "object_type", n.ObjectType, "operation", n.Operation)
+	payload, err := json.Marshal(n)
+	if err != nil {
+		log.ErrorContext(ctx, "openadr notification failed", "error", err)
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, op.CallbackURL, bytes.NewReader(payload))
+	if err != nil {
+		log.ErrorContext(ctx, "openadr notification failed", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if op.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+op.BearerToken)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.WarnContext(ctx, "openadr callback unreachable", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.WarnContext(ctx, "openadr callback refused notification", "status", resp.StatusCode)
+	}
+}
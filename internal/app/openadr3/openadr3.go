@@ -0,0 +1,196 @@
+// Package openadr3 is an OpenADR 3.0 VTN, the JSON REST flavour of OpenADR that newer VENs and aggregators speak.
+// Programs are derived from the utilities and the contracts of their projects, events from the dispatched DR events
+// and reports on an event become the project averages of the projects they name. Subscribers are called back when
+// events and reports change.
+//
+// Callers see what the authz policy lets them read: a homeowner's VEN the events its projects take part in, a utility
+// or a key acting for one every event of the utility. An event asks for the reduction the contracts of the targeted
+// projects promised, so a VEN of one project is asked for exactly its own.
+package openadr3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/apikeys"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// at most this many callbacks are in flight, the rest wait their turn
+const maxDeliveries = 16
+
+// VTN serves the programs, events, reports and subscriptions of the specification. Subscribers are resolved again
+// through the roles and API keys before each callback, without Firebase users aren't called back.
+type VTN struct {
+	store      *repositories.Store
+	policy     *authz.Policy
+	roles      *roles.Resolver
+	keys       *apikeys.Service
+	cfg        *config.OpenADR3Config
+	log        *slog.Logger
+	client     *http.Client
+	deliveries sync.WaitGroup
+	slots      chan struct{}
+	now        func() time.Time
+	newID      func() string
+}
+
+func New(store *repositories.Store, policy *authz.Policy, resolver *roles.Resolver, keys *apikeys.Service, cfg *config.OpenADR3Config, log *slog.Logger) *VTN {
+	return &VTN{
+		store:  store,
+		policy: policy,
+		roles:  resolver,
+		keys:   keys,
+		cfg:    cfg,
+		log:    log,
+		client: newCallbackClient(cfg),
+		slots:  make(chan struct{}, maxDeliveries),
+		now:    time.Now,
+		newID:  func() string { return uuid.New().String() },
+	}
+}
+
+// Wait blocks until every callback started so far was delivered or gave up
+func (h *VTN) Wait() {
+	h.deliveries.Wait()
+}
+
+// window is the skip and limit query parameters the lists of the specification page with
+type window struct {
+	skip  int
+	limit int
+}
+
+func parseWindow(r *http.Request) (window, error) {
+	w := window{limit: pagination.DefaultLimit}
+	var fields []custom_error.FieldError
+	parse := func(param string, min, max int, dst *int) {
+		s := r.URL.Query().Get(param)
+		if s == "" {
+			return
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			fields = append(fields, custom_error.FieldError{Field: param, Code: models.RuleRange, Message: "must be an integer from " + strconv.Itoa(min) + " to " + strconv.Itoa(max)})
+			return
+		}
+		*dst = n
+	}
+	parse("skip", 0, 1<<31-1, &w.skip)
+	parse("limit", 0, pagination.MaxLimit, &w.limit)
+	if len(fields) > 0 {
+		return w, custom_error.Validation(fields...)
+	}
+	return w, nil
+}
+
+// apply cuts the window out of rows
+func apply[T any](w window, rows []T) []T {
+	if w.skip >= len(rows) {
+		return []T{}
+	}
+	rows = rows[w.skip:]
+	if len(rows) > w.limit {
+		rows = rows[:w.limit]
+	}
+	return rows
+}
+
+// collect reads the pages of a list until it holds n rows or the list ends, every row for n 0
+func collect[T any](n int, list func(page pagination.Params) (pagination.Page[T], error)) ([]T, error) {
+	rows := []T{}
+	page := pagination.Params{Limit: pagination.MaxLimit}
+	for {
+		p, err := list(page)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, p.Data...)
+		if p.NextCursor == "" || (n > 0 && len(rows) >= n) {
+			return rows, nil
+		}
+		page.Cursor = p.NextCursor
+	}
+}
+
+func principal(ctx context.Context) (authz.Principal, error) {
+	p, ok := authz.PrincipalFrom(ctx)
+	if !ok {
+		return authz.Principal{}, custom_error.New(http.StatusUnauthorized, "Unauthorized", nil)
+	}
+	return p, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func isNotFound(err error) bool {
+	var customErr *custom_error.CustomError
+	return errors.As(err, &customErr) && customErr.Code == http.StatusNotFound
+}
+
+func dateTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// duration formats d as an ISO 8601 duration, such as PT1H30M
+func duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
+
+// isoDuration is the subset of ISO 8601 durations with a fixed length, years and months vary so they aren't accepted
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration reads an ISO 8601 duration such as PT15M or P1DT12H
+func parseDuration(s string) (time.Duration, bool) {
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, false
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d, true
+}
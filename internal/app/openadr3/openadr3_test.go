@@ -0,0 +1,464 @@
+package openadr3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/apikeys"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/handlers/v1"
+	"github.com/grid-stream-org/api/internal/app/middlewares"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type fixture struct {
+	t       *testing.T
+	store   *repositories.Store
+	members memberships
+	keys    *apikeys.Service
+	vtn     *VTN
+	router  chi.Router
+	utility string
+	now     time.Time
+}
+
+// memberships is the role store of the homeowners, subscribers are looked up in it before every callback
+type memberships map[string]*roles.Membership
+
+func (m memberships) Lookup(_ context.Context, uid string) (*roles.Membership, error) {
+	if membership, ok := m[uid]; ok {
+		return membership, nil
+	}
+	return nil, roles.ErrNoMembership
+}
+
+// newFixture is one utility with the projects of two homeowners, p-1 promising 5 kW and p-2 3 kW, and a dispatched
+// event both take part in next to a draft
+func newFixture(t *testing.T) *fixture {
+	ctx := context.Background()
+	store := memory.NewStore(log)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	for i, threshold := range []float64{5, 3} {
+		id := fmt.Sprintf("p-%d", i+1)
+		require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: id, UtilityID: util.ID, UserID: fmt.Sprintf("home-%d", i+1)}))
+		require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-" + id, ProjectID: id, ContractThreshold: threshold, Status: models.Active}))
+	}
+	event := func(id string, actions ...models.DREventAction) {
+		e := &models.DREvents{ID: id, UtilityID: util.ID, Status: models.DREventDraft, StartTime: now.Add(time.Hour), EndTime: now.Add(3 * time.Hour)}
+		require.NoError(t, store.DREvents.CreateDREvent(ctx, e))
+		_, err := store.DREvents.EnrollProjects(ctx, e, models.ParticipantTarget{ProjectIDs: []string{"p-1", "p-2"}}, now)
+		require.NoError(t, err)
+		for _, action := range actions {
+			require.NoError(t, store.DREvents.TransitionDREvent(ctx, id, e, action, now))
+		}
+	}
+	event("e-1", models.DREventDispatch)
+	event("e-draft")
+
+	members := memberships{
+		"home-1": {Role: authz.RoleResidential},
+		"home-2": {Role: authz.RoleResidential},
+	}
+	keys := apikeys.NewService(store.APIKeys, &config.APIKeysConfig{Expiry: time.Hour}, log)
+	resolver := roles.NewResolver(roles.NewCache(members, &config.RolesConfig{}))
+	vtn := New(store, authz.NewPolicy(store.Projects), resolver, keys, &config.OpenADR3Config{CallbackTimeout: time.Second, AllowHTTPCallbacks: true, AllowPrivateCallbacks: true}, log)
+	vtn.now = func() time.Time { return now }
+	ids := 0
+	vtn.newID = func() string { ids++; return fmt.Sprintf("id-%d", ids) }
+
+	r := chi.NewRouter()
+	r.Get("/events", middlewares.WrapHandler(vtn.ListEventsHandler, log))
+	r.Get("/events/{eventID}", middlewares.WrapHandler(vtn.GetEventHandler, log))
+	r.Post("/reports", middlewares.WrapHandler(vtn.CreateReportHandler, log))
+	r.Post("/subscriptions", middlewares.WrapHandler(vtn.CreateSubscriptionHandler, log))
+	return &fixture{t: t, store: store, members: members, keys: keys, vtn: vtn, router: r, utility: util.ID, now: now}
+}
+
+// activate starts e-1 and waits for the subscribers to be called back
+func (f *fixture) activate() {
+	f.t.Helper()
+	ctx := context.Background()
+	e, err := f.store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(f.t, err)
+	require.NoError(f.t, f.store.DREvents.TransitionDREvent(ctx, e.ID, e, models.DREventActivate, f.now))
+	f.vtn.DREventChanged(ctx, e, handlers.DREventTransitioned)
+	f.vtn.Wait()
+}
+
+// subscribe subscribes the homeowner's VEN to changes of events at callback
+func (f *fixture) subscribe(user, callback string) *httptest.ResponseRecorder {
+	return f.subscribeAs(homeowner(user), callback)
+}
+
+func (f *fixture) subscribeAs(p authz.Principal, callback string) *httptest.ResponseRecorder {
+	return f.do(p, http.MethodPost, "/subscriptions", subscription{
+		ClientName:       "ven-" + p.UserID,
+		ProgramID:        f.utility,
+		ObjectOperations: []objectOperation{{Objects: []string{objectEvent}, Operations: []string{operationPut}, CallbackURL: callback}},
+	})
+}
+
+func homeowner(id string) authz.Principal {
+	return authz.Principal{UserID: id, Role: authz.RoleResidential}
+}
+
+func (f *fixture) do(p authz.Principal, method, path string, body any) *httptest.ResponseRecorder {
+	f.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(f.t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req = req.WithContext(authz.WithPrincipal(req.Context(), p))
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEventsFollowViewer(t *testing.T) {
+	f := newFixture(t)
+	utility := authz.Principal{UserID: "util-user", Role: authz.RoleUtility, UtilityID: f.utility}
+
+	tests := []struct {
+		name      string
+		principal authz.Principal
+		setpoint  float64
+		targets   []any
+	}{
+		{"utility asks every project", utility, -8, []any{"p-1", "p-2"}},
+		{"homeowner asks their own", homeowner("home-1"), -5, []any{"p-1"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := f.do(tc.principal, http.MethodGet, "/events", nil)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var events []event
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&events))
+			require.Len(t, events, 1, "the draft isn't published")
+			assert.Equal(t, "e-1", events[0].ID)
+			assert.Equal(t, f.utility, events[0].ProgramID)
+			assert.Equal(t, "PT2H", events[0].IntervalPeriod.Duration)
+			assert.Equal(t, []any{tc.setpoint}, events[0].Intervals[0].Payloads[0].Values)
+			assert.Equal(t, tc.targets, events[0].Targets[0].Values)
+		})
+	}
+
+	assert.Equal(t, http.StatusNotFound, f.do(utility, http.MethodGet, "/events/e-draft", nil).Code)
+	assert.Equal(t, http.StatusNotFound, f.do(homeowner("home-3"), http.MethodGet, "/events/e-1", nil).Code)
+}
+
+func TestReportFeedsAverages(t *testing.T) {
+	f := newFixture(t)
+	start := f.now.Add(time.Hour)
+	reading := func(demand, baseline float64) []valuesMap {
+		return []valuesMap{{Type: payloadDemand, Values: []any{demand}}, {Type: payloadBaseline, Values: []any{baseline}}}
+	}
+	rep := report{
+		ProgramID:  f.utility,
+		EventID:    "e-1",
+		ClientName: "ven-1",
+		Resources: []resource{{
+			ResourceName:   "p-1",
+			IntervalPeriod: &intervalPeriod{Start: dateTime(start), Duration: "PT15M"},
+			Intervals: []interval{
+				{ID: 0, Payloads: reading(4, 10)},
+				{ID: 1, Payloads: reading(5, 10)},
+			},
+		}},
+	}
+
+	rec := f.do(homeowner("home-1"), http.MethodPost, "/reports", rep)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	averages, err := f.store.ProjectAverages.GetProjectAveragesByProjectID(context.Background(), "p-1", repositories.ProjectAverageFilter{}, pagination.Params{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, averages.Data, 2)
+	starts := map[time.Time]float64{}
+	for _, avg := range averages.Data {
+		assert.Equal(t, 5.0, avg.ContractThreshold, "taken from the active contract")
+		assert.Equal(t, 10.0, avg.Baseline)
+		starts[avg.StartTime.UTC()] = avg.AverageOutput
+	}
+	assert.Equal(t, map[time.Time]float64{start: 4, start.Add(15 * time.Minute): 5}, starts, "intervals follow their resource's period")
+
+	// the other homeowner's project isn't theirs to report on
+	other := rep
+	other.Resources = []resource{{ResourceName: "p-2", IntervalPeriod: rep.Resources[0].IntervalPeriod, Intervals: rep.Resources[0].Intervals}}
+	assert.Equal(t, http.StatusBadRequest, f.do(homeowner("home-1"), http.MethodPost, "/reports", other).Code)
+
+	missing := rep
+	missing.Resources = []resource{{ResourceName: "p-1", IntervalPeriod: rep.Resources[0].IntervalPeriod,
+		Intervals: []interval{{ID: 2, Payloads: []valuesMap{{Type: payloadDemand, Values: []any{4.0}}}}}}}
+	assert.Equal(t, http.StatusBadRequest, f.do(homeowner("home-1"), http.MethodPost, "/reports", missing).Code)
+
+	draft := rep
+	draft.EventID = "e-draft"
+	assert.Equal(t, http.StatusBadRequest, f.do(homeowner("home-1"), http.MethodPost, "/reports", draft).Code)
+}
+
+func TestSubscribersAreCalledBack(t *testing.T) {
+	f := newFixture(t)
+
+	var mu sync.Mutex
+	received := map[string]notification{}
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notification
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		mu.Lock()
+		received[r.URL.Path+" "+r.Header.Get("Authorization")] = n
+		mu.Unlock()
+	}))
+	defer callbacks.Close()
+
+	subscribe := func(user, path string, objects ...string) *httptest.ResponseRecorder {
+		return f.do(homeowner(user), http.MethodPost, "/subscriptions", subscription{
+			ClientName: "ven-" + user,
+			ProgramID:  f.utility,
+			ObjectOperations: []objectOperation{{
+				Objects:     objects,
+				Operations:  []string{operationPut},
+				CallbackURL: callbacks.URL + path,
+				BearerToken: "token-" + user,
+			}},
+		})
+	}
+	for _, rec := range []*httptest.ResponseRecorder{
+		subscribe("home-1", "/home-1", objectEvent),
+		subscribe("home-2", "/home-2", objectEvent),
+		subscribe("home-1", "/reports", objectReport),
+	} {
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	// without a project in the program there's nothing to hear of
+	assert.Equal(t, http.StatusBadRequest, subscribe("home-3", "/home-3", objectEvent).Code)
+
+	f.activate()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 2, "each hears of the event once, the report subscription stays quiet")
+	for user, setpoint := range map[string]float64{"home-1": -5, "home-2": -3} {
+		n, ok := received["/"+user+" Bearer token-"+user]
+		require.True(t, ok, user)
+		assert.Equal(t, objectEvent, n.ObjectType)
+		assert.Equal(t, operationPut, n.Operation)
+		object := n.Object.(map[string]any)
+		assert.Equal(t, "e-1", object["id"])
+		payloads := object["intervals"].([]any)[0].(map[string]any)["payloads"].([]any)
+		assert.Equal(t, []any{setpoint}, payloads[0].(map[string]any)["values"])
+	}
+}
+
+func TestSubscriptionCallbackMustBeHTTPS(t *testing.T) {
+	f := newFixture(t)
+	f.vtn.cfg.AllowHTTPCallbacks = false
+
+	rec := f.do(homeowner("home-1"), http.MethodPost, "/subscriptions", subscription{
+		ClientName:       "ven-1",
+		ProgramID:        f.utility,
+		ObjectOperations: []objectOperation{{Objects: []string{objectEvent}, Operations: []string{operationPost}, CallbackURL: "http://ven.example.com/cb"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	rec = f.do(homeowner("home-1"), http.MethodPost, "/subscriptions", subscription{
+		ClientName:       "ven-1",
+		ProgramID:        f.utility,
+		ObjectOperations: []objectOperation{{Objects: []string{objectProgram}, Operations: []string{operationPost}, CallbackURL: "https://ven.example.com/cb"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String(), "programs don't change through the API")
+}
+
+func TestSubscribersAreResolvedWhenCalledBack(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	called := map[string]bool{}
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		called[r.URL.Path] = true
+		mu.Unlock()
+	}))
+	defer callbacks.Close()
+
+	key, _, err := f.keys.Create(ctx, &apikeys.CreateRequest{Name: "aggregator", Scopes: []string{apikeys.ScopeOpenADRVEN}, UtilityIDs: []string{f.utility}}, "tech-1")
+	require.NoError(t, err)
+	for _, rec := range []*httptest.ResponseRecorder{
+		f.subscribe("home-1", callbacks.URL+"/home-1"),
+		f.subscribe("home-2", callbacks.URL+"/home-2"),
+		f.subscribeAs(apikeys.Principal(key), callbacks.URL+"/key"),
+	} {
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// home-1 moved out and the key was revoked after subscribing
+	delete(f.members, "home-1")
+	require.NoError(t, f.keys.Revoke(ctx, key.ID))
+	f.activate()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]bool{"/home-2": true}, called)
+}
+
+func TestSlowSubscribersDontHoldUpOthers(t *testing.T) {
+	f := newFixture(t)
+	f.vtn.client.Timeout = time.Minute // the slow callback doesn't time out before the other is called
+
+	release, reached := make(chan struct{}), make(chan struct{})
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+			return
+		}
+		close(reached)
+	}))
+	defer callbacks.Close()
+
+	require.Equal(t, http.StatusCreated, f.subscribe("home-1", callbacks.URL+"/slow").Code)
+	require.Equal(t, http.StatusCreated, f.subscribe("home-2", callbacks.URL+"/fast").Code)
+
+	ctx := context.Background()
+	e, err := f.store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	f.vtn.DREventChanged(ctx, e, handlers.DREventUpdated)
+	select {
+	case <-reached:
+	case <-time.After(5 * time.Second):
+		t.Error("the second subscriber waited on the first")
+	}
+	close(release)
+	f.vtn.Wait()
+}
+
+func TestCallbacksAreNotRedirected(t *testing.T) {
+	f := newFixture(t)
+
+	var redirected, followed atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Add(1)
+	}))
+	defer target.Close()
+	callbacks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected.Add(1)
+		http.Redirect(w, r, target.URL+"/internal", http.StatusTemporaryRedirect)
+	}))
+	defer callbacks.Close()
+
+	rec := f.subscribe("home-1", callbacks.URL+"/cb")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	f.activate()
+
+	assert.Equal(t, int32(1), redirected.Load())
+	assert.Zero(t, followed.Load(), "the redirect isn't followed")
+}
+
+func TestCallbacksToPrivateAddressesAreRefused(t *testing.T) {
+	f := newFixture(t)
+
+	var called atomic.Int32
+	callbacks := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Add(1)
+	}))
+	defer callbacks.Close()
+	require.True(t, strings.HasPrefix(callbacks.URL, "https://127.0.0.1:"), callbacks.URL)
+
+	// a subscription from before, or a name that resolves inside the network, is refused when dialing
+	rec := f.subscribe("home-1", callbacks.URL+"/cb")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	f.vtn.cfg.AllowPrivateCallbacks = false
+	f.vtn.client.Transport.(*http.Transport).TLSClientConfig = callbacks.Client().Transport.(*http.Transport).TLSClientConfig
+	f.activate()
+	assert.Zero(t, called.Load())
+
+	// the same callback is reached once private addresses are allowed
+	f.vtn.cfg.AllowPrivateCallbacks = true
+	e, err := f.store.DREvents.GetDREvent(context.Background(), "e-1")
+	require.NoError(t, err)
+	f.vtn.DREventChanged(context.Background(), e, handlers.DREventUpdated)
+	f.vtn.Wait()
+	assert.Equal(t, int32(1), called.Load())
+	f.vtn.cfg.AllowPrivateCallbacks = false
+
+	// addresses in the url are turned away when subscribing
+	for _, callback := range []string{callbacks.URL + "/cb", "https://10.0.0.1/cb", "https://169.254.169.254/latest", "https://[::1]/cb", "https://localhost/cb", "https://100.64.0.1/cb", "https://[64:ff9b::a00:1]/cb"} {
+		rec = f.subscribe("home-2", callback)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, callback)
+	}
+	assert.Equal(t, http.StatusCreated, f.subscribe("home-2", "https://ven.example.com/cb").Code)
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"192.168.0.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},    // this network
+		{"100.64.0.1", false}, // carrier-grade NAT
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"64:ff9b::a00:1", false}, // NAT64 of 10.0.0.1
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, isPublic(net.ParseIP(tc.ip)), tc.ip)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"PT15M", 15 * time.Minute, true},
+		{"P1DT12H", 36 * time.Hour, true},
+		{"PT0.5S", 500 * time.Millisecond, true},
+		{"P1W", 7 * 24 * time.Hour, true},
+		{"P1M", 0, false}, // months vary in length
+		{"PT", 0, false},
+		{"P", 0, false},
+		{"15m", 0, false},
+	}
+	for _, tc := range tests {
+		got, ok := parseDuration(tc.in)
+		assert.Equal(t, tc.ok, ok, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+	assert.Equal(t, "PT1H30M", duration(90*time.Minute))
+	assert.Equal(t, "PT0S", duration(0))
+}
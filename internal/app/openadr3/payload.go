@@ -0,0 +1,142 @@
+package openadr3
+
+// The objects of the OpenADR 3.0 schema, named and shaped as the specification's JSON. Request bodies decode into the
+// same structs, so the fields a client may send but the VTN provisions, such as ids and timestamps, are ignored.
+
+const (
+	objectProgram      = "PROGRAM"
+	objectEvent        = "EVENT"
+	objectReport       = "REPORT"
+	objectSubscription = "SUBSCRIPTION"
+
+	descriptorEvent  = "EVENT_PAYLOAD_DESCRIPTOR"
+	descriptorReport = "REPORT_PAYLOAD_DESCRIPTOR"
+
+	operationPost   = "POST"
+	operationPut    = "PUT"
+	operationDelete = "DELETE"
+
+	// targets naming projects, a project is a resource of the VEN controlling its DERs
+	targetResourceName = "RESOURCE_NAME"
+
+	// events ask for a reduction relative to the baseline, reports carry the measured demand and the baseline
+	payloadSetpointRelative = "DISPATCH_SETPOINT_RELATIVE"
+	payloadDemand           = "DEMAND"
+	payloadBaseline         = "BASELINE"
+	readingDirect           = "DIRECT_READ"
+	unitsKW                 = "KW"
+)
+
+type program struct {
+	ID                   string              `json:"id"`
+	CreatedDateTime      string              `json:"createdDateTime,omitempty"`
+	ModificationDateTime string              `json:"modificationDateTime,omitempty"`
+	ObjectType           string              `json:"objectType"`
+	ProgramName          string              `json:"programName"`
+	RetailerName         string              `json:"retailerName,omitempty"`
+	PayloadDescriptors   []payloadDescriptor `json:"payloadDescriptors"`
+	Targets              []valuesMap         `json:"targets"`
+}
+
+type event struct {
+	ID                   string              `json:"id"`
+	CreatedDateTime      string              `json:"createdDateTime,omitempty"`
+	ModificationDateTime string              `json:"modificationDateTime,omitempty"`
+	ObjectType           string              `json:"objectType"`
+	ProgramID            string              `json:"programID"`
+	Targets              []valuesMap         `json:"targets"`
+	PayloadDescriptors   []payloadDescriptor `json:"payloadDescriptors"`
+	ReportDescriptors    []reportDescriptor  `json:"reportDescriptors"`
+	IntervalPeriod       *intervalPeriod     `json:"intervalPeriod,omitempty"`
+	Intervals            []interval          `json:"intervals"`
+}
+
+type report struct {
+	ID                   string              `json:"id,omitempty"`
+	CreatedDateTime      string              `json:"createdDateTime,omitempty"`
+	ModificationDateTime string              `json:"modificationDateTime,omitempty"`
+	ObjectType           string              `json:"objectType,omitempty"`
+	ProgramID            string              `json:"programID"`
+	EventID              string              `json:"eventID"`
+	ClientName           string              `json:"clientName"`
+	ReportName           string              `json:"reportName,omitempty"`
+	PayloadDescriptors   []payloadDescriptor `json:"payloadDescriptors,omitempty"`
+	Resources            []resource          `json:"resources"`
+}
+
+// reportBody is the part of a report kept as JSON in models.OpenADRReport.Body
+type reportBody struct {
+	PayloadDescriptors []payloadDescriptor `json:"payloadDescriptors,omitempty"`
+	Resources          []resource          `json:"resources"`
+}
+
+type resource struct {
+	ResourceName   string          `json:"resourceName"`
+	IntervalPeriod *intervalPeriod `json:"intervalPeriod,omitempty"`
+	Intervals      []interval      `json:"intervals"`
+}
+
+type subscription struct {
+	ID                   string            `json:"id,omitempty"`
+	CreatedDateTime      string            `json:"createdDateTime,omitempty"`
+	ModificationDateTime string            `json:"modificationDateTime,omitempty"`
+	ObjectType           string            `json:"objectType,omitempty"`
+	ClientName           string            `json:"clientName"`
+	ProgramID            string            `json:"programID"`
+	ObjectOperations     []objectOperation `json:"objectOperations"`
+	Targets              []valuesMap       `json:"targets,omitempty"`
+}
+
+// subscriptionBody is the part of a subscription kept as JSON in models.OpenADRSubscription.Body
+type subscriptionBody struct {
+	ObjectOperations []objectOperation `json:"objectOperations"`
+	Targets          []valuesMap       `json:"targets,omitempty"`
+}
+
+type objectOperation struct {
+	Objects     []string `json:"objects"`
+	Operations  []string `json:"operations"`
+	CallbackURL string   `json:"callbackUrl"`
+	BearerToken string   `json:"bearerToken,omitempty"`
+}
+
+type notification struct {
+	ObjectType string `json:"objectType"`
+	Operation  string `json:"operation"`
+	Object     any    `json:"object"`
+}
+
+// valuesMap is a type and its values, the shape of targets and of interval payloads
+type valuesMap struct {
+	Type   string `json:"type"`
+	Values []any  `json:"values"`
+}
+
+type payloadDescriptor struct {
+	ObjectType  string   `json:"objectType,omitempty"`
+	PayloadType string   `json:"payloadType"`
+	ReadingType string   `json:"readingType,omitempty"`
+	Units       string   `json:"units,omitempty"`
+	Currency    string   `json:"currency,omitempty"`
+	Accuracy    *float64 `json:"accuracy,omitempty"`
+	Confidence  *int     `json:"confidence,omitempty"`
+}
+
+type reportDescriptor struct {
+	PayloadType string `json:"payloadType"`
+	ReadingType string `json:"readingType,omitempty"`
+	Units       string `json:"units,omitempty"`
+	Historical  bool   `json:"historical"`
+}
+
+type intervalPeriod struct {
+	Start          string `json:"start"`
+	Duration       string `json:"duration,omitempty"`
+	RandomizeStart string `json:"randomizeStart,omitempty"`
+}
+
+type interval struct {
+	ID             int             `json:"id"`
+	IntervalPeriod *intervalPeriod `json:"intervalPeriod,omitempty"`
+	Payloads       []valuesMap     `json:"payloads"`
+}
@@ -0,0 +1,391 @@
+package openadr3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// reportScope is the reports the caller may read, those on their utilities' events and those they sent
+func reportScope(v *viewer) authz.Scope {
+	return authz.Scope{All: v.scope.All, UtilityIDs: v.scope.UtilityIDs, UserID: v.principal.UserID}
+}
+
+func (v *viewer) report(r *models.OpenADRReport) bool {
+	return v.scope.Utility(r.UtilityID) || (v.principal.UserID != "" && r.CreatedBy == v.principal.UserID)
+}
+
+// toReport rebuilds the report of the specification from the stored row
+func toReport(row *models.OpenADRReport) (*report, error) {
+	var body reportBody
+	if err := json.Unmarshal([]byte(row.Body), &body); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to read report", err)
+	}
+	return &report{
+		ID:                   row.ID,
+		CreatedDateTime:      dateTime(row.CreatedAt),
+		ModificationDateTime: dateTime(row.ModifiedAt),
+		ObjectType:           objectReport,
+		ProgramID:            row.ProgramID,
+		EventID:              row.EventID,
+		ClientName:           row.ClientName,
+		ReportName:           row.ReportName,
+		PayloadDescriptors:   body.PayloadDescriptors,
+		Resources:            body.Resources,
+	}, nil
+}
+
+// averages checks a report sent about an event the caller can see and turns every interval of its resources into a
+// project average. Resources are projects of the event's utility the caller may write, and every interval carries a
+// DEMAND and a BASELINE value, the contract threshold is the one of the project's active contract.
+func (h *VTN) averages(ctx context.Context, v *viewer, req *report) ([]models.ProjectAverage, *models.DREvents, error) {
+	var fields []custom_error.FieldError
+	add := func(field, rule, message string) {
+		fields = append(fields, custom_error.FieldError{Field: field, Code: rule, Message: message})
+	}
+	if req.ClientName == "" {
+		add("clientName", models.RuleRequired, "is required")
+	}
+	if req.ProgramID == "" {
+		add("programID", models.RuleRequired, "is required")
+	}
+	if req.EventID == "" {
+		add("eventID", models.RuleRequired, "is required")
+	}
+	if len(req.Resources) == 0 {
+		add("resources", models.RuleRequired, "is required")
+	}
+	if len(fields) > 0 {
+		return nil, nil, custom_error.Validation(fields...)
+	}
+
+	e, err := h.loadEvent(ctx, req.EventID)
+	if err != nil && !isNotFound(err) {
+		return nil, nil, err
+	}
+	var seen *event
+	// a cancelled event may still be reported on, the devices may have followed it before it was called off
+	if err == nil && e.Status != models.DREventDraft {
+		if seen, err = h.event(ctx, v, e); err != nil {
+			return nil, nil, err
+		}
+	}
+	if seen == nil || e.UtilityID != req.ProgramID {
+		add("eventID", models.RuleOneOf, "must be an event of the program")
+		return nil, nil, custom_error.Validation(fields...)
+	}
+
+	var averages []models.ProjectAverage
+	for i, res := range req.Resources {
+		field := fmt.Sprintf("resources[%d]", i)
+		if err := h.policy.Project(ctx, res.ResourceName, authz.Write, "Project id not found"); err != nil {
+			if !isNotFound(err) {
+				return nil, nil, err
+			}
+			add(field+".resourceName", models.RuleOneOf, "must be the id of a project you may report for")
+			continue
+		}
+		project, err := h.store.Projects.GetProject(ctx, res.ResourceName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if project.UtilityID != e.UtilityID {
+			add(field+".resourceName", models.RuleOneOf, "must be a project of the program")
+			continue
+		}
+		threshold, err := h.threshold(ctx, v, project.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if threshold <= 0 {
+			add(field+".resourceName", models.RuleOneOf, "must be a project with an active contract")
+			continue
+		}
+		for j, iv := range res.Intervals {
+			field := fmt.Sprintf("%s.intervals[%d]", field, j)
+			start, end, ok := period(res.IntervalPeriod, iv)
+			if !ok {
+				add(field+".intervalPeriod", models.RuleRequired, "must have a start and a duration, on the interval or its resource")
+				continue
+			}
+			demand, okDemand := single(iv.Payloads, payloadDemand)
+			baseline, okBaseline := single(iv.Payloads, payloadBaseline)
+			if !okDemand || !okBaseline {
+				add(field+".payloads", models.RuleRequired, "must have one DEMAND and one BASELINE value")
+				continue
+			}
+			avg := models.ProjectAverage{
+				ProjectID:         project.ID,
+				StartTime:         start,
+				EndTime:           end,
+				Baseline:          baseline,
+				ContractThreshold: threshold,
+				AverageOutput:     demand,
+			}
+			if err := avg.Validate(); err != nil {
+				add(field, models.RuleRange, "must be a positive period with a positive BASELINE")
+				continue
+			}
+			averages = append(averages, avg)
+		}
+	}
+	if len(fields) > 0 {
+		return nil, nil, custom_error.Validation(fields...)
+	}
+	return averages, e, nil
+}
+
+// period is when an interval was measured. Without its own period it follows its resource's, the interval id
+// counting whole durations from the resource's start.
+func period(resourcePeriod *intervalPeriod, iv interval) (time.Time, time.Time, bool) {
+	p, offset := iv.IntervalPeriod, 0
+	if p == nil {
+		p, offset = resourcePeriod, iv.ID
+	}
+	if p == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	start, err := time.Parse(time.RFC3339, p.Start)
+	length, ok := parseDuration(p.Duration)
+	if err != nil || !ok || length <= 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	start = start.Add(time.Duration(offset) * length)
+	return start, start.Add(length), true
+}
+
+// single is the one numeric value of the payload of type t
+func single(payloads []valuesMap, t string) (float64, bool) {
+	for _, p := range payloads {
+		if p.Type != t || len(p.Values) != 1 {
+			continue
+		}
+		f, ok := p.Values[0].(float64)
+		return f, ok
+	}
+	return 0, false
+}
+
+// feed stores the averages a report brought, those some other report already stored are skipped
+func (h *VTN) feed(ctx context.Context, averages []models.ProjectAverage) error {
+	for i := range averages {
+		if err := h.store.ProjectAverages.CreateProjectAverage(ctx, &averages[i]); err != nil {
+			var customErr *custom_error.CustomError
+			if errors.As(err, &customErr) && customErr.Code == http.StatusConflict {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ownReport loads a report the caller may read, writable reports must also be theirs
+func (h *VTN) ownReport(ctx context.Context, v *viewer, id string, write bool) (*models.OpenADRReport, error) {
+	row, err := h.store.OpenADR.GetReport(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, custom_error.New(http.StatusNotFound, "Report not found", nil)
+		}
+		return nil, err
+	}
+	if !v.report(row) {
+		return nil, custom_error.New(http.StatusNotFound, "Report not found", nil)
+	}
+	if write && row.CreatedBy != v.principal.UserID {
+		return nil, custom_error.New(http.StatusForbidden, "Only the client that created the report may change it", nil)
+	}
+	return row, nil
+}
+
+// ListReportsHandler serves GET /reports, filtered by programID, eventID and clientName
+func (h *VTN) ListReportsHandler(w http.ResponseWriter, r *http.Request) error {
+	win, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	q := r.URL.Query()
+	filter := repositories.OpenADRReportFilter{
+		Scope:      reportScope(v),
+		ProgramID:  q.Get("programID"),
+		EventID:    q.Get("eventID"),
+		ClientName: q.Get("clientName"),
+	}
+	rows, err := collect(win.skip+win.limit, func(page pagination.Params) (pagination.Page[models.OpenADRReport], error) {
+		return h.store.OpenADR.ListReports(r.Context(), filter, page)
+	})
+	if err != nil {
+		return err
+	}
+	reports := []*report{}
+	for _, row := range apply(win, rows) {
+		rep, err := toReport(&row)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, rep)
+	}
+	return writeJSON(w, http.StatusOK, reports)
+}
+
+// GetReportHandler serves GET /reports/{reportID}
+func (h *VTN) GetReportHandler(w http.ResponseWriter, r *http.Request) error {
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	row, err := h.ownReport(r.Context(), v, chi.URLParam(r, "reportID"), false)
+	if err != nil {
+		return err
+	}
+	rep, err := toReport(row)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, rep)
+}
+
+// CreateReportHandler serves POST /reports, the report's intervals are stored as project averages
+func (h *VTN) CreateReportHandler(w http.ResponseWriter, r *http.Request) error {
+	var req report
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	averages, e, err := h.averages(r.Context(), v, &req)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(reportBody{PayloadDescriptors: req.PayloadDescriptors, Resources: req.Resources})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to encode report", err)
+	}
+
+	now := h.now()
+	row := &models.OpenADRReport{
+		ID:         h.newID(),
+		UtilityID:  e.UtilityID,
+		ProgramID:  req.ProgramID,
+		EventID:    req.EventID,
+		ClientName: req.ClientName,
+		ReportName: req.ReportName,
+		CreatedBy:  v.principal.UserID,
+		Body:       string(body),
+		CreatedAt:  now,
+		ModifiedAt: now,
+	}
+	if err := h.store.OpenADR.CreateReport(r.Context(), row); err != nil {
+		return err
+	}
+	if err := h.feed(r.Context(), averages); err != nil {
+		return err
+	}
+	logging.FromContext(r.Context(), h.log).InfoContext(r.Context(), "openadr report received", "report_id", row.ID, "event_id", row.EventID, "averages", len(averages))
+
+	rep, err := toReport(row)
+	if err != nil {
+		return err
+	}
+	h.reportChanged(r.Context(), row, operationPost)
+	w.Header().Set("Location", "/openadr3/reports/"+row.ID)
+	return writeJSON(w, http.StatusCreated, rep)
+}
+
+// UpdateReportHandler serves PUT /reports/{reportID}, only intervals the report didn't have before become averages
+func (h *VTN) UpdateReportHandler(w http.ResponseWriter, r *http.Request) error {
+	var req report
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	row, err := h.ownReport(r.Context(), v, chi.URLParam(r, "reportID"), true)
+	if err != nil {
+		return err
+	}
+	if req.ProgramID != row.ProgramID || req.EventID != row.EventID {
+		return custom_error.Validation(custom_error.FieldError{Field: "eventID", Code: logic.RuleImmutable, Message: "programID and eventID can't change"})
+	}
+	averages, _, err := h.averages(r.Context(), v, &req)
+	if err != nil {
+		return err
+	}
+	old, err := toReport(row)
+	if err != nil {
+		return err
+	}
+	previous, _, err := h.averages(r.Context(), v, old)
+	if err != nil {
+		previous = nil // the old intervals may no longer check out, every interval is then new
+	}
+	reported := map[string]bool{}
+	for _, avg := range previous {
+		reported[avg.ProjectID+"@"+avg.StartTime.String()] = true
+	}
+	var added []models.ProjectAverage
+	for _, avg := range averages {
+		if !reported[avg.ProjectID+"@"+avg.StartTime.String()] {
+			added = append(added, avg)
+		}
+	}
+
+	body, err := json.Marshal(reportBody{PayloadDescriptors: req.PayloadDescriptors, Resources: req.Resources})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to encode report", err)
+	}
+	row.ClientName, row.ReportName, row.Body, row.ModifiedAt = req.ClientName, req.ReportName, string(body), h.now()
+	if err := h.store.OpenADR.UpdateReport(r.Context(), row); err != nil {
+		return err
+	}
+	if err := h.feed(r.Context(), added); err != nil {
+		return err
+	}
+	rep, err := toReport(row)
+	if err != nil {
+		return err
+	}
+	h.reportChanged(r.Context(), row, operationPut)
+	return writeJSON(w, http.StatusOK, rep)
+}
+
+// DeleteReportHandler serves DELETE /reports/{reportID}, the averages it brought stay
+func (h *VTN) DeleteReportHandler(w http.ResponseWriter, r *http.Request) error {
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	row, err := h.ownReport(r.Context(), v, chi.URLParam(r, "reportID"), true)
+	if err != nil {
+		return err
+	}
+	if err := h.store.OpenADR.DeleteReport(r.Context(), row.ID); err != nil {
+		return err
+	}
+	rep, err := toReport(row)
+	if err != nil {
+		return err
+	}
+	h.reportChanged(r.Context(), row, operationDelete)
+	return writeJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,277 @@
+package openadr3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// only events and reports change after they are published, so they are the only objects anyone is called back about
+var (
+	notifiedObjects    = []string{objectEvent, objectReport}
+	notifiedOperations = []string{operationPost, operationPut, operationDelete}
+)
+
+func toSubscription(row *models.OpenADRSubscription) (*subscription, error) {
+	var body subscriptionBody
+	if err := json.Unmarshal([]byte(row.Body), &body); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to read subscription", err)
+	}
+	return &subscription{
+		ID:                   row.ID,
+		CreatedDateTime:      dateTime(row.CreatedAt),
+		ModificationDateTime: dateTime(row.ModifiedAt),
+		ObjectType:           objectSubscription,
+		ClientName:           row.ClientName,
+		ProgramID:            row.ProgramID,
+		ObjectOperations:     body.ObjectOperations,
+		Targets:              body.Targets,
+	}, nil
+}
+
+// checkSubscription validates a subscription sent by the caller, its program must be one they can see
+func (h *VTN) checkSubscription(ctx context.Context, v *viewer, req *subscription) error {
+	var fields []custom_error.FieldError
+	add := func(field, rule, message string) {
+		fields = append(fields, custom_error.FieldError{Field: field, Code: rule, Message: message})
+	}
+	if req.ClientName == "" {
+		add("clientName", models.RuleRequired, "is required")
+	}
+	if req.ProgramID == "" {
+		add("programID", models.RuleRequired, "is required")
+	} else if !v.program(req.ProgramID) {
+		add("programID", models.RuleOneOf, "must be the id of a program you can see")
+	} else if _, err := h.store.Utilities.GetUtility(ctx, req.ProgramID); err != nil {
+		if !isNotFound(err) {
+			return err
+		}
+		add("programID", models.RuleOneOf, "must be the id of a program you can see")
+	}
+	if len(req.ObjectOperations) == 0 {
+		add("objectOperations", models.RuleRequired, "is required")
+	}
+	for i, op := range req.ObjectOperations {
+		field := fmt.Sprintf("objectOperations[%d]", i)
+		if len(op.Objects) == 0 {
+			add(field+".objects", models.RuleRequired, "is required")
+		}
+		for _, object := range op.Objects {
+			if !slices.Contains(notifiedObjects, object) {
+				add(field+".objects", models.RuleOneOf, "must be EVENT or REPORT, programs and subscriptions don't change through this API")
+				break
+			}
+		}
+		if len(op.Operations) == 0 {
+			add(field+".operations", models.RuleRequired, "is required")
+		}
+		for _, operation := range op.Operations {
+			if !slices.Contains(notifiedOperations, operation) {
+				add(field+".operations", models.RuleOneOf, "must be POST, PUT or DELETE")
+				break
+			}
+		}
+		if !h.callbackAllowed(op.CallbackURL) {
+			add(field+".callbackUrl", models.RuleOneOf, "must be an absolute https URL of a public host")
+		}
+	}
+	if len(fields) > 0 {
+		return custom_error.Validation(fields...)
+	}
+	return nil
+}
+
+func (h *VTN) callbackAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || !h.cfg.AllowHTTPCallbacks) {
+		return false
+	}
+	// names are checked again once resolved, this only turns the obvious ones away early
+	if host := strings.ToLower(u.Hostname()); !h.cfg.AllowPrivateCallbacks {
+		if ip := net.ParseIP(host); ip != nil {
+			return isPublic(ip)
+		}
+		return host != "localhost" && !strings.HasSuffix(host, ".localhost")
+	}
+	return true
+}
+
+// ownSubscription loads a subscription of the caller, technicians may read and change everyone's
+func (h *VTN) ownSubscription(ctx context.Context, v *viewer, id string) (*models.OpenADRSubscription, error) {
+	row, err := h.store.OpenADR.GetSubscription(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, custom_error.New(http.StatusNotFound, "Subscription not found", nil)
+		}
+		return nil, err
+	}
+	if !v.scope.All && (v.principal.UserID == "" || row.CreatedBy != v.principal.UserID) {
+		return nil, custom_error.New(http.StatusNotFound, "Subscription not found", nil)
+	}
+	return row, nil
+}
+
+// ListSubscriptionsHandler serves GET /subscriptions, filtered by programID, clientName and objects
+func (h *VTN) ListSubscriptionsHandler(w http.ResponseWriter, r *http.Request) error {
+	win, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	q := r.URL.Query()
+	filter := repositories.OpenADRSubscriptionFilter{ProgramID: q.Get("programID"), ClientName: q.Get("clientName")}
+	if !v.scope.All {
+		if v.principal.UserID == "" {
+			return writeJSON(w, http.StatusOK, []*subscription{})
+		}
+		filter.CreatedBy = v.principal.UserID
+	}
+	rows, err := collect(0, func(page pagination.Params) (pagination.Page[models.OpenADRSubscription], error) {
+		return h.store.OpenADR.ListSubscriptions(r.Context(), filter, page)
+	})
+	if err != nil {
+		return err
+	}
+
+	objects := q["objects"]
+	subs := []*subscription{}
+	for i := range rows {
+		sub, err := toSubscription(&rows[i])
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 || slices.ContainsFunc(sub.ObjectOperations, func(op objectOperation) bool {
+			return slices.ContainsFunc(op.Objects, func(o string) bool { return slices.Contains(objects, o) })
+		}) {
+			subs = append(subs, sub)
+		}
+	}
+	return writeJSON(w, http.StatusOK, apply(win, subs))
+}
+
+// GetSubscriptionHandler serves GET /subscriptions/{subscriptionID}
+func (h *VTN) GetSubscriptionHandler(w http.ResponseWriter, r *http.Request) error {
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	row, err := h.ownSubscription(r.Context(), v, chi.URLParam(r, "subscriptionID"))
+	if err != nil {
+		return err
+	}
+	sub, err := toSubscription(row)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, sub)
+}
+
+// CreateSubscriptionHandler serves POST /subscriptions. The caller's principal is kept with the subscription, it
+// decides what the callbacks carry.
+func (h *VTN) CreateSubscriptionHandler(w http.ResponseWriter, r *http.Request) error {
+	var req subscription
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	if err := h.checkSubscription(r.Context(), v, &req); err != nil {
+		return err
+	}
+	body, err := json.Marshal(subscriptionBody{ObjectOperations: req.ObjectOperations, Targets: req.Targets})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to encode subscription", err)
+	}
+
+	now := h.now()
+	row := &models.OpenADRSubscription{
+		ID:         h.newID(),
+		ProgramID:  req.ProgramID,
+		ClientName: req.ClientName,
+		CreatedBy:  v.principal.UserID,
+		Body:       string(body),
+		CreatedAt:  now,
+		ModifiedAt: now,
+	}
+	if err := h.store.OpenADR.CreateSubscription(r.Context(), row); err != nil {
+		return err
+	}
+	sub, err := toSubscription(row)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/openadr3/subscriptions/"+row.ID)
+	return writeJSON(w, http.StatusCreated, sub)
+}
+
+// UpdateSubscriptionHandler serves PUT /subscriptions/{subscriptionID}, the whole subscription is replaced
+func (h *VTN) UpdateSubscriptionHandler(w http.ResponseWriter, r *http.Request) error {
+	var req subscription
+	if err := logic.DecodeJSON(r, &req); err != nil {
+		return err
+	}
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	row, err := h.ownSubscription(r.Context(), v, chi.URLParam(r, "subscriptionID"))
+	if err != nil {
+		return err
+	}
+	if err := h.checkSubscription(r.Context(), v, &req); err != nil {
+		return err
+	}
+	body, err := json.Marshal(subscriptionBody{ObjectOperations: req.ObjectOperations, Targets: req.Targets})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to encode subscription", err)
+	}
+	row.ProgramID, row.ClientName, row.Body, row.ModifiedAt = req.ProgramID, req.ClientName, string(body), h.now()
+	if err := h.store.OpenADR.UpdateSubscription(r.Context(), row); err != nil {
+		return err
+	}
+	sub, err := toSubscription(row)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, sub)
+}
+
+// DeleteSubscriptionHandler serves DELETE /subscriptions/{subscriptionID}
+func (h *VTN) DeleteSubscriptionHandler(w http.ResponseWriter, r *http.Request) error {
+	v, err := h.requestViewer(r)
+	if err != nil {
+		return err
+	}
+	row, err := h.ownSubscription(r.Context(), v, chi.URLParam(r, "subscriptionID"))
+	if err != nil {
+		return err
+	}
+	if err := h.store.OpenADR.DeleteSubscription(r.Context(), row.ID); err != nil {
+		return err
+	}
+	sub, err := toSubscription(row)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, sub)
+}
@@ -0,0 +1,383 @@
+// Package pagination implements the cursor pagination and sorting shared by the list endpoints.
+//
+// Pages are keyset based: the cursor holds the sort value and tiebreaker of the last row of a page, and the
+// query for the next page selects the rows after that position instead of skipping rows with an OFFSET. Rows
+// inserted or deleted in the meantime don't shift the pages, and the cost of a page doesn't grow with its depth.
+// Cursors are opaque to clients, they must be sent back with the same sort they were issued for.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Kind is the type of a sortable column, it decides how the column's values are compared and stored in cursors
+type Kind int
+
+const (
+	String Kind = iota
+	Number
+	Time
+	Date
+)
+
+// Field is a column a list can be sorted by
+type Field struct {
+	Kind Kind
+	// Nullable columns sort NULL as the zero value of their kind, first ascending and last descending
+	Nullable bool
+}
+
+// Spec lists how a list may be sorted. Field names are the json names of the model, which are also its columns.
+type Spec struct {
+	Fields map[string]Field
+	// Default is the sort of requests without one, "-" prefixed for descending
+	Default string
+	// Tiebreaker is a unique field ordering the rows with the same sort value, in the same direction
+	Tiebreaker string
+}
+
+// Params are the pagination parameters of a list request, the zero value is the first page with the default sort
+type Params struct {
+	Limit  int
+	Cursor string
+	Sort   string
+}
+
+// Page is one page of a list and the cursor of the next one, empty on the last page
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursor is the decoded form of Page.NextCursor
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// Keyset is a page request checked against the list's Spec, the query layer renders it into SQL
+type Keyset struct {
+	spec  Spec
+	sort  string
+	field string
+	desc  bool
+	limit int
+	// after holds the sort value and tiebreaker of the last row of the previous page, nil on the first page
+	after []any
+}
+
+// ParseParams reads the limit, cursor and sort query parameters of a list request
+func ParseParams(r *http.Request) (Params, error) {
+	q := r.URL.Query()
+	p := Params{Cursor: q.Get("cursor"), Sort: q.Get("sort")}
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return p, custom_error.Validation(custom_error.FieldError{
+				Field: "limit", Code: models.RuleRange, Message: fmt.Sprintf("must be a number from 1 to %d", MaxLimit),
+			})
+		}
+		p.Limit = limit
+	}
+	return p, nil
+}
+
+// Keyset checks the sort and decodes the cursor of p, either being invalid is the client's fault
+func (s Spec) Keyset(p Params) (*Keyset, error) {
+	k := &Keyset{spec: s, sort: p.Sort, limit: p.Limit}
+	if k.sort == "" {
+		k.sort = s.Default
+	}
+	if k.limit == 0 {
+		k.limit = DefaultLimit
+	}
+	k.field = strings.TrimPrefix(k.sort, "-")
+	k.desc = k.field != k.sort
+	if _, ok := s.Fields[k.field]; !ok {
+		return nil, custom_error.Validation(custom_error.FieldError{
+			Field: "sort", Code: models.RuleOneOf, Message: "must be one of " + strings.Join(s.sortable(), ", "),
+		})
+	}
+
+	if p.Cursor == "" {
+		return k, nil
+	}
+	invalid := custom_error.New(http.StatusBadRequest, "Invalid cursor, send the next_cursor of a previous page with the same sort", nil)
+	raw, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != k.sort || len(c.Values) != len(k.columns()) {
+		return nil, invalid
+	}
+	for i, field := range k.columns() {
+		v, err := decode(s.Fields[field].Kind, c.Values[i])
+		if err != nil {
+			return nil, invalid
+		}
+		k.after = append(k.after, v)
+	}
+	return k, nil
+}
+
+// sortable lists the sort parameters of the spec for error messages
+func (s Spec) sortable() []string {
+	names := make([]string, 0, 2*len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name, "-"+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// columns are the fields the rows are ordered by, the sort field then the tiebreaker unless they are the same
+func (k *Keyset) columns() []string {
+	if k.field == k.spec.Tiebreaker {
+		return []string{k.field}
+	}
+	return []string{k.field, k.spec.Tiebreaker}
+}
+
+// Fetch is the LIMIT of the query, one row more than the page so we know whether there is a next page
+func (k *Keyset) Fetch() int {
+	return k.limit + 1
+}
+
+// column is the SQL expression of field, prefix is the table alias of the query ("dr.") or empty
+func (k *Keyset) column(prefix string, field string) string {
+	f := k.spec.Fields[field]
+	if !f.Nullable {
+		return prefix + field
+	}
+	var zero string
+	switch f.Kind {
+	case Number:
+		zero = "0"
+	case Time:
+		zero = "TIMESTAMP '0001-01-01 00:00:00+00'"
+	case Date:
+		zero = "DATE '0001-01-01'"
+	default:
+		zero = "''"
+	}
+	return "COALESCE(" + prefix + field + ", " + zero + ")"
+}
+
+// OrderBy is the ORDER BY list of the query
+func (k *Keyset) OrderBy(prefix string) string {
+	direction := " ASC"
+	if k.desc {
+		direction = " DESC"
+	}
+	var order []string
+	for _, field := range k.columns() {
+		order = append(order, k.column(prefix, field)+direction)
+	}
+	return strings.Join(order, ", ")
+}
+
+// Where is the condition selecting the rows after the cursor, TRUE on the first page. arg adds a value to the
+// parameters of the query and returns its placeholder, so the same keyset renders for BigQuery and Postgres.
+func (k *Keyset) Where(prefix string, arg func(v any) string) string {
+	if k.after == nil {
+		return "TRUE"
+	}
+	op := " > "
+	if k.desc {
+		op = " < "
+	}
+	// (a, b) > (x, y) spelled out, BigQuery has no row comparison
+	columns := k.columns()
+	sortColumn := k.column(prefix, columns[0])
+	sortValue := arg(k.after[0])
+	if len(columns) == 1 {
+		return sortColumn + op + sortValue
+	}
+	return "(" + sortColumn + op + sortValue + " OR (" + sortColumn + " = " + sortValue + " AND " +
+		k.column(prefix, columns[1]) + op + arg(k.after[1]) + "))"
+}
+
+// NewPage trims the extra row fetched for Fetch off rows and sets the cursor of the next page from the last row
+func NewPage[T any](k *Keyset, rows []T) Page[T] {
+	page := Page[T]{Data: rows}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(rows) <= k.limit {
+		return page
+	}
+	page.Data = rows[:k.limit]
+
+	last := reflect.ValueOf(page.Data[k.limit-1])
+	c := cursor{Sort: k.sort}
+	for _, field := range k.columns() {
+		raw, _ := json.Marshal(encode(value(last, field)))
+		c.Values = append(c.Values, raw)
+	}
+	raw, _ := json.Marshal(c)
+	page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	return page
+}
+
+// Slice pages rows that are already in memory the way the query layer pages a table: sorted, after the cursor
+// and limited. It backs the in-memory store, databases must page in their queries.
+func Slice[T any](k *Keyset, rows []T) Page[T] {
+	columns := k.columns()
+	key := func(row T) []any {
+		v := reflect.ValueOf(row)
+		values := make([]any, len(columns))
+		for i, field := range columns {
+			values[i] = value(v, field)
+		}
+		return values
+	}
+	// less compares two sort keys in the direction of the keyset
+	less := func(a, b []any) bool {
+		for i := range a {
+			if c := compare(a[i], b[i]); c != 0 {
+				return (c < 0) != k.desc
+			}
+		}
+		return false
+	}
+
+	sorted := append([]T(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(key(sorted[i]), key(sorted[j])) })
+
+	start := 0
+	if k.after != nil {
+		start = sort.Search(len(sorted), func(i int) bool { return less(k.after, key(sorted[i])) })
+	}
+	end := start + k.Fetch()
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+	return NewPage(k, sorted[start:end])
+}
+
+// value reads the field of a model row by json name, nullable dates become their date or the zero date
+func value(row reflect.Value, field string) any {
+	row = reflect.Indirect(row)
+	t := row.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] != field {
+			continue
+		}
+		f := row.Field(i)
+		switch v := f.Interface().(type) {
+		case time.Time, civil.Date:
+			return v
+		case bigquery.NullDate:
+			if !v.Valid {
+				return civil.Date{Year: 1, Month: time.January, Day: 1}
+			}
+			return v.Date
+		}
+		switch f.Kind() {
+		case reflect.String:
+			return f.String()
+		case reflect.Float32, reflect.Float64:
+			return f.Float()
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			return float64(f.Int())
+		}
+		return f.Interface()
+	}
+	panic(fmt.Sprintf("pagination: %s has no field %q", t, field))
+}
+
+// encode is the JSON form of a value in a cursor
+func encode(v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case civil.Date:
+		return v.String()
+	}
+	return v
+}
+
+func decode(kind Kind, raw json.RawMessage) (any, error) {
+	switch kind {
+	case Number:
+		var f float64
+		err := json.Unmarshal(raw, &f)
+		return f, err
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case Time:
+		return time.Parse(time.RFC3339Nano, s)
+	case Date:
+		return civil.ParseDate(s)
+	}
+	return s, nil
+}
+
+// compare orders two values of the same kind
+func compare(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case civil.Date:
+		switch b := b.(civil.Date); {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	case float64:
+		switch b := b.(float64); {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// Write sends a page with a Link header to the next page, which is the request with the cursor swapped so the
+// filters, sort and limit carry over. Requests with a limit or cursor get the list envelope, requests without get
+// the page's rows as a bare array like the lists returned before they were paged.
+func Write[T any](w http.ResponseWriter, r *http.Request, page Page[T]) error {
+	if page.NextCursor != "" {
+		next := *r.URL
+		q := next.Query()
+		q.Set("cursor", page.NextCursor)
+		next.RawQuery = q.Encode()
+		next.Scheme, next.Host = "", ""
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if q := r.URL.Query(); !q.Has("limit") && !q.Has("cursor") {
+		return json.NewEncoder(w).Encode(page.Data)
+	}
+	return json.NewEncoder(w).Encode(page)
+}
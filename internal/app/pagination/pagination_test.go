@@ -0,0 +1,76 @@
+package pagination
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type row struct {
+	ID    string    `json:"id"`
+	Start time.Time `json:"start_time"`
+}
+
+var spec = Spec{
+	Fields:     map[string]Field{"id": {Kind: String}, "start_time": {Kind: Time}},
+	Default:    "-start_time",
+	Tiebreaker: "id",
+}
+
+// placeholders renders arguments as $N and collects them
+func placeholders(args *[]any) func(v any) string {
+	return func(v any) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+}
+
+func TestKeysetSQL(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	k, err := spec.Keyset(Params{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "dr.start_time DESC, dr.id DESC", k.OrderBy("dr."))
+	assert.Equal(t, "TRUE", k.Where("dr.", nil))
+	assert.Equal(t, 2, k.Fetch())
+
+	page := NewPage(k, []row{{ID: "b", Start: start}, {ID: "a", Start: start}})
+	require.Len(t, page.Data, 1)
+	require.NotEmpty(t, page.NextCursor)
+
+	k, err = spec.Keyset(Params{Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	var args []any
+	assert.Equal(t, "(dr.start_time < $1 OR (dr.start_time = $1 AND dr.id < $2))", k.Where("dr.", placeholders(&args)))
+	assert.Equal(t, []any{start, "b"}, args)
+
+	// the cursor belongs to the descending sort
+	_, err = spec.Keyset(Params{Cursor: page.NextCursor, Sort: "start_time"})
+	assert.Error(t, err)
+	_, err = spec.Keyset(Params{Sort: "-end_time"})
+	assert.Error(t, err)
+}
+
+func TestSlice(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rows := []row{{ID: "a", Start: start}, {ID: "c", Start: start.Add(time.Hour)}, {ID: "b", Start: start}}
+
+	var ids []string
+	p := Params{Limit: 2, Sort: "start_time"}
+	for {
+		k, err := spec.Keyset(p)
+		require.NoError(t, err)
+		page := Slice(k, rows)
+		for _, r := range page.Data {
+			ids = append(ids, r.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		p.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"google.golang.org/api/iterator"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RotateAPIKey(ctx context.Context, id string, prefix string, hash string, expiresAt time.Time) error
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+type apiKeyRepository struct {
+	client *tableClient
+	log    *slog.Logger
+}
+
+func NewAPIKeyRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) APIKeyRepository {
+	return &apiKeyRepository{client: newTableClient(client, tables), log: log}
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	if err := r.client.Put(ctx, "api_keys", key); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create api key", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.client.Get(ctx, "api_keys", id, &key); err != nil {
+		if err == bqclient.ErrNotFound {
+			return nil, custom_error.New(http.StatusNotFound, "api key not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch api key", err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := `
+        SELECT *
+        FROM {{table "api_keys"}}
+        WHERE key_hash = @key_hash
+        LIMIT 1`
+
+	it, err := r.client.Query(ctx, query, []bigquery.QueryParameter{{Name: "key_hash", Value: hash}})
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch api key", err)
+	}
+	var key models.APIKey
+	if err := it.Next(&key); err != nil {
+		if err == iterator.Done {
+			return nil, custom_error.New(http.StatusNotFound, "api key not found", bqclient.ErrNotFound)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch api key", err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `
+        SELECT *
+        FROM {{table "api_keys"}}
+        ORDER BY created_at DESC`
+
+	it, err := r.client.Query(ctx, query, nil)
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch api keys", err)
+	}
+	keys := []models.APIKey{}
+	for {
+		var key models.APIKey
+		err := it.Next(&key)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, custom_error.New(http.StatusInternalServerError, "Error reading api key data", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) RotateAPIKey(ctx context.Context, id string, prefix string, hash string, expiresAt time.Time) error {
+	updates := map[string]any{"prefix": prefix, "key_hash": hash, "expires_at": expiresAt}
+	if err := r.client.Update(ctx, "api_keys", id, updates); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to rotate api key", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	if err := r.client.Update(ctx, "api_keys", id, map[string]any{"revoked_at": at}); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to revoke api key", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	if err := r.client.Update(ctx, "api_keys", id, map[string]any{"last_used_at": at}); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update api key", err)
+	}
+	return nil
+}
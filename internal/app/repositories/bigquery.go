@@ -0,0 +1,328 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// tableClient wraps bqclient so every query, including the generic Get/Put/Update/Delete calls,
+// is built through the TableResolver instead of bqclient's fixed dataset
+type tableClient struct {
+	client bqclient.BQClient
+	tables *TableResolver
+}
+
+func newTableClient(client bqclient.BQClient, tables *TableResolver) *tableClient {
+	return &tableClient{client: client, tables: tables}
+}
+
+// Query renders the {{table "name"}} references of query and runs it
+func (c *tableClient) Query(ctx context.Context, query string, params []bigquery.QueryParameter) (*bigquery.RowIterator, error) {
+	rendered, err := c.tables.Render(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Query(ctx, rendered, params)
+}
+
+// Exec renders the {{table "name"}} references of a statement that returns no rows and runs it
+func (c *tableClient) Exec(ctx context.Context, query string, params []bigquery.QueryParameter) error {
+	rendered, err := c.tables.Render(query)
+	if err != nil {
+		return err
+	}
+	return c.exec(ctx, rendered, params)
+}
+
+// exec runs a statement that returns no rows, table names must already be resolved
+func (c *tableClient) exec(ctx context.Context, query string, params []bigquery.QueryParameter) error {
+	it, err := c.client.Query(ctx, query, params)
+	if err != nil {
+		return err
+	}
+	var row []bigquery.Value
+	if err := it.Next(&row); err != nil && err != iterator.Done {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Put inserts a struct, or a slice of structs, using their bigquery tags as columns
+func (c *tableClient) Put(ctx context.Context, table string, data any) error {
+	name, err := c.tables.table(table)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(data))
+	rows := []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		rows = rows[:0]
+		for i := 0; i < v.Len(); i++ {
+			rows = append(rows, reflect.Indirect(v.Index(i)))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var columns []string
+	var values []string
+	var params []bigquery.QueryParameter
+	for i, row := range rows {
+		if row.Kind() != reflect.Struct {
+			return fmt.Errorf("cannot insert %s into %s", row.Kind(), table)
+		}
+		var placeholders []string
+		for j := 0; j < row.NumField(); j++ {
+			column := row.Type().Field(j).Tag.Get("bigquery")
+			if column == "" || column == "-" {
+				continue
+			}
+			if i == 0 {
+				columns = append(columns, column)
+			}
+			param := fmt.Sprintf("%s_%d", column, i)
+			placeholders = append(placeholders, "@"+param)
+			params = append(params, bigquery.QueryParameter{Name: param, Value: row.Field(j).Interface()})
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+	}
+
+	query := fmt.Sprintf(`
+        INSERT INTO %s
+        (%s)
+        VALUES
+        %s`,
+		name,
+		strings.Join(columns, ", "),
+		strings.Join(values, ",\n        "),
+	)
+	return c.exec(ctx, query, params)
+}
+
+// Get loads the row with id into dst, returning bqclient.ErrNotFound when there is none
+func (c *tableClient) Get(ctx context.Context, table string, id string, dst any) error {
+	name, err := c.tables.table(table)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+        SELECT *
+        FROM %s
+        WHERE id = @id
+        LIMIT 1`, name)
+
+	it, err := c.client.Query(ctx, query, []bigquery.QueryParameter{{Name: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if err := it.Next(dst); err != nil {
+		if err == iterator.Done {
+			return bqclient.ErrNotFound
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Update sets the columns in updates on the row with id
+func (c *tableClient) Update(ctx context.Context, table string, id string, updates map[string]any) error {
+	name, err := c.tables.table(table)
+	if err != nil {
+		return err
+	}
+
+	setStatements, params := setClause(updates)
+	params = append(params, bigquery.QueryParameter{Name: "id", Value: id})
+
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET %s
+        WHERE id = @id`,
+		name,
+		strings.Join(setStatements, ", "),
+	)
+	return c.exec(ctx, query, params)
+}
+
+// UpdateVersion sets the columns in updates on the row with id only if it is still at version, and bumps the version.
+// It returns bqclient.ErrNotFound when there is no such row and ErrStale when the row has another version.
+func (c *tableClient) UpdateVersion(ctx context.Context, table string, id string, version int64, updates map[string]any) error {
+	name, err := c.tables.table(table)
+	if err != nil {
+		return err
+	}
+
+	setStatements, params := setClause(updates)
+	params = append(params,
+		bigquery.QueryParameter{Name: "id", Value: id},
+		bigquery.QueryParameter{Name: "version", Value: version},
+	)
+
+	// the script returns the result of its last statement, whether the update matched and whether the row exists
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET %s, version = version + 1
+        WHERE id = @id AND version = @version;
+
+        SELECT @@row_count > 0 AS updated, EXISTS(SELECT 1 FROM %s WHERE id = @id) AS found;`,
+		name,
+		strings.Join(setStatements, ", "),
+		name,
+	)
+
+	it, err := c.client.Query(ctx, query, params)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Updated bool `bigquery:"updated"`
+		Found   bool `bigquery:"found"`
+	}
+	if err := it.Next(&result); err != nil {
+		return errors.WithStack(err)
+	}
+	switch {
+	case result.Updated:
+		return nil
+	case result.Found:
+		return ErrStale
+	default:
+		return bqclient.ErrNotFound
+	}
+}
+
+// keysetArg adds the values a pagination.Keyset compares against to the parameters of a query as @cursor_N
+func keysetArg(params *[]bigquery.QueryParameter) func(v any) string {
+	return func(v any) string {
+		name := fmt.Sprintf("cursor_%d", len(*params))
+		*params = append(*params, bigquery.QueryParameter{Name: name, Value: v})
+		return "@" + name
+	}
+}
+
+// setClause builds the col = @col statements of an UPDATE and their parameters, sorted by column
+func setClause(updates map[string]any) ([]string, []bigquery.QueryParameter) {
+	fields := make([]string, 0, len(updates))
+	for field := range updates {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	setStatements := make([]string, 0, len(fields))
+	params := make([]bigquery.QueryParameter, 0, len(fields))
+	for _, field := range fields {
+		setStatements = append(setStatements, fmt.Sprintf("%s = @%s", field, field))
+		params = append(params, bigquery.QueryParameter{Name: field, Value: updates[field]})
+	}
+	return setStatements, params
+}
+
+// Delete removes the row with id
+func (c *tableClient) Delete(ctx context.Context, table string, id string) error {
+	name, err := c.tables.table(table)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+        DELETE FROM %s
+        WHERE id = @id`, name)
+	return c.exec(ctx, query, []bigquery.QueryParameter{{Name: "id", Value: id}})
+}
+
+// DeleteVersion removes the row with id only if it is still at version and no column in refs holds the id.
+// It returns bqclient.ErrNotFound when there is no such row, ErrStale when the row has another version and
+// ErrReferenced when it is still referenced.
+func (c *tableClient) DeleteVersion(ctx context.Context, table string, id string, version int64, refs ...reference) error {
+	return c.DeleteCascade(ctx, table, id, version, nil, refs...)
+}
+
+// DeleteCascade is DeleteVersion that first deletes the rows whose column in cascade holds the id, in the same
+// transaction so they are only gone when the row is
+func (c *tableClient) DeleteCascade(ctx context.Context, table string, id string, version int64, cascade []reference, refs ...reference) error {
+	name, err := c.tables.table(table)
+	if err != nil {
+		return err
+	}
+
+	var guards []string
+	for _, ref := range refs {
+		refName, err := c.tables.table(ref.table)
+		if err != nil {
+			return err
+		}
+		guards = append(guards, fmt.Sprintf("AND NOT EXISTS(SELECT 1 FROM %s WHERE %s = @id)", refName, ref.column))
+	}
+	var deletes []string
+	for _, ref := range cascade {
+		refName, err := c.tables.table(ref.table)
+		if err != nil {
+			return err
+		}
+		deletes = append(deletes, fmt.Sprintf("DELETE FROM %s WHERE %s = @id;", refName, ref.column))
+	}
+	condition := "id = @id AND version = @version\n            " + strings.Join(guards, "\n            ")
+
+	// the check and the deletes run in one transaction, so the row can't change or gain a reference in between.
+	// The script returns the result of its last statement, whether the row was deleted, exists and is at version.
+	query := fmt.Sprintf(`
+        DECLARE deleted BOOL DEFAULT FALSE;
+        BEGIN TRANSACTION;
+        IF EXISTS(SELECT 1 FROM %s WHERE %s) THEN
+            %s
+            DELETE FROM %s WHERE %s;
+            SET deleted = @@row_count > 0;
+        END IF;
+        COMMIT TRANSACTION;
+
+        SELECT deleted,
+            EXISTS(SELECT 1 FROM %s WHERE id = @id) AS found,
+            EXISTS(SELECT 1 FROM %s WHERE id = @id AND version = @version) AS current;`,
+		name, condition,
+		strings.Join(deletes, "\n            "),
+		name, condition,
+		name,
+		name,
+	)
+
+	it, err := c.client.Query(ctx, query, []bigquery.QueryParameter{
+		{Name: "id", Value: id},
+		{Name: "version", Value: version},
+	})
+	if err != nil {
+		// the transaction lost to a concurrent change of the row or of the rows pointing at it
+		if isConcurrentUpdate(err) {
+			return ErrStale
+		}
+		return err
+	}
+	var result struct {
+		Deleted bool `bigquery:"deleted"`
+		Found   bool `bigquery:"found"`
+		Current bool `bigquery:"current"`
+	}
+	if err := it.Next(&result); err != nil {
+		return errors.WithStack(err)
+	}
+	switch {
+	case result.Deleted:
+		return nil
+	case result.Current:
+		return ErrReferenced
+	case result.Found:
+		return ErrStale
+	default:
+		return bqclient.ErrNotFound
+	}
+}
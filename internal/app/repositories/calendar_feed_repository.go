@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// CalendarFeedRepository stores the generation of each user's calendar feed tokens
+type CalendarFeedRepository interface {
+	// GetFeedGeneration returns the user's current generation, 0 when they never revoked their tokens
+	GetFeedGeneration(ctx context.Context, userID string) (int64, error)
+	// RevokeFeedTokens bumps the user's generation and returns the new one
+	RevokeFeedTokens(ctx context.Context, userID string, at time.Time) (int64, error)
+}
+
+type calendarFeedRepository struct {
+	client *tableClient
+	log    *slog.Logger
+}
+
+func NewCalendarFeedRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) CalendarFeedRepository {
+	return &calendarFeedRepository{client: newTableClient(client, tables), log: log}
+}
+
+func (r *calendarFeedRepository) GetFeedGeneration(ctx context.Context, userID string) (int64, error) {
+	query := `
+        SELECT generation
+        FROM {{table "calendar_feeds"}}
+        WHERE user_id = @user_id
+        LIMIT 1`
+
+	it, err := r.client.Query(ctx, query, []bigquery.QueryParameter{{Name: "user_id", Value: userID}})
+	if err != nil {
+		return 0, custom_error.New(http.StatusInternalServerError, "Failed to fetch calendar feed", err)
+	}
+	var row struct {
+		Generation int64 `bigquery:"generation"`
+	}
+	if err := it.Next(&row); err != nil {
+		if err == iterator.Done {
+			return 0, nil
+		}
+		return 0, custom_error.New(http.StatusInternalServerError, "Failed to fetch calendar feed", err)
+	}
+	return row.Generation, nil
+}
+
+func (r *calendarFeedRepository) RevokeFeedTokens(ctx context.Context, userID string, at time.Time) (int64, error) {
+	query := `
+        MERGE {{table "calendar_feeds"}} t
+        USING (SELECT @user_id AS user_id) s
+        ON t.user_id = s.user_id
+        WHEN MATCHED THEN
+            UPDATE SET generation = t.generation + 1, revoked_at = @revoked_at
+        WHEN NOT MATCHED THEN
+            INSERT (user_id, generation, revoked_at) VALUES (@user_id, 1, @revoked_at);
+
+        SELECT generation
+        FROM {{table "calendar_feeds"}}
+        WHERE user_id = @user_id;`
+
+	params := []bigquery.QueryParameter{
+		{Name: "user_id", Value: userID},
+		{Name: "revoked_at", Value: at},
+	}
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return 0, custom_error.New(http.StatusInternalServerError, "Failed to revoke calendar feed tokens", err)
+	}
+	var row struct {
+		Generation int64 `bigquery:"generation"`
+	}
+	if err := it.Next(&row); err != nil {
+		return 0, custom_error.New(http.StatusInternalServerError, "Failed to revoke calendar feed tokens", errors.WithStack(err))
+	}
+	return row.Generation, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
@@ -18,38 +19,57 @@ import (
 type ContractRepository interface {
 	CreateContract(ctx context.Context, data *models.Contract) error
 	GetContract(ctx context.Context, id string) (*models.Contract, error)
-	UpdateContract(ctx context.Context, id string, data *models.Contract) error
-	DeleteContract(ctx context.Context, id string) error
-	GetContractsByProjectID(ctx context.Context, id string) ([]models.Contract, error)
+	UpdateContract(ctx context.Context, id string, data *models.Contract, fields []string) error
+	DeleteContract(ctx context.Context, id string, version int64) error
+	GetContractsByProjectID(ctx context.Context, id string, filter ContractFilter, page pagination.Params) (pagination.Page[models.Contract], error)
+}
+
+// ContractFilter narrows a list of contracts, zero fields match every contract
+type ContractFilter struct {
+	Status models.ContractStatus
+}
+
+// ContractSort is how contract lists may be sorted, newest first by default. Cleared dates sort as the oldest.
+var ContractSort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":                 {Kind: pagination.String},
+		"start_date":         {Kind: pagination.Date, Nullable: true},
+		"end_date":           {Kind: pagination.Date, Nullable: true},
+		"contract_threshold": {Kind: pagination.Number},
+	},
+	Default:    "-start_date",
+	Tiebreaker: "id",
 }
 
 type contractRepository struct {
-	client bqclient.BQClient
+	client *tableClient
     log *slog.Logger
 }
 
-func NewContractRepository(client bqclient.BQClient, log *slog.Logger) ContractRepository {
-	return &contractRepository{client: client, log: log}
+func NewContractRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) ContractRepository {
+	return &contractRepository{client: newTableClient(client, tables), log: log}
 }
 
 func (r *contractRepository) CreateContract(ctx context.Context, data *models.Contract) error {
+	data.Version = 1
 	query := `
         DECLARE inserted BOOL DEFAULT FALSE;
 
-        INSERT INTO gridstream_operations.contracts (id, contract_threshold, start_date, end_date, status, project_id)
+        INSERT INTO {{table "contracts"}} (id, contract_threshold, start_date, end_date, status, project_id, version)
         SELECT 
             @id,
             @contract_threshold,
             DATE(@start_date),
             DATE(@end_date),
             @status,
-            @project_id
-        FROM gridstream_operations.projects p
+            @project_id,
+            @version
+        FROM {{table "projects"}} p
         WHERE p.id = @project_id;
 
         SET inserted = EXISTS(
             SELECT 1
-            FROM gridstream_operations.contracts c
+            FROM {{table "contracts"}} c
             WHERE c.id = @id
         );
         SELECT inserted AS inserted;`
@@ -61,6 +81,7 @@ func (r *contractRepository) CreateContract(ctx context.Context, data *models.Co
 		{Name: "end_date", Value: data.EndDate},
 		{Name: "status", Value: data.Status},
 		{Name: "project_id", Value: data.ProjectID},
+		{Name: "version", Value: data.Version},
 	}
 
 	it, err := r.client.Query(ctx, query, params)
@@ -106,31 +127,39 @@ func (r *contractRepository) GetContract(ctx context.Context, id string) (*model
 	return &contract, nil
 }
 
-func (r *contractRepository) UpdateContract(ctx context.Context, id string, data *models.Contract) error {
-	updates := logic.ExtractBody(data)
+func (r *contractRepository) UpdateContract(ctx context.Context, id string, data *models.Contract, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
 
 	if len(updates) == 0 {
 		return custom_error.New(http.StatusBadRequest, "No fields to update", errors.New("No fields to update"))
 	}
 
-	if err := r.client.Update(ctx, "contracts", id, updates); err != nil {
-		return custom_error.New(http.StatusInternalServerError, "Failed to update", err)
+	if err := r.client.UpdateVersion(ctx, "contracts", id, data.Version, updates); err != nil {
+		return versionedUpdateError(err, "Contract not found")
 	}
 
+	data.Version++
 	return nil
 }
 
-func (r *contractRepository) DeleteContract(ctx context.Context, id string) error {
-	if err := r.client.Delete(ctx, "contracts", id); err != nil {
-		if err == bqclient.ErrNotFound {
-			return custom_error.New(http.StatusNotFound, "contract id not found", err)
-		}
-		return custom_error.New(http.StatusInternalServerError, "Failed to delete contract", err)
+func (r *contractRepository) DeleteContract(ctx context.Context, id string, version int64) error {
+	if err := r.client.DeleteVersion(ctx, "contracts", id, version); err != nil {
+		return deleteError(err, "contract id not found", "Failed to delete contract")
 	}
 	return nil
 }
 
-func (r *contractRepository) GetContractsByProjectID(ctx context.Context, id string) ([]models.Contract, error) {
+func (r *contractRepository) GetContractsByProjectID(ctx context.Context, id string, filter ContractFilter, page pagination.Params) (pagination.Page[models.Contract], error) {
+	keyset, err := ContractSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Contract]{}, err
+	}
+
+	params := []bigquery.QueryParameter{
+		{Name: "project_id", Value: id},
+		{Name: "status", Value: string(filter.Status)},
+		{Name: "limit", Value: keyset.Fetch()},
+	}
 
 	query := `
         SELECT 
@@ -139,22 +168,22 @@ func (r *contractRepository) GetContractsByProjectID(ctx context.Context, id str
             c.start_date,
             c.end_date,
             c.status,
-            c.project_id
+            c.project_id,
+            c.version
         FROM 
-            gridstream_operations.contracts AS c
+            {{table "contracts"}} AS c
         WHERE 
             c.project_id = @project_id
+            AND (@status = '' OR c.status = @status)
+            AND ` + keyset.Where("c.", keysetArg(&params)) + `
         ORDER BY 
-            c.start_date DESC;
+            ` + keyset.OrderBy("c.") + `
+        LIMIT @limit;
     `
 
-	params := []bigquery.QueryParameter{
-		{Name: "project_id", Value: id},
-	}
-
 	it, err := r.client.Query(ctx, query, params)
 	if err != nil {
-		return nil, custom_error.New(http.StatusInternalServerError, "Failed to list contracts", err)
+		return pagination.Page[models.Contract]{}, custom_error.New(http.StatusInternalServerError, "Failed to list contracts", err)
 	}
 
 	contracts := []models.Contract{}
@@ -165,9 +194,9 @@ func (r *contractRepository) GetContractsByProjectID(ctx context.Context, id str
 			break
 		}
 		if err != nil {
-			return nil, custom_error.New(http.StatusInternalServerError, "Error reading contract data", err)
+			return pagination.Page[models.Contract]{}, custom_error.New(http.StatusInternalServerError, "Error reading contract data", err)
 		}
 		contracts = append(contracts, item)
 	}
-	return contracts, nil
+	return pagination.NewPage(keyset, contracts), nil
 }
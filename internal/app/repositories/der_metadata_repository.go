@@ -7,6 +7,7 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
@@ -17,19 +18,38 @@ type DERMetadataRepository interface {
 	CreateDERMetadata(ctx context.Context, data *models.DERMetadata) error
 	BatchCreateDERMetadata(ctx context.Context, data []models.DERMetadata) error
 	GetDERMetadata(ctx context.Context, id string) (*models.DERMetadata, error)
-	ListDERMetadataByProject(ctx context.Context, id string) ([]models.DERMetadata, error)
-	UpdateDERMetadata(ctx context.Context, id string, data *models.DERMetadata) error
-	DeleteDERMetadata(ctx context.Context, id string) error
+	ListDERMetadataByProject(ctx context.Context, id string, filter DERMetadataFilter, page pagination.Params) (pagination.Page[models.DERMetadata], error)
+	UpdateDERMetadata(ctx context.Context, id string, data *models.DERMetadata, fields []string) error
+	DeleteDERMetadata(ctx context.Context, id string, version int64) error
 }
+
+// DERMetadataFilter narrows a list of DERs, zero fields match every DER
+type DERMetadataFilter struct {
+	Type models.DERType
+}
+
+// DERMetadataSort is how DER lists may be sorted, by id by default
+var DERMetadataSort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":                 {Kind: pagination.String},
+		"type":               {Kind: pagination.String},
+		"nameplate_capacity": {Kind: pagination.Number},
+		"power_capacity":     {Kind: pagination.Number},
+	},
+	Default:    "id",
+	Tiebreaker: "id",
+}
+
 type derMetadataRepository struct {
-	client bqclient.BQClient
+	client *tableClient
 }
 
-func NewDERMetadataRepository(client bqclient.BQClient, log *slog.Logger) DERMetadataRepository {
-	return &derMetadataRepository{client: client}
+func NewDERMetadataRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) DERMetadataRepository {
+	return &derMetadataRepository{client: newTableClient(client, tables)}
 }
 
 func (r *derMetadataRepository) CreateDERMetadata(ctx context.Context, data *models.DERMetadata) error {
+	data.Version = 1
 	if err := r.client.Put(ctx, "der_metadata", data); err != nil {
 		return custom_error.New(http.StatusInternalServerError, "Failed to create der metadata", err)
 	}
@@ -37,6 +57,9 @@ func (r *derMetadataRepository) CreateDERMetadata(ctx context.Context, data *mod
 }
 
 func (r *derMetadataRepository) BatchCreateDERMetadata(ctx context.Context, data []models.DERMetadata) error {
+	for i := range data {
+		data[i].Version = 1
+	}
 	if err := r.client.Put(ctx, "der_metadata", data); err != nil {
 		return custom_error.New(http.StatusInternalServerError, "Failed to create all der metadata", err)
 	}
@@ -55,17 +78,28 @@ func (r *derMetadataRepository) GetDERMetadata(ctx context.Context, id string) (
 	return &derMetadata, nil
 }
 
-func (r *derMetadataRepository) ListDERMetadataByProject(ctx context.Context, id string) ([]models.DERMetadata, error) {
-	query := `
-        SELECT *
-        FROM gridstream_operations.der_metadata
-        WHERE project_id = @project_id`
+func (r *derMetadataRepository) ListDERMetadataByProject(ctx context.Context, id string, filter DERMetadataFilter, page pagination.Params) (pagination.Page[models.DERMetadata], error) {
+	keyset, err := DERMetadataSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DERMetadata]{}, err
+	}
+
 	params := []bigquery.QueryParameter{
 		{Name: "project_id", Value: id},
+		{Name: "type", Value: string(filter.Type)},
+		{Name: "limit", Value: keyset.Fetch()},
 	}
+	query := `
+        SELECT *
+        FROM {{table "der_metadata"}}
+        WHERE project_id = @project_id
+            AND (@type = '' OR type = @type)
+            AND ` + keyset.Where("", keysetArg(&params)) + `
+        ORDER BY ` + keyset.OrderBy("") + `
+        LIMIT @limit`
 	it, err := r.client.Query(ctx, query, params)
 	if err != nil {
-		return nil, custom_error.New(http.StatusInternalServerError, "Failed to list der metadata", err)
+		return pagination.Page[models.DERMetadata]{}, custom_error.New(http.StatusInternalServerError, "Failed to list der metadata", err)
 	}
 
 	derMetadata := []models.DERMetadata{}
@@ -76,34 +110,32 @@ func (r *derMetadataRepository) ListDERMetadataByProject(ctx context.Context, id
 			break
 		}
 		if err != nil {
-			return nil, custom_error.New(http.StatusInternalServerError, "Error reading der metadata", err)
+			return pagination.Page[models.DERMetadata]{}, custom_error.New(http.StatusInternalServerError, "Error reading der metadata", err)
 		}
 		derMetadata = append(derMetadata, item)
 	}
 
-	return derMetadata, nil
+	return pagination.NewPage(keyset, derMetadata), nil
 }
 
-func (r *derMetadataRepository) UpdateDERMetadata(ctx context.Context, id string, data *models.DERMetadata) error {
-	updates := logic.ExtractBody(data)
+func (r *derMetadataRepository) UpdateDERMetadata(ctx context.Context, id string, data *models.DERMetadata, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
 
 	if len(updates) == 0 {
 		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
 	}
 
-	if err := r.client.Update(ctx, "der_metadata", id, updates); err != nil {
-		return custom_error.New(http.StatusInternalServerError, "Failed to update", err)
+	if err := r.client.UpdateVersion(ctx, "der_metadata", id, data.Version, updates); err != nil {
+		return versionedUpdateError(err, "der not found")
 	}
 
+	data.Version++
 	return nil
 }
 
-func (r *derMetadataRepository) DeleteDERMetadata(ctx context.Context, id string) error {
-	if err := r.client.Delete(ctx, "der_metadata", id); err != nil {
-		if err == bqclient.ErrNotFound {
-			return custom_error.New(http.StatusNotFound, "der id not found", err)
-		}
-		return custom_error.New(http.StatusInternalServerError, "Failed to delete contract", err)
+func (r *derMetadataRepository) DeleteDERMetadata(ctx context.Context, id string, version int64) error {
+	if err := r.client.DeleteVersion(ctx, "der_metadata", id, version); err != nil {
+		return deleteError(err, "der id not found", "Failed to delete der metadata")
 	}
 	return nil
 }
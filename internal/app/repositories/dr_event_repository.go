@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/bigquery"
 	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
@@ -18,36 +21,122 @@ import (
 type DREventRepository interface {
 	CreateDREvent(ctx context.Context, data *models.DREvents) error
 	GetDREvent(ctx context.Context, id string) (*models.DREvents, error)
-	UpdateDREvent(ctx context.Context, id string, data *models.DREvents) error
-	DeleteDREvent(ctx context.Context, id string) error
-	GetDREventsByProjectID(ctx context.Context, id string) ([]models.DREvents, error)
-    GetDREventsByUtilityID(ctx context.Context, id string) ([]models.DREvents, error)
+	UpdateDREvent(ctx context.Context, id string, data *models.DREvents, fields []string) error
+	DeleteDREvent(ctx context.Context, id string, version int64) error
+	// TransitionDREvent applies action to the event as it was read at data.Version, see models.DREvents.Transition
+	TransitionDREvent(ctx context.Context, id string, data *models.DREvents, action models.DREventAction, at time.Time) error
+	// EnrollProjects adds the projects of the event's utility that target selects to its participants and returns
+	// them all, projects that already participate keep their status. It only enrolls while the event is still at
+	// event.Version and editable, see CheckEnrollment.
+	EnrollProjects(ctx context.Context, event *models.DREvents, target models.ParticipantTarget, at time.Time) ([]models.DREventParticipant, error)
+	GetParticipants(ctx context.Context, eventID string) ([]models.DREventParticipant, error)
+	// OptOut withdraws an enrolled project from the event
+	OptOut(ctx context.Context, eventID string, projectID string, at time.Time) (*models.DREventParticipant, error)
+	// RecordVENResponse keeps the last opt answer of the project's OpenADR gateway to the event
+	RecordVENResponse(ctx context.Context, eventID string, projectID string, opt models.VENOpt, at time.Time) error
+	GetDREventsByProjectID(ctx context.Context, id string, filter DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error)
+    GetDREventsByUtilityID(ctx context.Context, id string, filter DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error)
 }
 
+// NotParticipant is the error of opting out a project the event doesn't target
+func NotParticipant() error {
+	return custom_error.New(http.StatusNotFound, "Project is not a participant of the demand response event", nil)
+}
+
+// AlreadyOptedOut is the error of opting out twice
+func AlreadyOptedOut() error {
+	return custom_error.New(http.StatusConflict, "Project has already opted out of the demand response event", nil)
+}
+
+// CheckEnrollment checks the event as it is stored when projects are enrolled, it fails with a version conflict
+// when the event changed since version was read and with a conflict once its participants are fixed
+func CheckEnrollment(current *models.DREvents, version int64) error {
+	if current.Version != version {
+		return VersionConflict(ErrStale)
+	}
+	return current.CheckEditable()
+}
+
+// UnknownProjects is the error of enrolling projects that don't exist or belong to another utility
+func UnknownProjects(ids []string) error {
+	return custom_error.New(http.StatusBadRequest, fmt.Sprintf("Failed to enroll, these projects aren't in the event's utility: %s", strings.Join(ids, ", ")), nil)
+}
+
+// DREventFilter narrows a list of events to those overlapping [From, To), a zero bound is open, and to the set
+// status and DER type
+type DREventFilter struct {
+	From   time.Time
+	To     time.Time
+	Status models.DREventStatus
+	// DERType keeps the events with an enrolled project that has a DER of this type
+	DERType models.DERType
+}
+
+// DREventSort is how event lists may be sorted, latest start first by default
+var DREventSort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":         {Kind: pagination.String},
+		"start_time": {Kind: pagination.Time},
+		"end_time":   {Kind: pagination.Time},
+	},
+	Default:    "-start_time",
+	Tiebreaker: "id",
+}
+
+// timeRangeParams are the @from and @to parameters of a time range filter, NULL when the bound is open
+func timeRangeParams(from, to time.Time) []bigquery.QueryParameter {
+	return []bigquery.QueryParameter{
+		{Name: "from", Value: bigquery.NullTimestamp{Timestamp: from, Valid: !from.IsZero()}},
+		{Name: "to", Value: bigquery.NullTimestamp{Timestamp: to, Valid: !to.IsZero()}},
+	}
+}
+
+// eventFilterParams are the parameters of the eventFilter conditions
+func eventFilterParams(filter DREventFilter) []bigquery.QueryParameter {
+	return append(timeRangeParams(filter.From, filter.To),
+		bigquery.QueryParameter{Name: "status", Value: string(filter.Status)},
+		bigquery.QueryParameter{Name: "der_type", Value: string(filter.DERType)},
+	)
+}
+
+// eventFilter is the DREventFilter part of the WHERE clause of an event list, the events table is dr
+const eventFilter = `(@from IS NULL OR dr.end_time > @from)
+            AND (@to IS NULL OR dr.start_time < @to)
+            AND (@status = '' OR dr.status = @status)
+            AND (@der_type = '' OR EXISTS(
+                SELECT 1
+                FROM {{table "dr_event_participants"}} AS tp
+                JOIN {{table "der_metadata"}} AS der
+                    ON der.project_id = tp.project_id
+                WHERE tp.event_id = dr.id AND der.type = @der_type))`
+
 type drEventRepository struct {
-	client bqclient.BQClient
+	client *tableClient
 }
 
-func NewDREventRepository(client bqclient.BQClient, log *slog.Logger) DREventRepository {
-	return &drEventRepository{client: client}
+func NewDREventRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) DREventRepository {
+	return &drEventRepository{client: newTableClient(client, tables)}
 }
 
 func (r *drEventRepository) CreateDREvent(ctx context.Context, data *models.DREvents) error {
+	data.Version = 1
 	query := `
         DECLARE inserted BOOL DEFAULT FALSE;
 
-        INSERT INTO gridstream_operations.dr_events (id, utility_id, start_time, end_time)
+        INSERT INTO {{table "dr_events"}} (id, utility_id, start_time, end_time, status, version)
         SELECT 
             @id,
             @utility_id,
             TIMESTAMP(@start_time),
-            TIMESTAMP(@end_time)
-        FROM gridstream_operations.utilities p
+            TIMESTAMP(@end_time),
+            @status,
+            @version
+        FROM {{table "utilities"}} p
         WHERE p.id = @utility_id;
 
         SET inserted = EXISTS(
             SELECT 1
-            FROM gridstream_operations.dr_events c
+            FROM {{table "dr_events"}} c
             WHERE c.id = @id
         );
         SELECT inserted AS inserted;`
@@ -57,6 +146,8 @@ func (r *drEventRepository) CreateDREvent(ctx context.Context, data *models.DREv
 		{Name: "utility_id", Value: data.UtilityID},
 		{Name: "start_time", Value: data.StartTime},
 		{Name: "end_time", Value: data.EndTime},
+		{Name: "status", Value: data.Status},
+		{Name: "version", Value: data.Version},
 	}
 
 	it, err := r.client.Query(ctx, query, params)
@@ -102,99 +193,152 @@ func (r *drEventRepository) GetDREvent(ctx context.Context, id string) (*models.
 	return &event, nil
 }
 
-func (r *drEventRepository) UpdateDREvent(ctx context.Context, id string, data *models.DREvents) error {
-	updates := logic.ExtractBody(data)
+func (r *drEventRepository) UpdateDREvent(ctx context.Context, id string, data *models.DREvents, fields []string) error {
+	// the version guard of the update makes sure the status checked here is still the stored one
+	if err := data.CheckEditable(); err != nil {
+		return err
+	}
+	updates := logic.ExtractFields(data, fields)
 
 	if len(updates) == 0 {
 		return custom_error.New(http.StatusBadRequest, "No fields to update", errors.New("No fields to update"))
 	}
 
-	if err := r.client.Update(ctx, "dr_events", id, updates); err != nil {
-		return custom_error.New(http.StatusInternalServerError, "Failed to update", err)
+	if err := r.client.UpdateVersion(ctx, "dr_events", id, data.Version, updates); err != nil {
+		return versionedUpdateError(err, "demand response event not found")
 	}
 
+	data.Version++
 	return nil
 }
 
-func (r *drEventRepository) DeleteDREvent(ctx context.Context, id string) error {
-	if err := r.client.Delete(ctx, "dr_events", id); err != nil {
-		if err == bqclient.ErrNotFound {
-			return custom_error.New(http.StatusNotFound, "demand response event id not found", err)
-		}
-		return custom_error.New(http.StatusInternalServerError, "Failed to delete demand response event", err)
+func (r *drEventRepository) TransitionDREvent(ctx context.Context, id string, data *models.DREvents, action models.DREventAction, at time.Time) error {
+	fields, err := data.Transition(action, at)
+	if err != nil {
+		return err
+	}
+	if err := r.client.UpdateVersion(ctx, "dr_events", id, data.Version, logic.ExtractFields(data, fields)); err != nil {
+		return versionedUpdateError(err, "demand response event not found")
 	}
+
+	data.Version++
 	return nil
 }
 
-func (r *drEventRepository) GetDREventsByProjectID(ctx context.Context, id string) ([]models.DREvents, error) {
+func (r *drEventRepository) DeleteDREvent(ctx context.Context, id string, version int64) error {
+	// the participants go with the event, like the cascade of the other backends
+	participants := []reference{{table: "dr_event_participants", column: "event_id"}}
+	if err := r.client.DeleteCascade(ctx, "dr_events", id, version, participants); err != nil {
+		return deleteError(err, "demand response event id not found", "Failed to delete demand response event")
+	}
+	return nil
+}
+
+func (r *drEventRepository) GetDREventsByProjectID(ctx context.Context, id string, filter DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	keyset, err := DREventSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DREvents]{}, err
+	}
+
+	params := append(eventFilterParams(filter),
+		bigquery.QueryParameter{Name: "project_id", Value: id},
+		bigquery.QueryParameter{Name: "limit", Value: keyset.Fetch()},
+	)
+
 	query := `
 		SELECT
 			dr.id AS id,
 			dr.start_time,
 			dr.end_time,
 			dr.utility_id,
-			u.display_name AS utility_name
+			u.display_name AS utility_name,
+			dr.status,
+			dr.scheduled_at,
+			dr.activated_at,
+			dr.completed_at,
+			dr.cancelled_at,
+			pa.status AS participation,
+			dr.version
 		FROM
-			gridstream_operations.projects AS p
+			{{table "dr_event_participants"}} AS pa
 		JOIN
-			gridstream_operations.dr_events AS dr
-			ON p.utility_id = dr.utility_id
+			{{table "dr_events"}} AS dr
+			ON pa.event_id = dr.id
 		JOIN
-			gridstream_operations.utilities AS u
+			{{table "utilities"}} AS u
 			ON dr.utility_id = u.id
 		WHERE
-			p.id = @project_id
+			pa.project_id = @project_id
+			AND ` + eventFilter + `
+			AND ` + keyset.Where("dr.", keysetArg(&params)) + `
 		ORDER BY
-			dr.start_time DESC;
+			` + keyset.OrderBy("dr.") + `
+		LIMIT @limit;
 	`
 
-	params := []bigquery.QueryParameter{
-		{Name: "project_id", Value: id},
-	}
+	return r.list(ctx, keyset, query, params)
+}
 
-	it, err := r.client.Query(ctx, query, params)
+func (r *drEventRepository) GetDREventsByUtilityID(ctx context.Context, id string, filter DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	keyset, err := DREventSort.Keyset(page)
 	if err != nil {
-		return nil, custom_error.New(http.StatusInternalServerError, "Failed to list der metadata", err)
+		return pagination.Page[models.DREvents]{}, err
 	}
 
-	drEvents := []models.DREvents{}
-	for {
-		var item models.DREvents
-		err := it.Next(&item)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, custom_error.New(http.StatusInternalServerError, "Error reading der metadata", err)
-		}
-		drEvents = append(drEvents, item)
-	}
-	return drEvents, nil
-}
+	params := append(eventFilterParams(filter),
+		bigquery.QueryParameter{Name: "utility_id", Value: id},
+		bigquery.QueryParameter{Name: "limit", Value: keyset.Fetch()},
+	)
 
-func (r *drEventRepository) GetDREventsByUtilityID(ctx context.Context, id string) ([]models.DREvents, error) {
     query := `
         SELECT 
             dr.id AS id,
             dr.start_time,
             dr.end_time,
             dr.utility_id,
-            u.display_name AS utility_name
-        FROM gridstream_operations.dr_events AS dr
+            u.display_name AS utility_name,
+            dr.status,
+            dr.scheduled_at,
+            dr.activated_at,
+            dr.completed_at,
+            dr.cancelled_at,
+            dr.version
+        FROM {{table "dr_events"}} AS dr
         JOIN
-			gridstream_operations.utilities AS u
+			{{table "utilities"}} AS u
 			ON dr.utility_id = u.id
         WHERE 
             dr.utility_id = @utility_id
+            AND ` + eventFilter + `
+            AND ` + keyset.Where("dr.", keysetArg(&params)) + `
         ORDER BY 
-            dr.start_time DESC;
+            ` + keyset.OrderBy("dr.") + `
+        LIMIT @limit;
     `
-    params := []bigquery.QueryParameter{
-		{Name: "utility_id", Value: id},
+
+	events, err := r.list(ctx, keyset, query, params)
+	if err != nil {
+		return events, err
 	}
-    it, err := r.client.Query(ctx, query, params)
+	ids := make([]string, len(events.Data))
+	for i, event := range events.Data {
+		ids[i] = event.ID
+	}
+	participants, err := r.participants(ctx, ids)
+	if err != nil {
+		return events, err
+	}
+	for i := range events.Data {
+		events.Data[i].Participants = participants[events.Data[i].ID]
+	}
+	return events, nil
+}
+
+// list runs a query for a page of events joined with their utility's name
+func (r *drEventRepository) list(ctx context.Context, keyset *pagination.Keyset, query string, params []bigquery.QueryParameter) (pagination.Page[models.DREvents], error) {
+	it, err := r.client.Query(ctx, query, params)
 	if err != nil {
-		return nil, custom_error.New(http.StatusInternalServerError, "Failed to list der metadata", err)
+		return pagination.Page[models.DREvents]{}, custom_error.New(http.StatusInternalServerError, "Failed to list demand response events", err)
 	}
 
 	drEvents := []models.DREvents{}
@@ -205,9 +349,223 @@ func (r *drEventRepository) GetDREventsByUtilityID(ctx context.Context, id strin
 			break
 		}
 		if err != nil {
-			return nil, custom_error.New(http.StatusInternalServerError, "Error reading der metadata", err)
+			return pagination.Page[models.DREvents]{}, custom_error.New(http.StatusInternalServerError, "Error reading demand response events", err)
 		}
 		drEvents = append(drEvents, item)
 	}
-	return drEvents, nil
-}
\ No newline at end of file
+	return pagination.NewPage(keyset, drEvents), nil
+}
+
+func (r *drEventRepository) EnrollProjects(ctx context.Context, event *models.DREvents, target models.ParticipantTarget, at time.Time) ([]models.DREventParticipant, error) {
+	// participants are fixed once the event has started
+	if err := event.CheckEditable(); err != nil {
+		return nil, err
+	}
+	projectIDs := target.ProjectIDs
+	if projectIDs == nil {
+		projectIDs = []string{}
+	}
+	params := []bigquery.QueryParameter{
+		{Name: "event_id", Value: event.ID},
+		{Name: "version", Value: event.Version},
+		{Name: "utility_id", Value: event.UtilityID},
+		{Name: "project_ids", Value: projectIDs},
+		{Name: "active_contract", Value: target.ActiveContract},
+		{Name: "der_type", Value: string(target.DERType)},
+		{Name: "min_capacity", Value: target.MinCapacity},
+		{Name: "enrolled_at", Value: at},
+	}
+
+	if len(projectIDs) > 0 {
+		it, err := r.client.Query(ctx, `
+            SELECT id
+            FROM UNNEST(@project_ids) AS id
+            WHERE id NOT IN (SELECT p.id FROM {{table "projects"}} AS p WHERE p.utility_id = @utility_id)`, params)
+		if err != nil {
+			return nil, custom_error.New(http.StatusInternalServerError, "Failed to enroll projects", err)
+		}
+		var unknown []string
+		for {
+			var row struct {
+				ID string `bigquery:"id"`
+			}
+			err := it.Next(&row)
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, custom_error.New(http.StatusInternalServerError, "Error reading projects", err)
+			}
+			unknown = append(unknown, row.ID)
+		}
+		if len(unknown) > 0 {
+			return nil, UnknownProjects(unknown)
+		}
+	}
+
+	query := `
+        INSERT INTO {{table "dr_event_participants"}} (event_id, project_id, status, enrolled_at)
+        SELECT @event_id, p.id, 'enrolled', @enrolled_at
+        FROM {{table "projects"}} AS p
+        WHERE p.utility_id = @utility_id
+            AND (ARRAY_LENGTH(@project_ids) = 0 OR p.id IN UNNEST(@project_ids))
+            AND (NOT @active_contract OR EXISTS(
+                SELECT 1 FROM {{table "contracts"}} AS c WHERE c.project_id = p.id AND c.status = 'active'))
+            AND (@der_type = '' OR EXISTS(
+                SELECT 1 FROM {{table "der_metadata"}} AS d WHERE d.project_id = p.id AND d.type = @der_type))
+            AND (@min_capacity = 0 OR (
+                SELECT COALESCE(SUM(d.nameplate_capacity), 0)
+                FROM {{table "der_metadata"}} AS d
+                WHERE d.project_id = p.id AND (@der_type = '' OR d.type = @der_type)) >= @min_capacity)
+            AND p.id NOT IN (
+                SELECT pa.project_id FROM {{table "dr_event_participants"}} AS pa WHERE pa.event_id = @event_id)
+            AND EXISTS(
+                SELECT 1 FROM {{table "dr_events"}}
+                WHERE id = @event_id AND version = @version AND status IN ('draft', 'scheduled'));
+
+        SELECT @@row_count > 0 AS inserted,
+            (SELECT status FROM {{table "dr_events"}} WHERE id = @event_id) AS status,
+            (SELECT version FROM {{table "dr_events"}} WHERE id = @event_id) AS version;`
+
+	// the insert checks the event in the same statement, so it can't start or change in between. When nothing
+	// was inserted the event is read again to tell a refused insert from a target without new projects.
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to enroll projects", err)
+	}
+	var result struct {
+		Inserted bool                `bigquery:"inserted"`
+		Status   bigquery.NullString `bigquery:"status"`
+		Version  bigquery.NullInt64  `bigquery:"version"`
+	}
+	if err := it.Next(&result); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Error reading enrollment result", err)
+	}
+	if !result.Inserted {
+		if !result.Version.Valid {
+			return nil, custom_error.New(http.StatusNotFound, "demand response event id not found", bqclient.ErrNotFound)
+		}
+		current := &models.DREvents{Status: models.DREventStatus(result.Status.StringVal), Version: result.Version.Int64}
+		if err := CheckEnrollment(current, event.Version); err != nil {
+			return nil, err
+		}
+	}
+	return r.GetParticipants(ctx, event.ID)
+}
+
+func (r *drEventRepository) GetParticipants(ctx context.Context, eventID string) ([]models.DREventParticipant, error) {
+	participants, err := r.participants(ctx, []string{eventID})
+	if err != nil {
+		return nil, err
+	}
+	if participants[eventID] == nil {
+		return []models.DREventParticipant{}, nil
+	}
+	return participants[eventID], nil
+}
+
+func (r *drEventRepository) OptOut(ctx context.Context, eventID string, projectID string, at time.Time) (*models.DREventParticipant, error) {
+	params := []bigquery.QueryParameter{
+		{Name: "event_id", Value: eventID},
+		{Name: "project_id", Value: projectID},
+		{Name: "opted_out_at", Value: at},
+	}
+	// the script returns the result of its last statement, whether the update matched and whether the row exists
+	query := `
+        UPDATE {{table "dr_event_participants"}}
+        SET status = 'opted_out', opted_out_at = @opted_out_at
+        WHERE event_id = @event_id AND project_id = @project_id AND status = 'enrolled';
+
+        SELECT @@row_count > 0 AS updated, EXISTS(
+            SELECT 1 FROM {{table "dr_event_participants"}} WHERE event_id = @event_id AND project_id = @project_id
+        ) AS found;`
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to opt out", err)
+	}
+	var result struct {
+		Updated bool `bigquery:"updated"`
+		Found   bool `bigquery:"found"`
+	}
+	if err := it.Next(&result); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Error reading opt out result", err)
+	}
+	switch {
+	case !result.Found:
+		return nil, NotParticipant()
+	case !result.Updated:
+		return nil, AlreadyOptedOut()
+	}
+
+	participants, err := r.GetParticipants(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range participants {
+		if p.ProjectID == projectID {
+			return &p, nil
+		}
+	}
+	return nil, NotParticipant()
+}
+
+func (r *drEventRepository) RecordVENResponse(ctx context.Context, eventID string, projectID string, opt models.VENOpt, at time.Time) error {
+	params := []bigquery.QueryParameter{
+		{Name: "event_id", Value: eventID},
+		{Name: "project_id", Value: projectID},
+		{Name: "ven_opt", Value: string(opt)},
+		{Name: "ven_responded_at", Value: at},
+	}
+	query := `
+        UPDATE {{table "dr_event_participants"}}
+        SET ven_opt = @ven_opt, ven_responded_at = @ven_responded_at
+        WHERE event_id = @event_id AND project_id = @project_id;
+
+        SELECT @@row_count > 0 AS updated;`
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to record ven response", err)
+	}
+	var result struct {
+		Updated bool `bigquery:"updated"`
+	}
+	if err := it.Next(&result); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Error reading ven response result", err)
+	}
+	if !result.Updated {
+		return NotParticipant()
+	}
+	return nil
+}
+
+// participants loads the participants of the events by event id, in project order
+func (r *drEventRepository) participants(ctx context.Context, eventIDs []string) (map[string][]models.DREventParticipant, error) {
+	byEvent := map[string][]models.DREventParticipant{}
+	if len(eventIDs) == 0 {
+		return byEvent, nil
+	}
+	query := `
+        SELECT event_id, project_id, status, enrolled_at, opted_out_at, ven_opt, ven_responded_at
+        FROM {{table "dr_event_participants"}}
+        WHERE event_id IN UNNEST(@event_ids)
+        ORDER BY event_id, project_id`
+
+	it, err := r.client.Query(ctx, query, []bigquery.QueryParameter{{Name: "event_ids", Value: eventIDs}})
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to list demand response event participants", err)
+	}
+	for {
+		var p models.DREventParticipant
+		err := it.Next(&p)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, custom_error.New(http.StatusInternalServerError, "Error reading demand response event participants", err)
+		}
+		byEvent[p.EventID] = append(byEvent[p.EventID], p)
+	}
+	return byEvent, nil
+}
@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteDREventScript(t *testing.T) {
+	tables, err := NewTableResolver("gridstream_operations", nil)
+	require.NoError(t, err)
+	client := &fakeBigQuery{err: errConcurrentUpdate}
+	repo := NewDREventRepository(client, tables, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err = repo.DeleteDREvent(context.Background(), "e-1", 3)
+
+	// the participants and the event go in one transaction, participants first
+	require.Len(t, client.queries, 1)
+	script := client.queries[0]
+	participants := strings.Index(script, "DELETE FROM `gridstream_operations.dr_event_participants` WHERE event_id = @id")
+	event := strings.Index(script, "DELETE FROM `gridstream_operations.dr_events` WHERE id = @id AND version = @version")
+	require.NotEqual(t, -1, participants)
+	require.NotEqual(t, -1, event)
+	assert.Less(t, strings.Index(script, "BEGIN TRANSACTION"), participants)
+	assert.Less(t, participants, event)
+	assert.Less(t, event, strings.Index(script, "COMMIT TRANSACTION"))
+
+	// losing to a concurrent change is answered like a stale version
+	var customErr *custom_error.CustomError
+	require.ErrorAs(t, err, &customErr)
+	assert.Equal(t, http.StatusPreconditionFailed, customErr.Code)
+}
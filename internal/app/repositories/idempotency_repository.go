@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/pkg/errors"
+)
+
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey stores rec as in progress and returns nil, unless a record with the same scope and key
+	// that hasn't expired exists, which is returned instead. Expired records are replaced.
+	ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response of the request that reserved the key
+	CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error
+	// ReleaseIdempotencyKey drops a reservation that wasn't completed, so the key can be used again
+	ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error
+}
+
+type idempotencyRepository struct {
+	client *tableClient
+	log    *slog.Logger
+}
+
+func NewIdempotencyRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) IdempotencyRepository {
+	return &idempotencyRepository{client: newTableClient(client, tables), log: log}
+}
+
+// ReserveIdempotencyKey retries once when the MERGE lost to a concurrent one, which usually reserved the same key.
+// The retry sees that reservation. When it loses again the key is answered as still being processed.
+func (r *idempotencyRepository) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	existing, err := r.reserve(ctx, rec)
+	if isConcurrentUpdate(err) {
+		existing, err = r.reserve(ctx, rec)
+	}
+	if isConcurrentUpdate(err) {
+		return nil, custom_error.New(http.StatusConflict, "A request with this Idempotency-Key is still being processed", err).
+			WithCode(custom_error.CodeIdempotencyBusy)
+	}
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to reserve idempotency key", err)
+	}
+	return existing, nil
+}
+
+// isConcurrentUpdate reports whether BigQuery aborted a DML statement because another one changed the table first
+func isConcurrentUpdate(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "concurrent update")
+}
+
+func (r *idempotencyRepository) reserve(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	// the MERGE only writes when the key is new or expired, the SELECT after it returns the row either way
+	// and whether the MERGE wrote it
+	query := `
+        MERGE {{table "idempotency_keys"}} t
+        USING (SELECT @scope AS scope, @idempotency_key AS idempotency_key) s
+        ON t.scope = s.scope AND t.idempotency_key = s.idempotency_key
+        WHEN MATCHED AND t.expires_at <= @created_at THEN
+            UPDATE SET request_hash = @request_hash, status = 0, content_type = '', location = '', body = '',
+                resource_id = '', created_at = @created_at, expires_at = @expires_at
+        WHEN NOT MATCHED THEN
+            INSERT (scope, idempotency_key, request_hash, status, content_type, location, body, resource_id, created_at, expires_at)
+            VALUES (@scope, @idempotency_key, @request_hash, 0, '', '', '', '', @created_at, @expires_at);
+
+        SELECT *, @@row_count > 0 AS reserved
+        FROM {{table "idempotency_keys"}}
+        WHERE scope = @scope AND idempotency_key = @idempotency_key;`
+
+	params := []bigquery.QueryParameter{
+		{Name: "scope", Value: rec.Scope},
+		{Name: "idempotency_key", Value: rec.Key},
+		{Name: "request_hash", Value: rec.RequestHash},
+		{Name: "created_at", Value: rec.CreatedAt},
+		{Name: "expires_at", Value: rec.ExpiresAt},
+	}
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	var row struct {
+		models.IdempotencyRecord
+		Reserved bool `bigquery:"reserved"`
+	}
+	if err := it.Next(&row); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if row.Reserved {
+		return nil, nil
+	}
+	return &row.IdempotencyRecord, nil
+}
+
+func (r *idempotencyRepository) CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	query := `
+        UPDATE {{table "idempotency_keys"}}
+        SET status = @status, content_type = @content_type, location = @location, body = @body, resource_id = @resource_id
+        WHERE scope = @scope AND idempotency_key = @idempotency_key AND request_hash = @request_hash`
+
+	params := []bigquery.QueryParameter{
+		{Name: "status", Value: rec.Status},
+		{Name: "content_type", Value: rec.ContentType},
+		{Name: "location", Value: rec.Location},
+		{Name: "body", Value: rec.Body},
+		{Name: "resource_id", Value: rec.ResourceID},
+		{Name: "scope", Value: rec.Scope},
+		{Name: "idempotency_key", Value: rec.Key},
+		{Name: "request_hash", Value: rec.RequestHash},
+	}
+	if err := r.client.Exec(ctx, query, params); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to store idempotent response", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	query := `
+        DELETE FROM {{table "idempotency_keys"}}
+        WHERE scope = @scope AND idempotency_key = @idempotency_key AND status = 0`
+
+	params := []bigquery.QueryParameter{
+		{Name: "scope", Value: scope},
+		{Name: "idempotency_key", Value: key},
+	}
+	if err := r.client.Exec(ctx, query, params); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to release idempotency key", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errConcurrentUpdate is how BigQuery aborts DML that raced another statement on the same table
+var errConcurrentUpdate = errors.New("googleapi: Error 400: Transaction is aborted due to concurrent update")
+
+// fakeBigQuery records the queries it is sent and fails them all with err
+type fakeBigQuery struct {
+	bqclient.BQClient
+	err     error
+	queries []string
+}
+
+func (f *fakeBigQuery) Query(ctx context.Context, query string, params []bigquery.QueryParameter) (*bigquery.RowIterator, error) {
+	f.queries = append(f.queries, query)
+	return nil, f.err
+}
+
+func TestReserveIdempotencyKeyConcurrentUpdate(t *testing.T) {
+	tables, err := NewTableResolver("gridstream_operations", nil)
+	require.NoError(t, err)
+	client := &fakeBigQuery{err: errConcurrentUpdate}
+	repo := NewIdempotencyRepository(client, tables, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	_, err = repo.ReserveIdempotencyKey(context.Background(), &models.IdempotencyRecord{Key: "k-1"})
+
+	assert.Len(t, client.queries, 2, "the reservation is retried once")
+	var customErr *custom_error.CustomError
+	require.True(t, errors.As(err, &customErr))
+	assert.Equal(t, http.StatusConflict, customErr.Code)
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type apiKeyRepository struct {
+	db *db
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.apiKeys = append(r.db.apiKeys, *key)
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return r.find(func(k *models.APIKey) bool { return k.ID == id })
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.find(func(k *models.APIKey) bool { return k.KeyHash == hash })
+}
+
+func (r *apiKeyRepository) find(match func(k *models.APIKey) bool) (*models.APIKey, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for i := range r.db.apiKeys {
+		if match(&r.db.apiKeys[i]) {
+			key := r.db.apiKeys[i]
+			return &key, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "api key not found", errNotFound)
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	// newest first, like ORDER BY created_at DESC
+	keys := make([]models.APIKey, 0, len(r.db.apiKeys))
+	for i := len(r.db.apiKeys) - 1; i >= 0; i-- {
+		keys = append(keys, r.db.apiKeys[i])
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) RotateAPIKey(ctx context.Context, id string, prefix string, hash string, expiresAt time.Time) error {
+	return r.update(id, func(k *models.APIKey) {
+		k.Prefix = prefix
+		k.KeyHash = hash
+		k.ExpiresAt = expiresAt
+	})
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) { k.RevokedAt = bigquery.NullTimestamp{Timestamp: at, Valid: true} })
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) { k.LastUsedAt = bigquery.NullTimestamp{Timestamp: at, Valid: true} })
+}
+
+func (r *apiKeyRepository) update(id string, apply func(k *models.APIKey)) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.apiKeys {
+		if r.db.apiKeys[i].ID == id {
+			apply(&r.db.apiKeys[i])
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "api key not found", errNotFound)
+}
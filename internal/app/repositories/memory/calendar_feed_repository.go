@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type calendarFeedRepository struct {
+	db *db
+}
+
+func (r *calendarFeedRepository) GetFeedGeneration(ctx context.Context, userID string) (int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, f := range r.db.calendarFeeds {
+		if f.UserID == userID {
+			return f.Generation, nil
+		}
+	}
+	return 0, nil
+}
+
+func (r *calendarFeedRepository) RevokeFeedTokens(ctx context.Context, userID string, at time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.calendarFeeds {
+		if r.db.calendarFeeds[i].UserID == userID {
+			r.db.calendarFeeds[i].Generation++
+			r.db.calendarFeeds[i].RevokedAt = at
+			return r.db.calendarFeeds[i].Generation, nil
+		}
+	}
+	r.db.calendarFeeds = append(r.db.calendarFeeds, models.CalendarFeed{UserID: userID, Generation: 1, RevokedAt: at})
+	return 1, nil
+}
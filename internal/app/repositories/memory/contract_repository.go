@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type contractRepository struct {
+	db *db
+}
+
+func (r *contractRepository) CreateContract(ctx context.Context, data *models.Contract) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// same as the INSERT ... SELECT FROM projects, nothing is inserted for an unknown project
+	if !r.db.projectExists(data.ProjectID) {
+		return custom_error.New(http.StatusBadRequest, fmt.Sprintf("Failed to insert, please make sure your project id was correct: %s", data.ProjectID), errors.New("Failed to insert, please make sure your project id was correct"))
+	}
+
+	data.Version = 1
+	r.db.contracts = append(r.db.contracts, *data)
+	return nil
+}
+
+func (r *contractRepository) GetContract(ctx context.Context, id string) (*models.Contract, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, c := range r.db.contracts {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "Contract not found", errNotFound)
+}
+
+func (r *contractRepository) UpdateContract(ctx context.Context, id string, data *models.Contract, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", errors.New("No fields to update"))
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.contracts {
+		if r.db.contracts[i].ID == id {
+			return updateVersion(&r.db.contracts[i], data, updates)
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Contract not found", errNotFound)
+}
+
+func (r *contractRepository) DeleteContract(ctx context.Context, id string, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, c := range r.db.contracts {
+		if c.ID == id {
+			if c.Version != version {
+				return repositories.VersionConflict(repositories.ErrStale)
+			}
+			r.db.contracts = append(r.db.contracts[:i], r.db.contracts[i+1:]...)
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "contract id not found", errNotFound)
+}
+
+func (r *contractRepository) GetContractsByProjectID(ctx context.Context, id string, filter repositories.ContractFilter, page pagination.Params) (pagination.Page[models.Contract], error) {
+	keyset, err := repositories.ContractSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Contract]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	contracts := []models.Contract{}
+	for _, c := range r.db.contracts {
+		if c.ProjectID == id && (filter.Status == "" || c.Status == filter.Status) {
+			contracts = append(contracts, c)
+		}
+	}
+	return pagination.Slice(keyset, contracts), nil
+}
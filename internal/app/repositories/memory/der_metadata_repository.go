@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type derMetadataRepository struct {
+	db *db
+}
+
+func (r *derMetadataRepository) CreateDERMetadata(ctx context.Context, data *models.DERMetadata) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	data.Version = 1
+	r.db.derMetadata = append(r.db.derMetadata, *data)
+	return nil
+}
+
+func (r *derMetadataRepository) BatchCreateDERMetadata(ctx context.Context, data []models.DERMetadata) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range data {
+		data[i].Version = 1
+	}
+	r.db.derMetadata = append(r.db.derMetadata, data...)
+	return nil
+}
+
+func (r *derMetadataRepository) GetDERMetadata(ctx context.Context, id string) (*models.DERMetadata, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, der := range r.db.derMetadata {
+		if der.ID == id {
+			return &der, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "der not found", errNotFound)
+}
+
+func (r *derMetadataRepository) ListDERMetadataByProject(ctx context.Context, id string, filter repositories.DERMetadataFilter, page pagination.Params) (pagination.Page[models.DERMetadata], error) {
+	keyset, err := repositories.DERMetadataSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DERMetadata]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	derMetadata := []models.DERMetadata{}
+	for _, der := range r.db.derMetadata {
+		if der.ProjectID == id && (filter.Type == "" || der.Type == filter.Type) {
+			derMetadata = append(derMetadata, der)
+		}
+	}
+	return pagination.Slice(keyset, derMetadata), nil
+}
+
+func (r *derMetadataRepository) UpdateDERMetadata(ctx context.Context, id string, data *models.DERMetadata, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.derMetadata {
+		if r.db.derMetadata[i].ID == id {
+			return updateVersion(&r.db.derMetadata[i], data, updates)
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "der not found", errNotFound)
+}
+
+func (r *derMetadataRepository) DeleteDERMetadata(ctx context.Context, id string, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, der := range r.db.derMetadata {
+		if der.ID == id {
+			if der.Version != version {
+				return repositories.VersionConflict(repositories.ErrStale)
+			}
+			r.db.derMetadata = append(r.db.derMetadata[:i], r.db.derMetadata[i+1:]...)
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "der id not found", errNotFound)
+}
@@ -0,0 +1,311 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type drEventRepository struct {
+	db *db
+}
+
+func (r *drEventRepository) CreateDREvent(ctx context.Context, data *models.DREvents) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// same as the INSERT ... SELECT FROM utilities, nothing is inserted for an unknown utility
+	if _, ok := r.db.utility(data.UtilityID); !ok {
+		return custom_error.New(http.StatusBadRequest, fmt.Sprintf("Failed to insert, please make sure your utility id is correct: %s", data.UtilityID), nil)
+	}
+
+	data.Version = 1
+	// utility_name is only ever filled by the joins on read, it isn't a column
+	event := *data
+	event.UtilityName = ""
+	r.db.drEvents = append(r.db.drEvents, event)
+	return nil
+}
+
+func (r *drEventRepository) GetDREvent(ctx context.Context, id string) (*models.DREvents, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, event := range r.db.drEvents {
+		if event.ID == id {
+			return &event, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "demand response event not found", errNotFound)
+}
+
+func (r *drEventRepository) UpdateDREvent(ctx context.Context, id string, data *models.DREvents, fields []string) error {
+	if err := data.CheckEditable(); err != nil {
+		return err
+	}
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", errors.New("No fields to update"))
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.drEvents {
+		if r.db.drEvents[i].ID == id {
+			return updateVersion(&r.db.drEvents[i], data, updates)
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "demand response event not found", errNotFound)
+}
+
+func (r *drEventRepository) TransitionDREvent(ctx context.Context, id string, data *models.DREvents, action models.DREventAction, at time.Time) error {
+	fields, err := data.Transition(action, at)
+	if err != nil {
+		return err
+	}
+	updates := logic.ExtractFields(data, fields)
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.drEvents {
+		if r.db.drEvents[i].ID == id {
+			return updateVersion(&r.db.drEvents[i], data, updates)
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "demand response event not found", errNotFound)
+}
+
+func (r *drEventRepository) DeleteDREvent(ctx context.Context, id string, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, event := range r.db.drEvents {
+		if event.ID == id {
+			if event.Version != version {
+				return repositories.VersionConflict(repositories.ErrStale)
+			}
+			r.db.drEvents = append(r.db.drEvents[:i], r.db.drEvents[i+1:]...)
+			r.db.removeParticipants(func(pa models.DREventParticipant) bool { return pa.EventID == id })
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "demand response event id not found", errNotFound)
+}
+
+func (r *drEventRepository) GetDREventsByProjectID(ctx context.Context, id string, filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	keyset, err := repositories.DREventSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DREvents]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	// the events the project participates in, joined with their utility like eventsForUtility
+	drEvents := []models.DREvents{}
+	for _, pa := range r.db.participants {
+		if pa.ProjectID != id {
+			continue
+		}
+		for _, event := range r.db.drEvents {
+			util, ok := r.db.utility(event.UtilityID)
+			if event.ID == pa.EventID && ok && r.db.matches(event, filter) {
+				event.UtilityName = util.DisplayName
+				event.Participation = pa.Status
+				drEvents = append(drEvents, event)
+			}
+		}
+	}
+	return pagination.Slice(keyset, drEvents), nil
+}
+
+func (r *drEventRepository) GetDREventsByUtilityID(ctx context.Context, id string, filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	keyset, err := repositories.DREventSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DREvents]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	events := pagination.Slice(keyset, r.db.eventsForUtility(id, filter))
+	for i := range events.Data {
+		events.Data[i].Participants = r.db.eventParticipants(events.Data[i].ID)
+	}
+	return events, nil
+}
+
+// eventsForUtility joins dr_events with utilities to fill in utility_name, events of an
+// unknown utility are dropped just like the inner join does
+func (d *db) eventsForUtility(utilityID string, filter repositories.DREventFilter) []models.DREvents {
+	events := []models.DREvents{}
+	util, ok := d.utility(utilityID)
+	if !ok {
+		return events
+	}
+	for _, event := range d.drEvents {
+		if event.UtilityID == utilityID && d.matches(event, filter) {
+			event.UtilityName = util.DisplayName
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// matches is the filter of the list queries
+func (d *db) matches(event models.DREvents, filter repositories.DREventFilter) bool {
+	if !overlaps(event.StartTime, event.EndTime, filter.From, filter.To) {
+		return false
+	}
+	if filter.Status != "" && event.Status != filter.Status {
+		return false
+	}
+	if filter.DERType == "" {
+		return true
+	}
+	return slices.ContainsFunc(d.participants, func(pa models.DREventParticipant) bool {
+		return pa.EventID == event.ID && slices.ContainsFunc(d.derMetadata, func(der models.DERMetadata) bool {
+			return der.ProjectID == pa.ProjectID && der.Type == filter.DERType
+		})
+	})
+}
+
+// overlaps is the time range filter of the list queries, end_time > from AND start_time < to with open zero bounds
+func overlaps(start, end, from, to time.Time) bool {
+	return (from.IsZero() || end.After(from)) && (to.IsZero() || start.Before(to))
+}
+
+func (r *drEventRepository) EnrollProjects(ctx context.Context, event *models.DREvents, target models.ParticipantTarget, at time.Time) ([]models.DREventParticipant, error) {
+	// participants are fixed once the event has started
+	if err := event.CheckEditable(); err != nil {
+		return nil, err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := slices.IndexFunc(r.db.drEvents, func(e models.DREvents) bool { return e.ID == event.ID })
+	if i < 0 {
+		return nil, custom_error.New(http.StatusNotFound, "demand response event id not found", errNotFound)
+	}
+	if err := repositories.CheckEnrollment(&r.db.drEvents[i], event.Version); err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+	for _, id := range target.ProjectIDs {
+		if !slices.ContainsFunc(r.db.projects, func(p models.Project) bool { return p.ID == id && p.UtilityID == event.UtilityID }) {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, repositories.UnknownProjects(unknown)
+	}
+
+	for _, p := range r.db.projects {
+		if p.UtilityID != event.UtilityID || !r.db.selects(&target, p.ID) {
+			continue
+		}
+		if slices.ContainsFunc(r.db.participants, func(pa models.DREventParticipant) bool { return pa.EventID == event.ID && pa.ProjectID == p.ID }) {
+			continue
+		}
+		r.db.participants = append(r.db.participants, models.DREventParticipant{
+			EventID: event.ID, ProjectID: p.ID, Status: models.ParticipantEnrolled, EnrolledAt: at,
+		})
+	}
+	return r.db.eventParticipants(event.ID), nil
+}
+
+func (r *drEventRepository) GetParticipants(ctx context.Context, eventID string) ([]models.DREventParticipant, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	return r.db.eventParticipants(eventID), nil
+}
+
+func (r *drEventRepository) OptOut(ctx context.Context, eventID string, projectID string, at time.Time) (*models.DREventParticipant, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.participants {
+		pa := &r.db.participants[i]
+		if pa.EventID != eventID || pa.ProjectID != projectID {
+			continue
+		}
+		if pa.Status != models.ParticipantEnrolled {
+			return nil, repositories.AlreadyOptedOut()
+		}
+		pa.Status = models.ParticipantOptedOut
+		pa.OptedOutAt = bigquery.NullTimestamp{Timestamp: at, Valid: true}
+		participant := *pa
+		return &participant, nil
+	}
+	return nil, repositories.NotParticipant()
+}
+
+func (r *drEventRepository) RecordVENResponse(ctx context.Context, eventID string, projectID string, opt models.VENOpt, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.participants {
+		pa := &r.db.participants[i]
+		if pa.EventID == eventID && pa.ProjectID == projectID {
+			pa.VENOpt = opt
+			pa.VENRespondedAt = bigquery.NullTimestamp{Timestamp: at, Valid: true}
+			return nil
+		}
+	}
+	return repositories.NotParticipant()
+}
+
+// selects is the WHERE clause of the enroll queries for a project of the event's utility
+func (d *db) selects(target *models.ParticipantTarget, projectID string) bool {
+	if len(target.ProjectIDs) > 0 {
+		return slices.Contains(target.ProjectIDs, projectID)
+	}
+	if target.ActiveContract && !slices.ContainsFunc(d.contracts, func(c models.Contract) bool {
+		return c.ProjectID == projectID && c.Status == models.Active
+	}) {
+		return false
+	}
+	hasType := target.DERType == ""
+	capacity := 0.0
+	for _, der := range d.derMetadata {
+		if der.ProjectID == projectID && (target.DERType == "" || der.Type == target.DERType) {
+			hasType = true
+			capacity += der.NameplateCapacity
+		}
+	}
+	return hasType && capacity >= target.MinCapacity
+}
+
+// eventParticipants are the participants of an event in project order, like the ORDER BY of the queries
+func (d *db) eventParticipants(eventID string) []models.DREventParticipant {
+	participants := []models.DREventParticipant{}
+	for _, pa := range d.participants {
+		if pa.EventID == eventID {
+			participants = append(participants, pa)
+		}
+	}
+	slices.SortFunc(participants, func(a, b models.DREventParticipant) int { return strings.Compare(a.ProjectID, b.ProjectID) })
+	return participants
+}
+
+// removeParticipants deletes the participants matching remove, the cascade of the postgres foreign keys
+func (d *db) removeParticipants(remove func(models.DREventParticipant) bool) {
+	d.participants = slices.DeleteFunc(d.participants, remove)
+}
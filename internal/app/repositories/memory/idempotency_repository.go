@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type idempotencyRepository struct {
+	db *db
+}
+
+func (r *idempotencyRepository) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// expired records are dropped here, nothing else would ever remove them
+	live := r.db.idempotency[:0]
+	var existing *models.IdempotencyRecord
+	for _, stored := range r.db.idempotency {
+		if !stored.ExpiresAt.After(rec.CreatedAt) {
+			continue
+		}
+		live = append(live, stored)
+		if stored.Scope == rec.Scope && stored.Key == rec.Key {
+			found := stored
+			existing = &found
+		}
+	}
+	r.db.idempotency = live
+	if existing != nil {
+		return existing, nil
+	}
+
+	r.db.idempotency = append(r.db.idempotency, *rec)
+	return nil, nil
+}
+
+func (r *idempotencyRepository) CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, stored := range r.db.idempotency {
+		if stored.Scope == rec.Scope && stored.Key == rec.Key && stored.RequestHash == rec.RequestHash {
+			r.db.idempotency[i] = *rec
+		}
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, stored := range r.db.idempotency {
+		if stored.Scope == scope && stored.Key == key && !stored.Completed() {
+			r.db.idempotency = append(r.db.idempotency[:i], r.db.idempotency[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
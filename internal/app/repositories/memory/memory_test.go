@@ -0,0 +1,374 @@
+package memory_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/repositories/memory"
+	"github.com/grid-stream-org/api/internal/app/repositories/repotest"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) bigquery.NullDate {
+	return bigquery.NullDate{Valid: true, Date: civil.Date{Year: year, Month: month, Day: day}}
+}
+
+func assertCode(t *testing.T, code int, err error) {
+	t.Helper()
+	customErr, ok := err.(*custom_error.CustomError)
+	require.True(t, ok, "expected custom error, got: %v", err)
+	assert.Equal(t, code, customErr.Code)
+}
+
+func TestContractsRequireProject(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+
+	err := store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "missing"})
+	assertCode(t, http.StatusBadRequest, err)
+
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: "u-1"}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "p-1", StartDate: date(2024, 1, 1)}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-2", ProjectID: "p-1", StartDate: date(2025, 1, 1)}))
+
+	contracts, err := store.Contracts.GetContractsByProjectID(ctx, "p-1", repositories.ContractFilter{}, pagination.Params{})
+	require.NoError(t, err)
+	require.Len(t, contracts.Data, 2)
+	assert.Equal(t, "c-2", contracts.Data[0].ID, "contracts should be ordered by start date descending")
+
+	update := &models.Contract{Status: models.Inactive, ContractThreshold: 12.5, Version: 1}
+	require.NoError(t, store.Contracts.UpdateContract(ctx, "c-1", update, []string{"status", "contract_threshold"}))
+	assert.Equal(t, int64(2), update.Version, "the update should return the new version")
+	contract, err := store.Contracts.GetContract(ctx, "c-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.Inactive, contract.Status)
+	assert.Equal(t, 12.5, contract.ContractThreshold)
+	assert.Equal(t, int64(2), contract.Version)
+	assert.Equal(t, "p-1", contract.ProjectID, "fields missing from the update should be untouched")
+
+	// an update made from the version before is stale
+	err = store.Contracts.UpdateContract(ctx, "c-1", &models.Contract{Status: models.Active, Version: 1}, []string{"status"})
+	assertCode(t, http.StatusPreconditionFailed, err)
+	err = store.Contracts.UpdateContract(ctx, "missing", &models.Contract{Status: models.Active, Version: 1}, []string{"status"})
+	assertCode(t, http.StatusNotFound, err)
+
+	require.NoError(t, store.Contracts.DeleteContract(ctx, "c-1", 2))
+	_, err = store.Contracts.GetContract(ctx, "c-1")
+	assertCode(t, http.StatusNotFound, err)
+}
+
+func TestDREventsJoinUtility(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	now := time.Now()
+
+	err := store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "e-1", UtilityID: "missing"})
+	assertCode(t, http.StatusBadRequest, err)
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NotEmpty(t, util.ID)
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "past", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour)}))
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "future", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}))
+	for _, id := range []string{"past", "future"} {
+		_, err := store.DREvents.EnrollProjects(ctx, &models.DREvents{ID: id, UtilityID: util.ID, Status: models.DREventScheduled, Version: 1}, models.ParticipantTarget{ProjectIDs: []string{"p-1"}}, now)
+		require.NoError(t, err)
+	}
+
+	events, err := store.DREvents.GetDREventsByProjectID(ctx, "p-1", repositories.DREventFilter{}, pagination.Params{})
+	require.NoError(t, err)
+	require.Len(t, events.Data, 2)
+	assert.Equal(t, "future", events.Data[0].ID, "events should be ordered by start time descending")
+	assert.Equal(t, "NB Power", events.Data[0].UtilityName)
+
+	events, err = store.DREvents.GetDREventsByUtilityID(ctx, util.ID, repositories.DREventFilter{From: now}, pagination.Params{})
+	require.NoError(t, err)
+	require.Len(t, events.Data, 1)
+	assert.Equal(t, "future", events.Data[0].ID)
+
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "p-1", Status: models.Active, ContractThreshold: 10}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-2", ProjectID: "p-1", Status: models.Pending, ContractThreshold: 5}))
+
+	summaries, err := store.Utilities.GetProjectSummary(ctx, util.ID)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, 1, summaries[0].TotalActive)
+	assert.Equal(t, 1, summaries[0].TotalPending)
+	assert.Equal(t, 15.0, summaries[0].TotalThreshold)
+	assert.Equal(t, "future", summaries[0].NextEventID)
+	assert.Equal(t, "past", summaries[0].RecentEventID)
+}
+
+func TestProjectAveragesDateRange(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	err := store.ProjectAverages.CreateProjectAverage(ctx, &models.ProjectAverage{ProjectID: "missing", StartTime: start, EndTime: start.Add(time.Minute)})
+	assertCode(t, http.StatusBadRequest, err)
+
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1"}))
+	for i := 0; i < 4; i++ {
+		s := start.Add(time.Duration(i) * 15 * time.Minute)
+		require.NoError(t, store.ProjectAverages.CreateProjectAverage(ctx, &models.ProjectAverage{ProjectID: "p-1", StartTime: s, EndTime: s.Add(15 * time.Minute)}))
+	}
+
+	all, err := store.ProjectAverages.GetProjectAveragesByProjectID(ctx, "p-1", repositories.ProjectAverageFilter{}, pagination.Params{})
+	require.NoError(t, err)
+	require.Len(t, all.Data, 4)
+	assert.True(t, all.Data[0].StartTime.After(all.Data[3].StartTime), "averages should be ordered by start time descending")
+
+	filter := repositories.ProjectAverageFilter{From: start.Add(15 * time.Minute), To: start.Add(45 * time.Minute)}
+	ranged, err := store.ProjectAverages.GetProjectAveragesByProjectID(ctx, "p-1", filter, pagination.Params{Sort: "start_time"})
+	require.NoError(t, err)
+	require.Len(t, ranged.Data, 2)
+	assert.Equal(t, start.Add(15*time.Minute), ranged.Data[0].StartTime, "ranged averages should be ordered by start time ascending")
+
+	// off the interval boundaries a range overlaps the averages at its ends but only holds the ones in between
+	filter = repositories.ProjectAverageFilter{From: start.Add(10 * time.Minute), To: start.Add(50 * time.Minute)}
+	ranged, err = store.ProjectAverages.GetProjectAveragesByProjectID(ctx, "p-1", filter, pagination.Params{})
+	require.NoError(t, err)
+	assert.Len(t, ranged.Data, 4)
+	filter.Within = true
+	ranged, err = store.ProjectAverages.GetProjectAveragesByProjectID(ctx, "p-1", filter, pagination.Params{})
+	require.NoError(t, err)
+	assert.Len(t, ranged.Data, 2)
+}
+
+func TestPagination(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1"}))
+	for i, id := range []string{"c-1", "c-2", "c-3", "c-4", "c-5"} {
+		// c-2 and c-3 share a start date and are ordered by id, c-5 has none and sorts last
+		status, start := models.Active, date(2024, 1, 1+i)
+		switch id {
+		case "c-3":
+			status, start = models.Inactive, date(2024, 1, 2)
+		case "c-5":
+			start = bigquery.NullDate{}
+		}
+		require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: id, ProjectID: "p-1", Status: status, StartDate: start}))
+	}
+
+	var ids []string
+	page := pagination.Params{Limit: 2}
+	for {
+		contracts, err := store.Contracts.GetContractsByProjectID(ctx, "p-1", repositories.ContractFilter{}, page)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(contracts.Data), 2)
+		for _, c := range contracts.Data {
+			ids = append(ids, c.ID)
+		}
+		if contracts.NextCursor == "" {
+			break
+		}
+		page.Cursor = contracts.NextCursor
+	}
+	assert.Equal(t, []string{"c-4", "c-3", "c-2", "c-1", "c-5"}, ids)
+
+	inactive, err := store.Contracts.GetContractsByProjectID(ctx, "p-1", repositories.ContractFilter{Status: models.Inactive}, pagination.Params{})
+	require.NoError(t, err)
+	require.Len(t, inactive.Data, 1)
+	assert.Equal(t, "c-3", inactive.Data[0].ID)
+	assert.Empty(t, inactive.NextCursor)
+
+	// a cursor only continues the sort it was issued for
+	first, err := store.Contracts.GetContractsByProjectID(ctx, "p-1", repositories.ContractFilter{}, pagination.Params{Limit: 1})
+	require.NoError(t, err)
+	_, err = store.Contracts.GetContractsByProjectID(ctx, "p-1", repositories.ContractFilter{}, pagination.Params{Cursor: first.NextCursor, Sort: "id"})
+	assertCode(t, http.StatusBadRequest, err)
+	_, err = store.Contracts.GetContractsByProjectID(ctx, "p-1", repositories.ContractFilter{}, pagination.Params{Sort: "status"})
+	assertCode(t, http.StatusBadRequest, err)
+}
+
+func TestListProjects(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: "u-1", UserID: "home-1", Location: "Moncton, NB"}))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-2", UtilityID: "u-1", Location: "Saint John, NB"}))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-3", UtilityID: "u-2", UserID: "home-2", Location: "Halifax, NS"}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "p-1", Status: models.Active}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-2", ProjectID: "p-2", Status: models.Pending}))
+	require.NoError(t, store.DERMetadata.CreateDERMetadata(ctx, &models.DERMetadata{ID: "d-1", ProjectID: "p-3", Type: models.Battery}))
+
+	active, inactive := true, false
+	tests := []struct {
+		name   string
+		filter repositories.ProjectFilter
+		ids    []string
+	}{
+		{"everything", repositories.ProjectFilter{Scope: authz.Scope{All: true}}, []string{"p-1", "p-2", "p-3"}},
+		{"nothing", repositories.ProjectFilter{}, nil},
+		{"utility scope", repositories.ProjectFilter{Scope: authz.Scope{UtilityIDs: []string{"u-1"}}}, []string{"p-1", "p-2"}},
+		{"owner scope", repositories.ProjectFilter{Scope: authz.Scope{UserID: "home-2"}}, []string{"p-3"}},
+		{"search", repositories.ProjectFilter{Scope: authz.Scope{All: true}, Search: "nb"}, []string{"p-1", "p-2"}},
+		{"search in scope", repositories.ProjectFilter{Scope: authz.Scope{UserID: "home-2"}, Search: "nb"}, nil},
+		{"active contract", repositories.ProjectFilter{Scope: authz.Scope{All: true}, HasActiveContract: &active}, []string{"p-1"}},
+		{"no active contract", repositories.ProjectFilter{Scope: authz.Scope{All: true}, HasActiveContract: &inactive}, []string{"p-2", "p-3"}},
+		{"der type", repositories.ProjectFilter{Scope: authz.Scope{All: true}, DERType: models.Battery}, []string{"p-3"}},
+		{"utility and user", repositories.ProjectFilter{Scope: authz.Scope{All: true}, UtilityID: "u-1", UserID: "home-1"}, []string{"p-1"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := store.Projects.ListProjects(ctx, tc.filter, pagination.Params{})
+			require.NoError(t, err)
+			var ids []string
+			for _, p := range page.Data {
+				ids = append(ids, p.ID)
+			}
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+
+	page, err := store.Projects.ListProjects(ctx, repositories.ProjectFilter{Scope: authz.Scope{All: true}}, pagination.Params{Sort: "-location", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "p-2", page.Data[0].ID)
+	assert.NotEmpty(t, page.NextCursor)
+}
+
+func TestDREventLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	now := time.Now()
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "e-1", UtilityID: util.ID, Status: models.DREventDraft, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}))
+
+	event, err := store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	require.NoError(t, store.DREvents.TransitionDREvent(ctx, "e-1", event, models.DREventDispatch, now))
+	require.NoError(t, store.DREvents.TransitionDREvent(ctx, "e-1", event, models.DREventActivate, now))
+
+	event, err = store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.DREventActive, event.Status)
+	assert.Equal(t, int64(3), event.Version)
+	assert.True(t, event.ScheduledAt.Valid)
+	assert.True(t, event.ActivatedAt.Valid)
+
+	// the timing of an active event is fixed, and it can't go back to scheduled
+	event.EndTime = now.Add(3 * time.Hour)
+	assertCode(t, http.StatusConflict, store.DREvents.UpdateDREvent(ctx, "e-1", event, []string{"end_time"}))
+	assertCode(t, http.StatusConflict, store.DREvents.TransitionDREvent(ctx, "e-1", event, models.DREventDispatch, now))
+
+	// a transition made from a stale read loses against the one made first
+	stale, err := store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	require.NoError(t, store.DREvents.TransitionDREvent(ctx, "e-1", event, models.DREventComplete, now))
+	assertCode(t, http.StatusPreconditionFailed, store.DREvents.TransitionDREvent(ctx, "e-1", stale, models.DREventCancel, now))
+
+	event, err = store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.DREventCompleted, event.Status)
+	assert.False(t, event.CancelledAt.Valid)
+}
+
+func TestEnrollProjects(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(nil)
+	now := time.Now()
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	other := &models.Utility{DisplayName: "Nova Scotia Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Utilities.CreateUtility(ctx, other))
+	for _, p := range []models.Project{{ID: "p-1", UtilityID: util.ID}, {ID: "p-2", UtilityID: util.ID}, {ID: "p-3", UtilityID: util.ID}, {ID: "p-4", UtilityID: other.ID}} {
+		require.NoError(t, store.Projects.CreateProject(ctx, &p))
+	}
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "p-1", Status: models.Active}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-2", ProjectID: "p-2", Status: models.Pending}))
+	require.NoError(t, store.DERMetadata.CreateDERMetadata(ctx, &models.DERMetadata{ID: "d-1", ProjectID: "p-2", Type: models.Battery, NameplateCapacity: 5}))
+	require.NoError(t, store.DERMetadata.CreateDERMetadata(ctx, &models.DERMetadata{ID: "d-2", ProjectID: "p-3", Type: models.Battery, NameplateCapacity: 20}))
+
+	enrolled := func(target models.ParticipantTarget) []string {
+		t.Helper()
+		event := &models.DREvents{ID: uuid.NewString(), UtilityID: util.ID, Status: models.DREventDraft, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+		require.NoError(t, store.DREvents.CreateDREvent(ctx, event))
+		participants, err := store.DREvents.EnrollProjects(ctx, event, target, now)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, p := range participants {
+			ids = append(ids, p.ProjectID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"p-1", "p-3"}, enrolled(models.ParticipantTarget{ProjectIDs: []string{"p-3", "p-1"}}))
+	assert.Equal(t, []string{"p-1"}, enrolled(models.ParticipantTarget{ActiveContract: true}))
+	assert.Equal(t, []string{"p-2", "p-3"}, enrolled(models.ParticipantTarget{DERType: models.Battery}))
+	assert.Equal(t, []string{"p-3"}, enrolled(models.ParticipantTarget{DERType: models.Battery, MinCapacity: 10}))
+
+	event := &models.DREvents{ID: "e-1", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, event))
+	_, err := store.DREvents.EnrollProjects(ctx, event, models.ParticipantTarget{ProjectIDs: []string{"p-1", "p-4"}}, now)
+	assertCode(t, http.StatusBadRequest, err)
+
+	// enrolling again keeps the opt out
+	_, err = store.DREvents.EnrollProjects(ctx, event, models.ParticipantTarget{ProjectIDs: []string{"p-1"}}, now)
+	require.NoError(t, err)
+	_, err = store.DREvents.OptOut(ctx, "e-1", "p-1", now)
+	require.NoError(t, err)
+	_, err = store.DREvents.OptOut(ctx, "e-1", "p-1", now)
+	assertCode(t, http.StatusConflict, err)
+	_, err = store.DREvents.OptOut(ctx, "e-1", "p-2", now)
+	assertCode(t, http.StatusNotFound, err)
+	participants, err := store.DREvents.EnrollProjects(ctx, event, models.ParticipantTarget{ActiveContract: true}, now)
+	require.NoError(t, err)
+	require.Len(t, participants, 1)
+	assert.Equal(t, models.ParticipantOptedOut, participants[0].Status)
+
+	events, err := store.DREvents.GetDREventsByProjectID(ctx, "p-1", repositories.DREventFilter{}, pagination.Params{})
+	require.NoError(t, err)
+	require.Len(t, events.Data, 3)
+	for _, e := range events.Data {
+		if e.ID == "e-1" {
+			assert.Equal(t, models.ParticipantOptedOut, e.Participation)
+		} else {
+			assert.Equal(t, models.ParticipantEnrolled, e.Participation)
+		}
+	}
+
+	// participants are fixed once the event starts, and leave with a deleted event
+	event.Status = models.DREventActive
+	_, err = store.DREvents.EnrollProjects(ctx, event, models.ParticipantTarget{ProjectIDs: []string{"p-2"}}, now)
+	assertCode(t, http.StatusConflict, err)
+	stored, err := store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	require.NoError(t, store.DREvents.DeleteDREvent(ctx, "e-1", stored.Version))
+	participants, err = store.DREvents.GetParticipants(ctx, "e-1")
+	require.NoError(t, err)
+	assert.Empty(t, participants)
+}
+
+func TestReferencedDeletes(t *testing.T) {
+	repotest.ReferencedDeletes(t, memory.NewStore(nil))
+}
+
+func TestVersionedDeletes(t *testing.T) {
+	repotest.VersionedDeletes(t, memory.NewStore(nil))
+}
+
+func TestDREventFilters(t *testing.T) {
+	repotest.DREventFilters(t, memory.NewStore(nil))
+}
+
+func TestGuardedEnrollment(t *testing.T) {
+	repotest.GuardedEnrollment(t, memory.NewStore(nil))
+}
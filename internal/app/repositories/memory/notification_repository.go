@@ -0,0 +1,26 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type notificationRepository struct {
+	db  *db
+	log *slog.Logger
+}
+
+// NotifyUser keeps the notification instead of adding it to the Firestore notifications collection
+func (r *notificationRepository) NotifyUser(ctx context.Context, data *models.FaultNotification) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.notifications = append(r.db.notifications, *data)
+	if r.log != nil {
+		logging.FromContext(ctx, r.log).InfoContext(ctx, "stored notification in memory", "project_id", data.ProjectID)
+	}
+	return nil
+}
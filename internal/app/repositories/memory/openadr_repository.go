@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type openADRRepository struct {
+	db *db
+}
+
+func (r *openADRRepository) CreateReport(ctx context.Context, report *models.OpenADRReport) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.reports = append(r.db.reports, *report)
+	return nil
+}
+
+func (r *openADRRepository) GetReport(ctx context.Context, id string) (*models.OpenADRReport, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, report := range r.db.reports {
+		if report.ID == id {
+			return &report, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "Report id not found", errNotFound)
+}
+
+func (r *openADRRepository) UpdateReport(ctx context.Context, report *models.OpenADRReport) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.reports {
+		if r.db.reports[i].ID == report.ID {
+			stored := &r.db.reports[i]
+			stored.ClientName, stored.ReportName, stored.Body, stored.ModifiedAt = report.ClientName, report.ReportName, report.Body, report.ModifiedAt
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Report id not found", errNotFound)
+}
+
+func (r *openADRRepository) DeleteReport(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.reports = slices.DeleteFunc(r.db.reports, func(report models.OpenADRReport) bool { return report.ID == id })
+	return nil
+}
+
+func (r *openADRRepository) ListReports(ctx context.Context, filter repositories.OpenADRReportFilter, page pagination.Params) (pagination.Page[models.OpenADRReport], error) {
+	keyset, err := repositories.OpenADRSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.OpenADRReport]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	reports := []models.OpenADRReport{}
+	for _, report := range r.db.reports {
+		visible := filter.Scope.Utility(report.UtilityID) || (filter.Scope.UserID != "" && report.CreatedBy == filter.Scope.UserID)
+		if visible &&
+			(filter.ProgramID == "" || report.ProgramID == filter.ProgramID) &&
+			(filter.EventID == "" || report.EventID == filter.EventID) &&
+			(filter.ClientName == "" || report.ClientName == filter.ClientName) {
+			reports = append(reports, report)
+		}
+	}
+	return pagination.Slice(keyset, reports), nil
+}
+
+func (r *openADRRepository) CreateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.subscriptions = append(r.db.subscriptions, *sub)
+	return nil
+}
+
+func (r *openADRRepository) GetSubscription(ctx context.Context, id string) (*models.OpenADRSubscription, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, sub := range r.db.subscriptions {
+		if sub.ID == id {
+			return &sub, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "Subscription id not found", errNotFound)
+}
+
+func (r *openADRRepository) UpdateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.subscriptions {
+		if r.db.subscriptions[i].ID == sub.ID {
+			stored := &r.db.subscriptions[i]
+			stored.ProgramID, stored.ClientName, stored.Body, stored.ModifiedAt = sub.ProgramID, sub.ClientName, sub.Body, sub.ModifiedAt
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Subscription id not found", errNotFound)
+}
+
+func (r *openADRRepository) DeleteSubscription(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.subscriptions = slices.DeleteFunc(r.db.subscriptions, func(sub models.OpenADRSubscription) bool { return sub.ID == id })
+	return nil
+}
+
+func (r *openADRRepository) ListSubscriptions(ctx context.Context, filter repositories.OpenADRSubscriptionFilter, page pagination.Params) (pagination.Page[models.OpenADRSubscription], error) {
+	keyset, err := repositories.OpenADRSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.OpenADRSubscription]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	subs := []models.OpenADRSubscription{}
+	for _, sub := range r.db.subscriptions {
+		if (filter.CreatedBy == "" || sub.CreatedBy == filter.CreatedBy) &&
+			(filter.ProgramID == "" || sub.ProgramID == filter.ProgramID) &&
+			(filter.ClientName == "" || sub.ClientName == filter.ClientName) {
+			subs = append(subs, sub)
+		}
+	}
+	return pagination.Slice(keyset, subs), nil
+}
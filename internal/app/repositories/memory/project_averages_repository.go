@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type projectAverageRepository struct {
+	db *db
+}
+
+func (r *projectAverageRepository) CreateProjectAverage(ctx context.Context, data *models.ProjectAverage) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// same as the INSERT ... SELECT FROM projects, nothing is inserted for an unknown project
+	if !r.db.projectExists(data.ProjectID) {
+		return custom_error.New(http.StatusBadRequest,
+			"Failed to insert, please make sure your project id is correct: "+data.ProjectID,
+			errors.New("Failed to insert, project ID not found"))
+	}
+
+	// timestamps go through RFC3339 on the way into BigQuery, so sub-second precision is lost
+	avg := *data
+	avg.StartTime = avg.StartTime.Truncate(time.Second)
+	avg.EndTime = avg.EndTime.Truncate(time.Second)
+	r.db.projectAverages = append(r.db.projectAverages, avg)
+	return nil
+}
+
+func (r *projectAverageRepository) GetProjectAveragesByProjectID(ctx context.Context, projectID string, filter repositories.ProjectAverageFilter, page pagination.Params) (pagination.Page[models.ProjectAverage], error) {
+	keyset, err := repositories.ProjectAverageSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.ProjectAverage]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	averages := []models.ProjectAverage{}
+	for _, avg := range r.db.projectAverages {
+		if avg.ProjectID == projectID && averageMatches(avg, filter) {
+			averages = append(averages, avg)
+		}
+	}
+	return pagination.Slice(keyset, averages), nil
+}
+
+// averageMatches is the time range filter of GetProjectAveragesByProjectID
+func averageMatches(avg models.ProjectAverage, filter repositories.ProjectAverageFilter) bool {
+	if !filter.Within {
+		return overlaps(avg.StartTime, avg.EndTime, filter.From, filter.To)
+	}
+	return (filter.From.IsZero() || !avg.StartTime.Before(filter.From)) && (filter.To.IsZero() || !avg.EndTime.After(filter.To))
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type projectRepository struct {
+	db *db
+}
+
+func (r *projectRepository) CreateProject(ctx context.Context, data *models.Project) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	data.Version = 1
+	r.db.projects = append(r.db.projects, *data)
+	return nil
+}
+
+func (r *projectRepository) GetProject(ctx context.Context, id string) (*models.Project, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, p := range r.db.projects {
+		if p.ID == id {
+			return &p, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "Project id not found", errNotFound)
+}
+
+func (r *projectRepository) UpdateProject(ctx context.Context, id string, data *models.Project, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.projects {
+		if r.db.projects[i].ID == id {
+			return updateVersion(&r.db.projects[i], data, updates)
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Project id not found", errNotFound)
+}
+
+func (r *projectRepository) DeleteProject(ctx context.Context, id string, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, p := range r.db.projects {
+		if p.ID == id {
+			if p.Version != version {
+				return repositories.VersionConflict(repositories.ErrStale)
+			}
+			if r.db.projectReferenced(id) {
+				return repositories.StillReferenced(repositories.ErrReferenced)
+			}
+			r.db.projects = append(r.db.projects[:i], r.db.projects[i+1:]...)
+			r.db.removeParticipants(func(pa models.DREventParticipant) bool { return pa.ProjectID == id })
+			r.db.vens = slices.DeleteFunc(r.db.vens, func(v models.VEN) bool { return v.ProjectID == id })
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Project id not found", errNotFound)
+}
+
+func (r *projectRepository) ListProjects(ctx context.Context, filter repositories.ProjectFilter, page pagination.Params) (pagination.Page[models.Project], error) {
+	keyset, err := repositories.ProjectSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Project]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	projects := []models.Project{}
+	for _, p := range r.db.projects {
+		if !filter.Scope.Project(&p) ||
+			!containsFold(p.Location, filter.Search) ||
+			(filter.UtilityID != "" && p.UtilityID != filter.UtilityID) ||
+			(filter.UserID != "" && p.UserID != filter.UserID) ||
+			(filter.HasActiveContract != nil && r.db.hasActiveContract(p.ID) != *filter.HasActiveContract) ||
+			(filter.DERType != "" && !r.db.hasDER(p.ID, filter.DERType)) {
+			continue
+		}
+		projects = append(projects, p)
+	}
+	return pagination.Slice(keyset, projects), nil
+}
+
+func (d *db) hasActiveContract(projectID string) bool {
+	for _, c := range d.contracts {
+		if c.ProjectID == projectID && c.Status == models.Active {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *db) hasDER(projectID string, t models.DERType) bool {
+	for _, der := range d.derMetadata {
+		if der.ProjectID == projectID && der.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold is the STRPOS(LOWER(s), LOWER(substr)) > 0 of the search filters, an empty search matches anything
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
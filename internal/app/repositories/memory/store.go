@@ -0,0 +1,122 @@
+// Package memory is an in-memory storage backend used for local development and tests.
+// It mirrors the behaviour of the BigQuery repositories, including the foreign key style
+// checks, ordering and error messages, so handlers can't tell the difference.
+package memory
+
+import (
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+// errNotFound mirrors bqclient.ErrNotFound as the wrapped error of not found responses
+var errNotFound = errors.New("no rows returned")
+
+// db holds every table, rows are kept in insertion order like an unordered SELECT would return them
+type db struct {
+	mu              sync.RWMutex
+	projects        []models.Project
+	utilities       []models.Utility
+	contracts       []models.Contract
+	derMetadata     []models.DERMetadata
+	drEvents        []models.DREvents
+	participants    []models.DREventParticipant
+	projectAverages []models.ProjectAverage
+	notifications   []models.FaultNotification
+	apiKeys         []models.APIKey
+	idempotency     []models.IdempotencyRecord
+	vens            []models.VEN
+	reports         []models.OpenADRReport
+	subscriptions   []models.OpenADRSubscription
+	calendarFeeds   []models.CalendarFeed
+}
+
+// NewStore creates a store where every repository shares the same in-memory tables
+func NewStore(log *slog.Logger) *repositories.Store {
+	d := &db{}
+	return &repositories.Store{
+		Projects:        &projectRepository{db: d},
+		Utilities:       &utilityRepository{db: d},
+		Contracts:       &contractRepository{db: d},
+		DERMetadata:     &derMetadataRepository{db: d},
+		DREvents:        &drEventRepository{db: d},
+		ProjectAverages: &projectAverageRepository{db: d},
+		Notifications:   &notificationRepository{db: d, log: log},
+		APIKeys:         &apiKeyRepository{db: d},
+		Idempotency:     &idempotencyRepository{db: d},
+		VENs:            &venRepository{db: d},
+		OpenADR:         &openADRRepository{db: d},
+		CalendarFeeds:   &calendarFeedRepository{db: d},
+	}
+}
+
+func (d *db) projectExists(id string) bool {
+	for _, p := range d.projects {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// projectReferenced reports whether rows still hold the project's id, the foreign keys of the postgres backend
+// refuse to delete it then. Event participation and VEN registrations go with the project instead.
+func (d *db) projectReferenced(id string) bool {
+	return slices.ContainsFunc(d.contracts, func(c models.Contract) bool { return c.ProjectID == id }) ||
+		slices.ContainsFunc(d.derMetadata, func(m models.DERMetadata) bool { return m.ProjectID == id }) ||
+		slices.ContainsFunc(d.projectAverages, func(a models.ProjectAverage) bool { return a.ProjectID == id })
+}
+
+// utilityReferenced reports whether projects or DR events still hold the utility's id
+func (d *db) utilityReferenced(id string) bool {
+	return slices.ContainsFunc(d.projects, func(p models.Project) bool { return p.UtilityID == id }) ||
+		slices.ContainsFunc(d.drEvents, func(e models.DREvents) bool { return e.UtilityID == id })
+}
+
+func (d *db) utility(id string) (models.Utility, bool) {
+	for _, u := range d.utilities {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return models.Utility{}, false
+}
+
+// applyUpdates sets the fields of dst named by the json tags in updates, the same map
+// the BigQuery repositories build with logic.ExtractFields and turn into SET statements
+func applyUpdates(dst any, updates map[string]any) {
+	v := reflect.ValueOf(dst).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		value, ok := updates[name]
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		val := reflect.ValueOf(value)
+		if val.Type().ConvertibleTo(field.Type()) {
+			field.Set(val.Convert(field.Type()))
+		}
+	}
+}
+
+// updateVersion applies updates to row, a pointer to a stored model, only while it is still at the version of data,
+// then bumps the version of both, like the versioned UPDATE of the other backends
+func updateVersion(row any, data any, updates map[string]any) error {
+	stored := reflect.ValueOf(row).Elem().FieldByName("Version")
+	read := reflect.ValueOf(data).Elem().FieldByName("Version")
+	if stored.Int() != read.Int() {
+		return repositories.VersionConflict(repositories.ErrStale)
+	}
+	applyUpdates(row, updates)
+	stored.SetInt(stored.Int() + 1)
+	read.SetInt(stored.Int())
+	return nil
+}
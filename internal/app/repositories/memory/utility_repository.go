@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type utilityRepository struct {
+	db *db
+}
+
+func (r *utilityRepository) CreateUtility(ctx context.Context, data *models.Utility) error {
+	data.ID = uuid.New().String()
+	data.Version = 1
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.utilities = append(r.db.utilities, *data)
+	return nil
+}
+
+func (r *utilityRepository) GetUtility(ctx context.Context, id string) (*models.Utility, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	util, ok := r.db.utility(id)
+	if !ok {
+		return nil, custom_error.New(http.StatusNotFound, "Utility id not found", errNotFound)
+	}
+	return &util, nil
+}
+
+func (r *utilityRepository) UpdateUtility(ctx context.Context, id string, data *models.Utility, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.utilities {
+		if r.db.utilities[i].ID == id {
+			return updateVersion(&r.db.utilities[i], data, updates)
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Utility id not found", errNotFound)
+}
+
+func (r *utilityRepository) DeleteUtility(ctx context.Context, id string, version int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, u := range r.db.utilities {
+		if u.ID == id {
+			if u.Version != version {
+				return repositories.VersionConflict(repositories.ErrStale)
+			}
+			if r.db.utilityReferenced(id) {
+				return repositories.StillReferenced(repositories.ErrReferenced)
+			}
+			r.db.utilities = append(r.db.utilities[:i], r.db.utilities[i+1:]...)
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "Utility id not found", errNotFound)
+}
+
+// GetProjectSummary follows the BigQuery query, which cross joins the next and most recent
+// events so no row is returned unless the utility has both. Drafts and cancelled events don't count.
+func (r *utilityRepository) GetProjectSummary(ctx context.Context, utilityID string) ([]models.ProjectSummary, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	projects := map[string]bool{}
+	for _, p := range r.db.projects {
+		if p.UtilityID == utilityID {
+			projects[p.ID] = true
+		}
+	}
+
+	var summary models.ProjectSummary
+	for _, c := range r.db.contracts {
+		if !projects[c.ProjectID] {
+			continue
+		}
+		switch c.Status {
+		case models.Active:
+			summary.TotalActive++
+		case models.Pending:
+			summary.TotalPending++
+		}
+		summary.TotalThreshold += c.ContractThreshold
+	}
+
+	now := time.Now()
+	var next, recent *models.DREvents
+	for i := range r.db.drEvents {
+		event := &r.db.drEvents[i]
+		if event.UtilityID != utilityID || event.Status == models.DREventDraft || event.Status == models.DREventCancelled {
+			continue
+		}
+		if event.StartTime.After(now) && (next == nil || event.StartTime.Before(next.StartTime)) {
+			next = event
+		}
+		if event.EndTime.Before(now) && (recent == nil || event.EndTime.After(recent.EndTime)) {
+			recent = event
+		}
+	}
+	if next == nil || recent == nil {
+		return []models.ProjectSummary{}, nil
+	}
+
+	summary.NextEventID = next.ID
+	summary.NextEventStart = next.StartTime
+	summary.NextEventEnd = next.EndTime
+	summary.RecentEventID = recent.ID
+	summary.RecentEventStart = recent.StartTime
+	summary.RecentEventEnd = recent.EndTime
+
+	return []models.ProjectSummary{summary}, nil
+}
+
+func (r *utilityRepository) ListUtilities(ctx context.Context, filter repositories.UtilityFilter, page pagination.Params) (pagination.Page[models.Utility], error) {
+	keyset, err := repositories.UtilitySort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Utility]{}, err
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	utilities := []models.Utility{}
+	for _, u := range r.db.utilities {
+		if filter.Scope.Utility(u.ID) && containsFold(u.DisplayName, filter.Search) {
+			utilities = append(utilities, u)
+		}
+	}
+	return pagination.Slice(keyset, utilities), nil
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+)
+
+type venRepository struct {
+	db *db
+}
+
+func (r *venRepository) CreateVEN(ctx context.Context, ven *models.VEN) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.vens = append(r.db.vens, *ven)
+	return nil
+}
+
+func (r *venRepository) GetVEN(ctx context.Context, id string) (*models.VEN, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, v := range r.db.vens {
+		if v.ID == id {
+			return &v, nil
+		}
+	}
+	return nil, custom_error.New(http.StatusNotFound, "VEN id not found", errNotFound)
+}
+
+func (r *venRepository) DeleteVEN(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i, v := range r.db.vens {
+		if v.ID == id {
+			r.db.vens = append(r.db.vens[:i], r.db.vens[i+1:]...)
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "VEN id not found", errNotFound)
+}
+
+func (r *venRepository) SetEventsDigest(ctx context.Context, id string, digest string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for i := range r.db.vens {
+		if r.db.vens[i].ID == id {
+			r.db.vens[i].EventsDigest = digest
+			return nil
+		}
+	}
+	return custom_error.New(http.StatusNotFound, "VEN id not found", errNotFound)
+}
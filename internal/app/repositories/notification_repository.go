@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/grid-stream-org/api/internal/app/tracing"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/api/pkg/firebase"
@@ -29,7 +30,9 @@ func NewNotificationRepository(fb firebase.FirebaseClient, log *slog.Logger) Not
 func (r *notificationRepository) NotifyUser(ctx context.Context, data *models.FaultNotification) error {
 	firestore := r.fb.Firestore()
 
+	ctx, span := tracing.Start(ctx, "firestore.notifications.add")
 	_, _, err := firestore.Collection("notifications").Add(ctx, data)
+	tracing.End(span, err)
 	if err != nil {
 		return custom_error.New(http.StatusInternalServerError, "Failed to send notification", err)
 	}
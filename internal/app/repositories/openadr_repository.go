@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"google.golang.org/api/iterator"
+)
+
+// OpenADRRepository stores the reports and subscriptions of OpenADR 3.0 clients, programs and events are
+// derived from utilities and DR events so they aren't stored
+type OpenADRRepository interface {
+	CreateReport(ctx context.Context, report *models.OpenADRReport) error
+	GetReport(ctx context.Context, id string) (*models.OpenADRReport, error)
+	UpdateReport(ctx context.Context, report *models.OpenADRReport) error
+	DeleteReport(ctx context.Context, id string) error
+	ListReports(ctx context.Context, filter OpenADRReportFilter, page pagination.Params) (pagination.Page[models.OpenADRReport], error)
+
+	CreateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error
+	GetSubscription(ctx context.Context, id string) (*models.OpenADRSubscription, error)
+	UpdateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	ListSubscriptions(ctx context.Context, filter OpenADRSubscriptionFilter, page pagination.Params) (pagination.Page[models.OpenADRSubscription], error)
+}
+
+// OpenADRReportFilter narrows a list of reports, zero fields match every report. The Scope's utilities select the
+// reports on their events and its UserID the reports the user created.
+type OpenADRReportFilter struct {
+	Scope      authz.Scope
+	ProgramID  string
+	EventID    string
+	ClientName string
+}
+
+// OpenADRSubscriptionFilter narrows a list of subscriptions, zero fields match every subscription
+type OpenADRSubscriptionFilter struct {
+	CreatedBy  string
+	ProgramID  string
+	ClientName string
+}
+
+// OpenADRSort is the order of report and subscription lists, oldest first so offsets stay put as rows are added
+var OpenADRSort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":         {Kind: pagination.String},
+		"created_at": {Kind: pagination.Time},
+	},
+	Default:    "created_at",
+	Tiebreaker: "id",
+}
+
+type openADRRepository struct {
+	client *tableClient
+	log    *slog.Logger
+}
+
+func NewOpenADRRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) OpenADRRepository {
+	return &openADRRepository{client: newTableClient(client, tables), log: log}
+}
+
+func (r *openADRRepository) CreateReport(ctx context.Context, report *models.OpenADRReport) error {
+	if err := r.client.Put(ctx, "openadr_reports", report); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create report", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) GetReport(ctx context.Context, id string) (*models.OpenADRReport, error) {
+	var report models.OpenADRReport
+	if err := r.client.Get(ctx, "openadr_reports", id, &report); err != nil {
+		if err == bqclient.ErrNotFound {
+			return nil, custom_error.New(http.StatusNotFound, "Report id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch report", err)
+	}
+	return &report, nil
+}
+
+func (r *openADRRepository) UpdateReport(ctx context.Context, report *models.OpenADRReport) error {
+	updates := map[string]any{
+		"client_name": report.ClientName,
+		"report_name": report.ReportName,
+		"body":        report.Body,
+		"modified_at": report.ModifiedAt,
+	}
+	if err := r.client.Update(ctx, "openadr_reports", report.ID, updates); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update report", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) DeleteReport(ctx context.Context, id string) error {
+	if err := r.client.Delete(ctx, "openadr_reports", id); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to delete report", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) ListReports(ctx context.Context, filter OpenADRReportFilter, page pagination.Params) (pagination.Page[models.OpenADRReport], error) {
+	keyset, err := OpenADRSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.OpenADRReport]{}, err
+	}
+
+	params := append(scopeParams(filter.Scope),
+		bigquery.QueryParameter{Name: "program_id", Value: filter.ProgramID},
+		bigquery.QueryParameter{Name: "event_id", Value: filter.EventID},
+		bigquery.QueryParameter{Name: "client_name", Value: filter.ClientName},
+		bigquery.QueryParameter{Name: "limit", Value: keyset.Fetch()},
+	)
+
+	query := `
+        SELECT *
+        FROM {{table "openadr_reports"}}
+        WHERE (@scope_all
+                OR utility_id IN UNNEST(@scope_utility_ids)
+                OR (@scope_user_id != '' AND created_by = @scope_user_id))
+            AND (@program_id = '' OR program_id = @program_id)
+            AND (@event_id = '' OR event_id = @event_id)
+            AND (@client_name = '' OR client_name = @client_name)
+            AND ` + keyset.Where("", keysetArg(&params)) + `
+        ORDER BY ` + keyset.OrderBy("") + `
+        LIMIT @limit`
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return pagination.Page[models.OpenADRReport]{}, custom_error.New(http.StatusInternalServerError, "Failed to list reports", err)
+	}
+
+	reports := []models.OpenADRReport{}
+	for {
+		var item models.OpenADRReport
+		err := it.Next(&item)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return pagination.Page[models.OpenADRReport]{}, custom_error.New(http.StatusInternalServerError, "Error reading reports", err)
+		}
+		reports = append(reports, item)
+	}
+	return pagination.NewPage(keyset, reports), nil
+}
+
+func (r *openADRRepository) CreateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error {
+	if err := r.client.Put(ctx, "openadr_subscriptions", sub); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create subscription", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) GetSubscription(ctx context.Context, id string) (*models.OpenADRSubscription, error) {
+	var sub models.OpenADRSubscription
+	if err := r.client.Get(ctx, "openadr_subscriptions", id, &sub); err != nil {
+		if err == bqclient.ErrNotFound {
+			return nil, custom_error.New(http.StatusNotFound, "Subscription id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch subscription", err)
+	}
+	return &sub, nil
+}
+
+func (r *openADRRepository) UpdateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error {
+	updates := map[string]any{
+		"program_id":  sub.ProgramID,
+		"client_name": sub.ClientName,
+		"body":        sub.Body,
+		"modified_at": sub.ModifiedAt,
+	}
+	if err := r.client.Update(ctx, "openadr_subscriptions", sub.ID, updates); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update subscription", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) DeleteSubscription(ctx context.Context, id string) error {
+	if err := r.client.Delete(ctx, "openadr_subscriptions", id); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to delete subscription", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) ListSubscriptions(ctx context.Context, filter OpenADRSubscriptionFilter, page pagination.Params) (pagination.Page[models.OpenADRSubscription], error) {
+	keyset, err := OpenADRSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.OpenADRSubscription]{}, err
+	}
+
+	params := []bigquery.QueryParameter{
+		{Name: "created_by", Value: filter.CreatedBy},
+		{Name: "program_id", Value: filter.ProgramID},
+		{Name: "client_name", Value: filter.ClientName},
+		{Name: "limit", Value: keyset.Fetch()},
+	}
+
+	query := `
+        SELECT *
+        FROM {{table "openadr_subscriptions"}}
+        WHERE (@created_by = '' OR created_by = @created_by)
+            AND (@program_id = '' OR program_id = @program_id)
+            AND (@client_name = '' OR client_name = @client_name)
+            AND ` + keyset.Where("", keysetArg(&params)) + `
+        ORDER BY ` + keyset.OrderBy("") + `
+        LIMIT @limit`
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return pagination.Page[models.OpenADRSubscription]{}, custom_error.New(http.StatusInternalServerError, "Failed to list subscriptions", err)
+	}
+
+	subs := []models.OpenADRSubscription{}
+	for {
+		var item models.OpenADRSubscription
+		err := it.Next(&item)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return pagination.Page[models.OpenADRSubscription]{}, custom_error.New(http.StatusInternalServerError, "Error reading subscriptions", err)
+		}
+		subs = append(subs, item)
+	}
+	return pagination.NewPage(keyset, subs), nil
+}
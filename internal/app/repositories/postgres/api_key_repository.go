@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type apiKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+const apiKeyColumns = "id, name, prefix, key_hash, scopes, utility_ids, created_by, created_at, expires_at, last_used_at, revoked_at"
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	var lastUsed, revoked pgtype.Timestamptz
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &key.Scopes, &key.UtilityIDs,
+		&key.CreatedBy, &key.CreatedAt, &key.ExpiresAt, &lastUsed, &revoked)
+	if err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = toNullTimestamp(lastUsed)
+	key.RevokedAt = toNullTimestamp(revoked)
+	return &key, nil
+}
+
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	_, err := r.pool.Exec(ctx, `
+        INSERT INTO api_keys (`+apiKeyColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		key.ID, key.Name, key.Prefix, key.KeyHash, key.Scopes, key.UtilityIDs, key.CreatedBy,
+		key.CreatedAt, key.ExpiresAt, fromNullTimestamp(key.LastUsedAt), fromNullTimestamp(key.RevokedAt))
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create api key", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	return r.get(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id)
+}
+
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.get(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash)
+}
+
+func (r *apiKeyRepository) get(ctx context.Context, query string, arg string) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.pool.QueryRow(ctx, query, arg))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "api key not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch api key", err)
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.pool.Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch api keys", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, custom_error.New(http.StatusInternalServerError, "Error reading api key data", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Error reading api key data", err)
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) RotateAPIKey(ctx context.Context, id string, prefix string, hash string, expiresAt time.Time) error {
+	found, err := update(ctx, r.pool, "api_keys", id, map[string]any{"prefix": prefix, "key_hash": hash, "expires_at": expiresAt})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to rotate api key", err)
+	}
+	if !found {
+		return custom_error.New(http.StatusNotFound, "api key not found", nil)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	found, err := update(ctx, r.pool, "api_keys", id, map[string]any{"revoked_at": at})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to revoke api key", err)
+	}
+	if !found {
+		return custom_error.New(http.StatusNotFound, "api key not found", nil)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	if _, err := update(ctx, r.pool, "api_keys", id, map[string]any{"last_used_at": at}); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update api key", err)
+	}
+	return nil
+}
+
+func fromNullTimestamp(t bigquery.NullTimestamp) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t.Timestamp, Valid: t.Valid}
+}
+
+func toNullTimestamp(t pgtype.Timestamptz) bigquery.NullTimestamp {
+	return bigquery.NullTimestamp{Timestamp: t.Time, Valid: t.Valid}
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type calendarFeedRepository struct {
+	pool *pgxpool.Pool
+}
+
+func (r *calendarFeedRepository) GetFeedGeneration(ctx context.Context, userID string) (int64, error) {
+	var generation int64
+	err := r.pool.QueryRow(ctx, "SELECT generation FROM calendar_feeds WHERE user_id = $1", userID).Scan(&generation)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, nil
+		}
+		return 0, custom_error.New(http.StatusInternalServerError, "Failed to fetch calendar feed", err)
+	}
+	return generation, nil
+}
+
+func (r *calendarFeedRepository) RevokeFeedTokens(ctx context.Context, userID string, at time.Time) (int64, error) {
+	var generation int64
+	err := r.pool.QueryRow(ctx, `
+        INSERT INTO calendar_feeds (user_id, generation, revoked_at)
+        VALUES ($1, 1, $2)
+        ON CONFLICT (user_id) DO UPDATE SET generation = calendar_feeds.generation + 1, revoked_at = EXCLUDED.revoked_at
+        RETURNING generation`,
+		userID, at).Scan(&generation)
+	if err != nil {
+		return 0, custom_error.New(http.StatusInternalServerError, "Failed to revoke calendar feed tokens", err)
+	}
+	return generation, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type contractRepository struct {
+	pool *pgxpool.Pool
+}
+
+const contractColumns = "id, contract_threshold, start_date, end_date, status, project_id, version"
+
+func scanContract(row pgx.Row) (models.Contract, error) {
+	var c models.Contract
+	var start, end pgtype.Date
+	if err := row.Scan(&c.ID, &c.ContractThreshold, &start, &end, &c.Status, &c.ProjectID, &c.Version); err != nil {
+		return c, err
+	}
+	c.StartDate = toNullDate(start)
+	c.EndDate = toNullDate(end)
+	return c, nil
+}
+
+func (r *contractRepository) CreateContract(ctx context.Context, data *models.Contract) error {
+	data.Version = 1
+	_, err := r.pool.Exec(ctx, "INSERT INTO contracts ("+contractColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		data.ID, data.ContractThreshold, fromNullDate(data.StartDate), fromNullDate(data.EndDate), data.Status, data.ProjectID, data.Version)
+	if err != nil {
+		if pgErrorCode(err) == foreignKeyViolation {
+			return custom_error.New(http.StatusBadRequest, fmt.Sprintf("Failed to insert, please make sure your project id was correct: %s", data.ProjectID), errors.New("Failed to insert, please make sure your project id was correct"))
+		}
+		return custom_error.New(http.StatusInternalServerError, "Failed to create contract", err)
+	}
+	return nil
+}
+
+func (r *contractRepository) GetContract(ctx context.Context, id string) (*models.Contract, error) {
+	contract, err := scanContract(r.pool.QueryRow(ctx, "SELECT "+contractColumns+" FROM contracts WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "Contract not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to retrieve contract", err)
+	}
+	return &contract, nil
+}
+
+func (r *contractRepository) UpdateContract(ctx context.Context, id string, data *models.Contract, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", errors.New("No fields to update"))
+	}
+
+	if err := updateVersion(ctx, r.pool, "contracts", id, data.Version, updates); err != nil {
+		return updateError(err, "contract id not found", "Failed to update")
+	}
+	data.Version++
+	return nil
+}
+
+func (r *contractRepository) DeleteContract(ctx context.Context, id string, version int64) error {
+	if err := removeVersion(ctx, r.pool, "contracts", id, version); err != nil {
+		return versionedDeleteError(err, "contract id not found", "Failed to delete contract")
+	}
+	return nil
+}
+
+func (r *contractRepository) GetContractsByProjectID(ctx context.Context, id string, filter repositories.ContractFilter, page pagination.Params) (pagination.Page[models.Contract], error) {
+	keyset, err := repositories.ContractSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Contract]{}, err
+	}
+
+	args := []any{id, string(filter.Status), keyset.Fetch()}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+contractColumns+`
+        FROM contracts
+        WHERE project_id = $1
+        AND ($2 = '' OR status = $2)
+        AND `+keyset.Where("", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("")+`
+        LIMIT $3`, args...)
+	if err != nil {
+		return pagination.Page[models.Contract]{}, custom_error.New(http.StatusInternalServerError, "Failed to list contracts", err)
+	}
+	defer rows.Close()
+
+	contracts := []models.Contract{}
+	for rows.Next() {
+		item, err := scanContract(rows)
+		if err != nil {
+			return pagination.Page[models.Contract]{}, custom_error.New(http.StatusInternalServerError, "Error reading contract data", err)
+		}
+		contracts = append(contracts, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.Contract]{}, custom_error.New(http.StatusInternalServerError, "Error reading contract data", err)
+	}
+	return pagination.NewPage(keyset, contracts), nil
+}
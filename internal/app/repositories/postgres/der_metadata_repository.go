@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type derMetadataRepository struct {
+	pool *pgxpool.Pool
+}
+
+const (
+	derMetadataColumns = "id, project_id, type, nameplate_capacity, power_capacity, version"
+	insertDERMetadata  = "INSERT INTO der_metadata (" + derMetadataColumns + ") VALUES ($1, $2, $3, $4, $5, $6)"
+)
+
+func scanDERMetadata(row pgx.Row) (models.DERMetadata, error) {
+	var d models.DERMetadata
+	err := row.Scan(&d.ID, &d.ProjectID, &d.Type, &d.NameplateCapacity, &d.PowerCapacity, &d.Version)
+	return d, err
+}
+
+func createDERMetadataError(err error, message string) error {
+	switch pgErrorCode(err) {
+	case foreignKeyViolation:
+		return custom_error.New(http.StatusBadRequest, "Failed to insert, please make sure your project id is correct", err)
+	case uniqueViolation:
+		return custom_error.New(http.StatusConflict, "der id already exists", err)
+	}
+	return custom_error.New(http.StatusInternalServerError, message, err)
+}
+
+func (r *derMetadataRepository) CreateDERMetadata(ctx context.Context, data *models.DERMetadata) error {
+	data.Version = 1
+	_, err := r.pool.Exec(ctx, insertDERMetadata, data.ID, data.ProjectID, data.Type, data.NameplateCapacity, data.PowerCapacity, data.Version)
+	if err != nil {
+		return createDERMetadataError(err, "Failed to create der metadata")
+	}
+	return nil
+}
+
+// BatchCreateDERMetadata inserts every der in one transaction, so a bad row leaves nothing behind
+func (r *derMetadataRepository) BatchCreateDERMetadata(ctx context.Context, data []models.DERMetadata) error {
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for i := range data {
+			der := &data[i]
+			der.Version = 1
+			batch.Queue(insertDERMetadata, der.ID, der.ProjectID, der.Type, der.NameplateCapacity, der.PowerCapacity, der.Version)
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return createDERMetadataError(err, "Failed to create all der metadata")
+	}
+	return nil
+}
+
+func (r *derMetadataRepository) GetDERMetadata(ctx context.Context, id string) (*models.DERMetadata, error) {
+	der, err := scanDERMetadata(r.pool.QueryRow(ctx, "SELECT "+derMetadataColumns+" FROM der_metadata WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "der not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to retrieve der", err)
+	}
+	return &der, nil
+}
+
+func (r *derMetadataRepository) ListDERMetadataByProject(ctx context.Context, id string, filter repositories.DERMetadataFilter, page pagination.Params) (pagination.Page[models.DERMetadata], error) {
+	keyset, err := repositories.DERMetadataSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DERMetadata]{}, err
+	}
+
+	args := []any{id, string(filter.Type), keyset.Fetch()}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+derMetadataColumns+`
+        FROM der_metadata
+        WHERE project_id = $1
+        AND ($2 = '' OR type = $2)
+        AND `+keyset.Where("", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("")+`
+        LIMIT $3`, args...)
+	if err != nil {
+		return pagination.Page[models.DERMetadata]{}, custom_error.New(http.StatusInternalServerError, "Failed to list der metadata", err)
+	}
+	defer rows.Close()
+
+	derMetadata := []models.DERMetadata{}
+	for rows.Next() {
+		item, err := scanDERMetadata(rows)
+		if err != nil {
+			return pagination.Page[models.DERMetadata]{}, custom_error.New(http.StatusInternalServerError, "Error reading der metadata", err)
+		}
+		derMetadata = append(derMetadata, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.DERMetadata]{}, custom_error.New(http.StatusInternalServerError, "Error reading der metadata", err)
+	}
+	return pagination.NewPage(keyset, derMetadata), nil
+}
+
+func (r *derMetadataRepository) UpdateDERMetadata(ctx context.Context, id string, data *models.DERMetadata, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
+	}
+
+	if err := updateVersion(ctx, r.pool, "der_metadata", id, data.Version, updates); err != nil {
+		return updateError(err, "der id not found", "Failed to update")
+	}
+	data.Version++
+	return nil
+}
+
+func (r *derMetadataRepository) DeleteDERMetadata(ctx context.Context, id string, version int64) error {
+	if err := removeVersion(ctx, r.pool, "der_metadata", id, version); err != nil {
+		return versionedDeleteError(err, "der id not found", "Failed to delete der metadata")
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type drEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+// drEventColumns are the dr_events columns scanEvent reads, after the id, times, utility, utility name and participation
+const drEventColumns = "dr.status, dr.scheduled_at, dr.activated_at, dr.completed_at, dr.cancelled_at, dr.version"
+
+// scanEvent reads a row of id, start_time, end_time, utility_id, utility_name, participation and the drEventColumns
+func scanEvent(row pgx.Row, event *models.DREvents) error {
+	var scheduled, activated, completed, cancelled pgtype.Timestamptz
+	if err := row.Scan(&event.ID, &event.StartTime, &event.EndTime, &event.UtilityID, &event.UtilityName,
+		&event.Participation, &event.Status, &scheduled, &activated, &completed, &cancelled, &event.Version); err != nil {
+		return err
+	}
+	event.ScheduledAt = toNullTimestamp(scheduled)
+	event.ActivatedAt = toNullTimestamp(activated)
+	event.CompletedAt = toNullTimestamp(completed)
+	event.CancelledAt = toNullTimestamp(cancelled)
+	return nil
+}
+
+func (r *drEventRepository) CreateDREvent(ctx context.Context, data *models.DREvents) error {
+	data.Version = 1
+	_, err := r.pool.Exec(ctx, `
+        INSERT INTO dr_events (id, utility_id, start_time, end_time, status, version)
+        VALUES ($1, $2, $3, $4, $5, $6)`,
+		data.ID, data.UtilityID, data.StartTime, data.EndTime, data.Status, data.Version)
+	if err != nil {
+		if pgErrorCode(err) == foreignKeyViolation {
+			return custom_error.New(http.StatusBadRequest, fmt.Sprintf("Failed to insert, please make sure your utility id is correct: %s", data.UtilityID), err)
+		}
+		return custom_error.New(http.StatusInternalServerError, "Failed to create demand response event", err)
+	}
+	return nil
+}
+
+func (r *drEventRepository) GetDREvent(ctx context.Context, id string) (*models.DREvents, error) {
+	var event models.DREvents
+	// utility_name and participation are left empty like the BigQuery SELECT * of the table
+	err := scanEvent(r.pool.QueryRow(ctx, "SELECT dr.id, dr.start_time, dr.end_time, dr.utility_id, '', '', "+drEventColumns+
+		" FROM dr_events dr WHERE dr.id = $1", id), &event)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "demand response event not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to retrieve demand response event", err)
+	}
+	return &event, nil
+}
+
+func (r *drEventRepository) UpdateDREvent(ctx context.Context, id string, data *models.DREvents, fields []string) error {
+	// the version guard of the update makes sure the status checked here is still the stored one
+	if err := data.CheckEditable(); err != nil {
+		return err
+	}
+	updates := logic.ExtractFields(data, fields)
+	// utility_name only comes from the join with utilities, it isn't a column
+	delete(updates, "utility_name")
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", errors.New("No fields to update"))
+	}
+
+	if err := updateVersion(ctx, r.pool, "dr_events", id, data.Version, updates); err != nil {
+		if pgErrorCode(err) == foreignKeyViolation {
+			return custom_error.New(http.StatusBadRequest, "Failed to update, please make sure your utility id is correct", err)
+		}
+		return updateError(err, "demand response event id not found", "Failed to update")
+	}
+	data.Version++
+	return nil
+}
+
+func (r *drEventRepository) TransitionDREvent(ctx context.Context, id string, data *models.DREvents, action models.DREventAction, at time.Time) error {
+	fields, err := data.Transition(action, at)
+	if err != nil {
+		return err
+	}
+	if err := updateVersion(ctx, r.pool, "dr_events", id, data.Version, logic.ExtractFields(data, fields)); err != nil {
+		return updateError(err, "demand response event id not found", "Failed to update")
+	}
+	data.Version++
+	return nil
+}
+
+func (r *drEventRepository) DeleteDREvent(ctx context.Context, id string, version int64) error {
+	if err := removeVersion(ctx, r.pool, "dr_events", id, version); err != nil {
+		return versionedDeleteError(err, "demand response event id not found", "Failed to delete demand response event")
+	}
+	return nil
+}
+
+func (r *drEventRepository) GetDREventsByProjectID(ctx context.Context, id string, filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	return r.list(ctx, `
+        SELECT dr.id, dr.start_time, dr.end_time, dr.utility_id, u.display_name, pa.status, `+drEventColumns+`
+        FROM dr_event_participants pa
+        JOIN dr_events dr ON pa.event_id = dr.id
+        JOIN utilities u ON dr.utility_id = u.id
+        WHERE pa.project_id = $1`, id, filter, page)
+}
+
+func (r *drEventRepository) GetDREventsByUtilityID(ctx context.Context, id string, filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	events, err := r.list(ctx, `
+        SELECT dr.id, dr.start_time, dr.end_time, dr.utility_id, u.display_name, '', `+drEventColumns+`
+        FROM dr_events dr
+        JOIN utilities u ON dr.utility_id = u.id
+        WHERE dr.utility_id = $1`, id, filter, page)
+	if err != nil {
+		return events, err
+	}
+	ids := make([]string, len(events.Data))
+	for i, event := range events.Data {
+		ids[i] = event.ID
+	}
+	participants, err := r.participants(ctx, ids)
+	if err != nil {
+		return events, err
+	}
+	for i := range events.Data {
+		events.Data[i].Participants = participants[events.Data[i].ID]
+	}
+	return events, nil
+}
+
+// list pages the events selected by query, which filters on the id in $1 and is completed here with the filter
+// and keyset conditions
+func (r *drEventRepository) list(ctx context.Context, query string, id string, filter repositories.DREventFilter, page pagination.Params) (pagination.Page[models.DREvents], error) {
+	keyset, err := repositories.DREventSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.DREvents]{}, err
+	}
+
+	args := []any{id, nullTime(filter.From), nullTime(filter.To), keyset.Fetch(), string(filter.Status), string(filter.DERType)}
+	rows, err := r.pool.Query(ctx, query+`
+        AND ($2::timestamptz IS NULL OR dr.end_time > $2)
+        AND ($3::timestamptz IS NULL OR dr.start_time < $3)
+        AND ($5 = '' OR dr.status = $5)
+        AND ($6 = '' OR EXISTS(
+            SELECT 1 FROM dr_event_participants tp
+            JOIN der_metadata der ON der.project_id = tp.project_id
+            WHERE tp.event_id = dr.id AND der.type = $6))
+        AND `+keyset.Where("dr.", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("dr.")+`
+        LIMIT $4`, args...)
+	if err != nil {
+		return pagination.Page[models.DREvents]{}, custom_error.New(http.StatusInternalServerError, "Failed to list demand response events", err)
+	}
+	defer rows.Close()
+
+	drEvents := []models.DREvents{}
+	for rows.Next() {
+		var item models.DREvents
+		if err := scanEvent(rows, &item); err != nil {
+			return pagination.Page[models.DREvents]{}, custom_error.New(http.StatusInternalServerError, "Error reading demand response events", err)
+		}
+		drEvents = append(drEvents, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.DREvents]{}, custom_error.New(http.StatusInternalServerError, "Error reading demand response events", err)
+	}
+	return pagination.NewPage(keyset, drEvents), nil
+}
+
+func (r *drEventRepository) EnrollProjects(ctx context.Context, event *models.DREvents, target models.ParticipantTarget, at time.Time) ([]models.DREventParticipant, error) {
+	// participants are fixed once the event has started
+	if err := event.CheckEditable(); err != nil {
+		return nil, err
+	}
+	projectIDs := nonNil(target.ProjectIDs)
+
+	// the event stays locked until the participants are in, so it can't start or change in between
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		current := &models.DREvents{}
+		err := tx.QueryRow(ctx, "SELECT status, version FROM dr_events WHERE id = $1 FOR UPDATE", event.ID).
+			Scan(&current.Status, &current.Version)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return custom_error.New(http.StatusNotFound, "demand response event id not found", err)
+			}
+			return custom_error.New(http.StatusInternalServerError, "Failed to enroll projects", err)
+		}
+		if err := repositories.CheckEnrollment(current, event.Version); err != nil {
+			return err
+		}
+
+		if len(projectIDs) > 0 {
+			rows, err := tx.Query(ctx, `
+                SELECT id FROM unnest($1::text[]) AS id
+                WHERE id NOT IN (SELECT p.id FROM projects p WHERE p.utility_id = $2)`, projectIDs, event.UtilityID)
+			if err != nil {
+				return custom_error.New(http.StatusInternalServerError, "Failed to enroll projects", err)
+			}
+			unknown, err := pgx.CollectRows(rows, pgx.RowTo[string])
+			if err != nil {
+				return custom_error.New(http.StatusInternalServerError, "Error reading projects", err)
+			}
+			if len(unknown) > 0 {
+				return repositories.UnknownProjects(unknown)
+			}
+		}
+
+		_, err = tx.Exec(ctx, `
+            INSERT INTO dr_event_participants (event_id, project_id, status, enrolled_at)
+            SELECT $1::text, p.id, 'enrolled', $7::timestamptz
+            FROM projects p
+            WHERE p.utility_id = $2
+                AND (cardinality($3::text[]) = 0 OR p.id = ANY($3))
+                AND (NOT $4::boolean OR EXISTS(
+                    SELECT 1 FROM contracts c WHERE c.project_id = p.id AND c.status = 'active'))
+                AND ($5::text = '' OR EXISTS(
+                    SELECT 1 FROM der_metadata d WHERE d.project_id = p.id AND d.type = $5))
+                AND ($6::double precision = 0 OR (
+                    SELECT COALESCE(SUM(d.nameplate_capacity), 0)
+                    FROM der_metadata d
+                    WHERE d.project_id = p.id AND ($5 = '' OR d.type = $5)) >= $6)
+            ON CONFLICT (event_id, project_id) DO NOTHING`,
+			event.ID, event.UtilityID, projectIDs, target.ActiveContract, string(target.DERType), target.MinCapacity, at)
+		if err != nil {
+			return custom_error.New(http.StatusInternalServerError, "Failed to enroll projects", err)
+		}
+		return nil
+	})
+	if err != nil {
+		var customErr *custom_error.CustomError
+		if errors.As(err, &customErr) {
+			return nil, err
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to enroll projects", err)
+	}
+	return r.GetParticipants(ctx, event.ID)
+}
+
+func (r *drEventRepository) GetParticipants(ctx context.Context, eventID string) ([]models.DREventParticipant, error) {
+	participants, err := r.participants(ctx, []string{eventID})
+	if err != nil {
+		return nil, err
+	}
+	if participants[eventID] == nil {
+		return []models.DREventParticipant{}, nil
+	}
+	return participants[eventID], nil
+}
+
+func (r *drEventRepository) OptOut(ctx context.Context, eventID string, projectID string, at time.Time) (*models.DREventParticipant, error) {
+	participant, err := scanParticipant(r.pool.QueryRow(ctx, `
+        UPDATE dr_event_participants SET status = 'opted_out', opted_out_at = $3
+        WHERE event_id = $1 AND project_id = $2 AND status = 'enrolled'
+        RETURNING `+participantColumns, eventID, projectID, at))
+	if err == nil {
+		return &participant, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to opt out", err)
+	}
+
+	var found bool
+	if err := r.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM dr_event_participants WHERE event_id = $1 AND project_id = $2)",
+		eventID, projectID).Scan(&found); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to opt out", err)
+	}
+	if !found {
+		return nil, repositories.NotParticipant()
+	}
+	return nil, repositories.AlreadyOptedOut()
+}
+
+func (r *drEventRepository) RecordVENResponse(ctx context.Context, eventID string, projectID string, opt models.VENOpt, at time.Time) error {
+	tag, err := r.pool.Exec(ctx, `
+        UPDATE dr_event_participants SET ven_opt = $3, ven_responded_at = $4
+        WHERE event_id = $1 AND project_id = $2`, eventID, projectID, opt, at)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to record ven response", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return repositories.NotParticipant()
+	}
+	return nil
+}
+
+const participantColumns = "event_id, project_id, status, enrolled_at, opted_out_at, COALESCE(ven_opt, ''), ven_responded_at"
+
+func scanParticipant(row pgx.Row) (models.DREventParticipant, error) {
+	var p models.DREventParticipant
+	var optedOut, responded pgtype.Timestamptz
+	if err := row.Scan(&p.EventID, &p.ProjectID, &p.Status, &p.EnrolledAt, &optedOut, &p.VENOpt, &responded); err != nil {
+		return p, err
+	}
+	p.OptedOutAt = toNullTimestamp(optedOut)
+	p.VENRespondedAt = toNullTimestamp(responded)
+	return p, nil
+}
+
+// participants loads the participants of the events by event id, in project order
+func (r *drEventRepository) participants(ctx context.Context, eventIDs []string) (map[string][]models.DREventParticipant, error) {
+	byEvent := map[string][]models.DREventParticipant{}
+	if len(eventIDs) == 0 {
+		return byEvent, nil
+	}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+participantColumns+`
+        FROM dr_event_participants
+        WHERE event_id = ANY($1)
+        ORDER BY event_id, project_id`, eventIDs)
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to list demand response event participants", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanParticipant(rows)
+		if err != nil {
+			return nil, custom_error.New(http.StatusInternalServerError, "Error reading demand response event participants", err)
+		}
+		byEvent[p.EventID] = append(byEvent[p.EventID], p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Error reading demand response event participants", err)
+	}
+	return byEvent, nil
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type idempotencyRepository struct {
+	pool *pgxpool.Pool
+}
+
+const idempotencyColumns = "scope, idempotency_key, request_hash, status, content_type, location, body, resource_id, created_at, expires_at"
+
+func (r *idempotencyRepository) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	// clean up as we go, the index on expires_at keeps this cheap
+	if _, err := r.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", rec.CreatedAt); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to reserve idempotency key", err)
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+        INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (scope, idempotency_key) DO NOTHING`,
+		rec.Scope, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt)
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to reserve idempotency key", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyRecord
+	err = r.pool.QueryRow(ctx, "SELECT "+idempotencyColumns+" FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2",
+		rec.Scope, rec.Key).Scan(&existing.Scope, &existing.Key, &existing.RequestHash, &existing.Status, &existing.ContentType,
+		&existing.Location, &existing.Body, &existing.ResourceID, &existing.CreatedAt, &existing.ExpiresAt)
+	if err == pgx.ErrNoRows {
+		// released between the insert and the select, report it as in progress so the client retries
+		return &models.IdempotencyRecord{Scope: rec.Scope, Key: rec.Key, RequestHash: rec.RequestHash}, nil
+	}
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to reserve idempotency key", err)
+	}
+	return &existing, nil
+}
+
+func (r *idempotencyRepository) CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	_, err := r.pool.Exec(ctx, `
+        UPDATE idempotency_keys
+        SET status = $1, content_type = $2, location = $3, body = $4, resource_id = $5
+        WHERE scope = $6 AND idempotency_key = $7 AND request_hash = $8`,
+		rec.Status, rec.ContentType, rec.Location, rec.Body, rec.ResourceID, rec.Scope, rec.Key, rec.RequestHash)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to store idempotent response", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	_, err := r.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2 AND status = 0", scope, key)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to release idempotency key", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS utilities (
+    id           TEXT PRIMARY KEY,
+    display_name TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS projects (
+    id         TEXT PRIMARY KEY,
+    utility_id TEXT NOT NULL REFERENCES utilities (id),
+    user_id    TEXT NOT NULL DEFAULT '',
+    location   TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS projects_utility_id_idx ON projects (utility_id);
+
+CREATE TABLE IF NOT EXISTS contracts (
+    id                 TEXT PRIMARY KEY,
+    contract_threshold DOUBLE PRECISION NOT NULL,
+    start_date         DATE,
+    end_date           DATE,
+    status             TEXT NOT NULL CHECK (status IN ('active', 'inactive', 'pending')),
+    project_id         TEXT NOT NULL REFERENCES projects (id)
+);
+
+CREATE INDEX IF NOT EXISTS contracts_project_id_idx ON contracts (project_id);
+
+CREATE TABLE IF NOT EXISTS der_metadata (
+    id                 TEXT PRIMARY KEY,
+    project_id         TEXT NOT NULL REFERENCES projects (id),
+    type               TEXT NOT NULL CHECK (type IN ('solar', 'battery', 'ev')),
+    nameplate_capacity DOUBLE PRECISION NOT NULL,
+    power_capacity     DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS der_metadata_project_id_idx ON der_metadata (project_id);
+
+CREATE TABLE IF NOT EXISTS dr_events (
+    id         TEXT PRIMARY KEY,
+    utility_id TEXT NOT NULL REFERENCES utilities (id),
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS dr_events_utility_id_idx ON dr_events (utility_id, start_time);
+
+CREATE TABLE IF NOT EXISTS project_averages (
+    project_id         TEXT NOT NULL REFERENCES projects (id),
+    start_time         TIMESTAMPTZ NOT NULL,
+    end_time           TIMESTAMPTZ NOT NULL,
+    baseline           DOUBLE PRECISION NOT NULL,
+    contract_threshold DOUBLE PRECISION NOT NULL,
+    average_output     DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (project_id, start_time)
+);
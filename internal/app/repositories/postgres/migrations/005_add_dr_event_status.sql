@@ -0,0 +1,7 @@
+-- events created before the lifecycle were live as soon as they were created
+ALTER TABLE dr_events ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'scheduled';
+ALTER TABLE dr_events ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE dr_events ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+ALTER TABLE dr_events ADD COLUMN IF NOT EXISTS activated_at TIMESTAMPTZ;
+ALTER TABLE dr_events ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ;
+ALTER TABLE dr_events ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
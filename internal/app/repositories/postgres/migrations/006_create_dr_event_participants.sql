@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS dr_event_participants (
+    event_id     TEXT NOT NULL REFERENCES dr_events (id) ON DELETE CASCADE,
+    project_id   TEXT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    status       TEXT NOT NULL,
+    enrolled_at  TIMESTAMPTZ NOT NULL,
+    opted_out_at TIMESTAMPTZ,
+    PRIMARY KEY (event_id, project_id)
+);
+
+CREATE INDEX IF NOT EXISTS dr_event_participants_project_id_idx ON dr_event_participants (project_id);
+
+-- events created before enrollment went to every project of their utility
+INSERT INTO dr_event_participants (event_id, project_id, status, enrolled_at)
+SELECT e.id, p.id, 'enrolled', now()
+FROM dr_events e
+JOIN projects p ON p.utility_id = e.utility_id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS openadr_vens (
+    id              TEXT PRIMARY KEY,
+    registration_id TEXT NOT NULL UNIQUE,
+    project_id      TEXT NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    registered_at   TIMESTAMPTZ NOT NULL,
+    events_digest   TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS openadr_vens_project_id_idx ON openadr_vens (project_id);
+
+ALTER TABLE dr_event_participants ADD COLUMN IF NOT EXISTS ven_opt TEXT;
+ALTER TABLE dr_event_participants ADD COLUMN IF NOT EXISTS ven_responded_at TIMESTAMPTZ;
@@ -0,0 +1,28 @@
+-- reports and subscriptions outlive the events and utilities they name, like the project averages reports feed
+CREATE TABLE IF NOT EXISTS openadr_reports (
+    id          TEXT PRIMARY KEY,
+    utility_id  TEXT NOT NULL,
+    program_id  TEXT NOT NULL,
+    event_id    TEXT NOT NULL,
+    client_name TEXT NOT NULL,
+    report_name TEXT NOT NULL DEFAULT '',
+    created_by  TEXT NOT NULL,
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    modified_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS openadr_reports_event_id_idx ON openadr_reports (event_id);
+CREATE INDEX IF NOT EXISTS openadr_reports_utility_id_idx ON openadr_reports (utility_id);
+
+CREATE TABLE IF NOT EXISTS openadr_subscriptions (
+    id          TEXT PRIMARY KEY,
+    program_id  TEXT NOT NULL,
+    client_name TEXT NOT NULL,
+    created_by  TEXT NOT NULL,
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    modified_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS openadr_subscriptions_program_id_idx ON openadr_subscriptions (program_id);
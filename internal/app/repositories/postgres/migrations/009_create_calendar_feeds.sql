@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    user_id    TEXT PRIMARY KEY,
+    generation BIGINT NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL
+);
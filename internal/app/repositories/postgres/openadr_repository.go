@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type openADRRepository struct {
+	pool *pgxpool.Pool
+}
+
+const (
+	reportColumns       = "id, utility_id, program_id, event_id, client_name, report_name, created_by, body, created_at, modified_at"
+	subscriptionColumns = "id, program_id, client_name, created_by, body, created_at, modified_at"
+)
+
+func scanReport(row pgx.Row, report *models.OpenADRReport) error {
+	return row.Scan(&report.ID, &report.UtilityID, &report.ProgramID, &report.EventID, &report.ClientName,
+		&report.ReportName, &report.CreatedBy, &report.Body, &report.CreatedAt, &report.ModifiedAt)
+}
+
+func scanSubscription(row pgx.Row, sub *models.OpenADRSubscription) error {
+	return row.Scan(&sub.ID, &sub.ProgramID, &sub.ClientName, &sub.CreatedBy, &sub.Body, &sub.CreatedAt, &sub.ModifiedAt)
+}
+
+func (r *openADRRepository) CreateReport(ctx context.Context, report *models.OpenADRReport) error {
+	_, err := r.pool.Exec(ctx, "INSERT INTO openadr_reports ("+reportColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		report.ID, report.UtilityID, report.ProgramID, report.EventID, report.ClientName,
+		report.ReportName, report.CreatedBy, report.Body, report.CreatedAt, report.ModifiedAt)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create report", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) GetReport(ctx context.Context, id string) (*models.OpenADRReport, error) {
+	var report models.OpenADRReport
+	if err := scanReport(r.pool.QueryRow(ctx, "SELECT "+reportColumns+" FROM openadr_reports WHERE id = $1", id), &report); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "Report id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch report", err)
+	}
+	return &report, nil
+}
+
+func (r *openADRRepository) UpdateReport(ctx context.Context, report *models.OpenADRReport) error {
+	found, err := update(ctx, r.pool, "openadr_reports", report.ID, map[string]any{
+		"client_name": report.ClientName,
+		"report_name": report.ReportName,
+		"body":        report.Body,
+		"modified_at": report.ModifiedAt,
+	})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update report", err)
+	}
+	if !found {
+		return custom_error.New(http.StatusNotFound, "Report id not found", nil)
+	}
+	return nil
+}
+
+func (r *openADRRepository) DeleteReport(ctx context.Context, id string) error {
+	if _, err := remove(ctx, r.pool, "openadr_reports", id); err != nil {
+		return deleteError(err, "Failed to delete report")
+	}
+	return nil
+}
+
+func (r *openADRRepository) ListReports(ctx context.Context, filter repositories.OpenADRReportFilter, page pagination.Params) (pagination.Page[models.OpenADRReport], error) {
+	keyset, err := repositories.OpenADRSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.OpenADRReport]{}, err
+	}
+
+	scope := filter.Scope
+	args := []any{
+		scope.All, nonNil(scope.UtilityIDs), scope.UserID,
+		filter.ProgramID, filter.EventID, filter.ClientName, keyset.Fetch(),
+	}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+reportColumns+`
+        FROM openadr_reports
+        WHERE ($1 OR utility_id = ANY($2) OR ($3 <> '' AND created_by = $3))
+        AND ($4 = '' OR program_id = $4)
+        AND ($5 = '' OR event_id = $5)
+        AND ($6 = '' OR client_name = $6)
+        AND `+keyset.Where("", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("")+`
+        LIMIT $7`, args...)
+	if err != nil {
+		return pagination.Page[models.OpenADRReport]{}, custom_error.New(http.StatusInternalServerError, "Failed to list reports", err)
+	}
+	defer rows.Close()
+
+	reports := []models.OpenADRReport{}
+	for rows.Next() {
+		var item models.OpenADRReport
+		if err := scanReport(rows, &item); err != nil {
+			return pagination.Page[models.OpenADRReport]{}, custom_error.New(http.StatusInternalServerError, "Error reading reports", err)
+		}
+		reports = append(reports, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.OpenADRReport]{}, custom_error.New(http.StatusInternalServerError, "Error reading reports", err)
+	}
+	return pagination.NewPage(keyset, reports), nil
+}
+
+func (r *openADRRepository) CreateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error {
+	_, err := r.pool.Exec(ctx, "INSERT INTO openadr_subscriptions ("+subscriptionColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		sub.ID, sub.ProgramID, sub.ClientName, sub.CreatedBy, sub.Body, sub.CreatedAt, sub.ModifiedAt)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create subscription", err)
+	}
+	return nil
+}
+
+func (r *openADRRepository) GetSubscription(ctx context.Context, id string) (*models.OpenADRSubscription, error) {
+	var sub models.OpenADRSubscription
+	if err := scanSubscription(r.pool.QueryRow(ctx, "SELECT "+subscriptionColumns+" FROM openadr_subscriptions WHERE id = $1", id), &sub); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "Subscription id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch subscription", err)
+	}
+	return &sub, nil
+}
+
+func (r *openADRRepository) UpdateSubscription(ctx context.Context, sub *models.OpenADRSubscription) error {
+	found, err := update(ctx, r.pool, "openadr_subscriptions", sub.ID, map[string]any{
+		"program_id":  sub.ProgramID,
+		"client_name": sub.ClientName,
+		"body":        sub.Body,
+		"modified_at": sub.ModifiedAt,
+	})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update subscription", err)
+	}
+	if !found {
+		return custom_error.New(http.StatusNotFound, "Subscription id not found", nil)
+	}
+	return nil
+}
+
+func (r *openADRRepository) DeleteSubscription(ctx context.Context, id string) error {
+	if _, err := remove(ctx, r.pool, "openadr_subscriptions", id); err != nil {
+		return deleteError(err, "Failed to delete subscription")
+	}
+	return nil
+}
+
+func (r *openADRRepository) ListSubscriptions(ctx context.Context, filter repositories.OpenADRSubscriptionFilter, page pagination.Params) (pagination.Page[models.OpenADRSubscription], error) {
+	keyset, err := repositories.OpenADRSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.OpenADRSubscription]{}, err
+	}
+
+	args := []any{filter.CreatedBy, filter.ProgramID, filter.ClientName, keyset.Fetch()}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+subscriptionColumns+`
+        FROM openadr_subscriptions
+        WHERE ($1 = '' OR created_by = $1)
+        AND ($2 = '' OR program_id = $2)
+        AND ($3 = '' OR client_name = $3)
+        AND `+keyset.Where("", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("")+`
+        LIMIT $4`, args...)
+	if err != nil {
+		return pagination.Page[models.OpenADRSubscription]{}, custom_error.New(http.StatusInternalServerError, "Failed to list subscriptions", err)
+	}
+	defer rows.Close()
+
+	subs := []models.OpenADRSubscription{}
+	for rows.Next() {
+		var item models.OpenADRSubscription
+		if err := scanSubscription(rows, &item); err != nil {
+			return pagination.Page[models.OpenADRSubscription]{}, custom_error.New(http.StatusInternalServerError, "Error reading subscriptions", err)
+		}
+		subs = append(subs, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.OpenADRSubscription]{}, custom_error.New(http.StatusInternalServerError, "Error reading subscriptions", err)
+	}
+	return pagination.NewPage(keyset, subs), nil
+}
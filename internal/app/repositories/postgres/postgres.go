@@ -0,0 +1,324 @@
+// Package postgres is a PostgreSQL storage backend for utilities that keep operational data on-prem.
+// Unlike BigQuery, referential integrity is enforced with real foreign keys, so creates don't need
+// the INSERT ... SELECT FROM projects / SET inserted = EXISTS(...) scripts to detect a bad parent id.
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/tracing"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/pkg/firebase"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pkgerrors "github.com/pkg/errors"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// Open connects to postgres and applies any pending migrations
+func Open(ctx context.Context, cfg *config.PostgresConfig, log *slog.Logger) (*pgxpool.Pool, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(cfg.URL)
+	if err != nil {
+		return nil, pkgerrors.WithStack(err)
+	}
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	poolCfg.ConnConfig.Tracer = tracing.PGXTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, pkgerrors.WithStack(err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, pkgerrors.WithStack(err)
+	}
+
+	if err := Migrate(ctx, pool, log); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Migrate applies every embedded migration that isn't recorded in schema_migrations, each in its own transaction
+func Migrate(ctx context.Context, pool *pgxpool.Pool, log *slog.Logger) error {
+	if _, err := pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version    TEXT PRIMARY KEY,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        )`); err != nil {
+		return pkgerrors.Wrap(err, "creating schema_migrations")
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return pkgerrors.WithStack(err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		version := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+		script, err := migrations.ReadFile(file)
+		if err != nil {
+			return pkgerrors.WithStack(err)
+		}
+
+		err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+			// serialize concurrent instances starting up against the same database
+			if _, err := tx.Exec(ctx, "LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+				return err
+			}
+			var applied bool
+			if err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied); err != nil {
+				return err
+			}
+			if applied {
+				return nil
+			}
+			if _, err := tx.Exec(ctx, string(script)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+				return err
+			}
+			if log != nil {
+				log.Info("applied postgres migration", "version", version)
+			}
+			return nil
+		})
+		if err != nil {
+			return pkgerrors.Wrapf(err, "applying migration %s", version)
+		}
+	}
+	return nil
+}
+
+// NewStore creates a store backed by postgres, notifications are still written to Firestore
+func NewStore(pool *pgxpool.Pool, fb firebase.FirebaseClient, log *slog.Logger) *repositories.Store {
+	return &repositories.Store{
+		Projects:        &projectRepository{pool: pool},
+		Utilities:       &utilityRepository{pool: pool},
+		Contracts:       &contractRepository{pool: pool},
+		DERMetadata:     &derMetadataRepository{pool: pool},
+		DREvents:        &drEventRepository{pool: pool},
+		ProjectAverages: &projectAverageRepository{pool: pool},
+		Notifications:   repositories.NewNotificationRepository(fb, log),
+		APIKeys:         &apiKeyRepository{pool: pool},
+		Idempotency:     &idempotencyRepository{pool: pool},
+		VENs:            &venRepository{pool: pool},
+		OpenADR:         &openADRRepository{pool: pool},
+		CalendarFeeds:   &calendarFeedRepository{pool: pool},
+	}
+}
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// update runs UPDATE table SET ... WHERE id = $1 for the columns in updates, reporting whether a row matched
+func update(ctx context.Context, pool *pgxpool.Pool, table string, id string, updates map[string]any) (bool, error) {
+	columns := make([]string, 0, len(updates))
+	for column := range updates {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, 0, len(columns))
+	args := []any{id}
+	for _, column := range columns {
+		args = append(args, toPG(updates[column]))
+		sets = append(sets, fmt.Sprintf("%s = $%d", pgx.Identifier{column}.Sanitize(), len(args)))
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $1", pgx.Identifier{table}.Sanitize(), strings.Join(sets, ", "))
+	tag, err := pool.Exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// updateVersion sets the columns in updates on the row with id only if it is still at version, and bumps the version.
+// Like tableClient.UpdateVersion it returns pgx.ErrNoRows when there is no such row and repositories.ErrStale
+// when the row has another version.
+func updateVersion(ctx context.Context, pool *pgxpool.Pool, table string, id string, version int64, updates map[string]any) error {
+	columns := make([]string, 0, len(updates))
+	for column := range updates {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, 0, len(columns)+1)
+	args := []any{id, version}
+	for _, column := range columns {
+		args = append(args, toPG(updates[column]))
+		sets = append(sets, fmt.Sprintf("%s = $%d", pgx.Identifier{column}.Sanitize(), len(args)))
+	}
+	sets = append(sets, "version = version + 1")
+
+	name := pgx.Identifier{table}.Sanitize()
+	query := fmt.Sprintf(`
+        WITH updated AS (
+            UPDATE %s SET %s WHERE id = $1 AND version = $2 RETURNING 1
+        )
+        SELECT EXISTS(SELECT 1 FROM updated), EXISTS(SELECT 1 FROM %s WHERE id = $1)`,
+		name, strings.Join(sets, ", "), name)
+
+	var updated, found bool
+	if err := pool.QueryRow(ctx, query, args...).Scan(&updated, &found); err != nil {
+		return err
+	}
+	switch {
+	case updated:
+		return nil
+	case found:
+		return repositories.ErrStale
+	default:
+		return pgx.ErrNoRows
+	}
+}
+
+// updateError maps a failed updateVersion, message is the error of anything but a missing row or a stale version
+func updateError(err error, notFound string, message string) error {
+	switch {
+	case errors.Is(err, repositories.ErrStale):
+		return repositories.VersionConflict(err)
+	case errors.Is(err, pgx.ErrNoRows):
+		return custom_error.New(http.StatusNotFound, notFound, err)
+	}
+	return custom_error.New(http.StatusInternalServerError, message, err)
+}
+
+// remove deletes a row by id, reporting whether it existed
+func remove(ctx context.Context, pool *pgxpool.Pool, table string, id string) (bool, error) {
+	tag, err := pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", pgx.Identifier{table}.Sanitize()), id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// removeVersion deletes the row with id only if it is still at version. Like updateVersion it returns pgx.ErrNoRows
+// when there is no such row and repositories.ErrStale when the row has another version.
+func removeVersion(ctx context.Context, pool *pgxpool.Pool, table string, id string, version int64) error {
+	name := pgx.Identifier{table}.Sanitize()
+	query := fmt.Sprintf(`
+        WITH deleted AS (
+            DELETE FROM %s WHERE id = $1 AND version = $2 RETURNING 1
+        )
+        SELECT EXISTS(SELECT 1 FROM deleted), EXISTS(SELECT 1 FROM %s WHERE id = $1)`,
+		name, name)
+
+	var deleted, found bool
+	if err := pool.QueryRow(ctx, query, id, version).Scan(&deleted, &found); err != nil {
+		return err
+	}
+	switch {
+	case deleted:
+		return nil
+	case found:
+		return repositories.ErrStale
+	default:
+		return pgx.ErrNoRows
+	}
+}
+
+// versionedDeleteError maps a failed removeVersion like updateError, rows still referencing the id are a conflict
+func versionedDeleteError(err error, notFound string, message string) error {
+	if pgErrorCode(err) == foreignKeyViolation {
+		return deleteError(err, message)
+	}
+	return updateError(err, notFound, message)
+}
+
+// deleteError maps a failed delete, rows still referencing the id are a conflict rather than a server error
+func deleteError(err error, message string) error {
+	if pgErrorCode(err) == foreignKeyViolation {
+		return repositories.StillReferenced(err)
+	}
+	return custom_error.New(http.StatusInternalServerError, message, err)
+}
+
+// toPG converts the model types pgx can't encode on its own
+func toPG(value any) any {
+	switch v := value.(type) {
+	case bigquery.NullDate:
+		return fromNullDate(v)
+	case bigquery.NullTimestamp:
+		return fromNullTimestamp(v)
+	}
+	return value
+}
+
+// keysetArg adds the values a pagination.Keyset compares against to args as $N
+func keysetArg(args *[]any) func(v any) string {
+	return func(v any) string {
+		if d, ok := v.(civil.Date); ok {
+			v = fromNullDate(bigquery.NullDate{Date: d, Valid: true})
+		}
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+}
+
+// nonNil is ids as a text[] argument, an empty array rather than NULL for a nil slice so = ANY is false, not NULL
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+// nullTime is the bound of a time range filter, NULL when it is open
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func fromNullDate(d bigquery.NullDate) pgtype.Date {
+	if !d.Valid {
+		return pgtype.Date{}
+	}
+	return pgtype.Date{Time: d.Date.In(time.UTC), Valid: true}
+}
+
+func toNullDate(d pgtype.Date) bigquery.NullDate {
+	if !d.Valid {
+		return bigquery.NullDate{}
+	}
+	return bigquery.NullDate{Date: civil.DateOf(d.Time), Valid: true}
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type projectAverageRepository struct {
+	pool *pgxpool.Pool
+}
+
+const projectAverageColumns = "project_id, start_time, end_time, baseline, contract_threshold, average_output"
+
+func (r *projectAverageRepository) CreateProjectAverage(ctx context.Context, data *models.ProjectAverage) error {
+	_, err := r.pool.Exec(ctx, "INSERT INTO project_averages ("+projectAverageColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		data.ProjectID, data.StartTime, data.EndTime, data.Baseline, data.ContractThreshold, data.AverageOutput)
+	if err != nil {
+		switch pgErrorCode(err) {
+		case foreignKeyViolation:
+			return custom_error.New(http.StatusBadRequest,
+				"Failed to insert, please make sure your project id is correct: "+data.ProjectID,
+				errors.New("Failed to insert, project ID not found"))
+		case uniqueViolation:
+			return custom_error.New(http.StatusConflict, "Project average already exists for this start time", err)
+		}
+		return custom_error.New(http.StatusInternalServerError, "Failed to create project average", err)
+	}
+	return nil
+}
+
+func (r *projectAverageRepository) GetProjectAveragesByProjectID(ctx context.Context, projectID string, filter repositories.ProjectAverageFilter, page pagination.Params) (pagination.Page[models.ProjectAverage], error) {
+	keyset, err := repositories.ProjectAverageSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.ProjectAverage]{}, err
+	}
+
+	args := []any{projectID, nullTime(filter.From), nullTime(filter.To), keyset.Fetch(), filter.Within}
+	rows, err := r.pool.Query(ctx, `
+        SELECT `+projectAverageColumns+`
+        FROM project_averages
+        WHERE project_id = $1
+        AND ($2::timestamptz IS NULL OR CASE WHEN $5 THEN start_time >= $2 ELSE end_time > $2 END)
+        AND ($3::timestamptz IS NULL OR CASE WHEN $5 THEN end_time <= $3 ELSE start_time < $3 END)
+        AND `+keyset.Where("", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("")+`
+        LIMIT $4`, args...)
+	if err != nil {
+		return pagination.Page[models.ProjectAverage]{}, custom_error.New(http.StatusInternalServerError, "Failed to fetch project averages", err)
+	}
+	defer rows.Close()
+
+	averages := []models.ProjectAverage{}
+	for rows.Next() {
+		var item models.ProjectAverage
+		if err := rows.Scan(&item.ProjectID, &item.StartTime, &item.EndTime, &item.Baseline, &item.ContractThreshold, &item.AverageOutput); err != nil {
+			return pagination.Page[models.ProjectAverage]{}, custom_error.New(http.StatusInternalServerError, "Error reading project average data", err)
+		}
+		averages = append(averages, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.ProjectAverage]{}, custom_error.New(http.StatusInternalServerError, "Error reading project average data", err)
+	}
+	return pagination.NewPage(keyset, averages), nil
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type projectRepository struct {
+	pool *pgxpool.Pool
+}
+
+func (r *projectRepository) CreateProject(ctx context.Context, data *models.Project) error {
+	data.Version = 1
+	_, err := r.pool.Exec(ctx, `
+        INSERT INTO projects (id, utility_id, user_id, location, version)
+        VALUES ($1, $2, $3, $4, $5)`,
+		data.ID, data.UtilityID, data.UserID, data.Location, data.Version)
+	if err != nil {
+		if pgErrorCode(err) == foreignKeyViolation {
+			return custom_error.New(http.StatusBadRequest, "Failed to insert, please make sure your utility id is correct: "+data.UtilityID, err)
+		}
+		return custom_error.New(http.StatusInternalServerError, "Failed to create project", err)
+	}
+	return nil
+}
+
+func (r *projectRepository) GetProject(ctx context.Context, id string) (*models.Project, error) {
+	var proj models.Project
+	err := r.pool.QueryRow(ctx, `
+        SELECT id, utility_id, user_id, location, version
+        FROM projects
+        WHERE id = $1`, id).Scan(&proj.ID, &proj.UtilityID, &proj.UserID, &proj.Location, &proj.Version)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "Project id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to get project", err)
+	}
+	return &proj, nil
+}
+
+func (r *projectRepository) UpdateProject(ctx context.Context, id string, data *models.Project, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if len(updates) == 0 {
+		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
+	}
+
+	if err := updateVersion(ctx, r.pool, "projects", id, data.Version, updates); err != nil {
+		if pgErrorCode(err) == foreignKeyViolation {
+			return custom_error.New(http.StatusBadRequest, "Failed to update, please make sure your utility id is correct", err)
+		}
+		return updateError(err, "Project id not found", "Failed to update")
+	}
+	data.Version++
+	return nil
+}
+
+func (r *projectRepository) DeleteProject(ctx context.Context, id string, version int64) error {
+	if err := removeVersion(ctx, r.pool, "projects", id, version); err != nil {
+		return versionedDeleteError(err, "Project id not found", "Failed to delete project")
+	}
+	return nil
+}
+
+func (r *projectRepository) ListProjects(ctx context.Context, filter repositories.ProjectFilter, page pagination.Params) (pagination.Page[models.Project], error) {
+	keyset, err := repositories.ProjectSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Project]{}, err
+	}
+
+	scope := filter.Scope
+	args := []any{
+		scope.All, nonNil(scope.UtilityIDs), scope.UserID, nonNil(scope.ProjectIDs),
+		filter.Search, filter.UtilityID, filter.UserID, filter.HasActiveContract, string(filter.DERType), keyset.Fetch(),
+	}
+	rows, err := r.pool.Query(ctx, `
+        SELECT p.id, p.utility_id, p.user_id, p.location, p.version
+        FROM projects p
+        WHERE ($1 OR p.utility_id = ANY($2) OR ($3 <> '' AND p.user_id = $3) OR p.id = ANY($4))
+        AND ($5 = '' OR strpos(lower(p.location), lower($5)) > 0)
+        AND ($6 = '' OR p.utility_id = $6)
+        AND ($7 = '' OR p.user_id = $7)
+        AND ($8::boolean IS NULL OR $8 = EXISTS(
+            SELECT 1 FROM contracts c WHERE c.project_id = p.id AND c.status = 'active'))
+        AND ($9 = '' OR EXISTS(SELECT 1 FROM der_metadata d WHERE d.project_id = p.id AND d.type = $9))
+        AND `+keyset.Where("p.", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("p.")+`
+        LIMIT $10`, args...)
+	if err != nil {
+		return pagination.Page[models.Project]{}, custom_error.New(http.StatusInternalServerError, "Failed to list projects", err)
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		var item models.Project
+		if err := rows.Scan(&item.ID, &item.UtilityID, &item.UserID, &item.Location, &item.Version); err != nil {
+			return pagination.Page[models.Project]{}, custom_error.New(http.StatusInternalServerError, "Error reading projects", err)
+		}
+		projects = append(projects, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.Project]{}, custom_error.New(http.StatusInternalServerError, "Error reading projects", err)
+	}
+	return pagination.NewPage(keyset, projects), nil
+}
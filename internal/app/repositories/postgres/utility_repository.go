@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type utilityRepository struct {
+	pool *pgxpool.Pool
+}
+
+func (r *utilityRepository) CreateUtility(ctx context.Context, data *models.Utility) error {
+	data.ID = uuid.New().String()
+	data.Version = 1
+
+	_, err := r.pool.Exec(ctx, "INSERT INTO utilities (id, display_name, version) VALUES ($1, $2, $3)", data.ID, data.DisplayName, data.Version)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to create utility", err)
+	}
+	return nil
+}
+
+func (r *utilityRepository) GetUtility(ctx context.Context, id string) (*models.Utility, error) {
+	var util models.Utility
+	err := r.pool.QueryRow(ctx, "SELECT id, display_name, version FROM utilities WHERE id = $1", id).Scan(&util.ID, &util.DisplayName, &util.Version)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "Utility id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch utility", err)
+	}
+	return &util, nil
+}
+
+func (r *utilityRepository) UpdateUtility(ctx context.Context, id string, data *models.Utility, fields []string) error {
+	updates := logic.ExtractFields(data, fields)
+
+	if err := updateVersion(ctx, r.pool, "utilities", id, data.Version, updates); err != nil {
+		return updateError(err, "Utility id not found", "Failed updating utility")
+	}
+	data.Version++
+	return nil
+}
+
+func (r *utilityRepository) DeleteUtility(ctx context.Context, id string, version int64) error {
+	if err := removeVersion(ctx, r.pool, "utilities", id, version); err != nil {
+		return versionedDeleteError(err, "Utility id not found", "Failed to delete utility id")
+	}
+	return nil
+}
+
+// GetProjectSummary keeps the shape of the BigQuery query, including the cross join on the
+// next and most recent events, so no row is returned unless the utility has both
+func (r *utilityRepository) GetProjectSummary(ctx context.Context, utilityID string) ([]models.ProjectSummary, error) {
+	query := `
+        WITH utility_contracts AS (
+            SELECT c.status, c.contract_threshold
+            FROM contracts c
+            JOIN projects p ON p.id = c.project_id
+            WHERE p.utility_id = $1
+        ),
+        totals AS (
+            SELECT
+                COUNT(*) FILTER (WHERE status = 'active') AS total_active,
+                COUNT(*) FILTER (WHERE status = 'pending') AS total_pending,
+                COALESCE(SUM(contract_threshold), 0) AS total_threshold
+            FROM utility_contracts
+        ),
+        next_dr_event AS (
+            SELECT id, start_time, end_time
+            FROM dr_events
+            WHERE start_time > now()
+            AND utility_id = $1
+            AND status NOT IN ('draft', 'cancelled')
+            ORDER BY start_time ASC
+            LIMIT 1
+        ),
+        recent_dr_event AS (
+            SELECT id, start_time, end_time
+            FROM dr_events
+            WHERE end_time < now()
+            AND utility_id = $1
+            AND status NOT IN ('draft', 'cancelled')
+            ORDER BY end_time DESC
+            LIMIT 1
+        )
+        SELECT
+            t.total_active,
+            t.total_pending,
+            t.total_threshold,
+            n.id, n.start_time, n.end_time,
+            r.id, r.start_time, r.end_time
+        FROM totals t, next_dr_event n, recent_dr_event r`
+
+	rows, err := r.pool.Query(ctx, query, utilityID)
+	if err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch project summary", err)
+	}
+	defer rows.Close()
+
+	summaries := []models.ProjectSummary{}
+	for rows.Next() {
+		var s models.ProjectSummary
+		if err := rows.Scan(
+			&s.TotalActive, &s.TotalPending, &s.TotalThreshold,
+			&s.NextEventID, &s.NextEventStart, &s.NextEventEnd,
+			&s.RecentEventID, &s.RecentEventStart, &s.RecentEventEnd,
+		); err != nil {
+			return nil, custom_error.New(http.StatusInternalServerError, "Error reading project summary data", err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, custom_error.New(http.StatusInternalServerError, "Error reading project summary data", err)
+	}
+
+	// This should only return one row
+	return summaries, nil
+}
+
+func (r *utilityRepository) ListUtilities(ctx context.Context, filter repositories.UtilityFilter, page pagination.Params) (pagination.Page[models.Utility], error) {
+	keyset, err := repositories.UtilitySort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Utility]{}, err
+	}
+
+	args := []any{filter.Scope.All, nonNil(filter.Scope.UtilityIDs), filter.Search, keyset.Fetch()}
+	rows, err := r.pool.Query(ctx, `
+        SELECT id, display_name, version
+        FROM utilities
+        WHERE ($1 OR id = ANY($2))
+        AND ($3 = '' OR strpos(lower(display_name), lower($3)) > 0)
+        AND `+keyset.Where("", keysetArg(&args))+`
+        ORDER BY `+keyset.OrderBy("")+`
+        LIMIT $4`, args...)
+	if err != nil {
+		return pagination.Page[models.Utility]{}, custom_error.New(http.StatusInternalServerError, "Failed to list utilities", err)
+	}
+	defer rows.Close()
+
+	utilities := []models.Utility{}
+	for rows.Next() {
+		var item models.Utility
+		if err := rows.Scan(&item.ID, &item.DisplayName, &item.Version); err != nil {
+			return pagination.Page[models.Utility]{}, custom_error.New(http.StatusInternalServerError, "Error reading utilities", err)
+		}
+		utilities = append(utilities, item)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.Utility]{}, custom_error.New(http.StatusInternalServerError, "Error reading utilities", err)
+	}
+	return pagination.NewPage(keyset, utilities), nil
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type venRepository struct {
+	pool *pgxpool.Pool
+}
+
+const venColumns = "id, registration_id, project_id, name, registered_at, events_digest"
+
+func (r *venRepository) CreateVEN(ctx context.Context, ven *models.VEN) error {
+	_, err := r.pool.Exec(ctx, "INSERT INTO openadr_vens ("+venColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		ven.ID, ven.RegistrationID, ven.ProjectID, ven.Name, ven.RegisteredAt, ven.EventsDigest)
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to register ven", err)
+	}
+	return nil
+}
+
+func (r *venRepository) GetVEN(ctx context.Context, id string) (*models.VEN, error) {
+	var ven models.VEN
+	err := r.pool.QueryRow(ctx, "SELECT "+venColumns+" FROM openadr_vens WHERE id = $1", id).
+		Scan(&ven.ID, &ven.RegistrationID, &ven.ProjectID, &ven.Name, &ven.RegisteredAt, &ven.EventsDigest)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, custom_error.New(http.StatusNotFound, "VEN id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch ven", err)
+	}
+	return &ven, nil
+}
+
+func (r *venRepository) DeleteVEN(ctx context.Context, id string) error {
+	found, err := remove(ctx, r.pool, "openadr_vens", id)
+	if err != nil {
+		return deleteError(err, "Failed to cancel ven registration")
+	}
+	if !found {
+		return custom_error.New(http.StatusNotFound, "VEN id not found", nil)
+	}
+	return nil
+}
+
+func (r *venRepository) SetEventsDigest(ctx context.Context, id string, digest string) error {
+	found, err := update(ctx, r.pool, "openadr_vens", id, map[string]any{"events_digest": digest})
+	if err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update ven", err)
+	}
+	if !found {
+		return custom_error.New(http.StatusNotFound, "VEN id not found", nil)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
@@ -16,24 +17,43 @@ import (
 
 type ProjectAverageRepository interface {
 	CreateProjectAverage(ctx context.Context, data *models.ProjectAverage) error
-	GetProjectAveragesByProjectID(ctx context.Context, projectID string) ([]models.ProjectAverage, error)
-	GetProjectAveragesByDateRange(ctx context.Context, projectID string, startTime, endTime time.Time) ([]models.ProjectAverage, error)
+	GetProjectAveragesByProjectID(ctx context.Context, projectID string, filter ProjectAverageFilter, page pagination.Params) (pagination.Page[models.ProjectAverage], error)
+}
+
+// ProjectAverageFilter narrows a list of averages to the intervals overlapping [From, To), a zero bound is open.
+// The intervals are aligned, so a range on interval boundaries selects exactly the averages inside it.
+type ProjectAverageFilter struct {
+	From time.Time
+	To   time.Time
+	// Within keeps only the intervals inside [From, To], how the older start_time and end_time parameters
+	// select averages
+	Within bool
+}
+
+// ProjectAverageSort is how average lists may be sorted, latest first by default. A project has one average per
+// start time so it is its own tiebreaker.
+var ProjectAverageSort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"start_time": {Kind: pagination.Time},
+	},
+	Default:    "-start_time",
+	Tiebreaker: "start_time",
 }
 
 type projectAverageRepository struct {
-	client bqclient.BQClient
+	client *tableClient
 	log    *slog.Logger
 }
 
-func NewProjectAverageRepository(client bqclient.BQClient, log *slog.Logger) ProjectAverageRepository {
-	return &projectAverageRepository{client: client, log: log}
+func NewProjectAverageRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) ProjectAverageRepository {
+	return &projectAverageRepository{client: newTableClient(client, tables), log: log}
 }
 
 func (r *projectAverageRepository) CreateProjectAverage(ctx context.Context, data *models.ProjectAverage) error {
 	query := `
 		DECLARE inserted BOOL DEFAULT FALSE;
 
-		INSERT INTO gridstream_operations.project_averages (project_id, start_time, end_time, baseline, contract_threshold, average_output)
+		INSERT INTO {{table "project_averages"}} (project_id, start_time, end_time, baseline, contract_threshold, average_output)
 		SELECT 
 			@project_id,
 			TIMESTAMP(@start_time),
@@ -41,12 +61,12 @@ func (r *projectAverageRepository) CreateProjectAverage(ctx context.Context, dat
 			@baseline,
 			@contract_threshold,
 			@average_output
-		FROM gridstream_operations.projects p
+		FROM {{table "projects"}} p
 		WHERE p.id = @project_id;
 
 		SET inserted = EXISTS(
 			SELECT 1
-			FROM gridstream_operations.project_averages pa
+			FROM {{table "project_averages"}} pa
 			WHERE pa.project_id = @project_id 
 			AND pa.start_time = TIMESTAMP(@start_time)
 		);
@@ -92,49 +112,18 @@ func (r *projectAverageRepository) CreateProjectAverage(ctx context.Context, dat
 	return nil
 }
 
-func (r *projectAverageRepository) GetProjectAveragesByProjectID(ctx context.Context, projectID string) ([]models.ProjectAverage, error) {
-	query := `
-		SELECT 
-			project_id,
-			start_time,
-			end_time,
-			baseline,
-			contract_threshold,
-			average_output
-		FROM 
-			gridstream_operations.project_averages
-		WHERE 
-			project_id = @project_id
-		ORDER BY 
-			start_time DESC;
-	`
-
-	params := []bigquery.QueryParameter{
-		{Name: "project_id", Value: projectID},
-	}
-
-	it, err := r.client.Query(ctx, query, params)
+func (r *projectAverageRepository) GetProjectAveragesByProjectID(ctx context.Context, projectID string, filter ProjectAverageFilter, page pagination.Params) (pagination.Page[models.ProjectAverage], error) {
+	keyset, err := ProjectAverageSort.Keyset(page)
 	if err != nil {
-		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch project averages", err)
+		return pagination.Page[models.ProjectAverage]{}, err
 	}
 
-	averages := []models.ProjectAverage{}
-	for {
-		var item models.ProjectAverage
-		err := it.Next(&item)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, custom_error.New(http.StatusInternalServerError, "Error reading project average data", err)
-		}
-		averages = append(averages, item)
-	}
-
-	return averages, nil
-}
+	params := append(timeRangeParams(filter.From, filter.To),
+		bigquery.QueryParameter{Name: "project_id", Value: projectID},
+		bigquery.QueryParameter{Name: "within", Value: filter.Within},
+		bigquery.QueryParameter{Name: "limit", Value: keyset.Fetch()},
+	)
 
-func (r *projectAverageRepository) GetProjectAveragesByDateRange(ctx context.Context, projectID string, startTime, endTime time.Time) ([]models.ProjectAverage, error) {
 	query := `
 		SELECT 
 			project_id,
@@ -144,24 +133,20 @@ func (r *projectAverageRepository) GetProjectAveragesByDateRange(ctx context.Con
 			contract_threshold,
 			average_output
 		FROM 
-			gridstream_operations.project_averages
+			{{table "project_averages"}}
 		WHERE 
 			project_id = @project_id
-			AND start_time >= TIMESTAMP(@start_time)
-			AND end_time <= TIMESTAMP(@end_time)
+			AND (@from IS NULL OR IF(@within, start_time >= @from, end_time > @from))
+			AND (@to IS NULL OR IF(@within, end_time <= @to, start_time < @to))
+			AND ` + keyset.Where("", keysetArg(&params)) + `
 		ORDER BY 
-			start_time ASC;
+			` + keyset.OrderBy("") + `
+		LIMIT @limit;
 	`
 
-	params := []bigquery.QueryParameter{
-		{Name: "project_id", Value: projectID},
-		{Name: "start_time", Value: startTime.Format(time.RFC3339)},
-		{Name: "end_time", Value: endTime.Format(time.RFC3339)},
-	}
-
 	it, err := r.client.Query(ctx, query, params)
 	if err != nil {
-		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch project averages in date range", err)
+		return pagination.Page[models.ProjectAverage]{}, custom_error.New(http.StatusInternalServerError, "Failed to fetch project averages", err)
 	}
 
 	averages := []models.ProjectAverage{}
@@ -172,10 +157,10 @@ func (r *projectAverageRepository) GetProjectAveragesByDateRange(ctx context.Con
 			break
 		}
 		if err != nil {
-			return nil, custom_error.New(http.StatusInternalServerError, "Error reading project average data", err)
+			return pagination.Page[models.ProjectAverage]{}, custom_error.New(http.StatusInternalServerError, "Error reading project average data", err)
 		}
 		averages = append(averages, item)
 	}
 
-	return averages, nil
-}
\ No newline at end of file
+	return pagination.NewPage(keyset, averages), nil
+}
@@ -11,28 +11,75 @@ import (
 	"log/slog"
 	"net/http"
 
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/authz"
 	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"google.golang.org/api/iterator"
 )
 
 type ProjectRepository interface {
 	CreateProject(ctx context.Context, data *models.Project) error
 	GetProject(ctx context.Context, id string) (*models.Project, error)
-	UpdateProject(ctx context.Context, id string, data *models.Project) error
-	DeleteProject(ctx context.Context, id string) error
+	UpdateProject(ctx context.Context, id string, data *models.Project, fields []string) error
+	DeleteProject(ctx context.Context, id string, version int64) error
+	ListProjects(ctx context.Context, filter ProjectFilter, page pagination.Params) (pagination.Page[models.Project], error)
+}
+
+// ProjectFilter narrows a list of projects to the caller's Scope, zero fields match every project
+type ProjectFilter struct {
+	Scope authz.Scope
+	// Search is a case-insensitive substring of the location
+	Search    string
+	UtilityID string
+	UserID    string
+	// HasActiveContract keeps the projects with (true) or without (false) an active contract
+	HasActiveContract *bool
+	// DERType keeps the projects with at least one DER of the type
+	DERType models.DERType
+}
+
+// ProjectSort is how project lists may be sorted, by id by default
+var ProjectSort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":         {Kind: pagination.String},
+		"location":   {Kind: pagination.String},
+		"utility_id": {Kind: pagination.String},
+	},
+	Default:    "id",
+	Tiebreaker: "id",
+}
+
+// scopeParams are the @scope_* parameters of the scope condition of a list query
+func scopeParams(scope authz.Scope) []bigquery.QueryParameter {
+	utilityIDs, projectIDs := scope.UtilityIDs, scope.ProjectIDs
+	if utilityIDs == nil {
+		utilityIDs = []string{}
+	}
+	if projectIDs == nil {
+		projectIDs = []string{}
+	}
+	return []bigquery.QueryParameter{
+		{Name: "scope_all", Value: scope.All},
+		{Name: "scope_utility_ids", Value: utilityIDs},
+		{Name: "scope_user_id", Value: scope.UserID},
+		{Name: "scope_project_ids", Value: projectIDs},
+	}
 }
 
 type projectRepository struct {
-	client bqclient.BQClient
+	client *tableClient
 }
 
-func NewProjectRepository(client bqclient.BQClient, log *slog.Logger) ProjectRepository {
-	return &projectRepository{client: client}
+func NewProjectRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) ProjectRepository {
+	return &projectRepository{client: newTableClient(client, tables)}
 }
 
 func (r *projectRepository) CreateProject(ctx context.Context, post *models.Project) error {
+	post.Version = 1
 
 	if err := r.client.Put(ctx, "projects", post); err != nil {
 		return custom_error.New(http.StatusInternalServerError, "Failed to create project", err)
@@ -41,18 +88,19 @@ func (r *projectRepository) CreateProject(ctx context.Context, post *models.Proj
 	return nil
 }
 
-func (r *projectRepository) UpdateProject(ctx context.Context, id string, post *models.Project) error {
+func (r *projectRepository) UpdateProject(ctx context.Context, id string, post *models.Project, fields []string) error {
 
-	updates := logic.ExtractBody(post)
+	updates := logic.ExtractFields(post, fields)
 
 	if len(updates) == 0 {
 		return custom_error.New(http.StatusBadRequest, "No fields to update", nil)
 	}
 
-	if err := r.client.Update(ctx, "projects", id, updates); err != nil {
-		return custom_error.New(http.StatusInternalServerError, "Failed to update", err)
+	if err := r.client.UpdateVersion(ctx, "projects", id, post.Version, updates); err != nil {
+		return versionedUpdateError(err, "Project id not found")
 	}
 
+	post.Version++
 	return nil
 }
 
@@ -67,12 +115,68 @@ func (r *projectRepository) GetProject(ctx context.Context, id string) (*models.
 	return &proj, nil
 }
 
-func (r *projectRepository) DeleteProject(ctx context.Context, id string) error {
-	if err := r.client.Delete(ctx, "projects", id); err != nil {
-		if err == bqclient.ErrNotFound {
-			return custom_error.New(http.StatusNotFound, "Project id not found", err)
-		}
-		return custom_error.New(http.StatusInternalServerError, "Failed to get project", err)
+func (r *projectRepository) DeleteProject(ctx context.Context, id string, version int64) error {
+	// the project leaves the events it was enrolled in and its ven registrations, like the cascade of the other
+	// backends
+	cascade := []reference{
+		{table: "dr_event_participants", column: "project_id"},
+		{table: "openadr_vens", column: "project_id"},
+	}
+	if err := r.client.DeleteCascade(ctx, "projects", id, version, cascade, projectReferences...); err != nil {
+		return deleteError(err, "Project id not found", "Failed to delete project")
 	}
 	return nil
 }
+
+func (r *projectRepository) ListProjects(ctx context.Context, filter ProjectFilter, page pagination.Params) (pagination.Page[models.Project], error) {
+	keyset, err := ProjectSort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Project]{}, err
+	}
+
+	params := append(scopeParams(filter.Scope),
+		bigquery.QueryParameter{Name: "search", Value: filter.Search},
+		bigquery.QueryParameter{Name: "utility_id", Value: filter.UtilityID},
+		bigquery.QueryParameter{Name: "user_id", Value: filter.UserID},
+		bigquery.QueryParameter{Name: "has_active_contract", Value: bigquery.NullBool{Bool: filter.HasActiveContract != nil && *filter.HasActiveContract, Valid: filter.HasActiveContract != nil}},
+		bigquery.QueryParameter{Name: "der_type", Value: string(filter.DERType)},
+		bigquery.QueryParameter{Name: "limit", Value: keyset.Fetch()},
+	)
+
+	query := `
+        SELECT p.*
+        FROM {{table "projects"}} AS p
+        WHERE (@scope_all
+                OR p.utility_id IN UNNEST(@scope_utility_ids)
+                OR (@scope_user_id != '' AND p.user_id = @scope_user_id)
+                OR p.id IN UNNEST(@scope_project_ids))
+            AND (@search = '' OR STRPOS(LOWER(p.location), LOWER(@search)) > 0)
+            AND (@utility_id = '' OR p.utility_id = @utility_id)
+            AND (@user_id = '' OR p.user_id = @user_id)
+            AND (@has_active_contract IS NULL OR @has_active_contract = EXISTS(
+                SELECT 1 FROM {{table "contracts"}} AS c WHERE c.project_id = p.id AND c.status = 'active'))
+            AND (@der_type = '' OR EXISTS(
+                SELECT 1 FROM {{table "der_metadata"}} AS d WHERE d.project_id = p.id AND d.type = @der_type))
+            AND ` + keyset.Where("p.", keysetArg(&params)) + `
+        ORDER BY ` + keyset.OrderBy("p.") + `
+        LIMIT @limit`
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return pagination.Page[models.Project]{}, custom_error.New(http.StatusInternalServerError, "Failed to list projects", err)
+	}
+
+	projects := []models.Project{}
+	for {
+		var item models.Project
+		err := it.Next(&item)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return pagination.Page[models.Project]{}, custom_error.New(http.StatusInternalServerError, "Error reading projects", err)
+		}
+		projects = append(projects, item)
+	}
+	return pagination.NewPage(keyset, projects), nil
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+)
+
+// ErrReferenced is returned by a delete when other rows still hold the id of the row, the foreign keys of the
+// postgres backend refuse the same deletes
+var ErrReferenced = errors.New("row is still referenced")
+
+// reference is a column of a table holding ids of another table's rows
+type reference struct {
+	table  string
+	column string
+}
+
+// projectReferences and utilityReferences keep a project or utility from being deleted while they hold its id.
+// Event participation and VEN registrations are deleted with the project instead.
+var (
+	projectReferences = []reference{
+		{table: "contracts", column: "project_id"},
+		{table: "der_metadata", column: "project_id"},
+		{table: "project_averages", column: "project_id"},
+	}
+	utilityReferences = []reference{
+		{table: "projects", column: "utility_id"},
+		{table: "dr_events", column: "utility_id"},
+	}
+)
+
+// StillReferenced is the error of a delete of a row other rows still point at
+func StillReferenced(err error) *custom_error.CustomError {
+	return custom_error.New(http.StatusConflict, "Still referenced by other records", err)
+}
+
+// deleteError maps a failed tableClient.DeleteVersion to the response of the resource's delete
+func deleteError(err error, notFound string, message string) error {
+	switch {
+	case errors.Is(err, ErrReferenced):
+		return StillReferenced(err)
+	case errors.Is(err, ErrStale):
+		return VersionConflict(err)
+	case errors.Is(err, bqclient.ErrNotFound):
+		return custom_error.New(http.StatusNotFound, notFound, err)
+	}
+	return custom_error.New(http.StatusInternalServerError, message, err)
+}
@@ -0,0 +1,158 @@
+// Package repotest holds the tests every storage backend has to pass, so the backends can't drift apart.
+// Each backend's tests call them with a fresh store.
+package repotest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/app/pagination"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertCode(t *testing.T, code int, err error) {
+	t.Helper()
+	customErr, ok := err.(*custom_error.CustomError)
+	require.True(t, ok, "expected custom error, got: %v", err)
+	assert.Equal(t, code, customErr.Code)
+}
+
+// ReferencedDeletes checks that projects and utilities can't be deleted while other records still point at them
+func ReferencedDeletes(t *testing.T, store *repositories.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-2", UtilityID: util.ID}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "p-1", Status: models.Active, ContractThreshold: 10}))
+	require.NoError(t, store.DERMetadata.CreateDERMetadata(ctx, &models.DERMetadata{ID: "d-1", ProjectID: "p-1", Type: models.Solar, NameplateCapacity: 5}))
+	require.NoError(t, store.ProjectAverages.CreateProjectAverage(ctx, &models.ProjectAverage{ProjectID: "p-2", StartTime: now, EndTime: now.Add(time.Minute)}))
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "e-1", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}))
+	_, err := store.DREvents.EnrollProjects(ctx, &models.DREvents{ID: "e-1", UtilityID: util.ID, Status: models.DREventScheduled, Version: 1}, models.ParticipantTarget{ProjectIDs: []string{"p-1"}}, now)
+	require.NoError(t, err)
+
+	// contracts, DER metadata and telemetry keep a project, projects and events their utility
+	assertCode(t, http.StatusConflict, store.Projects.DeleteProject(ctx, "p-1", 1))
+	assertCode(t, http.StatusConflict, store.Projects.DeleteProject(ctx, "p-2", 1))
+	assertCode(t, http.StatusConflict, store.Utilities.DeleteUtility(ctx, util.ID, 1))
+	_, err = store.Projects.GetProject(ctx, "p-1")
+	require.NoError(t, err, "a refused delete leaves the project")
+
+	require.NoError(t, store.Contracts.DeleteContract(ctx, "c-1", 1))
+	assertCode(t, http.StatusConflict, store.Projects.DeleteProject(ctx, "p-1", 1))
+	require.NoError(t, store.DERMetadata.DeleteDERMetadata(ctx, "d-1", 1))
+
+	// event participation goes with the project
+	require.NoError(t, store.Projects.DeleteProject(ctx, "p-1", 1))
+	_, err = store.Projects.GetProject(ctx, "p-1")
+	assertCode(t, http.StatusNotFound, err)
+	assertCode(t, http.StatusNotFound, store.Projects.DeleteProject(ctx, "p-1", 1))
+	assertCode(t, http.StatusNotFound, store.Utilities.DeleteUtility(ctx, "missing", 1))
+}
+
+// VersionedDeletes checks that a delete only goes through while the row is still at the version the caller read
+func VersionedDeletes(t *testing.T, store *repositories.Store) {
+	ctx := context.Background()
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+	require.NoError(t, store.Contracts.CreateContract(ctx, &models.Contract{ID: "c-1", ProjectID: "p-1", Status: models.Active, ContractThreshold: 10}))
+	require.NoError(t, store.Contracts.UpdateContract(ctx, "c-1", &models.Contract{Status: models.Inactive, Version: 1}, []string{"status"}))
+
+	// the contract was updated since version 1 was read
+	assertCode(t, http.StatusPreconditionFailed, store.Contracts.DeleteContract(ctx, "c-1", 1))
+	contract, err := store.Contracts.GetContract(ctx, "c-1")
+	require.NoError(t, err, "a stale delete leaves the contract")
+	assert.Equal(t, int64(2), contract.Version)
+
+	require.NoError(t, store.Contracts.DeleteContract(ctx, "c-1", 2))
+	assertCode(t, http.StatusNotFound, store.Contracts.DeleteContract(ctx, "c-1", 2))
+
+	// a stale version wins over references, the caller has to reload either way
+	require.NoError(t, store.DERMetadata.CreateDERMetadata(ctx, &models.DERMetadata{ID: "d-1", ProjectID: "p-1", Type: models.Solar, NameplateCapacity: 5}))
+	assertCode(t, http.StatusPreconditionFailed, store.Projects.DeleteProject(ctx, "p-1", 7))
+	assertCode(t, http.StatusConflict, store.Projects.DeleteProject(ctx, "p-1", 1))
+}
+
+// DREventFilters checks the status and DER type filters of both event lists
+func DREventFilters(t *testing.T, store *repositories.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+	require.NoError(t, store.DERMetadata.CreateDERMetadata(ctx, &models.DERMetadata{ID: "d-1", ProjectID: "p-1", Type: models.Solar, NameplateCapacity: 5}))
+	for _, event := range []*models.DREvents{
+		{ID: "e-1", UtilityID: util.ID, Status: models.DREventDraft, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
+		{ID: "e-2", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour)},
+	} {
+		require.NoError(t, store.DREvents.CreateDREvent(ctx, event))
+	}
+	_, err := store.DREvents.EnrollProjects(ctx, &models.DREvents{ID: "e-2", UtilityID: util.ID, Status: models.DREventScheduled, Version: 1}, models.ParticipantTarget{ProjectIDs: []string{"p-1"}}, now)
+	require.NoError(t, err)
+
+	ids := func(page pagination.Page[models.DREvents], err error) []string {
+		t.Helper()
+		require.NoError(t, err)
+		ids := []string{}
+		for _, event := range page.Data {
+			ids = append(ids, event.ID)
+		}
+		return ids
+	}
+	list := func(filter repositories.DREventFilter) []string {
+		return ids(store.DREvents.GetDREventsByUtilityID(ctx, util.ID, filter, pagination.Params{}))
+	}
+
+	assert.Equal(t, []string{"e-2", "e-1"}, list(repositories.DREventFilter{}))
+	assert.Equal(t, []string{"e-1"}, list(repositories.DREventFilter{Status: models.DREventDraft}))
+	assert.Equal(t, []string{"e-2"}, list(repositories.DREventFilter{DERType: models.Solar}))
+	assert.Empty(t, list(repositories.DREventFilter{DERType: models.Battery}))
+	assert.Empty(t, list(repositories.DREventFilter{Status: models.DREventDraft, DERType: models.Solar}))
+
+	assert.Equal(t, []string{"e-2"}, ids(store.DREvents.GetDREventsByProjectID(ctx, "p-1", repositories.DREventFilter{Status: models.DREventScheduled}, pagination.Params{})))
+	assert.Empty(t, ids(store.DREvents.GetDREventsByProjectID(ctx, "p-1", repositories.DREventFilter{Status: models.DREventCancelled}, pagination.Params{})))
+}
+
+// GuardedEnrollment checks that projects are only enrolled while the event is still as the caller read it
+func GuardedEnrollment(t *testing.T, store *repositories.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	util := &models.Utility{DisplayName: "NB Power"}
+	require.NoError(t, store.Utilities.CreateUtility(ctx, util))
+	require.NoError(t, store.Projects.CreateProject(ctx, &models.Project{ID: "p-1", UtilityID: util.ID}))
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "e-1", UtilityID: util.ID, Status: models.DREventScheduled, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}))
+	require.NoError(t, store.DREvents.CreateDREvent(ctx, &models.DREvents{ID: "e-2", UtilityID: util.ID, Status: models.DREventActive, StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)}))
+	target := models.ParticipantTarget{ProjectIDs: []string{"p-1"}}
+
+	read, err := store.DREvents.GetDREvent(ctx, "e-1")
+	require.NoError(t, err)
+	rescheduled := *read
+	rescheduled.EndTime = now.Add(3 * time.Hour)
+	require.NoError(t, store.DREvents.UpdateDREvent(ctx, "e-1", &rescheduled, []string{"end_time"}))
+
+	// the event was rescheduled after it was read
+	_, err = store.DREvents.EnrollProjects(ctx, read, target, now)
+	assertCode(t, http.StatusPreconditionFailed, err)
+	participants, err := store.DREvents.GetParticipants(ctx, "e-1")
+	require.NoError(t, err)
+	assert.Empty(t, participants, "a refused enrollment adds no one")
+
+	// the event started after it was read as scheduled
+	_, err = store.DREvents.EnrollProjects(ctx, &models.DREvents{ID: "e-2", UtilityID: util.ID, Status: models.DREventScheduled, Version: 1}, target, now)
+	assertCode(t, http.StatusConflict, err)
+
+	_, err = store.DREvents.EnrollProjects(ctx, &models.DREvents{ID: "missing", UtilityID: util.ID, Status: models.DREventScheduled, Version: 1}, target, now)
+	assertCode(t, http.StatusNotFound, err)
+}
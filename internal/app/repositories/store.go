@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"log/slog"
+
+	"github.com/grid-stream-org/api/pkg/firebase"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+)
+
+// Store bundles every repository the handlers depend on so the storage backend can be swapped as a unit
+type Store struct {
+	Projects        ProjectRepository
+	Utilities       UtilityRepository
+	Contracts       ContractRepository
+	DERMetadata     DERMetadataRepository
+	DREvents        DREventRepository
+	ProjectAverages ProjectAverageRepository
+	Notifications   NotificationRepository
+	APIKeys         APIKeyRepository
+	Idempotency     IdempotencyRepository
+	VENs            VENRepository
+	OpenADR         OpenADRRepository
+	CalendarFeeds   CalendarFeedRepository
+}
+
+// NewBigQueryStore creates a store backed by BigQuery, notifications are still written to Firestore
+func NewBigQueryStore(client bqclient.BQClient, tables *TableResolver, fb firebase.FirebaseClient, log *slog.Logger) *Store {
+	return &Store{
+		Projects:        NewProjectRepository(client, tables, log),
+		Utilities:       NewUtilityRepository(client, tables, log),
+		Contracts:       NewContractRepository(client, tables, log),
+		DERMetadata:     NewDERMetadataRepository(client, tables, log),
+		DREvents:        NewDREventRepository(client, tables, log),
+		ProjectAverages: NewProjectAverageRepository(client, tables, log),
+		Notifications:   NewNotificationRepository(fb, log),
+		APIKeys:         NewAPIKeyRepository(client, tables, log),
+		Idempotency:     NewIdempotencyRepository(client, tables, log),
+		VENs:            NewVENRepository(client, tables, log),
+		OpenADR:         NewOpenADRRepository(client, tables, log),
+		CalendarFeeds:   NewCalendarFeedRepository(client, tables, log),
+	}
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// Tables every query may refer to, overrides for anything else are rejected to catch typos in config
+var knownTables = []string{
+	"projects",
+	"utilities",
+	"contracts",
+	"der_metadata",
+	"dr_events",
+	"dr_event_participants",
+	"openadr_vens",
+	"openadr_reports",
+	"openadr_subscriptions",
+	"calendar_feeds",
+	"project_averages",
+	"api_keys",
+	"idempotency_keys",
+	"schema_migrations",
+}
+
+// TableResolver turns logical table names into fully qualified BigQuery names, so one binary can target
+// the production, staging, demo or a customer dataset. Queries refer to tables with {{table "name"}}.
+type TableResolver struct {
+	dataset   string
+	overrides map[string]string
+	templates sync.Map
+}
+
+// NewTableResolver resolves tables to dataset.table, overrides map a table to another name, either
+// table, dataset.table or project.dataset.table
+func NewTableResolver(dataset string, overrides map[string]string) (*TableResolver, error) {
+	if dataset == "" {
+		return nil, errors.New("dataset required to resolve table names")
+	}
+	for table := range overrides {
+		if !isKnownTable(table) {
+			return nil, fmt.Errorf("table override for unknown table: %s", table)
+		}
+	}
+	return &TableResolver{dataset: dataset, overrides: overrides}, nil
+}
+
+func isKnownTable(table string) bool {
+	for _, t := range knownTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the dataset (prefixed with the project if the override names one) and the table name
+func (t *TableResolver) Path(table string) (string, string) {
+	override, ok := t.overrides[table]
+	if !ok {
+		return t.dataset, table
+	}
+	if i := strings.LastIndex(override, "."); i >= 0 {
+		return override[:i], override[i+1:]
+	}
+	return t.dataset, override
+}
+
+// Name returns the quoted fully qualified table name
+func (t *TableResolver) Name(table string) string {
+	dataset, name := t.Path(table)
+	return fmt.Sprintf("`%s.%s`", dataset, name)
+}
+
+// Tables lists every logical table name in a stable order
+func (t *TableResolver) Tables() []string {
+	tables := append([]string{}, knownTables...)
+	sort.Strings(tables)
+	return tables
+}
+
+// Render fills in every {{table "name"}} of query, parsed templates are cached since queries are constants
+func (t *TableResolver) Render(query string) (string, error) {
+	cached, ok := t.templates.Load(query)
+	if !ok {
+		tmpl, err := template.New("query").Funcs(template.FuncMap{"table": t.table}).Parse(query)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		cached, _ = t.templates.LoadOrStore(query, tmpl)
+	}
+
+	var buf bytes.Buffer
+	if err := cached.(*template.Template).Execute(&buf, nil); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return buf.String(), nil
+}
+
+func (t *TableResolver) table(name string) (string, error) {
+	if !isKnownTable(name) {
+		return "", fmt.Errorf("unknown table: %s", name)
+	}
+	return t.Name(name), nil
+}
@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableResolver(t *testing.T) {
+	tables, err := NewTableResolver("gridstream_operations", map[string]string{
+		"contracts": "demo_contracts",
+		"dr_events": "staging.dr_events",
+		"utilities": "other-project.shared.utilities",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "`gridstream_operations.projects`", tables.Name("projects"))
+	assert.Equal(t, "`gridstream_operations.demo_contracts`", tables.Name("contracts"))
+	assert.Equal(t, "`staging.dr_events`", tables.Name("dr_events"))
+	assert.Equal(t, "`other-project.shared.utilities`", tables.Name("utilities"))
+
+	dataset, name := tables.Path("utilities")
+	assert.Equal(t, "other-project.shared", dataset)
+	assert.Equal(t, "utilities", name)
+
+	query, err := tables.Render(`SELECT * FROM {{table "dr_events"}} JOIN {{table "projects"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `staging.dr_events` JOIN `gridstream_operations.projects`", query)
+
+	_, err = tables.Render(`SELECT * FROM {{table "projcts"}}`)
+	assert.Error(t, err, "Unknown tables should fail to render")
+}
+
+func TestNewTableResolverRejectsUnknownOverrides(t *testing.T) {
+	_, err := NewTableResolver("gridstream_operations", map[string]string{"contract": "demo.contracts"})
+	assert.Error(t, err)
+
+	_, err = NewTableResolver("", nil)
+	assert.Error(t, err)
+}
@@ -7,7 +7,9 @@ import (
 
 	"cloud.google.com/go/bigquery"
 	"github.com/google/uuid"
+	"github.com/grid-stream-org/api/internal/app/authz"
 	"github.com/grid-stream-org/api/internal/app/logic"
+	"github.com/grid-stream-org/api/internal/app/pagination"
 	"github.com/grid-stream-org/api/internal/custom_error"
 	"github.com/grid-stream-org/api/internal/models"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
@@ -17,24 +19,43 @@ import (
 type UtilityRepository interface {
 	CreateUtility(ctx context.Context, data *models.Utility) error
 	GetUtility(ctx context.Context, id string) (*models.Utility, error)
-	UpdateUtility(ctx context.Context, id string, data *models.Utility) error
-	DeleteUtility(ctx context.Context, id string) error
+	UpdateUtility(ctx context.Context, id string, data *models.Utility, fields []string) error
+	DeleteUtility(ctx context.Context, id string, version int64) error
 	GetProjectSummary(ctx context.Context, utilityID string) ([]models.ProjectSummary, error)
+	ListUtilities(ctx context.Context, filter UtilityFilter, page pagination.Params) (pagination.Page[models.Utility], error)
+}
+
+// UtilityFilter narrows a list of utilities to the caller's Scope
+type UtilityFilter struct {
+	Scope authz.Scope
+	// Search is a case-insensitive substring of the display name
+	Search string
+}
+
+// UtilitySort is how utility lists may be sorted, by display name by default
+var UtilitySort = pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":           {Kind: pagination.String},
+		"display_name": {Kind: pagination.String},
+	},
+	Default:    "display_name",
+	Tiebreaker: "id",
 }
 type utilityRepository struct {
-	client bqclient.BQClient
+	client *tableClient
 	logger *slog.Logger
 }
 
-func NewUtilityRepository(client bqclient.BQClient, logger *slog.Logger) UtilityRepository {
+func NewUtilityRepository(client bqclient.BQClient, tables *TableResolver, logger *slog.Logger) UtilityRepository {
 	return &utilityRepository{
-		client: client,
+		client: newTableClient(client, tables),
 		logger: logger,
 	}
 }
 
 func (r *utilityRepository) CreateUtility(ctx context.Context, data *models.Utility) error {
 	data.ID = uuid.New().String()
+	data.Version = 1
 
 	if err := r.client.Put(ctx, "utilities", data); err != nil {
 		return custom_error.New(http.StatusInternalServerError, "Failed to create utility", err)
@@ -54,27 +75,22 @@ func (r *utilityRepository) GetUtility(ctx context.Context, id string) (*models.
 	return &util, nil
 }
 
-func (r *utilityRepository) UpdateUtility(ctx context.Context, id string, data *models.Utility) error {
+func (r *utilityRepository) UpdateUtility(ctx context.Context, id string, data *models.Utility, fields []string) error {
 
 	// // we already validated display name is not empty in the handler
-	updates := logic.ExtractBody(data)
+	updates := logic.ExtractFields(data, fields)
 
-	if err := r.client.Update(ctx, "utilities", id, updates); err != nil {
-		if err == bqclient.ErrNotFound {
-			return custom_error.New(http.StatusNotFound, "Utility id not found", err)
-		}
-		return custom_error.New(http.StatusInternalServerError, "Failed updating utility", err)
+	if err := r.client.UpdateVersion(ctx, "utilities", id, data.Version, updates); err != nil {
+		return versionedUpdateError(err, "Utility id not found")
 	}
 
+	data.Version++
 	return nil
 }
 
-func (r *utilityRepository) DeleteUtility(ctx context.Context, id string) error {
-	if err := r.client.Delete(ctx, "utilities", id); err != nil {
-		if err == bqclient.ErrNotFound {
-			return custom_error.New(http.StatusNotFound, "Utility id not found", err)
-		}
-		return custom_error.New(http.StatusInternalServerError, "Failed to delete utility id", err)
+func (r *utilityRepository) DeleteUtility(ctx context.Context, id string, version int64) error {
+	if err := r.client.DeleteVersion(ctx, "utilities", id, version, utilityReferences...); err != nil {
+		return deleteError(err, "Utility id not found", "Failed to delete utility id")
 	}
 	return nil
 }
@@ -84,32 +100,33 @@ func (r *utilityRepository) GetProjectSummary(ctx context.Context, utilityID str
         -- Total Active Contracts
         WITH active_contracts AS (
         SELECT COUNT(*) as total_active
-        FROM gridstream_operations.contracts
+        FROM {{table "contracts"}}
         WHERE status = 'active'
-        AND project_id IN (SELECT id FROM gridstream_operations.projects WHERE utility_id = @utility_id)
+        AND project_id IN (SELECT id FROM {{table "projects"}} WHERE utility_id = @utility_id)
         ),
 
         -- Total Pending Contracts
         pending_contracts AS (
         SELECT COUNT(*) as total_pending
-        FROM gridstream_operations.contracts    m                                                                                                                   
+        FROM {{table "contracts"}}    m                                                                                                                   
         WHERE status = 'pending'
-        AND project_id IN (SELECT id FROM gridstream_operations.projects WHERE utility_id = @utility_id)
+        AND project_id IN (SELECT id FROM {{table "projects"}} WHERE utility_id = @utility_id)
         ),
 
         -- Total Sum of Contract Thresholds
         contract_threshold_sum AS (
         SELECT SUM(contract_threshold) as total_threshold
-        FROM gridstream_operations.contracts
-        WHERE project_id IN (SELECT id FROM gridstream_operations.projects WHERE utility_id = @utility_id)
+        FROM {{table "contracts"}}
+        WHERE project_id IN (SELECT id FROM {{table "projects"}} WHERE utility_id = @utility_id)
         ),
 
         -- Next DR Event
         next_dr_event AS (
         SELECT id, start_time, end_time
-        FROM gridstream_operations.dr_events
+        FROM {{table "dr_events"}}
         WHERE start_time > CURRENT_TIMESTAMP()
         AND utility_id = @utility_id
+        AND status NOT IN ('draft', 'cancelled')
         ORDER BY start_time ASC
         LIMIT 1
         ),
@@ -117,9 +134,10 @@ func (r *utilityRepository) GetProjectSummary(ctx context.Context, utilityID str
         -- Most Recent DR Event
         recent_dr_event AS (
         SELECT id, start_time, end_time
-        FROM gridstream_operations.dr_events
+        FROM {{table "dr_events"}}
         WHERE end_time < CURRENT_TIMESTAMP()
         AND utility_id = @utility_id
+        AND status NOT IN ('draft', 'cancelled')
         ORDER BY end_time DESC
         LIMIT 1
         )
@@ -167,3 +185,43 @@ func (r *utilityRepository) GetProjectSummary(ctx context.Context, utilityID str
 	// This should only return one row
 	return summaries, nil
 }
+
+func (r *utilityRepository) ListUtilities(ctx context.Context, filter UtilityFilter, page pagination.Params) (pagination.Page[models.Utility], error) {
+	keyset, err := UtilitySort.Keyset(page)
+	if err != nil {
+		return pagination.Page[models.Utility]{}, err
+	}
+
+	params := append(scopeParams(filter.Scope),
+		bigquery.QueryParameter{Name: "search", Value: filter.Search},
+		bigquery.QueryParameter{Name: "limit", Value: keyset.Fetch()},
+	)
+
+	query := `
+        SELECT *
+        FROM {{table "utilities"}}
+        WHERE (@scope_all OR id IN UNNEST(@scope_utility_ids))
+            AND (@search = '' OR STRPOS(LOWER(display_name), LOWER(@search)) > 0)
+            AND ` + keyset.Where("", keysetArg(&params)) + `
+        ORDER BY ` + keyset.OrderBy("") + `
+        LIMIT @limit`
+
+	it, err := r.client.Query(ctx, query, params)
+	if err != nil {
+		return pagination.Page[models.Utility]{}, custom_error.New(http.StatusInternalServerError, "Failed to list utilities", err)
+	}
+
+	utilities := []models.Utility{}
+	for {
+		var item models.Utility
+		err := it.Next(&item)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return pagination.Page[models.Utility]{}, custom_error.New(http.StatusInternalServerError, "Error reading utilities", err)
+		}
+		utilities = append(utilities, item)
+	}
+	return pagination.NewPage(keyset, utilities), nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+)
+
+// VENRepository stores the OpenADR gateways registered to the projects
+type VENRepository interface {
+	CreateVEN(ctx context.Context, ven *models.VEN) error
+	GetVEN(ctx context.Context, id string) (*models.VEN, error)
+	DeleteVEN(ctx context.Context, id string) error
+	SetEventsDigest(ctx context.Context, id string, digest string) error
+}
+
+type venRepository struct {
+	client *tableClient
+	log    *slog.Logger
+}
+
+func NewVENRepository(client bqclient.BQClient, tables *TableResolver, log *slog.Logger) VENRepository {
+	return &venRepository{client: newTableClient(client, tables), log: log}
+}
+
+func (r *venRepository) CreateVEN(ctx context.Context, ven *models.VEN) error {
+	if err := r.client.Put(ctx, "openadr_vens", ven); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to register ven", err)
+	}
+	return nil
+}
+
+func (r *venRepository) GetVEN(ctx context.Context, id string) (*models.VEN, error) {
+	var ven models.VEN
+	if err := r.client.Get(ctx, "openadr_vens", id, &ven); err != nil {
+		if err == bqclient.ErrNotFound {
+			return nil, custom_error.New(http.StatusNotFound, "VEN id not found", err)
+		}
+		return nil, custom_error.New(http.StatusInternalServerError, "Failed to fetch ven", err)
+	}
+	return &ven, nil
+}
+
+func (r *venRepository) DeleteVEN(ctx context.Context, id string) error {
+	if err := r.client.Delete(ctx, "openadr_vens", id); err != nil {
+		if err == bqclient.ErrNotFound {
+			return custom_error.New(http.StatusNotFound, "VEN id not found", err)
+		}
+		return custom_error.New(http.StatusInternalServerError, "Failed to cancel ven registration", err)
+	}
+	return nil
+}
+
+func (r *venRepository) SetEventsDigest(ctx context.Context, id string, digest string) error {
+	if err := r.client.Update(ctx, "openadr_vens", id, map[string]any{"events_digest": digest}); err != nil {
+		return custom_error.New(http.StatusInternalServerError, "Failed to update ven", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+)
+
+// ErrStale is returned by a versioned update when the row exists but was updated since it was read
+var ErrStale = errors.New("row version changed since it was read")
+
+// VersionConflict is the error of an update that lost the race against another update of the same resource
+func VersionConflict(err error) *custom_error.CustomError {
+	return custom_error.New(http.StatusPreconditionFailed, "Resource was modified by another request, reload it and retry", err).
+		WithCode(custom_error.CodePreconditionFailed)
+}
+
+// versionedUpdateError maps a failed tableClient.UpdateVersion to the response of the resource's update
+func versionedUpdateError(err error, notFound string) error {
+	switch {
+	case errors.Is(err, ErrStale):
+		return VersionConflict(err)
+	case errors.Is(err, bqclient.ErrNotFound):
+		return custom_error.New(http.StatusNotFound, notFound, err)
+	}
+	return custom_error.New(http.StatusInternalServerError, "Failed to update", err)
+}
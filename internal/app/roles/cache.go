@@ -0,0 +1,96 @@
+package roles
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/config"
+)
+
+// Cache is a size bounded LRU over a Store, entries expire after the TTL so role changes
+// made straight in Firestore are picked up without a restart
+type Cache struct {
+	source Store
+	ttl    time.Duration
+	size   int
+	now    func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	uid        string
+	membership *Membership
+	expires    time.Time
+}
+
+func NewCache(source Store, cfg *config.RolesConfig) *Cache {
+	size := cfg.CacheSize
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		source:  source,
+		ttl:     cfg.CacheTTL,
+		size:    size,
+		now:     time.Now,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Lookup returns the cached membership, or looks it up in the source, errors are not cached
+func (c *Cache) Lookup(ctx context.Context, uid string) (*Membership, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[uid]; ok {
+		entry := el.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			return entry.membership, nil
+		}
+		c.remove(el)
+	}
+	c.mu.Unlock()
+
+	m, err := c.source.Lookup(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[uid]; ok {
+		c.remove(el)
+	}
+	c.entries[uid] = c.order.PushFront(&cacheEntry{uid: uid, membership: m, expires: c.now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return m, nil
+}
+
+// Invalidate drops the cached membership of uid, call it whenever their role changes
+func (c *Cache) Invalidate(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[uid]; ok {
+		c.remove(el)
+	}
+}
+
+// Len is the number of cached memberships
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).uid)
+}
@@ -0,0 +1,62 @@
+package roles
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/grid-stream-org/api/internal/app/tracing"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const usersCollection = "users"
+
+type firestoreStore struct {
+	client *firestore.Client
+}
+
+// NewFirestoreStore reads memberships from the users/{uid} documents
+func NewFirestoreStore(client *firestore.Client) Store {
+	return &firestoreStore{client: client}
+}
+
+func (s *firestoreStore) Lookup(ctx context.Context, uid string) (*Membership, error) {
+	ctx, span := tracing.Start(ctx, "firestore.users.get")
+	doc, err := s.client.Collection(usersCollection).Doc(uid).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNoMembership
+		}
+		return nil, errors.Wrap(err, "fetching user doc")
+	}
+	data := doc.Data()
+
+	value, ok := data[ClaimRole]
+	if !ok {
+		return nil, ErrNoMembership
+	}
+	role, ok := value.(int64)
+	if !ok {
+		return nil, errors.Errorf("user %s has a role of type %T", uid, value)
+	}
+
+	m := &Membership{Role: RoleFromInt(int(role))}
+	if m.Role == "" {
+		return nil, ErrNoMembership
+	}
+	m.UtilityID, _ = data[ClaimUtilityID].(string)
+	if ids, ok := data[ClaimProjectIDs].([]interface{}); ok {
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				m.ProjectIDs = append(m.ProjectIDs, s)
+			}
+		}
+	}
+	return m, nil
+}
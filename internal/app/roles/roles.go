@@ -0,0 +1,112 @@
+// Package roles resolves what an authenticated user is, their role and the utility and projects they belong to.
+// Firebase ID token custom claims are used when present so most requests never touch Firestore, the
+// Firestore user document is the source of truth and is read through a cache otherwise.
+package roles
+
+import (
+	"context"
+
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/tracing"
+	"github.com/pkg/errors"
+)
+
+// ErrNoMembership is returned when the user has no user document or the document has no role
+var ErrNoMembership = errors.New("user has no role")
+
+// Custom claim names, also the field names of the Firestore user document apart from role which is stored as an int there
+const (
+	ClaimRole       = "role"
+	ClaimUtilityID  = "utility_id"
+	ClaimProjectIDs = "project_ids"
+)
+
+// Membership is the role of a user and the utility and projects they belong to
+type Membership struct {
+	Role       string   `json:"role" yaml:"role"`
+	UtilityID  string   `json:"utility_id,omitempty" yaml:"utility_id"`
+	ProjectIDs []string `json:"project_ids,omitempty" yaml:"project_ids"`
+}
+
+// Principal is the user as a caller with the membership
+func (m *Membership) Principal(uid string) authz.Principal {
+	return authz.Principal{
+		UserID:     uid,
+		Role:       m.Role,
+		UtilityID:  m.UtilityID,
+		ProjectIDs: m.ProjectIDs,
+	}
+}
+
+// Store looks up the membership of a user, Firestore in production or a YAML file when running offline
+type Store interface {
+	Lookup(ctx context.Context, uid string) (*Membership, error)
+}
+
+// RoleFromInt maps the role stored in the Firestore user document to its name
+func RoleFromInt(value int) string {
+	switch value {
+	case 0:
+		return authz.RoleUtility
+	case 1:
+		return authz.RoleResidential
+	case 2:
+		return authz.RoleTechnician
+	default:
+		return ""
+	}
+}
+
+// FromClaims reads the membership from ID token custom claims, ok is false when the claims have no role
+func FromClaims(claims map[string]interface{}) (*Membership, bool) {
+	role, _ := claims[ClaimRole].(string)
+	if role == "" {
+		return nil, false
+	}
+	m := &Membership{Role: role}
+	m.UtilityID, _ = claims[ClaimUtilityID].(string)
+	if ids, ok := claims[ClaimProjectIDs].([]interface{}); ok {
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				m.ProjectIDs = append(m.ProjectIDs, s)
+			}
+		}
+	}
+	return m, true
+}
+
+// Claims returns the membership as custom claims for FromClaims to read back
+func (m *Membership) Claims() map[string]interface{} {
+	claims := map[string]interface{}{ClaimRole: m.Role}
+	if m.UtilityID != "" {
+		claims[ClaimUtilityID] = m.UtilityID
+	}
+	if len(m.ProjectIDs) > 0 {
+		claims[ClaimProjectIDs] = m.ProjectIDs
+	}
+	return claims
+}
+
+// Resolver prefers the token's custom claims and falls back to the cached source
+type Resolver struct {
+	cache *Cache
+}
+
+func NewResolver(cache *Cache) *Resolver {
+	return &Resolver{cache: cache}
+}
+
+func (r *Resolver) Resolve(ctx context.Context, uid string, claims map[string]interface{}) (*Membership, error) {
+	if m, ok := FromClaims(claims); ok {
+		return m, nil
+	}
+	// a cache miss shows up as a child firestore span
+	ctx, span := tracing.Start(ctx, "roles.Resolve")
+	m, err := r.cache.Lookup(ctx, uid)
+	if errors.Is(err, ErrNoMembership) {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return m, err
+}
@@ -0,0 +1,139 @@
+package roles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingSource serves memberships from a map and counts the lookups
+type countingSource struct {
+	memberships map[string]*Membership
+	lookups     int
+}
+
+func (s *countingSource) Lookup(ctx context.Context, uid string) (*Membership, error) {
+	s.lookups++
+	m, ok := s.memberships[uid]
+	if !ok {
+		return nil, ErrNoMembership
+	}
+	return m, nil
+}
+
+type recordingSetter struct {
+	claims map[string]map[string]interface{}
+}
+
+func (s *recordingSetter) SetCustomUserClaims(ctx context.Context, uid string, claims map[string]interface{}) error {
+	s.claims[uid] = claims
+	return nil
+}
+
+func TestClaimsRoundTrip(t *testing.T) {
+	m := &Membership{Role: "Residential", UtilityID: "util-1", ProjectIDs: []string{"proj-1", "proj-2"}}
+
+	// claims come back from a verified token as decoded JSON
+	raw, err := json.Marshal(m.Claims())
+	require.NoError(t, err)
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &claims))
+
+	decoded, ok := FromClaims(claims)
+	require.True(t, ok)
+	assert.Equal(t, m, decoded)
+
+	_, ok = FromClaims(map[string]interface{}{"email": "user@example.com"})
+	assert.False(t, ok, "Tokens without a role claim should fall back to the source")
+}
+
+func TestResolverPrefersClaims(t *testing.T) {
+	source := &countingSource{memberships: map[string]*Membership{"user-1": {Role: "Utility", UtilityID: "util-1"}}}
+	resolver := NewResolver(NewCache(source, &config.RolesConfig{CacheTTL: time.Minute, CacheSize: 10}))
+
+	m, err := resolver.Resolve(context.Background(), "user-1", map[string]interface{}{ClaimRole: "Technician"})
+	require.NoError(t, err)
+	assert.Equal(t, "Technician", m.Role)
+	assert.Equal(t, 0, source.lookups)
+
+	m, err = resolver.Resolve(context.Background(), "user-1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Utility", m.Role)
+	assert.Equal(t, 1, source.lookups)
+}
+
+func TestCache(t *testing.T) {
+	source := &countingSource{memberships: map[string]*Membership{
+		"user-1": {Role: "Residential"},
+		"user-2": {Role: "Residential"},
+		"user-3": {Role: "Utility"},
+	}}
+	cache := NewCache(source, &config.RolesConfig{CacheTTL: time.Minute, CacheSize: 2})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := cache.Lookup(ctx, "user-1")
+	require.NoError(t, err)
+	_, err = cache.Lookup(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, source.lookups, "Second lookup should be cached")
+
+	// user-1 was used most recently, so user-2 is evicted when user-3 comes in
+	_, _ = cache.Lookup(ctx, "user-2")
+	_, _ = cache.Lookup(ctx, "user-1")
+	_, _ = cache.Lookup(ctx, "user-3")
+	assert.Equal(t, 2, cache.Len())
+	source.lookups = 0
+	_, _ = cache.Lookup(ctx, "user-1")
+	assert.Equal(t, 0, source.lookups)
+	_, _ = cache.Lookup(ctx, "user-2")
+	assert.Equal(t, 1, source.lookups, "Least recently used entry should have been evicted")
+
+	// expired entries are looked up again
+	source.lookups = 0
+	now = now.Add(2 * time.Minute)
+	_, _ = cache.Lookup(ctx, "user-2")
+	assert.Equal(t, 1, source.lookups)
+
+	cache.Invalidate("user-2")
+	_, _ = cache.Lookup(ctx, "user-2")
+	assert.Equal(t, 2, source.lookups, "Invalidated entry should be looked up again")
+
+	// missing users are not cached
+	_, err = cache.Lookup(ctx, "user-404")
+	assert.True(t, errors.Is(err, ErrNoMembership))
+	_, _ = cache.Lookup(ctx, "user-404")
+	assert.Equal(t, 4, source.lookups)
+}
+
+func TestSyncer(t *testing.T) {
+	source := &countingSource{memberships: map[string]*Membership{"user-1": {Role: "Residential"}}}
+	cache := NewCache(source, &config.RolesConfig{CacheTTL: time.Minute, CacheSize: 10})
+	setter := &recordingSetter{claims: map[string]map[string]interface{}{}}
+	syncer := NewSyncer(source, setter, cache)
+	ctx := context.Background()
+
+	_, err := cache.Lookup(ctx, "user-1")
+	require.NoError(t, err)
+
+	// the role changed in Firestore
+	source.memberships["user-1"] = &Membership{Role: "Utility", UtilityID: "util-1"}
+	m, err := syncer.Sync(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Utility", m.Role)
+	assert.Equal(t, map[string]interface{}{ClaimRole: "Utility", ClaimUtilityID: "util-1"}, setter.claims["user-1"])
+
+	cached, err := cache.Lookup(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Utility", cached.Role, "Sync should invalidate the cached role")
+
+	_, err = syncer.Sync(ctx, "user-404")
+	assert.True(t, errors.Is(err, ErrNoMembership))
+}
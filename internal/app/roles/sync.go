@@ -0,0 +1,49 @@
+package roles
+
+import (
+	"context"
+
+	"github.com/grid-stream-org/api/internal/app/tracing"
+	"github.com/pkg/errors"
+)
+
+// ClaimsSetter is the part of the Firebase auth client used to write custom claims
+type ClaimsSetter interface {
+	SetCustomUserClaims(ctx context.Context, uid string, customClaims map[string]interface{}) error
+}
+
+// Syncer copies a user's Firestore membership into their custom claims, tokens issued
+// after the sync carry the claims, tokens already issued keep the old ones until they are refreshed
+type Syncer struct {
+	source Store
+	claims ClaimsSetter
+	cache  *Cache
+}
+
+// NewSyncer reads from source directly, not through the cache, so the claims are never stale.
+// cache may be nil when nothing in the process caches memberships.
+func NewSyncer(source Store, claims ClaimsSetter, cache *Cache) *Syncer {
+	return &Syncer{source: source, claims: claims, cache: cache}
+}
+
+func (s *Syncer) Sync(ctx context.Context, uid string) (*Membership, error) {
+	m, err := s.source.Lookup(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	claimsCtx, span := tracing.Start(ctx, "firebase.auth.SetCustomUserClaims")
+	err = s.claims.SetCustomUserClaims(claimsCtx, uid, m.Claims())
+	tracing.End(span, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "setting custom claims")
+	}
+	s.Invalidate(uid)
+	return m, nil
+}
+
+// Invalidate drops the cached membership of uid so the next request without claims reads Firestore again
+func (s *Syncer) Invalidate(uid string) {
+	if s.cache != nil {
+		s.cache.Invalidate(uid)
+	}
+}
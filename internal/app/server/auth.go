@@ -0,0 +1,48 @@
+package server
+
+import (
+	"github.com/grid-stream-org/api/internal/app/identity"
+	"github.com/grid-stream-org/api/internal/app/roles"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/pkg/firebase"
+	"github.com/pkg/errors"
+)
+
+// Auth is how users are authenticated and how their roles are resolved
+type Auth struct {
+	Verifier identity.TokenVerifier
+	Resolver *roles.Resolver
+	Cache    *roles.Cache
+	Syncer   *roles.Syncer // only firebase has custom claims to sync
+}
+
+// NewAuth builds the provider selected by cfg.AuthProvider, roles come from the token's claims
+// and fall back to the provider's role store through a cache. Firebase without a client
+// leaves Verifier nil so every request with an ID token is rejected.
+func NewAuth(cfg *config.Config, fbClient firebase.FirebaseClient) (*Auth, error) {
+	switch cfg.AuthProvider {
+	case config.AuthLocal:
+		verifier, err := identity.NewLocalVerifier(cfg.LocalAuth)
+		if err != nil {
+			return nil, errors.Wrap(err, "init local token verifier")
+		}
+		store, err := identity.NewYAMLRoleStore(cfg.LocalAuth.RolesFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "init local role store")
+		}
+		cache := roles.NewCache(store, cfg.Roles)
+		return &Auth{Verifier: verifier, Resolver: roles.NewResolver(cache), Cache: cache}, nil
+	default:
+		if fbClient == nil {
+			return &Auth{}, nil
+		}
+		store := roles.NewFirestoreStore(fbClient.Firestore())
+		cache := roles.NewCache(store, cfg.Roles)
+		return &Auth{
+			Verifier: identity.NewFirebaseVerifier(fbClient.Auth()),
+			Resolver: roles.NewResolver(cache),
+			Cache:    cache,
+			Syncer:   roles.NewSyncer(store, fbClient.Auth(), cache),
+		}, nil
+	}
+}
@@ -6,68 +6,98 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/app/apikeys"
+	"github.com/grid-stream-org/api/internal/app/authz"
+	"github.com/grid-stream-org/api/internal/app/calendar"
 	"github.com/grid-stream-org/api/internal/app/handlers/v1"
+	"github.com/grid-stream-org/api/internal/app/idempotency"
+	"github.com/grid-stream-org/api/internal/app/metrics"
 	"github.com/grid-stream-org/api/internal/app/middlewares"
+	"github.com/grid-stream-org/api/internal/app/openadr"
+	"github.com/grid-stream-org/api/internal/app/openadr3"
 	"github.com/grid-stream-org/api/internal/app/repositories"
-	"github.com/grid-stream-org/api/pkg/firebase"
-	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/api/internal/custom_error"
 )
 
 func AddRoutes(
 	r *chi.Mux,
+	cfg *config.Config,
 	log *slog.Logger,
-	bqClient bqclient.BQClient,
-	fbClient firebase.FirebaseClient,
-) {
-	// initialize repositories
-	projectRepo := repositories.NewProjectRepository(bqClient, log)
-	utilRepo := repositories.NewUtilityRepository(bqClient, log)
-	contractRepo := repositories.NewContractRepository(bqClient, log)
-	derMetaRepo := repositories.NewDERMetadataRepository(bqClient, log)
-	drEventsRepo := repositories.NewDREventRepository(bqClient, log)
-	notificationRepo := repositories.NewNotificationRepository(fbClient, log)
-	projectAverageRepo := repositories.NewProjectAverageRepository(bqClient, log) 
-
-
-	// init handlers
-	projectHandlers := handlers.NewProjectHandlers(projectRepo, log)
-	utilHandlers := handlers.NewUtilityRepository(utilRepo, log)
-	contractHandlers := handlers.NewContractHandlers(contractRepo, log)
-	healthHandler := handlers.NewHealthHandler(log)
-	derHandler := handlers.NewDERMetadataHandlers(derMetaRepo, log)
-	drEventsHandler := handlers.NewDREventHandlers(drEventsRepo, log)
-	notificationHandler := handlers.NewNotificationHandler(notificationRepo, log)
-	projectAverageHandlers := handlers.NewProjectAverageHandlers(projectAverageRepo, log)
+	store *repositories.Store,
+	auth *Auth,
+	healthHandler *handlers.HealthHandler,
+	m *metrics.Metrics,
+) *openadr3.VTN {
+	// init handlers, every handler scopes what the caller can see with the policy
+	policy := authz.NewPolicy(store.Projects)
+	projectHandlers := handlers.NewProjectHandlers(store.Projects, policy, log)
+	utilHandlers := handlers.NewUtilityRepository(store.Utilities, policy, log)
+	contractHandlers := handlers.NewContractHandlers(store.Contracts, policy, log)
+	derHandler := handlers.NewDERMetadataHandlers(store.DERMetadata, policy, log)
+	// backend services call with API keys instead of ID tokens
+	keys := apikeys.NewService(store.APIKeys, cfg.APIKeys, log)
+	// the OpenADR 3.0 VTN calls back its subscribers when events change through the API
+	vtn3 := openadr3.New(store, policy, auth.Resolver, keys, cfg.OpenADR3, log)
+	drEventsHandler := handlers.NewDREventHandlers(store.DREvents, cfg.DREvents, policy, vtn3, log)
+	notificationHandler := handlers.NewNotificationHandler(store.Notifications, policy, log)
+	projectAverageHandlers := handlers.NewProjectAverageHandlers(store.ProjectAverages, policy, log)
+	vtn := openadr.New(store, policy, cfg.OpenADR, cfg.DREvents.OptOutCutoff, log)
+
+	// calendar apps fetch feeds with a signed token in the url instead of an ID token
+	feedTokens := calendar.NewTokens(cfg.Calendar, store.CalendarFeeds)
+	calendarHandlers := handlers.NewCalendarHandlers(store.DREvents, feedTokens, cfg.Calendar, policy, log)
+
+	roleHandlers := handlers.NewRoleHandlers(auth.Syncer, auth.Cache, log)
+
+	apiKeyHandlers := handlers.NewAPIKeyHandlers(keys, log)
 
 	// init middlewares
-	authMiddleware := middlewares.NewAuthMiddleware(fbClient, log)
-	r.Use(middlewares.PerClientRateLimiter)
-	r.Use(middlewares.BlockSuspiciousRequests)
+	authMiddleware := middlewares.NewAuthMiddleware(auth.Verifier, auth.Resolver, keys, m, log)
+	// retried POSTs replay the first response, after auth since keys are scoped to the caller
+	idem := idempotency.New(store.Idempotency, cfg.Idempotency, log).Handler
+	r.Use(m.Middleware) // outermost so rate limited and blocked requests are counted too
+	r.Use(middlewares.PerClientRateLimiter(m))
+	r.Use(middlewares.BlockSuspiciousRequests(m))
 
 	// explicitely set 404 not found, no redirects, to stop the random bots in its tracks right away
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, "Not Found", http.StatusNotFound)
+		custom_error.Write(w, r, http.StatusNotFound, custom_error.CodeRouteNotFound, "Not Found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		custom_error.Write(w, r, http.StatusMethodNotAllowed, custom_error.CodeMethodNotAllowed, "Method Not Allowed")
 	})
 
 	// Health check route
 	r.Get("/health", middlewares.WrapHandler(healthHandler.HealthCheckHandler, log))
+	r.Get("/livez", middlewares.WrapHandler(healthHandler.LivezHandler, log))
+	r.Get("/readyz", middlewares.WrapHandler(healthHandler.ReadyzHandler, log))
+	if m != nil {
+		r.Handle(cfg.Metrics.Path, m.Handler())
+	}
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Route("/projects", func(r chi.Router) {
-			// GET and PUT: only need "Residential"
+			// every role lists only the projects it may see
+			r.With(authMiddleware.RequireAuth).Get("/", middlewares.WrapHandler(projectHandlers.ListProjectsHandler, log))
+
+			// GET and PUT: only need "Residential", technicians reassign projects
 			r.With(authMiddleware.RequireAuth).Get("/{id}", middlewares.WrapHandler(projectHandlers.GetProjectHandler, log))
-			r.With(authMiddleware.RequireRole("Residential")).Put("/{id}", middlewares.WrapHandler(projectHandlers.UpdateProjectHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Technician")).Put("/{id}", middlewares.WrapHandler(projectHandlers.UpdateProjectHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Technician")).Patch("/{id}", middlewares.WrapHandler(projectHandlers.PatchProjectHandler, log))
 
 			// POST and DELETE: only "Utility"
-			r.With(authMiddleware.RequireRole("Technician")).Post("/", middlewares.WrapHandler(projectHandlers.CreateProjectHandler, log))
+			r.With(authMiddleware.RequireRole("Technician"), idem).Post("/", middlewares.WrapHandler(projectHandlers.CreateProjectHandler, log))
 			r.With(authMiddleware.RequireRole("Technician")).Delete("/{id}", middlewares.WrapHandler(projectHandlers.DeleteProjectHandler, log))
 
 		})
 
 		r.Route("/utilities", func(r chi.Router) {
+			r.With(authMiddleware.RequireAuth).Get("/", middlewares.WrapHandler(utilHandlers.ListUtilitiesHandler, log))
 			r.With(authMiddleware.RequireRole("Technician", "Residential")).Get("/{id}", middlewares.WrapHandler(utilHandlers.GetUtilityHandler, log))
-			r.With(authMiddleware.RequireRole("Technician")).Post("/", middlewares.WrapHandler(utilHandlers.CreateUtilityHandler, log))
+			r.With(authMiddleware.RequireRole("Technician"), idem).Post("/", middlewares.WrapHandler(utilHandlers.CreateUtilityHandler, log))
 			r.With(authMiddleware.RequireRole("Utility")).Put("/{id}", middlewares.WrapHandler(utilHandlers.UpdateUtilityHandler, log))
+			r.With(authMiddleware.RequireRole("Utility")).Patch("/{id}", middlewares.WrapHandler(utilHandlers.PatchUtilityHandler, log))
 			r.With(authMiddleware.RequireRole("Utility")).Delete("/{id}", middlewares.WrapHandler(utilHandlers.DeleteUtilityHandler, log))
 			r.With(authMiddleware.RequireRole("Utility")).Get("/project-summary", middlewares.WrapHandler(utilHandlers.GetProjectSummaryHandler, log))
 		})
@@ -76,17 +106,19 @@ func AddRoutes(
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Get("/{id}", middlewares.WrapHandler(contractHandlers.GetContractHandler, log))
 			r.With(authMiddleware.RequireRole("Utility", "Residential")).Get("/project/{projectId}", middlewares.WrapHandler(contractHandlers.GetContractsByProjectIDHandler, log))
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Put("/{id}", middlewares.WrapHandler(contractHandlers.UpdateContractHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Utility")).Patch("/{id}", middlewares.WrapHandler(contractHandlers.PatchContractHandler, log))
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Delete("/{id}", middlewares.WrapHandler(contractHandlers.DeleteContractHandler, log))
-			r.With(authMiddleware.RequireRole("Residential", "Utility")).Post("/", middlewares.WrapHandler(contractHandlers.CreateContractHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Utility"), idem).Post("/", middlewares.WrapHandler(contractHandlers.CreateContractHandler, log))
 		})
 
 		r.Route("/der-metadata", func(r chi.Router) {
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Get("/{id}", middlewares.WrapHandler(derHandler.GetDERMetadataHandler, log))
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Get("/", middlewares.WrapHandler(derHandler.ListDERMetadataByProjectHandler, log))
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Put("/{id}", middlewares.WrapHandler(derHandler.UpdateDERMetadataHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Utility")).Patch("/{id}", middlewares.WrapHandler(derHandler.PatchDERMetadataHandler, log))
 			r.With(authMiddleware.RequireRole("Residential", "Utility")).Delete("/{id}", middlewares.WrapHandler(derHandler.DeleteDERMetadataHandler, log))
-			r.With(authMiddleware.RequireRole("Residential", "Utility")).Post("/", middlewares.WrapHandler(derHandler.CreateDERMetadataHandler, log))
-			r.With(authMiddleware.RequireRole("Residential", "Utility")).Post("/batch", middlewares.WrapHandler(derHandler.BatchCreateDERMetadataHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Utility"), idem).Post("/", middlewares.WrapHandler(derHandler.CreateDERMetadataHandler, log))
+			r.With(authMiddleware.RequireRole("Residential", "Utility"), idem).Post("/batch", middlewares.WrapHandler(derHandler.BatchCreateDERMetadataHandler, log))
 		})
 
 		r.Route("/dr-events", func(r chi.Router) {
@@ -94,19 +126,90 @@ func AddRoutes(
 			r.With(authMiddleware.RequireRole("Utility")).Get("/utility/{utilityID}", middlewares.WrapHandler(drEventsHandler.GetDREventsByUtilityIDHandler, log))
 			r.With(authMiddleware.RequireRole("Utility")).Get("/{id}", middlewares.WrapHandler(drEventsHandler.GetDREventHandler, log))
 			r.With(authMiddleware.RequireRole("Utility")).Put("/{id}", middlewares.WrapHandler(drEventsHandler.UpdateDREventHandler, log))
-			r.With(authMiddleware.RequireRole("Utility")).Post("/", middlewares.WrapHandler(drEventsHandler.CreateDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility")).Patch("/{id}", middlewares.WrapHandler(drEventsHandler.PatchDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility"), idem).Post("/", middlewares.WrapHandler(drEventsHandler.CreateDREventHandler, log))
 			r.With(authMiddleware.RequireRole("Utility")).Delete("/{id}", middlewares.WrapHandler(drEventsHandler.DeleteDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility"), idem).Post("/{id}/dispatch", middlewares.WrapHandler(drEventsHandler.DispatchDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility"), idem).Post("/{id}/activate", middlewares.WrapHandler(drEventsHandler.ActivateDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility"), idem).Post("/{id}/complete", middlewares.WrapHandler(drEventsHandler.CompleteDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility"), idem).Post("/{id}/cancel", middlewares.WrapHandler(drEventsHandler.CancelDREventHandler, log))
+			r.With(authMiddleware.RequireRole("Utility")).Get("/{id}/participants", middlewares.WrapHandler(drEventsHandler.GetDREventParticipantsHandler, log))
+			r.With(authMiddleware.RequireRole("Utility"), idem).Post("/{id}/participants", middlewares.WrapHandler(drEventsHandler.EnrollDREventParticipantsHandler, log))
+			r.With(authMiddleware.RequireRole("Utility", "Residential"), idem).Post("/{id}/participants/{projectID}/opt-out", middlewares.WrapHandler(drEventsHandler.OptOutDREventHandler, log))
+		})
+
+		// like api keys, issuing a feed token doesn't take an Idempotency-Key
+		r.Route("/calendar", func(r chi.Router) {
+			r.With(authMiddleware.RequireAuth).Post("/feeds", middlewares.WrapHandler(calendarHandlers.CreateFeedTokenHandler, log))
+			r.With(authMiddleware.RequireAuth).Delete("/feeds", middlewares.WrapHandler(calendarHandlers.RevokeFeedTokensHandler, log))
 		})
 
 		r.Route("/notifications", func(r chi.Router) {
-			r.Use(authMiddleware.RequireAuth)
-			r.Post("/", middlewares.WrapHandler(notificationHandler.NotifyUserHandler, log))
+			r.Use(authMiddleware.RequireRoleOrService(apikeys.ScopeNotificationsWrite))
+			r.With(idem).Post("/", middlewares.WrapHandler(notificationHandler.NotifyUserHandler, log))
+		})
+
+		// technician only, kept out of an /admin prefix since BlockSuspiciousRequests rejects it.
+		// Creating and rotating keys don't take an Idempotency-Key, replaying them would mean storing the secret.
+		r.Route("/api-keys", func(r chi.Router) {
+			r.Use(authMiddleware.RequireRole("Technician"))
+			r.Post("/", middlewares.WrapHandler(apiKeyHandlers.CreateAPIKeyHandler, log))
+			r.Get("/", middlewares.WrapHandler(apiKeyHandlers.ListAPIKeysHandler, log))
+			r.Post("/{id}/rotate", middlewares.WrapHandler(apiKeyHandlers.RotateAPIKeyHandler, log))
+			r.Delete("/{id}", middlewares.WrapHandler(apiKeyHandlers.RevokeAPIKeyHandler, log))
+		})
+
+		r.Route("/users/{uid}", func(r chi.Router) {
+			r.Use(authMiddleware.RequireRole("Technician"))
+			r.With(idem).Post("/claims/sync", middlewares.WrapHandler(roleHandlers.SyncClaimsHandler, log))
+			r.Delete("/role-cache", middlewares.WrapHandler(roleHandlers.InvalidateRoleHandler, log))
 		})
 
 		r.Route("/project-averages", func(r chi.Router) {
-			r.With(authMiddleware.RequireRole("Residential", "Utility")).Post("/", middlewares.WrapHandler(projectAverageHandlers.CreateProjectAverageHandler, log))
-			r.With(authMiddleware.RequireRole("Residential", "Utility")).Get("/", middlewares.WrapHandler(projectAverageHandlers.GetProjectAveragesHandler, log))
+			r.With(authMiddleware.RequireRoleOrService(apikeys.ScopeProjectAveragesWrite, "Residential", "Utility"), idem).Post("/", middlewares.WrapHandler(projectAverageHandlers.CreateProjectAverageHandler, log))
+			r.With(authMiddleware.RequireRoleOrService(apikeys.ScopeProjectAveragesRead, "Residential", "Utility")).Get("/", middlewares.WrapHandler(projectAverageHandlers.GetProjectAveragesHandler, log))
+		})
+	})
+
+	// iCalendar feeds of DR events, subscribed to as /calendar/...?token=<feed token>
+	r.Route("/calendar", func(r chi.Router) {
+		r.Use(authMiddleware.RequireFeedToken(feedTokens))
+		r.Get("/projects/{projectID}/events.ics", middlewares.WrapHandler(calendarHandlers.ProjectFeedHandler, log))
+		r.Get("/utilities/{utilityID}/events.ics", middlewares.WrapHandler(calendarHandlers.UtilityFeedHandler, log))
+	})
+
+	// OpenADR 2.0b simple HTTP profile, gateways call with a key, technicians may commission one with their token
+	r.Route("/OpenADR2/Simple/2.0b", func(r chi.Router) {
+		r.Use(authMiddleware.RequireRoleOrService(apikeys.ScopeOpenADRVEN, "Technician"))
+		r.Post("/EiRegisterParty", middlewares.WrapHandler(vtn.RegisterPartyHandler, log))
+		r.Post("/EiEvent", middlewares.WrapHandler(vtn.EventHandler, log))
+		r.Post("/EiReport", middlewares.WrapHandler(vtn.ReportHandler, log))
+		r.Post("/OadrPoll", middlewares.WrapHandler(vtn.PollHandler, log))
+	})
+
+	// OpenADR 3.0, VENs send their key as a bearer token and users their ID token. Programs and events follow the
+	// utilities and DR events so they are read only, writing them is answered 405 by the router.
+	r.Route("/openadr3", func(r chi.Router) {
+		r.Use(authMiddleware.RequireRoleOrService(apikeys.ScopeOpenADRVEN))
+		r.Get("/programs", middlewares.WrapHandler(vtn3.ListProgramsHandler, log))
+		r.Get("/programs/{programID}", middlewares.WrapHandler(vtn3.GetProgramHandler, log))
+		r.Get("/events", middlewares.WrapHandler(vtn3.ListEventsHandler, log))
+		r.Get("/events/{eventID}", middlewares.WrapHandler(vtn3.GetEventHandler, log))
+		r.Route("/reports", func(r chi.Router) {
+			r.Get("/", middlewares.WrapHandler(vtn3.ListReportsHandler, log))
+			r.Post("/", middlewares.WrapHandler(vtn3.CreateReportHandler, log))
+			r.Get("/{reportID}", middlewares.WrapHandler(vtn3.GetReportHandler, log))
+			r.Put("/{reportID}", middlewares.WrapHandler(vtn3.UpdateReportHandler, log))
+			r.Delete("/{reportID}", middlewares.WrapHandler(vtn3.DeleteReportHandler, log))
+		})
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.Get("/", middlewares.WrapHandler(vtn3.ListSubscriptionsHandler, log))
+			r.Post("/", middlewares.WrapHandler(vtn3.CreateSubscriptionHandler, log))
+			r.Get("/{subscriptionID}", middlewares.WrapHandler(vtn3.GetSubscriptionHandler, log))
+			r.Put("/{subscriptionID}", middlewares.WrapHandler(vtn3.UpdateSubscriptionHandler, log))
+			r.Delete("/{subscriptionID}", middlewares.WrapHandler(vtn3.DeleteSubscriptionHandler, log))
 		})
 	})
 
+	return vtn3
 }
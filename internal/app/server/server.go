@@ -1,49 +1,95 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
-	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/grid-stream-org/api/internal/app/handlers/v1"
+	"github.com/grid-stream-org/api/internal/app/health"
+	"github.com/grid-stream-org/api/internal/app/logging"
+	"github.com/grid-stream-org/api/internal/app/metrics"
+	"github.com/grid-stream-org/api/internal/app/openadr3"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/api/internal/app/tracing"
 	"github.com/grid-stream-org/api/internal/config"
 	"github.com/grid-stream-org/api/pkg/firebase"
-	"github.com/grid-stream-org/go-commons/pkg/bqclient"
 )
 
+// Server is the API's HTTP handler, it also reports when the API is draining and waits on the work requests
+// left running in the background
+type Server struct {
+	http.Handler
+	health *handlers.HealthHandler
+	vtn3   *openadr3.VTN
+}
+
 // NewServer sets up and returns an HTTP server
 func NewServer(
 	cfg *config.Config,
-	bqclient bqclient.BQClient,
+	store *repositories.Store,
 	fbclient firebase.FirebaseClient,
+	checker *health.Checker,
+	m *metrics.Metrics,
 	log *slog.Logger,
-) http.Handler {
+) (*Server, error) {
 	r := chi.NewRouter()
 
-	addMidleware(r, cfg)
-	AddRoutes(r, log, bqclient, fbclient)
+	auth, err := NewAuth(cfg, fbclient)
+	if err != nil {
+		return nil, err
+	}
+	healthHandler := handlers.NewHealthHandler(checker, log)
+
+	addMidleware(r, cfg, log)
+	vtn3 := AddRoutes(r, cfg, log, store, auth, healthHandler, m)
+
+	return &Server{Handler: r, health: healthHandler, vtn3: vtn3}, nil
 
-	return r
+}
 
+// Drain fails the health and readiness checks so load balancers stop sending new requests
+func (s *Server) Drain() {
+	s.health.SetDraining(true)
+}
+
+// Wait blocks until the OpenADR 3.0 subscribers the requests served so far started calling back were called, or ctx
+// is done
+func (s *Server) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.vtn3.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func addMidleware(
 	r *chi.Mux,
 	cfg *config.Config,
+	log *slog.Logger,
 ) {
 	// TODO should be configured with conf maybe
 	corsOptions := cors.Options{
 		AllowedOrigins:   cfg.AllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "Traceparent", "Tracestate"},
+		ExposedHeaders:   []string{"ETag", "Idempotent-Replayed", "Link", "Location", tracing.TraceIDHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}
 
+	r.Use(tracing.Middleware) // first so the request's span covers everything below
 	r.Use(cors.Handler(corsOptions))
-	r.Use(middleware.RequestID) // generate unique request id for each request
-	r.Use(middleware.Logger)    // midleware logger to log incoming requests
-	r.Use(middleware.Recoverer) // prevents server from crashing and responds with 500 error
+	r.Use(middleware.RequestID)    // generate unique request id for each request
+	r.Use(logging.Middleware(log)) // one structured line per request, and a request scoped logger in the context
+	r.Use(logging.Recoverer(log))  // prevents server from crashing and responds with 500 error
 }
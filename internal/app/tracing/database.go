@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/callsite"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var dbSystemBigQuery = semconv.DBSystemKey.String("bigquery")
+
+// startQuery starts a client span named after the repository method on the stack, finding it
+// is only worth it when the span is recorded
+func startQuery(ctx context.Context, system attribute.KeyValue, operation string) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(system, semconv.DBOperationName(operation)),
+	)
+	if span.IsRecording() {
+		repository, method := callsite.Repository()
+		span.SetName(repository + "." + method)
+		span.SetAttributes(attribute.String("repository", repository), attribute.String("repository.method", method))
+	}
+	return ctx, span
+}
+
+// InstrumentBigQuery wraps client so every call gets a span under the repository method that made it
+func InstrumentBigQuery(client bqclient.BQClient) bqclient.BQClient {
+	return &bigQueryClient{BQClient: client}
+}
+
+type bigQueryClient struct {
+	bqclient.BQClient
+}
+
+func (c *bigQueryClient) Query(ctx context.Context, query string, params []bigquery.QueryParameter) (it *bigquery.RowIterator, err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "Query")
+	defer func() { End(span, err) }()
+	// only the query text, parameter values stay out of traces
+	span.SetAttributes(semconv.DBQueryText(query))
+	return c.BQClient.Query(ctx, query, params)
+}
+
+func (c *bigQueryClient) QueryRow(ctx context.Context, query string, params []bigquery.QueryParameter, dst any) (err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "QueryRow")
+	defer func() { End(span, err) }()
+	span.SetAttributes(semconv.DBQueryText(query))
+	return c.BQClient.QueryRow(ctx, query, params, dst)
+}
+
+func (c *bigQueryClient) Put(ctx context.Context, table string, data any) (err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "Put")
+	defer func() { End(span, err) }()
+	return c.BQClient.Put(ctx, table, data)
+}
+
+func (c *bigQueryClient) StreamPut(ctx context.Context, table string, data any) (err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "StreamPut")
+	defer func() { End(span, err) }()
+	return c.BQClient.StreamPut(ctx, table, data)
+}
+
+func (c *bigQueryClient) StreamPutAll(ctx context.Context, inputs map[string][]any) (err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "StreamPutAll")
+	defer func() { End(span, err) }()
+	return c.BQClient.StreamPutAll(ctx, inputs)
+}
+
+func (c *bigQueryClient) Update(ctx context.Context, table string, id string, updates map[string]any) (err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "Update")
+	defer func() { End(span, err) }()
+	return c.BQClient.Update(ctx, table, id, updates)
+}
+
+func (c *bigQueryClient) Delete(ctx context.Context, table string, id string) (err error) {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "Delete")
+	defer func() { End(span, err) }()
+	return c.BQClient.Delete(ctx, table, id)
+}
+
+func (c *bigQueryClient) Get(ctx context.Context, table string, id string, dst any) error {
+	ctx, span := startQuery(ctx, dbSystemBigQuery, "Get")
+	err := c.BQClient.Get(ctx, table, id, dst)
+	if err == bqclient.ErrNotFound {
+		// not found is an answer, not a failed call
+		span.SetAttributes(attribute.Bool("not_found", true))
+		End(span, nil)
+		return err
+	}
+	End(span, err)
+	return err
+}
+
+// PGXTracer gives every postgres query a span under the repository method that made it
+type PGXTracer struct{}
+
+var _ pgx.QueryTracer = PGXTracer{}
+
+type pgxSpanKey struct{}
+
+func (PGXTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, span := startQuery(ctx, semconv.DBSystemPostgreSQL, "Query")
+	span.SetAttributes(semconv.DBQueryText(data.SQL))
+	return context.WithValue(ctx, pgxSpanKey{}, span)
+}
+
+func (PGXTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(pgxSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	err := data.Err
+	if err == pgx.ErrNoRows {
+		err = nil
+	}
+	End(span, err)
+}
@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logHandler adds the trace and span id of the context to every record logged with one
+type logHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h so InfoContext, ErrorContext and friends carry trace_id and span_id
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return logHandler{Handler: h}
+}
+
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-chi/chi/v5"
+	"github.com/grid-stream-org/api/internal/config"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBigQuery struct {
+	bqclient.BQClient
+}
+
+func (f *fakeBigQuery) Query(ctx context.Context, query string, params []bigquery.QueryParameter) (*bigquery.RowIterator, error) {
+	return nil, errors.New("boom")
+}
+
+// widgetRepository stands in for a repository, its span is named after the method
+type widgetRepository struct {
+	client bqclient.BQClient
+}
+
+func (r *widgetRepository) GetWidget(ctx context.Context) error {
+	_, err := r.client.Query(ctx, "SELECT 1", nil)
+	return err
+}
+
+type exportedSpan struct {
+	Name        string
+	SpanContext struct{ TraceID, SpanID string }
+	Parent      struct{ TraceID, SpanID string }
+	Status      struct{ Code string }
+}
+
+func TestFileExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), &config.TracingConfig{Exporter: config.TracingStdout, File: file, ServiceName: "test", SampleRatio: 1})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	log := slog.New(NewLogHandler(slog.NewJSONHandler(&logs, nil)))
+	repo := &widgetRepository{client: InstrumentBigQuery(&fakeBigQuery{})}
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/v1/widgets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(r.Context(), "firestore.users.get")
+		End(span, nil)
+		if err := repo.GetWidget(ctx); err != nil {
+			log.ErrorContext(r.Context(), "Handler error", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/v1/widgets/w-1", nil)
+	req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, traceID, rec.Header().Get(TraceIDHeader), "Incoming trace should be continued")
+
+	require.NoError(t, shutdown(context.Background()))
+
+	raw, err := os.ReadFile(file)
+	require.NoError(t, err)
+	spans := map[string]exportedSpan{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		var span exportedSpan
+		if err := dec.Decode(&span); err == io.EOF {
+			break
+		} else {
+			require.NoError(t, err)
+		}
+		spans[span.Name] = span
+	}
+
+	server, ok := spans["GET /v1/widgets/{id}"]
+	require.True(t, ok, "Server span should be named after the route pattern: %v", spans)
+	assert.Equal(t, traceID, server.SpanContext.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID)
+	assert.Equal(t, "Error", server.Status.Code)
+
+	query, ok := spans["widgetRepository.GetWidget"]
+	require.True(t, ok, "Query span should be named after the repository method: %v", spans)
+	assert.Equal(t, "Error", query.Status.Code)
+	assert.Equal(t, server.SpanContext.SpanID, spans["firestore.users.get"].Parent.SpanID)
+
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, traceID, line["trace_id"])
+	assert.Equal(t, server.SpanContext.SpanID, line["span_id"])
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, (&config.TracingConfig{Exporter: config.TracingNone, SampleRatio: 1}).Validate())
+	assert.NoError(t, (&config.TracingConfig{Exporter: config.TracingOTLP, SampleRatio: 0.1}).Validate())
+	assert.Error(t, (&config.TracingConfig{Exporter: "jaeger", SampleRatio: 1}).Validate())
+	assert.Error(t, (&config.TracingConfig{Exporter: config.TracingStdout, SampleRatio: 2}).Validate())
+}
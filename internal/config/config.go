@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/grid-stream-org/api/pkg/firebase"
 	"github.com/grid-stream-org/go-commons/pkg/bqclient"
@@ -12,10 +13,174 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StorageBigQuery = "bigquery"
+	StorageMemory   = "memory"
+	StoragePostgres = "postgres"
+)
+
+// Auth providers selectable with AUTH_PROVIDER
+const (
+	AuthFirebase = "firebase"
+	AuthLocal    = "local"
+)
+
+// Tracing exporters selectable with TRACING_EXPORTER
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp" // endpoint and headers come from the standard OTEL_EXPORTER_OTLP_* variables
+)
+
+// ServerConfig is the http.Server's limits and how it shuts down
+type ServerConfig struct {
+	ReadTimeout       time.Duration `envconfig:"SERVER_READ_TIMEOUT" default:"15s"`
+	ReadHeaderTimeout time.Duration `envconfig:"SERVER_READ_HEADER_TIMEOUT" default:"5s"`
+	WriteTimeout      time.Duration `envconfig:"SERVER_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout       time.Duration `envconfig:"SERVER_IDLE_TIMEOUT" default:"120s"`
+	MaxHeaderBytes    int           `envconfig:"SERVER_MAX_HEADER_BYTES" default:"1048576"`
+	DrainDelay        time.Duration `envconfig:"SERVER_DRAIN_DELAY" default:"5s"`       // health check fails this long before the listener closes
+	ShutdownTimeout   time.Duration `envconfig:"SERVER_SHUTDOWN_TIMEOUT" default:"20s"` // deadline for in-flight requests to finish
+}
+
+// PostgresConfig is the database of the postgres storage backend
+type PostgresConfig struct {
+	URL      string `envconfig:"URL"`
+	MaxConns int32  `envconfig:"MAX_CONNS" default:"10"`
+}
+
+func (c *PostgresConfig) Validate() error {
+	if c == nil || c.URL == "" {
+		return errors.New("postgres url required")
+	}
+	return nil
+}
+
+type RolesConfig struct {
+	CacheTTL  time.Duration `envconfig:"ROLE_CACHE_TTL" default:"5m"`
+	CacheSize int           `envconfig:"ROLE_CACHE_SIZE" default:"10000"`
+}
+
+type APIKeysConfig struct {
+	Expiry time.Duration `envconfig:"API_KEY_EXPIRY" default:"2160h"` // lifetime of new and rotated keys, 90 days
+	// CacheTTL is how long a checked key is trusted before it is read again. Revoking or rotating a key drops it
+	// from the cache of the instance that handled it, other instances keep accepting it for up to CacheTTL.
+	CacheTTL time.Duration `envconfig:"API_KEY_CACHE_TTL" default:"10s"`
+}
+
+// LocalAuthConfig configures the local provider, tokens are signed either with an HS256 secret or RS256
+// keys from a JWKS file, roles come from a YAML file
+type LocalAuthConfig struct {
+	HS256Secret string `envconfig:"AUTH_LOCAL_HS256_SECRET"`
+	JWKSFile    string `envconfig:"AUTH_LOCAL_JWKS_FILE"`
+	Issuer      string `envconfig:"AUTH_LOCAL_ISSUER"`
+	Audience    string `envconfig:"AUTH_LOCAL_AUDIENCE"`
+	RolesFile   string `envconfig:"AUTH_LOCAL_ROLES_FILE"`
+}
+
+func (c *LocalAuthConfig) Validate() error {
+	if c.HS256Secret == "" && c.JWKSFile == "" {
+		return errors.New("local auth requires AUTH_LOCAL_HS256_SECRET or AUTH_LOCAL_JWKS_FILE")
+	}
+	if c.RolesFile == "" {
+		return errors.New("local auth requires AUTH_LOCAL_ROLES_FILE")
+	}
+	return nil
+}
+
+// HealthConfig bounds the dependency probes of the health checks
+type HealthConfig struct {
+	Timeout  time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"` // per dependency
+	CacheTTL time.Duration `envconfig:"HEALTH_CACHE_TTL" default:"10s"`    // how long a report is served before probing again
+}
+
+type MetricsConfig struct {
+	Enabled bool   `envconfig:"METRICS_ENABLED" default:"false"`
+	Path    string `envconfig:"METRICS_PATH" default:"/metrics"`
+	// bytes processed isn't returned with query results, looking it up costs a jobs.get call per query
+	BigQueryBytes bool `envconfig:"METRICS_BIGQUERY_BYTES" default:"true"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `envconfig:"TRACING_EXPORTER" default:"none"`
+	File        string  `envconfig:"TRACING_FILE"` // the stdout exporter writes here instead, one JSON span per line
+	ServiceName string  `envconfig:"TRACING_SERVICE_NAME" default:"gridstream-api"`
+	SampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"` // of traces started here, incoming sampling decisions are kept
+}
+
+func (c *TracingConfig) Validate() error {
+	switch c.Exporter {
+	case TracingNone, TracingStdout, TracingOTLP:
+	default:
+		return fmt.Errorf("unknown tracing exporter: %s", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample ratio must be between 0 and 1: %v", c.SampleRatio)
+	}
+	return nil
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration `envconfig:"IDEMPOTENCY_TTL" default:"24h"` // how long a response is replayed to retries
+}
+
+// DREventsConfig is how participants may change their enrollment
+type DREventsConfig struct {
+	OptOutCutoff time.Duration `envconfig:"DR_OPT_OUT_CUTOFF" default:"2h"` // how long before the start of an event opting out closes
+}
+
+// OpenADRConfig is the OpenADR 2.0b VTN
+type OpenADRConfig struct {
+	VTNID         string        `envconfig:"OPENADR_VTN_ID" default:"grid-stream"`
+	PollFrequency time.Duration `envconfig:"OPENADR_POLL_FREQUENCY" default:"10s"`                      // how often VENs are asked to poll
+	NearWindow    time.Duration `envconfig:"OPENADR_NEAR_WINDOW" default:"1h"`                          // scheduled events starting this soon are near rather than far
+	MarketContext string        `envconfig:"OPENADR_MARKET_CONTEXT" default:"urn:grid-stream:utility:"` // prefix of the event's utility id
+}
+
+// OpenADR3Config is the OpenADR 3.0 VTN and how it calls subscribers back
+type OpenADR3Config struct {
+	CallbackTimeout    time.Duration `envconfig:"OPENADR3_CALLBACK_TIMEOUT" default:"10s"`
+	AllowHTTPCallbacks bool          `envconfig:"OPENADR3_ALLOW_HTTP_CALLBACKS" default:"false"` // plain http callback urls, for local development only
+	// callbacks to loopback and private addresses, for local development only
+	AllowPrivateCallbacks bool `envconfig:"OPENADR3_ALLOW_PRIVATE_CALLBACKS" default:"false"`
+}
+
+// CalendarConfig is how calendar feed tokens are signed and what the feeds hold
+type CalendarConfig struct {
+	Secret   string        `envconfig:"CALENDAR_FEED_SECRET"`                    // signs feed tokens, feeds are off without one. Changing it revokes every token
+	TokenTTL time.Duration `envconfig:"CALENDAR_FEED_TOKEN_TTL" default:"8760h"` // lifetime of a feed token, a year
+	Past     time.Duration `envconfig:"CALENDAR_FEED_PAST" default:"720h"`       // how long ended events stay in a feed
+}
+
+func (c *CalendarConfig) Validate() error {
+	if c.Secret != "" && len(c.Secret) < 32 {
+		return errors.New("CALENDAR_FEED_SECRET must be at least 32 bytes")
+	}
+	return nil
+}
+
 type Config struct {
-	Port           int      `envconfig:"PORT"`
-	AllowedOrigins []string `envconfig:"ALLOWED_ORIGINS"`
+	Port           int               `envconfig:"PORT"`
+	AllowedOrigins []string          `envconfig:"ALLOWED_ORIGINS"`
+	StorageBackend string            `envconfig:"STORAGE_BACKEND" default:"bigquery"`
+	AuthProvider   string            `envconfig:"AUTH_PROVIDER" default:"firebase"`
+	SchemaCheck    bool              `envconfig:"SCHEMA_CHECK" default:"true"` // verify the bigquery dataset matches the models on startup
+	TableOverrides map[string]string `envconfig:"TABLE_OVERRIDES"`             // table:name pairs, name can be table, dataset.table or project.dataset.table
+	Server         *ServerConfig
+	Health         *HealthConfig
+	Metrics        *MetricsConfig
+	Tracing        *TracingConfig
 	Database       *bqclient.Config
+	Postgres       *PostgresConfig
+	LocalAuth      *LocalAuthConfig
+	Roles          *RolesConfig
+	APIKeys        *APIKeysConfig
+	Idempotency    *IdempotencyConfig
+	DREvents       *DREventsConfig
+	OpenADR        *OpenADRConfig
+	OpenADR3       *OpenADR3Config
+	Calendar       *CalendarConfig
 	Logger         *logger.Config
 	Firebase       *firebase.FirebaseConfig
 }
@@ -36,9 +201,37 @@ func Load() (*Config, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	switch cfg.StorageBackend {
+	case StorageBigQuery, StorageMemory:
+	case StoragePostgres:
+		if err := cfg.Postgres.Validate(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	default:
+		return nil, errors.WithStack(fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend))
+	}
+
+	switch cfg.AuthProvider {
+	case AuthFirebase:
+	case AuthLocal:
+		if err := cfg.LocalAuth.Validate(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	default:
+		return nil, errors.WithStack(fmt.Errorf("unknown auth provider: %s", cfg.AuthProvider))
+	}
+
+	if err := cfg.Tracing.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := cfg.Calendar.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	// Ensure Firebase credentials file exists
-	// also bypass this check if we are running unit tests
-	if os.Getenv("TEST_ENV") != "true" {
+	// also bypass this check if we are running unit tests, or running in memory without firebase configured
+	skipFirebase := cfg.StorageBackend == StorageMemory && cfg.Firebase.GoogleCredential == ""
+	if os.Getenv("TEST_ENV") != "true" && !skipFirebase {
 		if _, err := os.Stat(cfg.Firebase.GoogleCredential); os.IsNotExist(err) {
 			return nil, errors.WithStack(fmt.Errorf("firebase credentials file not found: %s", cfg.Firebase.GoogleCredential))
 		}
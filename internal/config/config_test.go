@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -27,3 +28,109 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, "test-project-id", cfg.Firebase.ProjectID, "Firebase ProjectID should match")
 	assert.Equal(t, "/path/to/credential.json", cfg.Firebase.GoogleCredential, "Firebase GoogleCredential should match")
 }
+
+func TestLoadConfigStorageBackend(t *testing.T) {
+	t.Setenv("TEST_ENV", "true")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, StorageBigQuery, cfg.StorageBackend, "Storage backend should default to bigquery")
+
+	t.Setenv("STORAGE_BACKEND", "memory")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, StorageMemory, cfg.StorageBackend)
+
+	t.Setenv("STORAGE_BACKEND", "postgres")
+	_, err = Load()
+	assert.Error(t, err, "Postgres backend should require a url")
+
+	t.Setenv("POSTGRES_URL", "postgres://localhost:5432/gridstream")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://localhost:5432/gridstream", cfg.Postgres.URL)
+	assert.Equal(t, int32(10), cfg.Postgres.MaxConns, "Postgres max conns should default to 10")
+
+	t.Setenv("STORAGE_BACKEND", "postgres-ish")
+	_, err = Load()
+	assert.Error(t, err, "Unknown storage backends should be rejected")
+}
+
+func TestLoadConfigTableOverrides(t *testing.T) {
+	t.Setenv("TEST_ENV", "true")
+	t.Setenv("TABLE_OVERRIDES", "contracts:demo.contracts,dr_events:events")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"contracts": "demo.contracts", "dr_events": "events"}, cfg.TableOverrides)
+}
+
+func TestLoadConfigRoles(t *testing.T) {
+	t.Setenv("TEST_ENV", "true")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cfg.Roles.CacheTTL, "Role cache ttl should default to 5 minutes")
+	assert.Equal(t, 10000, cfg.Roles.CacheSize)
+
+	t.Setenv("ROLE_CACHE_TTL", "30s")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Roles.CacheTTL)
+}
+
+func TestLoadConfigAuthProvider(t *testing.T) {
+	t.Setenv("TEST_ENV", "true")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "firebase", cfg.AuthProvider, "Auth provider should default to firebase")
+
+	t.Setenv("AUTH_PROVIDER", "local")
+	_, err = Load()
+	assert.Error(t, err, "Local auth should require a key and a roles file")
+
+	t.Setenv("AUTH_LOCAL_HS256_SECRET", "secret")
+	t.Setenv("AUTH_LOCAL_ROLES_FILE", "roles.yaml")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", cfg.LocalAuth.HS256Secret)
+	assert.Equal(t, "roles.yaml", cfg.LocalAuth.RolesFile)
+
+	t.Setenv("AUTH_PROVIDER", "okta")
+	_, err = Load()
+	assert.Error(t, err, "Unknown auth providers should be rejected")
+}
+
+func TestLoadConfigServer(t *testing.T) {
+	t.Setenv("TEST_ENV", "true")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 30*time.Second, cfg.Server.WriteTimeout)
+	assert.Equal(t, 1<<20, cfg.Server.MaxHeaderBytes)
+	assert.Equal(t, 20*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, 2*time.Second, cfg.Health.Timeout)
+	assert.Equal(t, 10*time.Second, cfg.Health.CacheTTL)
+	assert.False(t, cfg.Metrics.Enabled, "Metrics should be off unless enabled")
+
+	t.Setenv("SERVER_SHUTDOWN_TIMEOUT", "45s")
+	t.Setenv("SERVER_DRAIN_DELAY", "0s")
+	cfg, err = Load()
+	assert.NoError(t, err)
+	assert.Equal(t, 45*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, time.Duration(0), cfg.Server.DrainDelay)
+}
+
+func TestLoadConfigTracing(t *testing.T) {
+	t.Setenv("TEST_ENV", "true")
+
+	cfg, err := Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "none", cfg.Tracing.Exporter, "Tracing should be off by default")
+
+	t.Setenv("TRACING_EXPORTER", "zipkin")
+	_, err = Load()
+	assert.Error(t, err, "Unknown exporters should be rejected")
+}
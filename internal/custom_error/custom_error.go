@@ -2,20 +2,80 @@ package custom_error
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/pkg/errors"
 )
 
-type CustomError struct {
-	Code    int    `json:"code"`
+// Stable error codes sent to clients in the problem's "code" member, one per failure type.
+// Clients branch on these, never on the message, so they must not change once released.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeInvalidPayload     = "invalid_payload"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeMissingCredentials = "missing_credentials"
+	CodeInvalidToken       = "invalid_token"
+	CodeInvalidAPIKey      = "invalid_api_key"
+	CodeForbidden          = "forbidden"
+	CodeNoRole             = "no_role"
+	CodeInsufficientRole   = "insufficient_role"
+	CodeInsufficientScope  = "insufficient_scope"
+	CodeBlocked            = "blocked"
+	CodeNotFound           = "not_found"
+	CodeRouteNotFound      = "route_not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodeInvalidTransition  = "invalid_transition"
+	CodeOptOutClosed       = "opt_out_closed"
+	CodePreconditionFailed = "precondition_failed"
+	CodeInvalidIdempotency = "invalid_idempotency_key"
+	CodeIdempotencyReused  = "idempotency_key_reused"
+	CodeIdempotencyBusy    = "idempotency_key_in_progress"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+	CodeUnavailable        = "service_unavailable"
+)
+
+// FieldError is a problem with one field of the request, Field is the JSON name of the field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
-	Err     error  `json:"err"`
+}
+
+// CustomError is returned by handlers and rendered by middlewares.WrapHandler. Message is what the client sees,
+// Err is for our logs only and is never sent back.
+type CustomError struct {
+	Code      int          `json:"code"`
+	Message   string       `json:"message"`
+	Err       error        `json:"-"`
+	ErrorCode string       `json:"error_code,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
 }
 
 func (e *CustomError) Error() string {
 	return fmt.Sprintf("Code: %d, Message: %s, Error: %s", e.Code, e.Message, errors.WithStack(e.Err))
 }
 
+// Unwrap lets errors.Is and errors.As see the wrapped error
+func (e *CustomError) Unwrap() error {
+	return e.Err
+}
+
+// WithCode sets the stable error code, without one the code is derived from the status
+func (e *CustomError) WithCode(code string) *CustomError {
+	e.ErrorCode = code
+	return e
+}
+
+// WithFields attaches field level errors
+func (e *CustomError) WithFields(fields ...FieldError) *CustomError {
+	e.Fields = append(e.Fields, fields...)
+	return e
+}
+
 func New(code int, message string, err error) *CustomError {
     if err == nil {
         err = errors.New(message)
@@ -26,3 +86,13 @@ func New(code int, message string, err error) *CustomError {
 		Err:     err,
 	}
 }
+
+// InvalidPayload is the error for a request body that can't be decoded
+func InvalidPayload(err error) *CustomError {
+	return New(http.StatusBadRequest, "Invalid request payload", err).WithCode(CodeInvalidPayload)
+}
+
+// Validation is the error for a request that decoded fine but has invalid fields
+func Validation(fields ...FieldError) *CustomError {
+	return New(http.StatusBadRequest, "Request validation failed", nil).WithCode(CodeValidationFailed).WithFields(fields...)
+}
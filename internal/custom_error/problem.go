@@ -0,0 +1,107 @@
+package custom_error
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProblemContentType is the media type of every error response, see RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix makes the stable code a URI for the problem's "type" member
+const problemTypePrefix = "urn:gridstream:problem:"
+
+// Problem is the RFC 7807 body of an error response, with the stable code, field errors and the ids
+// needed to find the request in our logs and traces as extension members
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+}
+
+// CodeForStatus is the stable code of an error that didn't set one
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// NewProblem builds the problem for err, anything that isn't a CustomError is an internal error.
+// Only the message of a CustomError makes it into the problem, the wrapped error stays on the server.
+func NewProblem(r *http.Request, err error) *Problem {
+	var customErr *CustomError
+	if !errors.As(err, &customErr) {
+		customErr = New(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+	status := customErr.Code
+	if status < http.StatusBadRequest || status > 599 {
+		status = http.StatusInternalServerError
+	}
+	code := customErr.ErrorCode
+	if code == "" {
+		code = CodeForStatus(status)
+	}
+	p := &Problem{
+		Type:     problemTypePrefix + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   customErr.Message,
+		Instance: r.URL.Path,
+		Code:     code,
+		Errors:   customErr.Fields,
+	}
+	p.RequestID = middleware.GetReqID(r.Context())
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		p.TraceID = sc.TraceID().String()
+	}
+	return p
+}
+
+// WriteProblem renders err as application/problem+json
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := NewProblem(r, err)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	// the status is out, nothing left to do if the body can't be written
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Write renders an error built from status, code and message, for middlewares that fail a request
+// before it reaches a handler
+func Write(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	WriteProblem(w, r, New(status, message, nil).WithCode(code))
+}
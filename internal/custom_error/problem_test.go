@@ -0,0 +1,65 @@
+package custom_error
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"custom error", New(http.StatusNotFound, "Project not found", errors.New("bigquery: row missing in projects")), http.StatusNotFound, CodeNotFound, "Project not found"},
+		{"explicit code", InvalidPayload(errors.New("json: cannot unmarshal string")), http.StatusBadRequest, CodeInvalidPayload, "Invalid request payload"},
+		{"wrapped custom error", errors.Join(New(http.StatusConflict, "Already exists", nil)), http.StatusConflict, CodeConflict, "Already exists"},
+		{"plain error", errors.New("pq: password authentication failed"), http.StatusInternalServerError, CodeInternal, "Internal Server Error"},
+		{"bad status", New(0, "Broken", nil), http.StatusInternalServerError, CodeInternal, "Broken"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteProblem(rec, httptest.NewRequest(http.MethodGet, "/v1/projects/p-1", nil), tc.err)
+
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+			var p Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, tc.status, p.Status)
+			assert.Equal(t, tc.code, p.Code)
+			assert.Equal(t, tc.detail, p.Detail)
+			assert.Equal(t, "/v1/projects/p-1", p.Instance)
+
+			// the wrapped error never reaches the client
+			assert.NotContains(t, rec.Body.String(), "bigquery")
+			assert.NotContains(t, rec.Body.String(), "json:")
+			assert.NotContains(t, rec.Body.String(), "password")
+		})
+	}
+}
+
+func TestValidationProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := Validation(
+		FieldError{Field: "end_time", Code: "after", Message: "must be after start_time"},
+		FieldError{Field: "status", Code: "oneof", Message: "must be one of active, inactive, pending"},
+	)
+	WriteProblem(rec, httptest.NewRequest(http.MethodPost, "/v1/dr-events", nil), err)
+
+	var p Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, CodeValidationFailed, p.Code)
+	require.Len(t, p.Errors, 2)
+	assert.Equal(t, "end_time", p.Errors[0].Field)
+	assert.Equal(t, "oneof", p.Errors[1].Code)
+}
@@ -0,0 +1,250 @@
+// Package migrate owns the DDL of the BigQuery dataset. Migrations are embedded sql files named
+// <version>_<name>.up.sql / .down.sql, applied versions are recorded in the schema_migrations table.
+// BigQuery can't run DDL inside a transaction, so a failed migration may be partially applied and is
+// written to be re-runnable (CREATE TABLE IF NOT EXISTS, ADD COLUMN IF NOT EXISTS, ...).
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/grid-stream-org/go-commons/pkg/bqclient"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// Load reads the embedded migrations ordered by version
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		body, err := files.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has mismatched names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Migrator struct {
+	client bqclient.BQClient
+	tables *repositories.TableResolver
+	log    *slog.Logger
+}
+
+// New creates a migrator, tables are resolved the same way the repositories resolve them
+func New(client bqclient.BQClient, tables *repositories.TableResolver, log *slog.Logger) *Migrator {
+	return &Migrator{client: client, tables: tables, log: log}
+}
+
+// Render fills in the {{table "name"}} references of a migration script
+func (m *Migrator) Render(script string) (string, error) {
+	return m.tables.Render(script)
+}
+
+func (m *Migrator) exec(ctx context.Context, script string, params []bigquery.QueryParameter) error {
+	query, err := m.Render(script)
+	if err != nil {
+		return err
+	}
+	it, err := m.client.Query(ctx, query, params)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// drain so errors from later statements of a script surface
+	for {
+		var row []bigquery.Value
+		err := it.Next(&row)
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	return m.exec(ctx, `
+        CREATE TABLE IF NOT EXISTS {{table "schema_migrations"}} (
+            version INT64 NOT NULL,
+            name STRING NOT NULL,
+            applied_at TIMESTAMP NOT NULL
+        )`, nil)
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	query, err := m.Render(`SELECT version, applied_at FROM {{table "schema_migrations"}}`)
+	if err != nil {
+		return nil, err
+	}
+	it, err := m.client.Query(ctx, query, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading applied migrations")
+	}
+
+	applied := map[int]time.Time{}
+	for {
+		var row struct {
+			Version   int64     `bigquery:"version"`
+			AppliedAt time.Time `bigquery:"applied_at"`
+		}
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading applied migrations")
+		}
+		applied[int(row.Version)] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, mig := range migrations {
+		at, ok := applied[mig.Version]
+		statuses = append(statuses, Status{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order, returning how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i, mig := range migrations {
+		if statuses[i].Applied {
+			continue
+		}
+		if err := m.exec(ctx, mig.Up, nil); err != nil {
+			return count, errors.Wrapf(err, "applying migration %d_%s", mig.Version, mig.Name)
+		}
+		err := m.exec(ctx, `
+            INSERT INTO {{table "schema_migrations"}} (version, name, applied_at)
+            VALUES (@version, @name, CURRENT_TIMESTAMP())`,
+			[]bigquery.QueryParameter{
+				{Name: "version", Value: mig.Version},
+				{Name: "name", Value: mig.Name},
+			})
+		if err != nil {
+			return count, errors.Wrapf(err, "recording migration %d_%s", mig.Version, mig.Name)
+		}
+		m.log.Info("applied migration", "version", mig.Version, "name", mig.Name)
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the last steps applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := migrations[i]
+		if !statuses[i].Applied {
+			continue
+		}
+		if err := m.exec(ctx, mig.Down, nil); err != nil {
+			return count, errors.Wrapf(err, "reverting migration %d_%s", mig.Version, mig.Name)
+		}
+		err := m.exec(ctx, `DELETE FROM {{table "schema_migrations"}} WHERE version = @version`,
+			[]bigquery.QueryParameter{{Name: "version", Value: mig.Version}})
+		if err != nil {
+			return count, errors.Wrapf(err, "unrecording migration %d_%s", mig.Version, mig.Name)
+		}
+		m.log.Info("reverted migration", "version", mig.Version, "name", mig.Name)
+		count++
+	}
+	return count, nil
+}
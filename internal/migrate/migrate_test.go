@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/grid-stream-org/api/internal/app/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	createTable = regexp.MustCompile(`(?is)CREATE TABLE IF NOT EXISTS\s+` + "`" + `test\.(\w+)` + "`" + `\s*\((.*?)\);`)
+	addColumn   = regexp.MustCompile(`(?i)ALTER TABLE\s+` + "`" + `test\.(\w+)` + "`" + `\s+ADD COLUMN IF NOT EXISTS\s+(\w+)\s+(\w+)`)
+	dropColumn  = regexp.MustCompile(`(?i)ALTER TABLE\s+` + "`" + `test\.(\w+)` + "`" + `\s+DROP COLUMN IF EXISTS\s+(\w+)`)
+	dropTable   = regexp.MustCompile(`(?i)DROP TABLE IF EXISTS\s+` + "`" + `test\.(\w+)` + "`")
+)
+
+// apply is just enough of a DDL parser to replay the migrations onto a Schema
+func apply(schema Schema, script string) {
+	for _, m := range createTable.FindAllStringSubmatch(script, -1) {
+		if _, ok := schema[m[1]]; ok {
+			continue
+		}
+		columns := map[string]string{}
+		for _, def := range strings.Split(m[2], ",") {
+			fields := strings.Fields(def)
+			if len(fields) >= 2 {
+				columns[fields[0]] = strings.ToUpper(fields[1])
+			}
+		}
+		schema[m[1]] = columns
+	}
+	for _, m := range addColumn.FindAllStringSubmatch(script, -1) {
+		schema[m[1]][m[2]] = strings.ToUpper(m[3])
+	}
+	for _, m := range dropColumn.FindAllStringSubmatch(script, -1) {
+		delete(schema[m[1]], m[2])
+	}
+	for _, m := range dropTable.FindAllStringSubmatch(script, -1) {
+		delete(schema, m[1])
+	}
+}
+
+func TestLoadOrdersMigrations(t *testing.T) {
+	migrations, err := Load()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+	}
+}
+
+// the migrations own the DDL, so replaying them must give the schema the models expect,
+// and reverting them all must leave nothing behind
+func TestMigrationsMatchModels(t *testing.T) {
+	migrations, err := Load()
+	require.NoError(t, err)
+	tables, err := repositories.NewTableResolver("test", nil)
+	require.NoError(t, err)
+	m := New(nil, tables, nil)
+
+	schema := Schema{}
+	for _, mig := range migrations {
+		up, err := m.Render(mig.Up)
+		require.NoError(t, err)
+		apply(schema, up)
+	}
+
+	expected, err := ExpectedSchema()
+	require.NoError(t, err)
+	assert.Empty(t, Diff(expected, schema))
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		down, err := m.Render(migrations[i].Down)
+		require.NoError(t, err)
+		apply(schema, down)
+	}
+	assert.Empty(t, schema)
+}
+
+func TestDiff(t *testing.T) {
+	expected := Schema{
+		"projects":  {"id": "STRING", "location": "STRING"},
+		"dr_events": {"id": "STRING", "start_time": "TIMESTAMP"},
+		"contracts": {"id": "STRING"},
+	}
+	actual := Schema{
+		"projects":  {"id": "STRING", "extra": "INT64"},
+		"dr_events": {"id": "STRING", "start_time": "DATETIME"},
+	}
+
+	assert.Equal(t, []string{
+		"contracts: table missing",
+		"dr_events.start_time: expected TIMESTAMP, found DATETIME",
+		"projects.location: column missing, expected STRING",
+	}, Diff(expected, actual))
+
+	assert.Empty(t, Diff(expected, expected))
+}
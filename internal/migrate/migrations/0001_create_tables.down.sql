@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS {{table "project_averages"}};
+DROP TABLE IF EXISTS {{table "dr_events"}};
+DROP TABLE IF EXISTS {{table "der_metadata"}};
+DROP TABLE IF EXISTS {{table "contracts"}};
+DROP TABLE IF EXISTS {{table "projects"}};
+DROP TABLE IF EXISTS {{table "utilities"}};
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS {{table "utilities"}} (
+    id STRING NOT NULL,
+    display_name STRING
+);
+
+CREATE TABLE IF NOT EXISTS {{table "projects"}} (
+    id STRING NOT NULL,
+    utility_id STRING,
+    user_id STRING,
+    location STRING
+);
+
+CREATE TABLE IF NOT EXISTS {{table "contracts"}} (
+    id STRING NOT NULL,
+    contract_threshold FLOAT64,
+    start_date DATE,
+    end_date DATE,
+    status STRING,
+    project_id STRING
+);
+
+CREATE TABLE IF NOT EXISTS {{table "der_metadata"}} (
+    id STRING NOT NULL,
+    project_id STRING,
+    type STRING,
+    nameplate_capacity FLOAT64,
+    power_capacity FLOAT64
+);
+
+CREATE TABLE IF NOT EXISTS {{table "dr_events"}} (
+    id STRING NOT NULL,
+    utility_id STRING,
+    start_time TIMESTAMP,
+    end_time TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {{table "project_averages"}} (
+    project_id STRING NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP,
+    baseline FLOAT64,
+    contract_threshold FLOAT64,
+    average_output FLOAT64
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS {{table "api_keys"}};
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS {{table "api_keys"}} (
+    id STRING NOT NULL,
+    name STRING,
+    prefix STRING,
+    key_hash STRING NOT NULL,
+    scopes ARRAY<STRING>,
+    utility_ids ARRAY<STRING>,
+    created_by STRING,
+    created_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
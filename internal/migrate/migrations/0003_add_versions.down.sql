@@ -0,0 +1,5 @@
+ALTER TABLE {{table "projects"}} DROP COLUMN IF EXISTS version;
+ALTER TABLE {{table "utilities"}} DROP COLUMN IF EXISTS version;
+ALTER TABLE {{table "contracts"}} DROP COLUMN IF EXISTS version;
+ALTER TABLE {{table "der_metadata"}} DROP COLUMN IF EXISTS version;
+ALTER TABLE {{table "dr_events"}} DROP COLUMN IF EXISTS version;
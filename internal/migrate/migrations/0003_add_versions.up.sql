@@ -0,0 +1,12 @@
+ALTER TABLE {{table "projects"}} ADD COLUMN IF NOT EXISTS version INT64;
+ALTER TABLE {{table "utilities"}} ADD COLUMN IF NOT EXISTS version INT64;
+ALTER TABLE {{table "contracts"}} ADD COLUMN IF NOT EXISTS version INT64;
+ALTER TABLE {{table "der_metadata"}} ADD COLUMN IF NOT EXISTS version INT64;
+ALTER TABLE {{table "dr_events"}} ADD COLUMN IF NOT EXISTS version INT64;
+
+-- rows written before versioning start at 1, like every new row
+UPDATE {{table "projects"}} SET version = 1 WHERE version IS NULL;
+UPDATE {{table "utilities"}} SET version = 1 WHERE version IS NULL;
+UPDATE {{table "contracts"}} SET version = 1 WHERE version IS NULL;
+UPDATE {{table "der_metadata"}} SET version = 1 WHERE version IS NULL;
+UPDATE {{table "dr_events"}} SET version = 1 WHERE version IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS {{table "idempotency_keys"}};
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS {{table "idempotency_keys"}} (
+    scope STRING NOT NULL,
+    idempotency_key STRING NOT NULL,
+    request_hash STRING NOT NULL,
+    status INT64,
+    content_type STRING,
+    location STRING,
+    body STRING,
+    resource_id STRING,
+    created_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE {{table "dr_events"}} DROP COLUMN IF EXISTS status;
+ALTER TABLE {{table "dr_events"}} DROP COLUMN IF EXISTS scheduled_at;
+ALTER TABLE {{table "dr_events"}} DROP COLUMN IF EXISTS activated_at;
+ALTER TABLE {{table "dr_events"}} DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE {{table "dr_events"}} DROP COLUMN IF EXISTS cancelled_at;
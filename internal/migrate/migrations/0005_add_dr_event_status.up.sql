@@ -0,0 +1,8 @@
+ALTER TABLE {{table "dr_events"}} ADD COLUMN IF NOT EXISTS status STRING;
+ALTER TABLE {{table "dr_events"}} ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP;
+ALTER TABLE {{table "dr_events"}} ADD COLUMN IF NOT EXISTS activated_at TIMESTAMP;
+ALTER TABLE {{table "dr_events"}} ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+ALTER TABLE {{table "dr_events"}} ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+
+-- events created before the lifecycle were live as soon as they were created
+UPDATE {{table "dr_events"}} SET status = 'scheduled' WHERE status IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS {{table "dr_event_participants"}};
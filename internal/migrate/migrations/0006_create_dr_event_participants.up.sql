@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS {{table "dr_event_participants"}} (
+    event_id STRING NOT NULL,
+    project_id STRING NOT NULL,
+    status STRING,
+    enrolled_at TIMESTAMP,
+    opted_out_at TIMESTAMP
+);
+
+-- events created before enrollment went to every project of their utility
+INSERT INTO {{table "dr_event_participants"}} (event_id, project_id, status, enrolled_at)
+SELECT e.id, p.id, 'enrolled', CURRENT_TIMESTAMP()
+FROM {{table "dr_events"}} e
+JOIN {{table "projects"}} p ON p.utility_id = e.utility_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM {{table "dr_event_participants"}} pa
+    WHERE pa.event_id = e.id AND pa.project_id = p.id
+);
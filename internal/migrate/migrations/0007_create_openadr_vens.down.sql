@@ -0,0 +1,3 @@
+ALTER TABLE {{table "dr_event_participants"}} DROP COLUMN IF EXISTS ven_opt;
+ALTER TABLE {{table "dr_event_participants"}} DROP COLUMN IF EXISTS ven_responded_at;
+DROP TABLE IF EXISTS {{table "openadr_vens"}};
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS {{table "openadr_vens"}} (
+    id STRING NOT NULL,
+    registration_id STRING NOT NULL,
+    project_id STRING NOT NULL,
+    name STRING,
+    registered_at TIMESTAMP,
+    events_digest STRING
+);
+
+ALTER TABLE {{table "dr_event_participants"}} ADD COLUMN IF NOT EXISTS ven_opt STRING;
+ALTER TABLE {{table "dr_event_participants"}} ADD COLUMN IF NOT EXISTS ven_responded_at TIMESTAMP;
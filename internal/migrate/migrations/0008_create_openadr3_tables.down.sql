@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS {{table "openadr_subscriptions"}};
+DROP TABLE IF EXISTS {{table "openadr_reports"}};
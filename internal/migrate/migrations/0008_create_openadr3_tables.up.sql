@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS {{table "openadr_reports"}} (
+    id STRING NOT NULL,
+    utility_id STRING NOT NULL,
+    program_id STRING NOT NULL,
+    event_id STRING NOT NULL,
+    client_name STRING NOT NULL,
+    report_name STRING,
+    created_by STRING NOT NULL,
+    body STRING,
+    created_at TIMESTAMP,
+    modified_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS {{table "openadr_subscriptions"}} (
+    id STRING NOT NULL,
+    program_id STRING NOT NULL,
+    client_name STRING NOT NULL,
+    created_by STRING NOT NULL,
+    body STRING,
+    created_at TIMESTAMP,
+    modified_at TIMESTAMP
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS {{table "calendar_feeds"}} (
+    user_id STRING NOT NULL,
+    generation INT64 NOT NULL,
+    revoked_at TIMESTAMP
+);
@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/models"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+)
+
+// Schema maps table name to column name to BigQuery data type
+type Schema map[string]map[string]string
+
+// tableModels are the tables the repositories read and write and the models they are loaded into.
+// computed lists bigquery tags that only exist in query results, such as columns from joins.
+var tableModels = []struct {
+	table    string
+	model    any
+	computed []string
+}{
+	{table: "projects", model: models.Project{}},
+	{table: "utilities", model: models.Utility{}},
+	{table: "contracts", model: models.Contract{}},
+	{table: "der_metadata", model: models.DERMetadata{}},
+	{table: "dr_events", model: models.DREvents{}, computed: []string{"utility_name", "participation"}},
+	{table: "dr_event_participants", model: models.DREventParticipant{}},
+	{table: "openadr_vens", model: models.VEN{}},
+	{table: "openadr_reports", model: models.OpenADRReport{}},
+	{table: "openadr_subscriptions", model: models.OpenADRSubscription{}},
+	{table: "project_averages", model: models.ProjectAverage{}},
+	{table: "api_keys", model: models.APIKey{}},
+	{table: "idempotency_keys", model: models.IdempotencyRecord{}},
+	{table: "calendar_feeds", model: models.CalendarFeed{}},
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	nullDateType      = reflect.TypeOf(bigquery.NullDate{})
+	nullTimestampType = reflect.TypeOf(bigquery.NullTimestamp{})
+)
+
+func bigqueryType(t reflect.Type) (string, error) {
+	switch t {
+	case timeType, nullTimestampType:
+		return "TIMESTAMP", nil
+	case nullDateType:
+		return "DATE", nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "STRING", nil
+	case reflect.Float32, reflect.Float64:
+		return "FLOAT64", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "INT64", nil
+	case reflect.Bool:
+		return "BOOL", nil
+	case reflect.Slice:
+		elem, err := bigqueryType(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "ARRAY<" + elem + ">", nil
+	}
+	return "", fmt.Errorf("no bigquery type for %s", t)
+}
+
+// ExpectedSchema derives the columns every table needs from the bigquery tags of the models
+func ExpectedSchema() (Schema, error) {
+	schema := Schema{}
+	for _, tm := range tableModels {
+		computed := map[string]bool{}
+		for _, c := range tm.computed {
+			computed[c] = true
+		}
+
+		columns := map[string]string{}
+		t := reflect.TypeOf(tm.model)
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Tag.Get("bigquery")
+			if name == "" || name == "-" || computed[name] {
+				continue
+			}
+			typ, err := bigqueryType(t.Field(i).Type)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s.%s", tm.table, name)
+			}
+			columns[name] = typ
+		}
+		schema[tm.table] = columns
+	}
+	return schema, nil
+}
+
+// Diff lists what actual is missing or has typed differently from expected, extra tables and columns are allowed
+func Diff(expected, actual Schema) []string {
+	var diffs []string
+	for table, columns := range expected {
+		live, ok := actual[table]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: table missing", table))
+			continue
+		}
+		for column, typ := range columns {
+			liveType, ok := live[column]
+			switch {
+			case !ok:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: column missing, expected %s", table, column, typ))
+			case liveType != typ:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: expected %s, found %s", table, column, typ, liveType))
+			}
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// LiveSchema reads the columns of the model tables from INFORMATION_SCHEMA, once per dataset
+// since overrides can move tables, keyed by logical table name
+func (m *Migrator) LiveSchema(ctx context.Context) (Schema, error) {
+	// dataset -> table name -> logical table name
+	datasets := map[string]map[string]string{}
+	for _, tm := range tableModels {
+		dataset, name := m.tables.Path(tm.table)
+		if datasets[dataset] == nil {
+			datasets[dataset] = map[string]string{}
+		}
+		datasets[dataset][name] = tm.table
+	}
+
+	schema := Schema{}
+	for dataset, names := range datasets {
+		tables := make([]string, 0, len(names))
+		for name := range names {
+			tables = append(tables, name)
+		}
+
+		query := fmt.Sprintf(`
+            SELECT table_name, column_name, data_type
+            FROM `+"`%s`"+`.INFORMATION_SCHEMA.COLUMNS
+            WHERE table_name IN UNNEST(@tables)`, dataset)
+		it, err := m.client.Query(ctx, query, []bigquery.QueryParameter{{Name: "tables", Value: tables}})
+		if err != nil {
+			return nil, errors.Wrap(err, "reading live schema")
+		}
+
+		for {
+			var row struct {
+				Table    string `bigquery:"table_name"`
+				Column   string `bigquery:"column_name"`
+				DataType string `bigquery:"data_type"`
+			}
+			err := it.Next(&row)
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "reading live schema")
+			}
+			table := names[row.Table]
+			if schema[table] == nil {
+				schema[table] = map[string]string{}
+			}
+			schema[table][row.Column] = row.DataType
+		}
+	}
+	return schema, nil
+}
+
+// Verify fails with the full diff when the live dataset doesn't have what the models expect
+func (m *Migrator) Verify(ctx context.Context) error {
+	expected, err := ExpectedSchema()
+	if err != nil {
+		return err
+	}
+	actual, err := m.LiveSchema(ctx)
+	if err != nil {
+		return err
+	}
+	if diffs := Diff(expected, actual); len(diffs) > 0 {
+		return fmt.Errorf("schema does not match the models, run the migrations:\n  %s", strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// APIKey is a credential for a backend service, only the hash of the secret is stored
+type APIKey struct {
+	ID         string                 `json:"id" bigquery:"id"`
+	Name       string                 `json:"name" bigquery:"name"`
+	Prefix     string                 `json:"prefix" bigquery:"prefix"` // start of the secret, to tell keys apart in logs and listings
+	KeyHash    string                 `json:"-" bigquery:"key_hash"`
+	Scopes     []string               `json:"scopes" bigquery:"scopes"`           // routes the key may call, e.g. notifications:write
+	UtilityIDs []string               `json:"utility_ids" bigquery:"utility_ids"` // utilities the key may act for, * for all
+	CreatedBy  string                 `json:"created_by" bigquery:"created_by"`
+	CreatedAt  time.Time              `json:"created_at" bigquery:"created_at"`
+	ExpiresAt  time.Time              `json:"expires_at" bigquery:"expires_at"`
+	LastUsedAt bigquery.NullTimestamp `json:"last_used_at" bigquery:"last_used_at"`
+	RevokedAt  bigquery.NullTimestamp `json:"revoked_at" bigquery:"revoked_at"`
+}
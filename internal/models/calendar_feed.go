@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// CalendarFeed holds a user's feed token generation. Tokens are signed with the generation they were issued at and
+// stop working once it's bumped, users without a row are at generation 0.
+type CalendarFeed struct {
+	UserID     string    `json:"user_id" bigquery:"user_id"`
+	Generation int64     `json:"generation" bigquery:"generation"`
+	RevokedAt  time.Time `json:"revoked_at" bigquery:"revoked_at"`
+}
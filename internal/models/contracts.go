@@ -10,6 +10,7 @@ type Contract struct {
 	EndDate           bigquery.NullDate `json:"end_date" bigquery:"end_date"`
 	Status            ContractStatus    `json:"status" bigquery:"status"`
 	ProjectID         string            `json:"project_id" bigquery:"project_id"`
+	Version           int64             `json:"version" bigquery:"version"` // bumped by every update, the ETag of the contract
 }
 
 const (
@@ -26,3 +27,40 @@ func (s ContractStatus) IsValid() bool {
 		return false
 	}
 }
+
+// Validate checks a new contract, every field but the id is required
+func (c *Contract) Validate() error {
+	v := &validator{}
+	v.positive(c.ContractThreshold, "contract_threshold")
+	v.requiredString(c.ProjectID, "project_id")
+	v.required(c.Status != "", "status")
+	v.required(c.StartDate.Valid, "start_date")
+	v.required(c.EndDate.Valid, "end_date")
+	c.validateFields(v)
+	return v.err()
+}
+
+// MutableFields are the fields PUT and PATCH may change, the project is fixed on create
+func (c *Contract) MutableFields() []string {
+	return []string{"contract_threshold", "start_date", "end_date", "status"}
+}
+
+// ValidateUpdate checks a contract after an update, the threshold may drop to zero and the dates may be cleared
+func (c *Contract) ValidateUpdate() error {
+	v := &validator{}
+	v.nonNegative(c.ContractThreshold, "contract_threshold")
+	v.required(c.Status != "", "status")
+	c.validateFields(v)
+	return v.err()
+}
+
+func (c *Contract) validateFields(v *validator) {
+	if c.Status != "" {
+		v.check(c.Status.IsValid(), "status", RuleOneOf, "must be one of active, inactive, pending")
+	}
+	v.contractDate(c.StartDate, "start_date")
+	v.contractDate(c.EndDate, "end_date")
+	if c.StartDate.Valid && c.EndDate.Valid {
+		v.check(c.EndDate.Date.After(c.StartDate.Date), "end_date", RuleAfter, "must be after start_date")
+	}
+}
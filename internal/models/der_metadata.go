@@ -7,6 +7,7 @@ type DERMetadata struct {
 	Type              DERType `json:"type" bigquery:"type"`
 	NameplateCapacity float64 `json:"nameplate_capacity" bigquery:"nameplate_capacity"`
 	PowerCapacity     float64 `json:"power_capacity" bigquery:"power_capacity"`
+	Version           int64   `json:"version" bigquery:"version"` // bumped by every update, the ETag of the DER
 }
 
 const (
@@ -23,4 +24,36 @@ func (t DERType) IsValid() bool {
 	default:
 		return false
 	}
-}
\ No newline at end of file
+}
+
+// Validate checks a new DER, power capacity can be entered later as it changes
+func (d *DERMetadata) Validate() error {
+	v := &validator{}
+	v.requiredString(d.ID, "id")
+	v.requiredString(d.ProjectID, "project_id")
+	v.required(d.Type != "", "type")
+	v.positive(d.NameplateCapacity, "nameplate_capacity")
+	d.validateFields(v)
+	return v.err()
+}
+
+// MutableFields are the fields PUT and PATCH may change, the id and project are fixed on create
+func (d *DERMetadata) MutableFields() []string {
+	return []string{"type", "nameplate_capacity", "power_capacity"}
+}
+
+// ValidateUpdate checks a DER after an update
+func (d *DERMetadata) ValidateUpdate() error {
+	v := &validator{}
+	v.required(d.Type != "", "type")
+	v.positive(d.NameplateCapacity, "nameplate_capacity")
+	d.validateFields(v)
+	return v.err()
+}
+
+func (d *DERMetadata) validateFields(v *validator) {
+	if d.Type != "" {
+		v.check(d.Type.IsValid(), "type", RuleOneOf, "must be one of solar, battery, ev")
+	}
+	v.nonNegative(d.PowerCapacity, "power_capacity")
+}
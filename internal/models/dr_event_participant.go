@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+// ParticipantStatus is whether a project takes part in a DR event it was enrolled in
+type ParticipantStatus string
+
+const (
+	ParticipantEnrolled ParticipantStatus = "enrolled"
+	ParticipantOptedOut ParticipantStatus = "opted_out"
+)
+
+// maxTargetProjects bounds the explicit project list of a ParticipantTarget, larger groups should use the filters
+const maxTargetProjects = 1000
+
+// DREventParticipant is a project enrolled in a DR event
+type DREventParticipant struct {
+	EventID    string                 `json:"event_id" bigquery:"event_id"`
+	ProjectID  string                 `json:"project_id" bigquery:"project_id"`
+	Status     ParticipantStatus      `json:"status" bigquery:"status"`
+	EnrolledAt time.Time              `json:"enrolled_at" bigquery:"enrolled_at"`
+	OptedOutAt bigquery.NullTimestamp `json:"opted_out_at" bigquery:"opted_out_at"`
+	// VENOpt is the last answer of the project's OpenADR gateway, empty until it answers
+	VENOpt         VENOpt                 `json:"ven_opt,omitempty" bigquery:"ven_opt"`
+	VENRespondedAt bigquery.NullTimestamp `json:"ven_responded_at" bigquery:"ven_responded_at"`
+}
+
+// ParticipantTarget selects the projects of an event's utility to enroll, either the listed projects or every
+// project matching all of the set filters
+type ParticipantTarget struct {
+	ProjectIDs     []string `json:"project_ids"`
+	ActiveContract bool     `json:"active_contract"` // projects with an active contract
+	DERType        DERType  `json:"der_type"`        // projects with a DER of this type
+	// MinCapacity is the nameplate capacity the project's DERs (of DERType when set) must add up to
+	MinCapacity float64 `json:"min_capacity"`
+}
+
+// Validate checks the target selects projects one way or the other
+func (t *ParticipantTarget) Validate() error {
+	v := &validator{}
+	filtered := t.ActiveContract || t.DERType != "" || t.MinCapacity != 0
+	switch {
+	case len(t.ProjectIDs) > 0 && filtered:
+		v.add("project_ids", RuleOneOf, "must not be combined with active_contract, der_type or min_capacity")
+	case len(t.ProjectIDs) == 0 && !filtered:
+		v.add("project_ids", RuleRequired, "is required unless active_contract, der_type or min_capacity is set")
+	}
+	v.check(len(t.ProjectIDs) <= maxTargetProjects, "project_ids", RuleMax, "must list at most 1000 projects")
+	for _, id := range t.ProjectIDs {
+		v.requiredString(id, "project_ids")
+	}
+	if t.DERType != "" {
+		v.check(t.DERType.IsValid(), "der_type", RuleOneOf, "must be one of solar, battery, ev")
+	}
+	v.nonNegative(t.MinCapacity, "min_capacity")
+	return v.err()
+}
+
+// CheckOptOut rejects opting out of an event that has started or starts within cutoff of now
+func (e *DREvents) CheckOptOut(now time.Time, cutoff time.Duration) error {
+	deadline := e.StartTime.Add(-cutoff)
+	if e.CheckEditable() != nil || !now.Before(deadline) {
+		return custom_error.New(http.StatusConflict, fmt.Sprintf("Opting out of this event closed at %s", deadline.UTC().Format(time.RFC3339)), nil).
+			WithCode(custom_error.CodeOptOutClosed)
+	}
+	return nil
+}
@@ -1,13 +1,149 @@
 package models
 
 import (
+	"fmt"
+	"net/http"
 	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+// DREventStatus is where an event is in its lifecycle, it only changes through a DREventAction
+type DREventStatus string
+
+const (
+	DREventDraft     DREventStatus = "draft"     // being planned, participants haven't been told
+	DREventScheduled DREventStatus = "scheduled" // dispatched to participants, waiting for its start
+	DREventActive    DREventStatus = "active"    // participants are curtailing
+	DREventCompleted DREventStatus = "completed"
+	DREventCancelled DREventStatus = "cancelled"
 )
 
+func (s DREventStatus) IsValid() bool {
+	switch s {
+	case DREventDraft, DREventScheduled, DREventActive, DREventCompleted, DREventCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// DREventAction moves an event from one status to another
+type DREventAction string
+
+const (
+	DREventDispatch DREventAction = "dispatch"
+	DREventActivate DREventAction = "activate"
+	DREventComplete DREventAction = "complete"
+	DREventCancel   DREventAction = "cancel"
+)
+
+// transition is the edge of the lifecycle an action takes and the timestamp field recording when it was taken
+type transition struct {
+	from  []DREventStatus
+	to    DREventStatus
+	stamp string
+	field func(e *DREvents) *bigquery.NullTimestamp
+}
+
+// drEventTransitions is the lifecycle: draft -> scheduled -> active -> completed, and cancelled from anything
+// that hasn't ended yet
+var drEventTransitions = map[DREventAction]transition{
+	DREventDispatch: {
+		from:  []DREventStatus{DREventDraft},
+		to:    DREventScheduled,
+		stamp: "scheduled_at",
+		field: func(e *DREvents) *bigquery.NullTimestamp { return &e.ScheduledAt },
+	},
+	DREventActivate: {
+		from:  []DREventStatus{DREventScheduled},
+		to:    DREventActive,
+		stamp: "activated_at",
+		field: func(e *DREvents) *bigquery.NullTimestamp { return &e.ActivatedAt },
+	},
+	DREventComplete: {
+		from:  []DREventStatus{DREventActive},
+		to:    DREventCompleted,
+		stamp: "completed_at",
+		field: func(e *DREvents) *bigquery.NullTimestamp { return &e.CompletedAt },
+	},
+	DREventCancel: {
+		from:  []DREventStatus{DREventDraft, DREventScheduled, DREventActive},
+		to:    DREventCancelled,
+		stamp: "cancelled_at",
+		field: func(e *DREvents) *bigquery.NullTimestamp { return &e.CancelledAt },
+	},
+}
+
 type DREvents struct {
-	ID          string    `json:"id" bigquery:"id"`
-	UtilityID   string    `json:"utility_id" bigquery:"utility_id"`
-	StartTime   time.Time `json:"start_time" bigquery:"start_time"`
-	EndTime     time.Time `json:"end_time" bigquery:"end_time"`
-	UtilityName string    `json:"utility_name" bigquery:"utility_name"`
+	ID          string                 `json:"id" bigquery:"id"`
+	UtilityID   string                 `json:"utility_id" bigquery:"utility_id"`
+	StartTime   time.Time              `json:"start_time" bigquery:"start_time"`
+	EndTime     time.Time              `json:"end_time" bigquery:"end_time"`
+	UtilityName string                 `json:"utility_name" bigquery:"utility_name"`
+	Status      DREventStatus          `json:"status" bigquery:"status"`
+	ScheduledAt bigquery.NullTimestamp `json:"scheduled_at" bigquery:"scheduled_at"` // when each transition was made, null until then
+	ActivatedAt bigquery.NullTimestamp `json:"activated_at" bigquery:"activated_at"`
+	CompletedAt bigquery.NullTimestamp `json:"completed_at" bigquery:"completed_at"`
+	CancelledAt bigquery.NullTimestamp `json:"cancelled_at" bigquery:"cancelled_at"`
+	Version     int64                  `json:"version" bigquery:"version"` // bumped by every update, the ETag of the event
+
+	// Participation is the project's status in the event on the project's event list
+	Participation ParticipantStatus `json:"participation,omitempty" bigquery:"participation"`
+	// Participants are the enrolled projects on the utility's event list
+	Participants []DREventParticipant `json:"participants,omitempty" bigquery:"-"`
+}
+
+// Validate checks a new event
+func (e *DREvents) Validate() error {
+	v := &validator{}
+	v.requiredString(e.UtilityID, "utility_id")
+	v.timeRange(e.StartTime, e.EndTime, "start_time", "end_time")
+	v.maxLength(e.UtilityName, "utility_name")
+	return v.err()
+}
+
+// MutableFields are the fields PUT and PATCH may change, the utility is fixed on create and the status
+// only changes through Transition
+func (e *DREvents) MutableFields() []string {
+	return []string{"start_time", "end_time"}
+}
+
+// ValidateUpdate checks an event after an update
+func (e *DREvents) ValidateUpdate() error {
+	v := &validator{}
+	v.timeRange(e.StartTime, e.EndTime, "start_time", "end_time")
+	return v.err()
+}
+
+// CheckEditable rejects changing the timing of an event that has started or ended, participants have already
+// acted on it
+func (e *DREvents) CheckEditable() error {
+	if e.Status == DREventDraft || e.Status == DREventScheduled {
+		return nil
+	}
+	return custom_error.New(http.StatusConflict, fmt.Sprintf("The timing of a %s demand response event can't change", e.Status), nil).
+		WithCode(custom_error.CodeInvalidTransition)
+}
+
+// Transition applies action to the event at the given time and returns the fields it changed. It fails with
+// a conflict when the event's status has no such transition.
+func (e *DREvents) Transition(action DREventAction, at time.Time) ([]string, error) {
+	t, ok := drEventTransitions[action]
+	if !ok {
+		return nil, custom_error.New(http.StatusBadRequest, fmt.Sprintf("Unknown demand response event action: %s", action), nil)
+	}
+	allowed := false
+	for _, from := range t.from {
+		allowed = allowed || e.Status == from
+	}
+	if !allowed {
+		return nil, custom_error.New(http.StatusConflict, fmt.Sprintf("Can't %s a %s demand response event", action, e.Status), nil).
+			WithCode(custom_error.CodeInvalidTransition)
+	}
+
+	*t.field(e) = bigquery.NullTimestamp{Timestamp: at.UTC(), Valid: true}
+	e.Status = t.to
+	return []string{"status", t.stamp}, nil
 }
@@ -11,3 +11,11 @@ type FaultNotification struct {
 	Average   float64   `firestore:"average" json:"average"`
 	Read      bool      `firestore:"read" json:"read"`
 }
+
+// Validate checks a notification from the validator
+func (n *FaultNotification) Validate() error {
+	v := &validator{}
+	v.requiredString(n.ProjectID, "project_id")
+	v.timeRange(n.StartTime, n.EndTime, "start_time", "end_time")
+	return v.err()
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of a POST sent with an Idempotency-Key header, replayed to retries of it
+type IdempotencyRecord struct {
+	Scope       string    `json:"scope" bigquery:"scope"` // the caller, keys of different callers never collide
+	Key         string    `json:"key" bigquery:"idempotency_key"`
+	RequestHash string    `json:"request_hash" bigquery:"request_hash"` // SHA-256 of the method, path and body
+	Status      int       `json:"status" bigquery:"status"`             // 0 while the first request is still running
+	ContentType string    `json:"content_type" bigquery:"content_type"`
+	Location    string    `json:"location" bigquery:"location"`
+	Body        string    `json:"body" bigquery:"body"`
+	ResourceID  string    `json:"resource_id" bigquery:"resource_id"` // id of the created resource, from Location
+	CreatedAt   time.Time `json:"created_at" bigquery:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" bigquery:"expires_at"`
+}
+
+// Completed reports whether the request that reserved the key has finished
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
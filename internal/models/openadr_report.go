@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// OpenADRReport is a report an OpenADR 3.0 client sent about an event, its intervals also become project averages
+type OpenADRReport struct {
+	ID         string `json:"id" bigquery:"id"`
+	UtilityID  string `json:"utility_id" bigquery:"utility_id"` // the event's utility, whose users may read the report
+	ProgramID  string `json:"program_id" bigquery:"program_id"`
+	EventID    string `json:"event_id" bigquery:"event_id"`
+	ClientName string `json:"client_name" bigquery:"client_name"`
+	ReportName string `json:"report_name" bigquery:"report_name"`
+	CreatedBy  string `json:"created_by" bigquery:"created_by"` // user id of the caller, only they may change the report
+	// Body is the JSON of the report's payload descriptors and resources, the rest of the report is in columns
+	Body       string    `json:"body" bigquery:"body"`
+	CreatedAt  time.Time `json:"created_at" bigquery:"created_at"`
+	ModifiedAt time.Time `json:"modified_at" bigquery:"modified_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// OpenADRSubscription is an OpenADR 3.0 client's request to be called back when objects of a program change
+type OpenADRSubscription struct {
+	ID         string `json:"id" bigquery:"id"`
+	ProgramID  string `json:"program_id" bigquery:"program_id"`
+	ClientName string `json:"client_name" bigquery:"client_name"`
+	// CreatedBy is the user, or service:<key id> of the API key, that subscribed. Notifications only carry what
+	// they may read when the notification is sent.
+	CreatedBy string `json:"created_by" bigquery:"created_by"`
+	// Body is the JSON of the subscription's object operations and targets
+	Body       string    `json:"body" bigquery:"body"`
+	CreatedAt  time.Time `json:"created_at" bigquery:"created_at"`
+	ModifiedAt time.Time `json:"modified_at" bigquery:"modified_at"`
+}
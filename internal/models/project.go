@@ -6,4 +6,26 @@ type Project struct {
 	UtilityID string `json:"utility_id" bigquery:"utility_id"` // Unique identifier for the utility
 	UserID    string `json:"user_id" bigquery:"user_id"`
 	Location  string `json:"location" bigquery:"location"`
+	Version   int64  `json:"version" bigquery:"version"` // bumped by every update, the ETag of the project
+}
+
+// Validate checks a new project, the user is set once a homeowner claims it
+func (p *Project) Validate() error {
+	v := &validator{}
+	v.requiredString(p.UtilityID, "utility_id")
+	v.requiredString(p.Location, "location")
+	v.maxLength(p.Location, "location")
+	return v.err()
+}
+
+// MutableFields are the fields PUT and PATCH may change, a project can't move to another utility
+func (p *Project) MutableFields() []string {
+	return []string{"user_id", "location"}
+}
+
+// ValidateUpdate checks a project after an update, the location may be cleared
+func (p *Project) ValidateUpdate() error {
+	v := &validator{}
+	v.maxLength(p.Location, "location")
+	return v.err()
 }
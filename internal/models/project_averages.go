@@ -10,4 +10,14 @@ type ProjectAverage struct {
 	Baseline          float64   `json:"baseline" bigquery:"baseline"`
 	ContractThreshold float64   `json:"contract_threshold" bigquery:"contract_threshold"`
 	AverageOutput     float64   `json:"average_output" bigquery:"average_output"`
-}
\ No newline at end of file
+}
+
+// Validate checks a new average
+func (a *ProjectAverage) Validate() error {
+	v := &validator{}
+	v.requiredString(a.ProjectID, "project_id")
+	v.timeRange(a.StartTime, a.EndTime, "start_time", "end_time")
+	v.positive(a.Baseline, "baseline")
+	v.positive(a.ContractThreshold, "contract_threshold")
+	return v.err()
+}
@@ -3,4 +3,18 @@ package models
 type Utility struct {
 	ID          string `json:"id" bigquery:"id"`
 	DisplayName string `json:"display_name" bigquery:"display_name"`
+	Version     int64  `json:"version" bigquery:"version"` // bumped by every update, the ETag of the utility
+}
+
+// MutableFields are the fields PUT and PATCH may change
+func (u *Utility) MutableFields() []string {
+	return []string{"display_name"}
+}
+
+// Validate checks a new utility or its update, the display name is the only field that can be set
+func (u *Utility) Validate() error {
+	v := &validator{}
+	v.requiredString(u.DisplayName, "display_name")
+	v.maxLength(u.DisplayName, "display_name")
+	return v.err()
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/grid-stream-org/api/internal/custom_error"
+)
+
+// Rules reported in a field error's code
+const (
+	RuleRequired = "required"
+	RuleMin      = "min"
+	RuleMax      = "max"
+	RuleOneOf    = "oneof"
+	RuleAfter    = "after"
+	RuleRange    = "range"
+)
+
+// Bounds for contract dates, anything outside is a typo rather than a real contract
+var (
+	earliestContractDate = civil.Date{Year: 2000, Month: time.January, Day: 1}
+	latestContractDate   = civil.Date{Year: 2100, Month: time.December, Day: 31}
+)
+
+// maxNameLength bounds free text names and locations
+const maxNameLength = 256
+
+// validator collects every violation of a payload so the client can fix them in one go
+type validator struct {
+	fields []custom_error.FieldError
+}
+
+func (v *validator) add(field, rule, message string) {
+	v.fields = append(v.fields, custom_error.FieldError{Field: field, Code: rule, Message: message})
+}
+
+func (v *validator) check(ok bool, field, rule, message string) {
+	if !ok {
+		v.add(field, rule, message)
+	}
+}
+
+func (v *validator) required(ok bool, field string) {
+	v.check(ok, field, RuleRequired, "is required")
+}
+
+func (v *validator) requiredString(s string, field string) {
+	v.required(strings.TrimSpace(s) != "", field)
+}
+
+func (v *validator) maxLength(s string, field string) {
+	v.check(len(s) <= maxNameLength, field, RuleMax, fmt.Sprintf("must be at most %d characters", maxNameLength))
+}
+
+func (v *validator) positive(f float64, field string) {
+	v.check(f > 0, field, RuleMin, "must be greater than 0")
+}
+
+func (v *validator) nonNegative(f float64, field string) {
+	v.check(f >= 0, field, RuleMin, "must not be negative")
+}
+
+// timeRange checks both ends of a range are set and end is after start
+func (v *validator) timeRange(start, end time.Time, startField, endField string) {
+	v.required(!start.IsZero(), startField)
+	v.required(!end.IsZero(), endField)
+	if !start.IsZero() && !end.IsZero() {
+		v.check(end.After(start), endField, RuleAfter, "must be after "+startField)
+	}
+}
+
+// contractDate checks a set date is within the contract bounds
+func (v *validator) contractDate(d bigquery.NullDate, field string) {
+	if d.Valid {
+		v.check(!d.Date.Before(earliestContractDate) && !d.Date.After(latestContractDate), field, RuleRange,
+			fmt.Sprintf("must be between %s and %s", earliestContractDate, latestContractDate))
+	}
+}
+
+// err is nil when nothing was violated, otherwise a validation error listing every violation
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return custom_error.Validation(v.fields...)
+}
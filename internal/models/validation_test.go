@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/grid-stream-org/api/internal/custom_error"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// violations returns field -> rule of a validation error
+func violations(t *testing.T, err error) map[string]string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var invalid *custom_error.CustomError
+	require.True(t, errors.As(err, &invalid))
+	assert.Equal(t, custom_error.CodeValidationFailed, invalid.ErrorCode)
+	out := map[string]string{}
+	for _, f := range invalid.Fields {
+		out[f.Field] = f.Code
+	}
+	return out
+}
+
+func date(year int, month time.Month, day int) bigquery.NullDate {
+	return bigquery.NullDate{Date: civil.Date{Year: year, Month: month, Day: day}, Valid: true}
+}
+
+func TestContractValidate(t *testing.T) {
+	valid := Contract{ContractThreshold: 10, ProjectID: "p-1", Status: Active, StartDate: date(2025, 1, 1), EndDate: date(2026, 1, 1)}
+	assert.NoError(t, valid.Validate())
+
+	tests := []struct {
+		name     string
+		contract Contract
+		want     map[string]string
+	}{
+		{"empty", Contract{}, map[string]string{
+			"contract_threshold": RuleMin, "project_id": RuleRequired, "status": RuleRequired,
+			"start_date": RuleRequired, "end_date": RuleRequired,
+		}},
+		{"bad status", Contract{ContractThreshold: 10, ProjectID: "p-1", Status: "done", StartDate: date(2025, 1, 1), EndDate: date(2026, 1, 1)},
+			map[string]string{"status": RuleOneOf}},
+		{"end before start", Contract{ContractThreshold: 10, ProjectID: "p-1", Status: Pending, StartDate: date(2026, 1, 1), EndDate: date(2025, 1, 1)},
+			map[string]string{"end_date": RuleAfter}},
+		{"out of bounds", Contract{ContractThreshold: 10, ProjectID: "p-1", Status: Pending, StartDate: date(1925, 1, 1), EndDate: date(2026, 1, 1)},
+			map[string]string{"start_date": RuleRange}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, violations(t, tc.contract.Validate()))
+		})
+	}
+
+	// after an update the threshold may be zero and the dates cleared, but the status is still required
+	assert.NoError(t, (&Contract{Status: Inactive}).ValidateUpdate())
+	assert.Equal(t, map[string]string{"contract_threshold": RuleMin, "status": RuleRequired}, violations(t, (&Contract{ContractThreshold: -1}).ValidateUpdate()))
+}
+
+func TestDREventValidate(t *testing.T) {
+	start := time.Date(2025, 7, 1, 17, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, (&DREvents{UtilityID: "u-1", StartTime: start, EndTime: start.Add(time.Hour)}).Validate())
+	assert.Equal(t, map[string]string{"utility_id": RuleRequired, "start_time": RuleRequired, "end_time": RuleRequired},
+		violations(t, (&DREvents{}).Validate()))
+	assert.Equal(t, map[string]string{"end_time": RuleAfter},
+		violations(t, (&DREvents{UtilityID: "u-1", StartTime: start, EndTime: start}).Validate()))
+}
+
+func TestDERMetadataValidate(t *testing.T) {
+	assert.NoError(t, (&DERMetadata{ID: "d-1", ProjectID: "p-1", Type: Battery, NameplateCapacity: 5}).Validate())
+	assert.Equal(t, map[string]string{"type": RuleOneOf, "power_capacity": RuleMin},
+		violations(t, (&DERMetadata{ID: "d-1", ProjectID: "p-1", Type: "wind", NameplateCapacity: 5, PowerCapacity: -1}).Validate()))
+}
+
+func TestDREventTransitions(t *testing.T) {
+	at := time.Date(2025, 7, 1, 17, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from    DREventStatus
+		action  DREventAction
+		to      DREventStatus
+		allowed bool
+	}{
+		{DREventDraft, DREventDispatch, DREventScheduled, true},
+		{DREventScheduled, DREventActivate, DREventActive, true},
+		{DREventActive, DREventComplete, DREventCompleted, true},
+		{DREventDraft, DREventCancel, DREventCancelled, true},
+		{DREventScheduled, DREventCancel, DREventCancelled, true},
+		{DREventActive, DREventCancel, DREventCancelled, true},
+		{DREventDraft, DREventActivate, "", false},
+		{DREventScheduled, DREventComplete, "", false},
+		{DREventCompleted, DREventCancel, "", false},
+		{DREventCancelled, DREventDispatch, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" "+string(tt.action), func(t *testing.T) {
+			event := DREvents{Status: tt.from}
+			fields, err := event.Transition(tt.action, at)
+			if !tt.allowed {
+				var conflict *custom_error.CustomError
+				require.True(t, errors.As(err, &conflict))
+				assert.Equal(t, custom_error.CodeInvalidTransition, conflict.ErrorCode)
+				assert.Equal(t, tt.from, event.Status)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.to, event.Status)
+			assert.Equal(t, "status", fields[0])
+			stamps := map[string]bigquery.NullTimestamp{
+				"scheduled_at": event.ScheduledAt, "activated_at": event.ActivatedAt,
+				"completed_at": event.CompletedAt, "cancelled_at": event.CancelledAt,
+			}
+			assert.Equal(t, bigquery.NullTimestamp{Timestamp: at, Valid: true}, stamps[fields[1]])
+		})
+	}
+}
+
+func TestDREventCheckEditable(t *testing.T) {
+	for status, editable := range map[DREventStatus]bool{
+		DREventDraft: true, DREventScheduled: true, DREventActive: false, DREventCompleted: false, DREventCancelled: false,
+	} {
+		event := DREvents{Status: status}
+		assert.Equal(t, editable, event.CheckEditable() == nil, status)
+	}
+}
+
+func TestParticipantTargetValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		target ParticipantTarget
+		want   map[string]string
+	}{
+		{"listed", ParticipantTarget{ProjectIDs: []string{"p-1"}}, nil},
+		{"filtered", ParticipantTarget{ActiveContract: true, DERType: Battery, MinCapacity: 5}, nil},
+		{"nothing", ParticipantTarget{}, map[string]string{"project_ids": RuleRequired}},
+		{"both", ParticipantTarget{ProjectIDs: []string{"p-1"}, ActiveContract: true}, map[string]string{"project_ids": RuleOneOf}},
+		{"bad filters", ParticipantTarget{DERType: "wind", MinCapacity: -1}, map[string]string{"der_type": RuleOneOf, "min_capacity": RuleMin}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, violations(t, tt.target.Validate()))
+		})
+	}
+}
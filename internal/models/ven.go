@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// VEN is an OpenADR Virtual End Node, a gateway registered to receive the DR events of a project
+type VEN struct {
+	ID             string    `json:"id" bigquery:"id"` // the venID the VTN assigned
+	RegistrationID string    `json:"registration_id" bigquery:"registration_id"`
+	ProjectID      string    `json:"project_id" bigquery:"project_id"`
+	Name           string    `json:"name" bigquery:"name"` // venName the gateway registered with
+	RegisteredAt   time.Time `json:"registered_at" bigquery:"registered_at"`
+	// EventsDigest identifies the event set last distributed to the VEN, polls only distribute again once it changes
+	EventsDigest string `json:"events_digest" bigquery:"events_digest"`
+}
+
+// VENOpt is a VEN's answer to an event, the optType of its oadrCreatedEvent
+type VENOpt string
+
+const (
+	VENOptIn  VENOpt = "optIn"
+	VENOptOut VENOpt = "optOut"
+)
+
+func (o VENOpt) IsValid() bool {
+	return o == VENOptIn || o == VENOptOut
+}